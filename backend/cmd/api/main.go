@@ -3,8 +3,13 @@ package main
 import (
 	"errors"
 	"github.com/AlexShmak/order-service/cmd/worker"
+	"github.com/AlexShmak/order-service/internal/audit"
 	"github.com/AlexShmak/order-service/internal/auth"
+	"github.com/AlexShmak/order-service/internal/encryption"
 	"github.com/AlexShmak/order-service/internal/kafka"
+	"github.com/AlexShmak/order-service/internal/mailer"
+	"github.com/AlexShmak/order-service/internal/oidc"
+	"github.com/AlexShmak/order-service/internal/ratelimit"
 	"github.com/AlexShmak/order-service/internal/storage/cache"
 	"log/slog"
 	"os"
@@ -52,17 +57,29 @@ func main() {
 		os.Exit(1)
 	}
 	slogLogger.Info("Migrations applied successfully.")
-	postgresStorage := storage.NewPostgresStorage(regularDB)
+	piiKeyring, emailHasher, err := encryption.NewPIIKeys(cfg.PII)
+	if err != nil {
+		slogLogger.Error("failed to load PII keys", "error", err)
+		os.Exit(1)
+	}
+	postgresStorage := storage.NewPostgresStorage(regularDB, piiKeyring, emailHasher)
 
 	// redisCache setup
 	redisClient := cache.NewRedisClient(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB)
-	redisCache := cache.NewRedisStorage(redisClient)
+	redisCache := cache.NewRedisStorage(redisClient, piiKeyring)
+
+	// event codecs, shared by the worker and the producer
+	codecs, err := kafka.LoadCodecs(cfg)
+	if err != nil {
+		slogLogger.Error("failed to set up kafka codecs", "error", err)
+		os.Exit(1)
+	}
 
 	// start worker
-	go worker.StartWorker(cfg, postgresStorage, slogLogger)
+	go worker.StartWorker(cfg, postgresStorage, codecs, slogLogger)
 
 	// setup kafka producer
-	kafkaProducer, err := kafka.NewProducer(cfg, slogLogger)
+	kafkaProducer, err := kafka.NewProducer(cfg, codecs.Encoder(), slogLogger)
 	if err != nil {
 		slogLogger.Error("failed to create kafka producer", "error", err)
 		os.Exit(1)
@@ -74,8 +91,59 @@ func main() {
 	}()
 
 	// setup router
-	jwtService := auth.NewJWTService(cfg.JWT.AccessSecret, cfg.JWT.RefreshSecret)
-	r := router.NewRouter(postgresStorage, slogLogger, jwtService, cfg, kafkaProducer, redisCache)
+	accessKeys := auth.NewHMACKeySet(cfg.JWT.AccessSecret)
+	if cfg.JWT.KeysDir != "" {
+		accessKeys, err = auth.LoadKeySet(cfg.JWT.KeysDir, cfg.JWT.SigningKeyID)
+		if err != nil {
+			slogLogger.Error("failed to load JWT signing keys", "error", err)
+			os.Exit(1)
+		}
+	}
+	jwtService := auth.NewJWTService(accessKeys, cfg.JWT.RefreshSecret, cfg.JWT.AccessTokenTTL, cfg.JWT.RefreshTokenTTL)
+	limiter := ratelimit.NewRedisLimiter(redisClient)
+	var routeLimiter ratelimit.Limiter = ratelimit.NewRedisTokenBucket(redisClient)
+	if cfg.RateLimit.Backend == "memory" {
+		routeLimiter = ratelimit.NewMemoryTokenBucket()
+	}
+	lockout := ratelimit.NewLockout(redisClient, ratelimit.LockoutPolicy{
+		Threshold:    cfg.BruteForce.LockoutThreshold,
+		Window:       cfg.BruteForce.LockoutWindow,
+		BaseDuration: cfg.BruteForce.LockoutBase,
+		MaxDuration:  cfg.BruteForce.LockoutMaxDuration,
+	})
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		slogLogger.Error("failed to create mailer", "error", err)
+		os.Exit(1)
+	}
+	passwordPolicy, err := auth.NewPasswordPolicy(cfg.Password.MinLength, cfg.Password.MaxLength, cfg.Password.BreachedListPath)
+	if err != nil {
+		slogLogger.Error("failed to load password policy", "error", err)
+		os.Exit(1)
+	}
+	totpKey, err := encryption.ParseKey(cfg.TOTP.EncryptionKey)
+	if err != nil {
+		slogLogger.Error("invalid TOTP encryption key", "error", err)
+		os.Exit(1)
+	}
+	totpCipher, err := encryption.NewCipher(totpKey)
+	if err != nil {
+		slogLogger.Error("failed to create TOTP cipher", "error", err)
+		os.Exit(1)
+	}
+	totp := auth.NewTOTPService(totpCipher, cfg.TOTP.Issuer)
+	auditRecorder := audit.NewRecorder(postgresStorage.AuditEvents, slogLogger, emailHasher)
+	var oidcClient *oidc.Client
+	if cfg.OIDC.Enabled {
+		oidcClient = oidc.NewClient(oidc.Config{
+			IssuerURL:    cfg.OIDC.IssuerURL,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: cfg.OIDC.ClientSecret,
+			RedirectURL:  cfg.OIDC.RedirectURL,
+			Scopes:       cfg.OIDC.Scopes,
+		})
+	}
+	r := router.NewRouter(postgresStorage, slogLogger, jwtService, cfg, kafkaProducer, redisCache, limiter, routeLimiter, lockout, mail, passwordPolicy, totp, oidcClient, auditRecorder)
 	if err := r.Run(cfg.Server.Host + ":" + cfg.Server.Port); err != nil {
 		slogLogger.Error("Error starting r", "error", err)
 		os.Exit(1)
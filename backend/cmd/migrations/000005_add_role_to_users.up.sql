@@ -0,0 +1,2 @@
+ALTER TABLE orders_service.users
+    ADD COLUMN IF NOT EXISTS role VARCHAR(32) NOT NULL DEFAULT 'customer';
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS orders_service.api_keys
+(
+    id           BIGSERIAL PRIMARY KEY,
+    name         VARCHAR(255) NOT NULL,
+    owner_id     BIGINT       NOT NULL,
+    prefix       VARCHAR(16)  NOT NULL UNIQUE,
+    key_hash     BYTEA        NOT NULL UNIQUE,
+    scopes       TEXT[]       NOT NULL DEFAULT '{}',
+    rate_limit   INT          NOT NULL DEFAULT 60,
+    expires_at   TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (owner_id) REFERENCES orders_service.users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_owner_id ON orders_service.api_keys (owner_id);
@@ -0,0 +1,2 @@
+ALTER TABLE orders_service.users
+    DROP COLUMN IF EXISTS email_verified_at;
@@ -0,0 +1,7 @@
+ALTER TABLE orders_service.users
+    ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+
+-- accounts created before verification existed are trusted as verified
+UPDATE orders_service.users
+SET email_verified_at = created_at
+WHERE email_verified_at IS NULL;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS orders_service.action_tokens;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS orders_service.action_tokens
+(
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT      NOT NULL,
+    purpose    VARCHAR(32) NOT NULL,
+    token_hash BYTEA       NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (user_id) REFERENCES orders_service.users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_action_tokens_user_id ON orders_service.action_tokens (user_id);
@@ -0,0 +1,5 @@
+-- The emails stay lower-cased and the parked accounts keep their placeholder:
+-- a parked address equals the lower-cased one of the older account, so the
+-- unique key on email would reject it. users_email_conflicts is kept for the
+-- same reason, the up migration picks it up again.
+DROP INDEX IF EXISTS orders_service.idx_users_email_lower;
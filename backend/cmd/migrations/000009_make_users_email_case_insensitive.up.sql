@@ -0,0 +1,40 @@
+-- Accounts whose emails only differ in case cannot be told apart by a
+-- case-insensitive login. The oldest one keeps the address, the others are
+-- parked in users_email_conflicts for an operator to resolve and get a
+-- placeholder address until then.
+CREATE TABLE IF NOT EXISTS orders_service.users_email_conflicts
+(
+    user_id     BIGINT       PRIMARY KEY REFERENCES orders_service.users (id) ON DELETE CASCADE,
+    email       VARCHAR(255) NOT NULL,
+    detected_at TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO orders_service.users_email_conflicts (user_id, email)
+SELECT id, email
+FROM (SELECT id, email, row_number() OVER (PARTITION BY lower(email) ORDER BY created_at, id) AS rank
+      FROM orders_service.users) ranked
+WHERE rank > 1
+ON CONFLICT (user_id) DO NOTHING;
+
+UPDATE orders_service.users u
+SET email = 'email-conflict-' || u.id || '@invalid'
+FROM orders_service.users_email_conflicts c
+WHERE c.user_id = u.id
+  AND u.email = c.email;
+
+-- emails are stored lower-cased from now on, see storage.NormalizeEmail
+UPDATE orders_service.users SET email = lower(email) WHERE email <> lower(email);
+
+DO
+$$
+    DECLARE
+        conflicts BIGINT;
+    BEGIN
+        SELECT count(*) INTO conflicts FROM orders_service.users_email_conflicts;
+        IF conflicts > 0 THEN
+            RAISE WARNING '% accounts share their email with an older one, see orders_service.users_email_conflicts', conflicts;
+        END IF;
+    END
+$$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON orders_service.users (lower(email));
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS orders_service.totp_recovery_codes;
+DROP TABLE IF EXISTS orders_service.totp_credentials;
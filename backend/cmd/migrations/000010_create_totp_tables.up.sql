@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS orders_service.totp_credentials
+(
+    user_id          BIGINT PRIMARY KEY,
+    secret_encrypted BYTEA       NOT NULL,
+    last_used_step   BIGINT      NOT NULL DEFAULT 0,
+    confirmed_at     TIMESTAMPTZ,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (user_id) REFERENCES orders_service.users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS orders_service.totp_recovery_codes
+(
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT      NOT NULL,
+    code_hash  BYTEA       NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (user_id) REFERENCES orders_service.users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_totp_recovery_codes_user_id ON orders_service.totp_recovery_codes (user_id);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS orders_service.user_identities;
+
+-- an empty hash never matches, so these users simply cannot log in with a password
+UPDATE orders_service.users SET password = ''::BYTEA WHERE password IS NULL;
+
+ALTER TABLE orders_service.users
+    ALTER COLUMN password SET NOT NULL;
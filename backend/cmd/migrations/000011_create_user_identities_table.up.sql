@@ -0,0 +1,16 @@
+-- staff signing in through single sign-on have no local password
+ALTER TABLE orders_service.users
+    ALTER COLUMN password DROP NOT NULL;
+
+CREATE TABLE IF NOT EXISTS orders_service.user_identities
+(
+    issuer     TEXT        NOT NULL,
+    subject    TEXT        NOT NULL,
+    user_id    BIGINT      NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (issuer, subject),
+    FOREIGN KEY (user_id) REFERENCES orders_service.users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON orders_service.user_identities (user_id);
@@ -0,0 +1,19 @@
+-- actor_id has no foreign key: the trail must outlive deleted users
+CREATE TABLE IF NOT EXISTS orders_service.audit_events
+(
+    id          BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    actor_id    BIGINT,
+    action      VARCHAR(64) NOT NULL,
+    target_type VARCHAR(32) NOT NULL DEFAULT '',
+    target_id   TEXT        NOT NULL DEFAULT '',
+    ip          TEXT        NOT NULL DEFAULT '',
+    user_agent  TEXT        NOT NULL DEFAULT '',
+    request_id  TEXT        NOT NULL DEFAULT '',
+    outcome     VARCHAR(16) NOT NULL,
+    details     JSONB       NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_occurred_at ON orders_service.audit_events (occurred_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON orders_service.audit_events (actor_id, occurred_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON orders_service.audit_events (action, occurred_at);
@@ -0,0 +1,24 @@
+-- Encrypted values cannot be decrypted in SQL, so once a row is encrypted this
+-- migration is irreversible: it refuses to run instead of dropping the PII.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM orders_service.deliveries WHERE data_key IS NOT NULL) THEN
+        RAISE EXCEPTION 'deliveries hold encrypted PII, which cannot be restored in SQL';
+    END IF;
+END $$;
+
+DROP INDEX IF EXISTS orders_service.idx_deliveries_key_id;
+DROP INDEX IF EXISTS orders_service.idx_deliveries_email_hash;
+
+ALTER TABLE orders_service.deliveries
+    DROP COLUMN IF EXISTS email_hash,
+    DROP COLUMN IF EXISTS email_encrypted,
+    DROP COLUMN IF EXISTS address_encrypted,
+    DROP COLUMN IF EXISTS phone_encrypted,
+    DROP COLUMN IF EXISTS name_encrypted,
+    DROP COLUMN IF EXISTS data_key,
+    DROP COLUMN IF EXISTS key_id,
+    ALTER COLUMN name SET NOT NULL,
+    ALTER COLUMN phone SET NOT NULL,
+    ALTER COLUMN address SET NOT NULL,
+    ALTER COLUMN email SET NOT NULL;
@@ -0,0 +1,18 @@
+-- name, phone, address and email are encrypted with a data key per row, which
+-- is stored wrapped by the key key_id of the keyring. Rows written before this
+-- migration keep their plaintext until the PII key rotation encrypts them.
+ALTER TABLE orders_service.deliveries
+    ADD COLUMN IF NOT EXISTS key_id            TEXT,
+    ADD COLUMN IF NOT EXISTS data_key          BYTEA,
+    ADD COLUMN IF NOT EXISTS name_encrypted    BYTEA,
+    ADD COLUMN IF NOT EXISTS phone_encrypted   BYTEA,
+    ADD COLUMN IF NOT EXISTS address_encrypted BYTEA,
+    ADD COLUMN IF NOT EXISTS email_encrypted   BYTEA,
+    ADD COLUMN IF NOT EXISTS email_hash        BYTEA,
+    ALTER COLUMN name DROP NOT NULL,
+    ALTER COLUMN phone DROP NOT NULL,
+    ALTER COLUMN address DROP NOT NULL,
+    ALTER COLUMN email DROP NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_deliveries_email_hash ON orders_service.deliveries (email_hash);
+CREATE INDEX IF NOT EXISTS idx_deliveries_key_id ON orders_service.deliveries (key_id);
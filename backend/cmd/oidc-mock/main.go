@@ -0,0 +1,37 @@
+// Command oidc-mock runs a throwaway OpenID Provider for trying out staff
+// single sign-on locally. Every visitor can log in as any of its users.
+package main
+
+import (
+	"flag"
+	"github.com/AlexShmak/order-service/internal/oidc"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:9000", "address to listen on")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer URL, must match OIDC_ISSUER_URL")
+	clientID := flag.String("client-id", "orders-service", "client ID, must match OIDC_CLIENT_ID")
+	clientSecret := flag.String("client-secret", "mock-secret", "client secret, must match OIDC_CLIENT_SECRET")
+	flag.Parse()
+
+	mockIssuer, err := oidc.NewMockIssuer(*issuer, *clientID, *clientSecret, oidc.DefaultMockUsers)
+	if err != nil {
+		slog.Error("failed to create mock issuer", "error", err)
+		os.Exit(1)
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           mockIssuer.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	slog.Info("mock OIDC issuer listening", "addr", *addr, "issuer", *issuer)
+	if err := server.ListenAndServe(); err != nil {
+		slog.Error("mock issuer stopped", "error", err)
+		os.Exit(1)
+	}
+}
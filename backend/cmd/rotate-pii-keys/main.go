@@ -0,0 +1,55 @@
+// Command rotate-pii-keys moves all delivery PII to the active key of the PII
+// keyring and encrypts rows that are still stored in plaintext. Once it has
+// finished, the keys other than PII_ACTIVE_KEY_ID can be removed.
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/AlexShmak/order-service/internal/config"
+	"github.com/AlexShmak/order-service/internal/db"
+	"github.com/AlexShmak/order-service/internal/encryption"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"log/slog"
+	"os"
+)
+
+func main() {
+	batchSize := flag.Int("batch-size", 500, "deliveries to rotate per transaction")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		os.Exit(1)
+	}
+
+	piiKeyring, emailHasher, err := encryption.NewPIIKeys(cfg.PII)
+	if err != nil {
+		slog.Error("failed to load PII keys", "error", err)
+		os.Exit(1)
+	}
+
+	regularDB, err := db.Connect(cfg)
+	if err != nil {
+		slog.Error("could not connect to database", "error", err)
+		os.Exit(1)
+	}
+	defer regularDB.Close()
+	postgresStorage := storage.NewPostgresStorage(regularDB, piiKeyring, emailHasher)
+
+	total := 0
+	for {
+		n, err := postgresStorage.Orders.RotateDeliveryKeys(context.Background(), *batchSize)
+		if err != nil {
+			slog.Error("failed to rotate delivery keys", "error", err, "rotated", total)
+			os.Exit(1)
+		}
+		if n == 0 {
+			break
+		}
+		total += n
+		slog.Info("rotated deliveries", "rotated", total)
+	}
+	slog.Info("all deliveries use the active key", "key_id", piiKeyring.ActiveKeyID(), "rotated", total)
+}
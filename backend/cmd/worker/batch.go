@@ -0,0 +1,141 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/events"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/IBM/sarama"
+)
+
+// runLane processes the messages of a lane one by one, or in batches when
+// c.batchSize is above 1. A message is done for the tracker only once its
+// batch is committed. A message that fails fails the tracker, the lane then
+// only drains what is left.
+func (c *Consumer) runLane(lane <-chan *sarama.ConsumerMessage, tracker *offsetTracker) {
+	if c.batchSize <= 1 {
+		for message := range lane {
+			if tracker.stopped() {
+				continue
+			}
+			produced, err := c.process(message)
+			if err != nil {
+				tracker.fail(err)
+				continue
+			}
+			tracker.done(message, produced)
+		}
+		return
+	}
+
+	batch := make([]*sarama.ConsumerMessage, 0, c.batchSize)
+	flush := func() {
+		if !tracker.stopped() {
+			if produced, err := c.processBatch(batch); err != nil {
+				tracker.fail(err)
+			} else {
+				tracker.doneBatch(batch, produced)
+			}
+		}
+		batch = batch[:0]
+	}
+
+	// the window starts with the first message of a batch
+	window := time.NewTimer(c.batchWindow)
+	window.Stop()
+	for {
+		select {
+		case message, ok := <-lane:
+			if !ok {
+				if len(batch) > 0 {
+					flush()
+				}
+				return
+			}
+			batch = append(batch, message)
+			if len(batch) == 1 {
+				window.Reset(c.batchWindow)
+			}
+			if len(batch) >= c.batchSize {
+				window.Stop()
+				flush()
+			}
+		case <-window.C:
+			flush()
+		}
+	}
+}
+
+// processBatch stores the orders of messages in one transaction. If that
+// fails, they are stored one by one, so that a bad order only fails itself.
+// It returns the downstream messages of every message, or an error if the
+// batch has to be processed again.
+func (c *Consumer) processBatch(messages []*sarama.ConsumerMessage) ([][]*sarama.ProducerMessage, error) {
+	ctx := context.Background()
+	produced := make([][]*sarama.ProducerMessage, len(messages))
+	var orders []*storage.Order
+	var loggers []*slog.Logger
+	// indexes holds the message of every order
+	var indexes []int
+
+	// storeOneByOne stops at the first order that fails on something other
+	// than the order itself
+	storeOneByOne := func() error {
+		for i, order := range orders {
+			ok, err := c.storeOrder(ctx, order, loggers[i])
+			if err != nil {
+				return err
+			}
+			if ok {
+				produced[indexes[i]] = c.stored(order, loggers[i])
+			}
+		}
+		return nil
+	}
+	flush := func() error {
+		defer func() {
+			orders, loggers, indexes = orders[:0], loggers[:0], indexes[:0]
+		}()
+		if len(orders) <= 1 {
+			return storeOneByOne()
+		}
+		if err := c.Storage.Orders.CreateBatch(ctx, orders); err != nil {
+			c.logger.Warn("Failed to create orders batch, creating them one by one", "orders", len(orders), "error", err)
+			return storeOneByOne()
+		}
+		for i, logger := range loggers {
+			logger.Info("Order created successfully")
+			produced[indexes[i]] = c.stored(orders[i], logger)
+		}
+		return nil
+	}
+
+	for i, message := range messages {
+		event, logger := c.decode(message)
+		if event == nil {
+			continue
+		}
+		if event.Type != events.TypeOrderCreated {
+			// other events of the orders must not overtake them
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			var err error
+			if produced[i], err = c.handle(ctx, event, logger); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if order, logger := orderOf(event, logger); order != nil {
+			orders = append(orders, order)
+			loggers = append(loggers, logger)
+			indexes = append(indexes, i)
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return produced, nil
+}
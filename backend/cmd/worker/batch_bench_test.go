@@ -0,0 +1,65 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/AlexShmak/order-service/internal/storage/memory"
+	"github.com/IBM/sarama"
+	"github.com/google/uuid"
+)
+
+// roundTrip is what a transaction costs against a nearby Postgres, the
+// in-memory storage alone would hide what batching saves.
+const roundTrip = 500 * time.Microsecond
+
+type slowOrders struct {
+	storage.Orders
+}
+
+func (o slowOrders) Create(ctx context.Context, order *storage.Order) error {
+	time.Sleep(roundTrip)
+	return o.Orders.Create(ctx, order)
+}
+
+func (o slowOrders) CreateBatch(ctx context.Context, orders []*storage.Order) error {
+	time.Sleep(roundTrip)
+	return o.Orders.CreateBatch(ctx, orders)
+}
+
+// BenchmarkConsumeClaim measures the throughput of one partition with and
+// without batching. For the cost of real inserts see BenchmarkOrdersCreateBatch
+// in internal/storage.
+func BenchmarkConsumeClaim(b *testing.B) {
+	for _, concurrency := range []int{1, 8} {
+		for _, batchSize := range []int{1, 10, 100} {
+			b.Run(fmt.Sprintf("concurrency=%d/batch=%d", concurrency, batchSize), func(b *testing.B) {
+				messages := make([]*sarama.ConsumerMessage, b.N)
+				for i := range messages {
+					messages[i] = orderMessage(b, int64(i), &storage.Order{OrderUID: uuid.NewString()})
+				}
+				pgStorage := memory.NewStorage()
+				pgStorage.Orders = slowOrders{pgStorage.Orders}
+				consumer := newPoolConsumer(pgStorage.Orders, concurrency)
+				consumer.batchSize, consumer.batchWindow = batchSize, 5*time.Millisecond
+
+				claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, b.N)}
+				for _, message := range messages {
+					claim.messages <- message
+				}
+				close(claim.messages)
+
+				b.ResetTimer()
+				if err := consumer.ConsumeClaim(&lockedSession{}, claim); err != nil {
+					b.Fatal(err)
+				}
+				b.StopTimer()
+
+				b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "orders/s")
+			})
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/storage"
+	storagemocks "github.com/AlexShmak/order-service/internal/storage/mocks"
+	"github.com/IBM/sarama"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newBatchConsumer(orders storage.Orders, size int, window time.Duration) *Consumer {
+	consumer := newPoolConsumer(orders, 1)
+	consumer.batchSize, consumer.batchWindow = size, window
+	return consumer
+}
+
+// startClaim runs ConsumeClaim over the orders, the claim stays open until
+// the returned function is called.
+func startClaim(t *testing.T, consumer *Consumer, uids ...string) (*lockedSession, func()) {
+	t.Helper()
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, len(uids))}
+	for i, uid := range uids {
+		claim.messages <- orderMessage(t, int64(i), &storage.Order{OrderUID: uid})
+	}
+
+	session := &lockedSession{}
+	done := make(chan error)
+	go func() {
+		done <- consumer.ConsumeClaim(session, claim)
+	}()
+	return session, func() {
+		close(claim.messages)
+		require.NoError(t, <-done)
+	}
+}
+
+func uidsOf(orders []*storage.Order) []string {
+	uids := make([]string, 0, len(orders))
+	for _, order := range orders {
+		uids = append(uids, order.OrderUID)
+	}
+	return uids
+}
+
+func TestConsumeClaimBatchesBySize(t *testing.T) {
+	orders := storagemocks.NewMockOrders(t)
+	orders.EXPECT().CreateBatch(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, batch []*storage.Order) error {
+		assert.Equal(t, []string{"order-0", "order-1", "order-2"}, uidsOf(batch))
+		return nil
+	}).Once()
+
+	// the window never ends, the batch is full first
+	session, stop := startClaim(t, newBatchConsumer(orders, 3, time.Hour), "order-0", "order-1", "order-2")
+	require.Eventually(t, func() bool { return len(session.Marked()) == 3 }, time.Second, time.Millisecond)
+	stop()
+
+	assert.Equal(t, []int64{0, 1, 2}, session.Marked())
+}
+
+func TestConsumeClaimBatchesByWindow(t *testing.T) {
+	orders := storagemocks.NewMockOrders(t)
+	orders.EXPECT().CreateBatch(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, batch []*storage.Order) error {
+		assert.Equal(t, []string{"order-0", "order-1"}, uidsOf(batch))
+		return nil
+	}).Once()
+
+	session, stop := startClaim(t, newBatchConsumer(orders, 100, 10*time.Millisecond), "order-0", "order-1")
+	require.Eventually(t, func() bool { return len(session.Marked()) == 2 }, time.Second, time.Millisecond)
+	stop()
+}
+
+func TestConsumeClaimMarksOnlyCommittedBatches(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	orders := storagemocks.NewMockOrders(t)
+	orders.EXPECT().CreateBatch(mock.Anything, mock.Anything).RunAndReturn(func(context.Context, []*storage.Order) error {
+		close(started)
+		<-release
+		return nil
+	}).Once()
+
+	session, stop := startClaim(t, newBatchConsumer(orders, 2, time.Hour), "order-0", "order-1")
+	<-started
+	time.Sleep(10 * time.Millisecond)
+	assert.Empty(t, session.Marked(), "the batch is not committed yet")
+
+	close(release)
+	require.Eventually(t, func() bool { return len(session.Marked()) == 2 }, time.Second, time.Millisecond)
+	stop()
+}
+
+func TestConsumeClaimIsolatesBadOrders(t *testing.T) {
+	orders := storagemocks.NewMockOrders(t)
+	tooLong := &pgconn.PgError{Code: "22001", Message: "value too long for type character varying(255)"}
+	orders.EXPECT().CreateBatch(mock.Anything, mock.Anything).Return(tooLong).Once()
+	var created []string
+	orders.EXPECT().Create(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, order *storage.Order) error {
+		switch order.OrderUID {
+		case "order-1":
+			return tooLong
+		case "order-2":
+			return storage.ErrOrderExists
+		}
+		created = append(created, order.OrderUID)
+		return nil
+	}).Times(4)
+
+	uids := make([]string, 4)
+	for i := range uids {
+		uids[i] = fmt.Sprintf("order-%d", i)
+	}
+	session, stop := startClaim(t, newBatchConsumer(orders, len(uids), time.Hour), uids...)
+	require.Eventually(t, func() bool { return len(session.Marked()) == len(uids) }, time.Second, time.Millisecond)
+	stop()
+
+	assert.Equal(t, []string{"order-0", "order-3"}, created, "one by one in order")
+}
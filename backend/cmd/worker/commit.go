@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/IBM/sarama"
+)
+
+// committer makes the progress of a partition durable. It gets the messages
+// done in offset order and the downstream messages they produced.
+type committer interface {
+	commit(messages []*sarama.ConsumerMessage, produced []*sarama.ProducerMessage) error
+	close() error
+}
+
+// sessionCommitter sends the downstream messages before it marks the
+// consumed ones, a crash in between sends them again.
+type sessionCommitter struct {
+	session sarama.ConsumerGroupSession
+	// producer is nil when there is nothing to send downstream
+	producer sarama.SyncProducer
+}
+
+func (c *sessionCommitter) commit(messages []*sarama.ConsumerMessage, produced []*sarama.ProducerMessage) error {
+	if len(produced) > 0 {
+		if err := c.producer.SendMessages(produced); err != nil {
+			return fmt.Errorf("could not send downstream events: %w", err)
+		}
+	}
+	for _, message := range messages {
+		c.session.MarkMessage(message, "")
+	}
+	return nil
+}
+
+func (c *sessionCommitter) close() error {
+	if c.producer == nil {
+		return nil
+	}
+	return c.producer.Close()
+}
+
+// txnCommitter sends the downstream messages and commits the consumed offsets
+// in one Kafka transaction, so read_committed consumers see the events of a
+// message exactly once. The orders themselves are stored before, a message
+// processed again finds its order stored and emits the event once more.
+type txnCommitter struct {
+	producer sarama.SyncProducer
+	groupID  string
+}
+
+func (c *txnCommitter) commit(messages []*sarama.ConsumerMessage, produced []*sarama.ProducerMessage) error {
+	if err := c.producer.BeginTxn(); err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+
+	var err error
+	if len(produced) > 0 {
+		err = c.producer.SendMessages(produced)
+	}
+	if err == nil {
+		// the offset of the last message commits every one before it
+		err = c.producer.AddMessageToTxn(messages[len(messages)-1], c.groupID, nil)
+	}
+	if err == nil {
+		err = c.producer.CommitTxn()
+	}
+	if err != nil {
+		if abortErr := c.producer.AbortTxn(); abortErr != nil {
+			err = errors.Join(err, abortErr)
+		}
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (c *txnCommitter) close() error {
+	return c.producer.Close()
+}
@@ -0,0 +1,228 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/events"
+	"github.com/AlexShmak/order-service/internal/kafka"
+	"github.com/AlexShmak/order-service/internal/storage"
+	storagemocks "github.com/AlexShmak/order-service/internal/storage/mocks"
+	"github.com/IBM/sarama"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingProducer records what the worker sends and how it uses
+// transactions.
+type recordingProducer struct {
+	sarama.SyncProducer
+	commitErr error
+
+	mu     sync.Mutex
+	calls  []string
+	sent   []*sarama.ProducerMessage
+	closed bool
+}
+
+func (p *recordingProducer) record(call string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, call)
+}
+
+func (p *recordingProducer) Calls() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.calls...)
+}
+
+func (p *recordingProducer) BeginTxn() error { p.record("begin"); return nil }
+func (p *recordingProducer) AbortTxn() error { p.record("abort"); return nil }
+
+func (p *recordingProducer) CommitTxn() error {
+	p.record("commit")
+	return p.commitErr
+}
+
+func (p *recordingProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	for _, msg := range msgs {
+		key, _ := msg.Key.Encode()
+		p.record(fmt.Sprintf("send %s %s", msg.Topic, key))
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sent = append(p.sent, msgs...)
+	return nil
+}
+
+func (p *recordingProducer) AddMessageToTxn(msg *sarama.ConsumerMessage, groupID string, _ *string) error {
+	p.record(fmt.Sprintf("offset %d %s", msg.Offset, groupID))
+	return nil
+}
+
+func (p *recordingProducer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+// newEmittingConsumer emits order.stored events through producer, in
+// transactions if transactionalID is set.
+func newEmittingConsumer(orders storage.Orders, transactionalID string, producer *recordingProducer) (*Consumer, *[]string) {
+	consumer := newPoolConsumer(orders, 1)
+	consumer.storedTopic = "orders-stored"
+	consumer.transactionalID = transactionalID
+	var ids []string
+	consumer.newProducer = func(transactionalID string) (sarama.SyncProducer, error) {
+		ids = append(ids, transactionalID)
+		return producer, nil
+	}
+	return consumer, &ids
+}
+
+func TestConsumeClaimEmitsInTransactions(t *testing.T) {
+	orders := storagemocks.NewMockOrders(t)
+	orders.EXPECT().Create(mock.Anything, mock.Anything).Return(nil).Once()
+	// a redelivered order emits its event again, the first one may have been
+	// aborted
+	orders.EXPECT().Create(mock.Anything, mock.Anything).Return(storage.ErrOrderExists).Once()
+
+	producer := &recordingProducer{}
+	consumer, ids := newEmittingConsumer(orders, "orders-tx", producer)
+	session, stop := startClaim(t, consumer, "order-1", "order-2")
+	stop()
+
+	assert.Equal(t, []string{"orders-tx-worker-orders-0"}, *ids, "one transactional ID per partition")
+	assert.Equal(t, []string{
+		"begin", "send orders-stored order-1", "offset 0 orders-group", "commit",
+		"begin", "send orders-stored order-2", "offset 1 orders-group", "commit",
+	}, producer.Calls())
+	assert.Empty(t, session.Marked(), "offsets are committed by the transactions")
+	assert.True(t, producer.closed)
+
+	// both copies of an event share its ID
+	first, err := kafka.JSONCodec{}.Decode("orders-stored", mustEncode(t, producer.sent[0].Value))
+	require.NoError(t, err)
+	again, err := events.NewOrderStored(&storage.Order{OrderUID: "order-1"})
+	require.NoError(t, err)
+	assert.Equal(t, events.TypeOrderStored, first.Type)
+	assert.Equal(t, again.ID, first.ID)
+}
+
+func TestConsumeClaimCommitsBatchInOneTransaction(t *testing.T) {
+	orders := storagemocks.NewMockOrders(t)
+	orders.EXPECT().CreateBatch(mock.Anything, mock.Anything).Return(nil).Once()
+
+	producer := &recordingProducer{}
+	consumer, _ := newEmittingConsumer(orders, "orders-tx", producer)
+	consumer.batchSize, consumer.batchWindow = 2, time.Minute
+	_, stop := startClaim(t, consumer, "order-1", "order-2")
+	stop()
+
+	assert.Equal(t, []string{
+		"begin", "send orders-stored order-1", "send orders-stored order-2", "offset 1 orders-group", "commit",
+	}, producer.Calls())
+}
+
+func TestConsumeClaimStopsAfterFailedTransaction(t *testing.T) {
+	orders := storagemocks.NewMockOrders(t)
+	orders.EXPECT().Create(mock.Anything, mock.Anything).Return(nil)
+
+	producer := &recordingProducer{commitErr: errors.New("coordinator not available")}
+	consumer, _ := newEmittingConsumer(orders, "orders-tx", producer)
+
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 3)}
+	for i, uid := range []string{"order-1", "order-2", "order-3"} {
+		claim.messages <- orderMessage(t, int64(i), &storage.Order{OrderUID: uid})
+	}
+	defer close(claim.messages)
+
+	// the claim ends without waiting for the rest, so that the next session
+	// starts over from the last committed offset
+	session := &lockedSession{}
+	err := consumer.ConsumeClaim(session, claim)
+	require.ErrorContains(t, err, "coordinator not available")
+	assert.Equal(t, []string{
+		"begin", "send orders-stored order-1", "offset 0 orders-group", "commit", "abort",
+	}, producer.Calls(), "nothing is committed after a failed transaction")
+	assert.Empty(t, session.Marked())
+}
+
+func TestConsumeClaimSendsBeforeMarking(t *testing.T) {
+	orders := storagemocks.NewMockOrders(t)
+	orders.EXPECT().Create(mock.Anything, mock.Anything).Return(nil).Twice()
+
+	producer := &recordingProducer{}
+	consumer, ids := newEmittingConsumer(orders, "", producer)
+	session, stop := startClaim(t, consumer, "order-1", "order-2")
+	stop()
+
+	assert.Equal(t, []string{""}, *ids, "not transactional")
+	assert.Equal(t, []string{"send orders-stored order-1", "send orders-stored order-2"}, producer.Calls())
+	assert.Equal(t, []int64{0, 1}, session.Marked())
+}
+
+func mustEncode(t *testing.T, encoder sarama.Encoder) []byte {
+	t.Helper()
+	value, err := encoder.Encode()
+	require.NoError(t, err)
+	return value
+}
+
+func TestConsumeClaimEmitsOnlyStoredOrders(t *testing.T) {
+	// the batch fails on a track number order-1 shares with a stored order
+	taken := &pgconn.PgError{Code: storage.CodeUniqueViolation, ConstraintName: "orders_track_number_key",
+		Message: `duplicate key value violates unique constraint "orders_track_number_key"`}
+	orders := storagemocks.NewMockOrders(t)
+	orders.EXPECT().CreateBatch(mock.Anything, mock.Anything).Return(taken).Once()
+	orders.EXPECT().Create(mock.Anything, mock.MatchedBy(func(order *storage.Order) bool { return order.OrderUID == "order-1" })).Return(taken).Once()
+	orders.EXPECT().Create(mock.Anything, mock.MatchedBy(func(order *storage.Order) bool { return order.OrderUID == "order-2" })).Return(nil).Once()
+
+	producer := &recordingProducer{}
+	consumer, _ := newEmittingConsumer(orders, "orders-tx", producer)
+	consumer.batchSize, consumer.batchWindow = 2, time.Minute
+	_, stop := startClaim(t, consumer, "order-1", "order-2")
+	stop()
+
+	assert.Equal(t, []string{
+		"begin", "send orders-stored order-2", "offset 1 orders-group", "commit",
+	}, producer.Calls())
+}
+
+func TestConsumeClaimStopsOnStorageErrors(t *testing.T) {
+	for _, batchSize := range []int{1, 2} {
+		t.Run(fmt.Sprintf("batch size %d", batchSize), func(t *testing.T) {
+			// order-1 is stored, the database goes away before order-2
+			down := errors.New("failed to connect to `host=postgres`: connection refused")
+			orders := storagemocks.NewMockOrders(t)
+			orders.EXPECT().CreateBatch(mock.Anything, mock.Anything).Return(down).Maybe()
+			orders.EXPECT().Create(mock.Anything, mock.MatchedBy(func(order *storage.Order) bool { return order.OrderUID == "order-1" })).Return(nil).Once()
+			orders.EXPECT().Create(mock.Anything, mock.MatchedBy(func(order *storage.Order) bool { return order.OrderUID == "order-2" })).Return(down).Once()
+
+			producer := &recordingProducer{}
+			consumer, _ := newEmittingConsumer(orders, "orders-tx", producer)
+			consumer.batchSize, consumer.batchWindow = batchSize, time.Minute
+
+			claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 3)}
+			for i, uid := range []string{"order-1", "order-2", "order-3"} {
+				claim.messages <- orderMessage(t, int64(i), &storage.Order{OrderUID: uid})
+			}
+			close(claim.messages)
+
+			// the order is not lost, the next session reads it again
+			err := consumer.ConsumeClaim(&lockedSession{}, claim)
+			require.ErrorIs(t, err, down)
+			for _, call := range producer.Calls() {
+				assert.NotContains(t, call, "offset 1", "the offset of order-2 is not committed")
+				assert.NotContains(t, call, "order-3", "nothing after order-2 is processed")
+			}
+		})
+	}
+}
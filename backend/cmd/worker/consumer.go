@@ -2,9 +2,11 @@ package worker
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/AlexShmak/order-service/internal/config"
+	"github.com/AlexShmak/order-service/internal/events"
+	"github.com/AlexShmak/order-service/internal/kafka"
 	"github.com/AlexShmak/order-service/internal/storage"
 	"github.com/IBM/sarama"
 	"log/slog"
@@ -12,12 +14,65 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 )
 
+// groupID is the consumer group of the workers.
+const groupID = "orders-group"
+
 type Consumer struct {
 	ready   chan bool
 	Storage *storage.PostgresStorage
-	logger  *slog.Logger
+	codecs  *kafka.Codecs
+	// concurrency is the number of messages of a partition processed at once
+	concurrency int
+	// a lane stores up to batchSize orders in one transaction, waiting at
+	// most batchWindow for a batch to fill up
+	batchSize   int
+	batchWindow time.Duration
+	// storedTopic receives an order.stored event per stored order if set.
+	// With a transactionalID, the events and the offsets of a partition are
+	// committed in one transaction
+	storedTopic     string
+	transactionalID string
+	newProducer     func(transactionalID string) (sarama.SyncProducer, error)
+	logger          *slog.Logger
+}
+
+func NewConsumer(pgStorage *storage.PostgresStorage, codecs *kafka.Codecs, cfg config.KafkaConfig, logger *slog.Logger) *Consumer {
+	return &Consumer{
+		ready:           make(chan bool),
+		Storage:         pgStorage,
+		codecs:          codecs,
+		concurrency:     cfg.WorkerConcurrency,
+		batchSize:       cfg.WorkerBatchSize,
+		batchWindow:     cfg.WorkerBatchWindow,
+		storedTopic:     cfg.StoredTopic,
+		transactionalID: cfg.TransactionalID,
+		newProducer: func(transactionalID string) (sarama.SyncProducer, error) {
+			return kafka.NewSyncProducer(cfg, transactionalID)
+		},
+		logger: logger,
+	}
+}
+
+// Run consumes topic in group until ctx is cancelled. The group is joined
+// again after every rebalance.
+func (c *Consumer) Run(ctx context.Context, group sarama.ConsumerGroup, topic string) {
+	for {
+		if err := group.Consume(ctx, []string{topic}, c); err != nil {
+			c.logger.Error("Error from consumer", "error", err)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		c.ready = make(chan bool)
+	}
+}
+
+// Ready is closed once the first session has started.
+func (c *Consumer) Ready() <-chan bool {
+	return c.ready
 }
 
 func (c *Consumer) Setup(sarama.ConsumerGroupSession) error {
@@ -29,73 +84,224 @@ func (c *Consumer) Cleanup(sarama.ConsumerGroupSession) error {
 	return nil
 }
 
+// ConsumeClaim processes the messages of a partition on c.concurrency lanes.
+// Messages with the same key share a lane, so the events of an order are
+// handled in the order they were published, and an offset is only committed
+// once every message before it is done.
 func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
-	for message := range claim.Messages() {
-		c.logger.Info(
-			"Message claimed",
-			"value", string(message.Value),
-			"timestamp", message.Timestamp,
-			"topic", message.Topic,
-			"partition", message.Partition,
-		)
-
-		var order storage.Order
-		if err := json.Unmarshal(message.Value, &order); err != nil {
-			c.logger.Error("Failed to unmarshal message", "error", err)
-			continue
+	committer, err := c.newCommitter(session, claim)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := committer.close(); err != nil {
+			c.logger.Error("Failed to close producer", "error", err)
+		}
+	}()
+
+	tracker := newOffsetTracker(committer)
+	lanes := make([]chan *sarama.ConsumerMessage, max(c.concurrency, 1))
+
+	var wg sync.WaitGroup
+	for i := range lanes {
+		lanes[i] = make(chan *sarama.ConsumerMessage, laneBuffer)
+		wg.Add(1)
+		go func(lane <-chan *sarama.ConsumerMessage) {
+			defer wg.Done()
+			c.runLane(lane, tracker)
+		}(lanes[i])
+	}
+
+consume:
+	for {
+		select {
+		case message, ok := <-claim.Messages():
+			if !ok {
+				break consume
+			}
+			tracker.add(message)
+			lanes[laneOf(message, len(lanes))] <- message
+		case <-tracker.failed:
+			// the claim stops, the next session starts over from the last
+			// committed offset
+			break consume
 		}
+	}
 
-		if err := createOrder(context.Background(), &order, c.Storage, c.logger); err != nil {
-			c.logger.Error("Failed to create order", "error", err)
+	// the claim ends on rebalance, the messages already taken are finished
+	// and committed before the partition is given up
+	for _, lane := range lanes {
+		close(lane)
+	}
+	wg.Wait()
+	return tracker.failure()
+}
+
+// newCommitter connects the producer for the downstream events of a claim.
+// Transactional IDs are per partition, so the producer of a new owner fences
+// the previous one if that is still committing after a rebalance.
+func (c *Consumer) newCommitter(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) (committer, error) {
+	if c.storedTopic == "" {
+		return &sessionCommitter{session: session}, nil
+	}
+
+	if c.transactionalID == "" {
+		producer, err := c.newProducer("")
+		if err != nil {
+			return nil, fmt.Errorf("could not create producer: %w", err)
 		}
+		return &sessionCommitter{session: session, producer: producer}, nil
+	}
 
-		session.MarkMessage(message, "")
+	transactionalID := fmt.Sprintf("%s-worker-%s-%d", c.transactionalID, claim.Topic(), claim.Partition())
+	producer, err := c.newProducer(transactionalID)
+	if err != nil {
+		return nil, fmt.Errorf("could not create transactional producer: %w", err)
 	}
-	return nil
+	return &txnCommitter{producer: producer, groupID: groupID}, nil
+}
+
+// process returns the downstream messages of message, or an error if it has
+// to be processed again.
+func (c *Consumer) process(message *sarama.ConsumerMessage) ([]*sarama.ProducerMessage, error) {
+	if event, logger := c.decode(message); event != nil {
+		return c.handle(context.Background(), event, logger)
+	}
+	return nil, nil
+}
+
+// decode returns nil for a message that cannot be read, it is skipped.
+func (c *Consumer) decode(message *sarama.ConsumerMessage) (*events.Event, *slog.Logger) {
+	// the value holds the customer's delivery data, so only metadata is logged
+	logger := c.logger.With(
+		"topic", message.Topic,
+		"partition", message.Partition,
+		"offset", message.Offset,
+	)
+	logger.Info("Message claimed", "timestamp", message.Timestamp, "size", len(message.Value))
+
+	event, err := c.codecs.Decode(message)
+	if err != nil {
+		logger.Error("Failed to unmarshal message", "error", err)
+		return nil, nil
+	}
+	return event, logger.With("event_id", event.ID, "event_type", event.Type, "event_version", event.Version)
+}
+
+// handle dispatches event on its type, the handler of a type reads every
+// payload version of it.
+func (c *Consumer) handle(ctx context.Context, event *events.Event, logger *slog.Logger) ([]*sarama.ProducerMessage, error) {
+	switch event.Type {
+	case events.TypeOrderCreated:
+		order, logger := orderOf(event, logger)
+		if order == nil {
+			return nil, nil
+		}
+		if ok, err := c.storeOrder(ctx, order, logger); !ok {
+			return nil, err
+		}
+		return c.stored(order, logger), nil
+	default:
+		logger.Warn("Skipping event of unknown type")
+	}
+	return nil, nil
+}
+
+// orderOf returns nil for an order.created event that cannot be read.
+func orderOf(event *events.Event, logger *slog.Logger) (*storage.Order, *slog.Logger) {
+	// a payload version this worker does not know fails here as well
+	order, err := event.Order()
+	if err != nil {
+		logger.Error("Failed to unmarshal message", "error", err)
+		return nil, nil
+	}
+
+	logger = logger.With("order_uid", order.OrderUID, "track_number", order.TrackNumber)
+	logger.Info("Order received", "items", len(order.Items), "date_created", order.DateCreated)
+	return order, logger
+}
+
+// storeOrder reports whether order is stored, by now or before. An order the
+// database rejects is skipped, as it would be rejected again, any other error
+// is returned so that the message is not committed.
+func (c *Consumer) storeOrder(ctx context.Context, order *storage.Order, logger *slog.Logger) (bool, error) {
+	err := createOrder(ctx, order, c.Storage, logger)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, storage.ErrOrderExists):
+		// a redelivered message, the order was stored the first time
+		logger.Warn("Order already exists, skipping")
+		return true, nil
+	case storage.IsDataError(err):
+		logger.Error("Failed to create order, skipping", "error", err)
+		return false, nil
+	}
+	logger.Error("Failed to create order", "error", err)
+	return false, err
+}
+
+// stored returns the order.stored event of order for the downstream topic.
+// A redelivered order gets it again, as the first one may not have been
+// committed.
+func (c *Consumer) stored(order *storage.Order, logger *slog.Logger) []*sarama.ProducerMessage {
+	if c.storedTopic == "" {
+		return nil
+	}
+
+	event, err := events.NewOrderStored(order)
+	if err != nil {
+		logger.Error("Failed to create order.stored event", "error", err)
+		return nil
+	}
+	msg, err := kafka.NewMessage(c.storedTopic, event, c.codecs.Encoder())
+	if err != nil {
+		logger.Error("Failed to encode order.stored event", "error", err)
+		return nil
+	}
+	return []*sarama.ProducerMessage{msg}
 }
 
 func createOrder(ctx context.Context, order *storage.Order, storage *storage.PostgresStorage, logger *slog.Logger) error {
 	if err := storage.Orders.Create(ctx, order); err != nil {
 		return fmt.Errorf("failed to create order in storage: %w", err)
 	}
-	logger.Info("Order created successfully", "order_uid", order.OrderUID)
+	logger.Info("Order created successfully")
 	return nil
 }
 
-func StartWorker(cfg *config.Config, pgStorage *storage.PostgresStorage, logger *slog.Logger) {
+func StartWorker(cfg *config.Config, pgStorage *storage.PostgresStorage, codecs *kafka.Codecs, logger *slog.Logger) {
 	consumerConfig := sarama.NewConfig()
 	consumerConfig.Consumer.Return.Errors = true
 	consumerConfig.Consumer.Offsets.Initial = sarama.OffsetOldest
+	// messages of aborted transactions are skipped, and those of open ones
+	// are only read once committed
+	consumerConfig.Consumer.IsolationLevel = sarama.ReadCommitted
 
-	consumerGroup, err := sarama.NewConsumerGroup(cfg.Kafka.Brokers, "orders-group", consumerConfig)
+	consumerGroup, err := sarama.NewConsumerGroup(cfg.Kafka.Brokers, groupID, consumerConfig)
 	if err != nil {
 		logger.Error("Error creating consumer group client", "error", err)
 		os.Exit(1)
 	}
 
-	consumer := &Consumer{
-		ready:   make(chan bool),
-		Storage: pgStorage,
-		logger:  logger,
-	}
+	// the group blocks on errors nobody receives
+	go func() {
+		for err := range consumerGroup.Errors() {
+			logger.Error("Error from consumer group", "error", err)
+		}
+	}()
+
+	consumer := NewConsumer(pgStorage, codecs, cfg.Kafka, logger)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		for {
-			if err := consumerGroup.Consume(ctx, []string{cfg.Kafka.Topic}, consumer); err != nil {
-				logger.Error("Error from consumer", "error", err)
-			}
-			if ctx.Err() != nil {
-				return
-			}
-			consumer.ready = make(chan bool)
-		}
+		consumer.Run(ctx, consumerGroup, cfg.Kafka.Topic)
 	}()
 
-	<-consumer.ready
+	<-consumer.Ready()
 	logger.Info("Consumer is ready")
 
 	sigterm := make(chan os.Signal, 1)
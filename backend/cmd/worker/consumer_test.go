@@ -0,0 +1,147 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/events"
+	"github.com/AlexShmak/order-service/internal/kafka"
+	"github.com/AlexShmak/order-service/internal/storage"
+	storagemocks "github.com/AlexShmak/order-service/internal/storage/mocks"
+	"github.com/IBM/sarama"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testOrderMessage = `{
+	"OrderUID": "b563feb7-b2b8-4b6e-9f2c-000000000000",
+	"TrackNumber": "WBILMTESTTRACK",
+	"Delivery": {
+		"Name": "Test Testov",
+		"Phone": "+9720000000",
+		"Zip": "2639809",
+		"City": "Kiryat Mozkin",
+		"Address": "Ploshad Mira 15",
+		"Region": "Kraiot",
+		"Email": "test@gmail.com"
+	},
+	"Items": [{"ChrtID": 9934930, "Name": "Mascaras"}]
+}`
+
+var testPII = []string{"Test Testov", "+9720000000", "Ploshad Mira 15", "test@gmail.com"}
+
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	marked []*sarama.ConsumerMessage
+}
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.marked = append(s.marked, msg)
+}
+
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Topic() string    { return "orders" }
+func (c *fakeClaim) Partition() int32 { return 0 }
+
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return c.messages
+}
+
+func consume(t *testing.T, orders storage.Orders, values ...string) (string, *fakeSession) {
+	t.Helper()
+
+	// a plain handler, the consumer itself must not log PII
+	var buf bytes.Buffer
+	consumer := &Consumer{
+		Storage: &storage.PostgresStorage{Orders: orders},
+		codecs:  kafka.NewCodecs(kafka.JSONCodec{}),
+		logger:  slog.New(slog.NewJSONHandler(&buf, nil)),
+	}
+
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, len(values))}
+	for i, value := range values {
+		claim.messages <- &sarama.ConsumerMessage{Topic: "orders", Partition: 0, Offset: int64(i), Value: []byte(value)}
+	}
+	close(claim.messages)
+
+	session := &fakeSession{}
+	require.NoError(t, consumer.ConsumeClaim(session, claim))
+	return buf.String(), session
+}
+
+func TestConsumeClaimLogsNoPII(t *testing.T) {
+	orders := storagemocks.NewMockOrders(t)
+	orders.EXPECT().Create(mock.Anything, mock.MatchedBy(func(order *storage.Order) bool {
+		return order.Delivery.Email == "test@gmail.com"
+	})).Return(nil).Once()
+
+	out, session := consume(t, orders, testOrderMessage)
+
+	assert.Len(t, session.marked, 1)
+	assert.Contains(t, out, `"order_uid":"b563feb7-b2b8-4b6e-9f2c-000000000000"`)
+	assert.Contains(t, out, `"track_number":"WBILMTESTTRACK"`)
+	assert.Contains(t, out, `"items":1`)
+	for _, pii := range testPII {
+		assert.NotContains(t, out, pii)
+	}
+}
+
+func TestConsumeClaimLogsNoPIIOnFailure(t *testing.T) {
+	orders := storagemocks.NewMockOrders(t)
+	orders.EXPECT().Create(mock.Anything, mock.Anything).Return(&pgconn.PgError{Code: "22001", Message: "value too long for type character varying(255)"}).Once()
+
+	malformed := `{"OrderUID": 1, "Delivery": {"Email": "test@gmail.com", "Phone": "+9720000000"}}`
+	out, _ := consume(t, orders, malformed, testOrderMessage)
+
+	assert.Contains(t, out, "Failed to unmarshal message")
+	assert.Contains(t, out, "Failed to create order")
+	for _, pii := range testPII {
+		assert.NotContains(t, out, pii)
+	}
+}
+
+func encodeEvent(t testing.TB, order *storage.Order, version int) string {
+	t.Helper()
+	event, err := events.NewOrderCreated(order, version)
+	require.NoError(t, err)
+	value, err := json.Marshal(event)
+	require.NoError(t, err)
+	return string(value)
+}
+
+func TestConsumeClaimReadsEveryOrderVersion(t *testing.T) {
+	var order storage.Order
+	require.NoError(t, json.Unmarshal([]byte(testOrderMessage), &order))
+	order.CustomerID = "1"
+	order.Payment = storage.Payment{Transaction: order.OrderUID, Amount: 1817, PaymentDt: 1637907727}
+	order.DateCreated = time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+
+	orders := storagemocks.NewMockOrders(t)
+	orders.EXPECT().Create(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, created *storage.Order) error {
+		assert.Equal(t, &order, created)
+		return nil
+	}).Times(3)
+
+	// published before there was an envelope
+	bare, err := json.Marshal(order)
+	require.NoError(t, err)
+	unknownVersion := `{"id": "1", "type": "order.created", "version": 99, "payload": {}}`
+	unknownType := `{"id": "2", "type": "order.cancelled", "version": 1, "payload": {}}`
+
+	out, session := consume(t, orders,
+		encodeEvent(t, &order, 1), encodeEvent(t, &order, 2), string(bare), unknownVersion, unknownType)
+
+	assert.Len(t, session.marked, 5)
+	assert.Contains(t, out, "unknown event version")
+	assert.Contains(t, out, "Skipping event of unknown type")
+}
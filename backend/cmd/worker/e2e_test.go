@@ -0,0 +1,128 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/audit"
+	"github.com/AlexShmak/order-service/internal/config"
+	"github.com/AlexShmak/order-service/internal/encryption"
+	"github.com/AlexShmak/order-service/internal/events"
+	"github.com/AlexShmak/order-service/internal/handlers"
+	"github.com/AlexShmak/order-service/internal/kafka"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/AlexShmak/order-service/internal/storage/cache"
+	"github.com/AlexShmak/order-service/internal/storage/memory"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const createOrderRequest = `{
+	"delivery": {
+		"name": "Test Testov",
+		"phone": "+9720000000",
+		"zip": "2639809",
+		"city": "Kiryat Mozkin",
+		"address": "Ploshad Mira 15",
+		"region": "Kraiot",
+		"email": "test@gmail.com"
+	},
+	"payment": {"currency": "USD", "provider": "wbpay", "bank": "alpha", "delivery_cost": 1500, "goods_total": 317},
+	"items": [{"chrt_id": 9934930, "name": "Mascaras", "price": 453, "sale": 30, "size": "0", "brand": "Vivienne Sabo", "total_price": 317, "nm_id": 2389212}],
+	"locale": "en",
+	"delivery_service": "meest"
+}`
+
+type discardAuditEvents struct{}
+
+func (discardAuditEvents) Create(context.Context, *storage.AuditEvent) error { return nil }
+func (discardAuditEvents) List(context.Context, storage.AuditFilter) ([]storage.AuditEvent, error) {
+	return nil, nil
+}
+
+// TestOrderFlow places an order through the API and reads it back once the
+// worker has stored it, with the in-memory broker and storage in place of
+// Kafka and Postgres.
+func TestOrderFlow(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		testOrderFlow(t, kafka.NewCodecs(kafka.JSONCodec{}))
+	})
+	t.Run("protobuf", func(t *testing.T) {
+		registry := kafka.NewFileSchemaRegistry(filepath.Join(t.TempDir(), "schemas.json"))
+		testOrderFlow(t, kafka.NewCodecs(kafka.NewProtobufCodec(registry), kafka.JSONCodec{}))
+	})
+}
+
+func testOrderFlow(t *testing.T, codecs *kafka.Codecs) {
+	gin.SetMode(gin.TestMode)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	pgStorage := memory.NewStorage()
+	broker := kafka.NewMemoryBroker(codecs.Encoder())
+	t.Cleanup(func() { _ = broker.Close() })
+
+	user := &storage.User{Name: "Test Testov", Email: "test@gmail.com", Password: "password"}
+	require.NoError(t, pgStorage.Users.Create(context.Background(), user))
+
+	cfg := &config.Config{Kafka: config.KafkaConfig{Topic: "orders", OrderEventVersion: events.OrderCreatedVersion}}
+	hasher, err := encryption.NewHasher([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	handler := handlers.NewHandler(pgStorage, logger, nil, cfg, broker, cache.NewMemoryStorage(), nil, nil, nil, nil, nil, nil, audit.NewRecorder(discardAuditEvents{}, logger, hasher))
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("userId", user.ID) })
+	router.POST("/api/orders", handler.CreateOrderHandler)
+	router.GET("/api/orders/:id", handler.GetOrderByIDHandler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	consumer := NewConsumer(pgStorage, codecs, config.KafkaConfig{WorkerConcurrency: 4, WorkerBatchSize: 10, WorkerBatchWindow: 10 * time.Millisecond}, logger)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		consumer.Run(ctx, broker.ConsumerGroup("orders-group"), cfg.Kafka.Topic)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	<-consumer.Ready()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(createOrderRequest)))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created struct {
+		OrderUID string `json:"order_uid"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	messages := broker.Messages(cfg.Kafka.Topic)
+	require.Len(t, messages, 1)
+	assert.Equal(t, created.OrderUID, string(messages[0].Key))
+	assert.Equal(t, events.TypeOrderCreated, kafka.Header(messages[0], kafka.HeaderEventType))
+	assert.Equal(t, "2", kafka.Header(messages[0], kafka.HeaderEventVersion))
+	assert.NotEmpty(t, kafka.Header(messages[0], kafka.HeaderEventID))
+	assert.Equal(t, codecs.Encoder().ContentType(), kafka.Header(messages[0], kafka.HeaderContentType))
+
+	var order storage.Order
+	require.Eventually(t, func() bool {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/orders/"+created.OrderUID, nil))
+		if rec.Code != http.StatusOK {
+			return false
+		}
+		return json.NewDecoder(bytes.NewReader(rec.Body.Bytes())).Decode(&order) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, created.OrderUID, order.OrderUID)
+	assert.Equal(t, "Ploshad Mira 15", order.Delivery.Address)
+	assert.Equal(t, 1817, order.Payment.Amount)
+	require.Len(t, order.Items, 1)
+	assert.Equal(t, "Mascaras", order.Items[0].Name)
+}
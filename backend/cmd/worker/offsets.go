@@ -0,0 +1,133 @@
+package worker
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/IBM/sarama"
+)
+
+// laneBuffer is the number of messages queued per lane. A slow message holds
+// back the commits of its partition, so the buffer also bounds how much is
+// processed again after a crash.
+const laneBuffer = 16
+
+// laneOf returns the lane for message. Unkeyed messages predate keying and
+// carry no ordering, they are spread by offset.
+func laneOf(message *sarama.ConsumerMessage, lanes int) int {
+	if len(message.Key) == 0 {
+		return int(message.Offset % int64(lanes))
+	}
+	h := fnv.New32a()
+	_, _ = h.Write(message.Key)
+	return int(h.Sum32() % uint32(lanes))
+}
+
+// offsetTracker commits the messages of a partition in offset order although
+// they complete out of order, a committed offset never skips a message that
+// is still being processed.
+type offsetTracker struct {
+	committer committer
+
+	mu sync.Mutex
+	// pending holds the messages not yet committed in the order they arrived,
+	// offsets of a partition can have gaps, compacted records or transaction
+	// markers, so arrival order is what counts
+	pending []*sarama.ConsumerMessage
+	// finished holds the downstream messages of every completed message that
+	// waits for an earlier one
+	finished map[int64][]*sarama.ProducerMessage
+	// failed is closed once a commit or a message fails, nothing is
+	// committed after it
+	failed chan struct{}
+	err    error
+}
+
+func newOffsetTracker(committer committer) *offsetTracker {
+	return &offsetTracker{
+		committer: committer,
+		finished:  make(map[int64][]*sarama.ProducerMessage),
+		failed:    make(chan struct{}),
+	}
+}
+
+func (t *offsetTracker) add(message *sarama.ConsumerMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = append(t.pending, message)
+}
+
+// done commits message, with the downstream messages it produced, and every
+// message after it that completed earlier, up to the first one still in
+// progress.
+func (t *offsetTracker) done(message *sarama.ConsumerMessage, produced []*sarama.ProducerMessage) {
+	t.doneBatch([]*sarama.ConsumerMessage{message}, [][]*sarama.ProducerMessage{produced})
+}
+
+// doneBatch is done for several messages at once, so that those ready are
+// committed together. produced holds the downstream messages of each.
+func (t *offsetTracker) doneBatch(batch []*sarama.ConsumerMessage, produced [][]*sarama.ProducerMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.err != nil {
+		return
+	}
+	for i, message := range batch {
+		t.finished[message.Offset] = produced[i]
+	}
+
+	var messages []*sarama.ConsumerMessage
+	var downstream []*sarama.ProducerMessage
+	for len(t.pending) > 0 {
+		head := t.pending[0]
+		produced, ok := t.finished[head.Offset]
+		if !ok {
+			break
+		}
+		messages = append(messages, head)
+		downstream = append(downstream, produced...)
+		delete(t.finished, head.Offset)
+		t.pending[0] = nil
+		t.pending = t.pending[1:]
+	}
+	if len(messages) == 0 {
+		return
+	}
+	if err := t.committer.commit(messages, downstream); err != nil {
+		t.stop(err)
+	}
+}
+
+// fail stops the tracker on a message that has to be processed again, e.g.
+// because the database was unavailable.
+func (t *offsetTracker) fail(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stop(err)
+}
+
+func (t *offsetTracker) stop(err error) {
+	if t.err == nil {
+		t.err = err
+		close(t.failed)
+	}
+}
+
+// stopped reports whether the tracker has failed, the messages still queued
+// are left for the next session.
+func (t *offsetTracker) stopped() bool {
+	select {
+	case <-t.failed:
+		return true
+	default:
+		return false
+	}
+}
+
+// failure returns the error of the failed commit, if there was one.
+func (t *offsetTracker) failure() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
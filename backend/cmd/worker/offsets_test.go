@@ -0,0 +1,171 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/kafka"
+	"github.com/AlexShmak/order-service/internal/storage"
+	storagemocks "github.com/AlexShmak/order-service/internal/storage/mocks"
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// lockedSession records the marked offsets, it is read while lanes mark.
+type lockedSession struct {
+	sarama.ConsumerGroupSession
+	mu     sync.Mutex
+	marked []int64
+}
+
+func (s *lockedSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marked = append(s.marked, msg.Offset)
+}
+
+func (s *lockedSession) Marked() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int64(nil), s.marked...)
+}
+
+func orderMessage(t testing.TB, offset int64, order *storage.Order) *sarama.ConsumerMessage {
+	t.Helper()
+	return &sarama.ConsumerMessage{
+		Topic:     "orders",
+		Partition: 0,
+		Offset:    offset,
+		Key:       []byte(order.OrderUID),
+		Value:     []byte(encodeEvent(t, order, 2)),
+	}
+}
+
+func newPoolConsumer(orders storage.Orders, concurrency int) *Consumer {
+	return &Consumer{
+		Storage:     &storage.PostgresStorage{Orders: orders},
+		codecs:      kafka.NewCodecs(kafka.JSONCodec{}),
+		concurrency: concurrency,
+		logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+func TestOffsetTracker(t *testing.T) {
+	session := &lockedSession{}
+	tracker := newOffsetTracker(&sessionCommitter{session: session})
+	// offset 3 and 4 are missing, as they are after a transaction marker
+	messages := []*sarama.ConsumerMessage{{Offset: 0}, {Offset: 1}, {Offset: 2}, {Offset: 5}}
+	for _, message := range messages {
+		tracker.add(message)
+	}
+
+	tracker.done(messages[2], nil)
+	tracker.done(messages[1], nil)
+	assert.Empty(t, session.Marked(), "offset 0 is still in progress")
+
+	tracker.done(messages[0], nil)
+	assert.Equal(t, []int64{0, 1, 2}, session.Marked())
+
+	tracker.done(messages[3], nil)
+	assert.Equal(t, []int64{0, 1, 2, 5}, session.Marked())
+}
+
+func TestConsumeClaimCommitsAfterEarlierMessages(t *testing.T) {
+	const concurrency = 4
+	// three orders that land on different lanes
+	var uids []string
+	lanes := map[int]bool{}
+	for i := 0; len(uids) < 3; i++ {
+		uid := fmt.Sprintf("order-%d", i)
+		lane := laneOf(&sarama.ConsumerMessage{Key: []byte(uid)}, concurrency)
+		if !lanes[lane] {
+			lanes[lane] = true
+			uids = append(uids, uid)
+		}
+	}
+
+	release := make(chan struct{})
+	created := make(chan string, len(uids))
+	orders := storagemocks.NewMockOrders(t)
+	orders.EXPECT().Create(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, order *storage.Order) error {
+		if order.OrderUID == uids[0] {
+			<-release
+		}
+		created <- order.OrderUID
+		return nil
+	}).Times(len(uids))
+
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, len(uids))}
+	for i, uid := range uids {
+		claim.messages <- orderMessage(t, int64(i), &storage.Order{OrderUID: uid})
+	}
+	session := &lockedSession{}
+	done := make(chan error)
+	go func() {
+		done <- newPoolConsumer(orders, concurrency).ConsumeClaim(session, claim)
+	}()
+
+	// the later messages finish while the first one is stuck
+	assert.ElementsMatch(t, uids[1:], []string{<-created, <-created})
+	time.Sleep(10 * time.Millisecond)
+	assert.Empty(t, session.Marked(), "nothing is committed past the stuck first message")
+
+	close(release)
+	assert.Equal(t, uids[0], <-created)
+	require.Eventually(t, func() bool { return len(session.Marked()) == len(uids) }, time.Second, time.Millisecond)
+	assert.Equal(t, []int64{0, 1, 2}, session.Marked())
+
+	close(claim.messages)
+	require.NoError(t, <-done)
+}
+
+func TestConsumeClaimKeepsOrderPerKey(t *testing.T) {
+	const perOrder = 20
+	uids := []string{"order-a", "order-b", "order-c", "order-d", "order-e"}
+
+	var mu sync.Mutex
+	seen := make(map[string][]int64)
+	orders := storagemocks.NewMockOrders(t)
+	orders.EXPECT().Create(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, order *storage.Order) error {
+		// completion order differs from arrival order across lanes
+		time.Sleep(time.Duration(rand.IntN(500)) * time.Microsecond)
+		mu.Lock()
+		defer mu.Unlock()
+		seen[order.OrderUID] = append(seen[order.OrderUID], order.SmID)
+		return nil
+	}).Times(perOrder * len(uids))
+
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, perOrder*len(uids))}
+	var offset int64
+	for i := range perOrder {
+		for _, uid := range uids {
+			// SmID numbers the events of an order
+			claim.messages <- orderMessage(t, offset, &storage.Order{OrderUID: uid, SmID: int64(i)})
+			offset++
+		}
+	}
+	close(claim.messages)
+
+	session := &lockedSession{}
+	require.NoError(t, newPoolConsumer(orders, 4).ConsumeClaim(session, claim))
+
+	for _, uid := range uids {
+		require.Len(t, seen[uid], perOrder, uid)
+		for i, smID := range seen[uid] {
+			assert.Equal(t, int64(i), smID, uid)
+		}
+	}
+	marked := session.Marked()
+	require.Len(t, marked, int(offset))
+	for i, o := range marked {
+		assert.Equal(t, int64(i), o)
+	}
+}
@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"context"
+	"encoding/hex"
+	"github.com/AlexShmak/order-service/internal/encryption"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"time"
+)
+
+const (
+	ActionLogin        = "auth.login"
+	ActionLoginLockout = "auth.login.lockout"
+	ActionLogout       = "auth.logout"
+	ActionRegister     = "auth.register"
+	ActionTokenRefresh = "auth.token_refresh"
+	ActionOrderCreate  = "order.create"
+)
+
+const (
+	TargetUser  = "user"
+	TargetOrder = "order"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	// OutcomeDenied is a request that was refused before it was attempted, e.g. during a lockout
+	OutcomeDenied = "denied"
+)
+
+const recordTimeout = 5 * time.Second
+
+// Event is what a handler knows about an action. The request metadata is
+// added by Recorder.Record.
+type Event struct {
+	Action  string
+	Outcome string
+	// ActorID is the acting user, when it differs from the user in the
+	// request context, e.g. on login. Zero means unknown.
+	ActorID    int64
+	TargetType string
+	TargetID   string
+	Details    map[string]any
+}
+
+// Recorder writes audit events. A failure to record is logged but never
+// fails the request being audited.
+type Recorder struct {
+	store  storage.AuditEvents
+	logger *slog.Logger
+	hasher *encryption.Hasher
+}
+
+func NewRecorder(store storage.AuditEvents, logger *slog.Logger, hasher *encryption.Hasher) *Recorder {
+	return &Recorder{store: store, logger: logger, hasher: hasher}
+}
+
+// EmailHash identifies an email address in event details without storing the
+// address itself: audit events are exported, the PII key is not.
+func (r *Recorder) EmailHash(email string) string {
+	return hex.EncodeToString(r.hasher.Hash(storage.NormalizeEmail(email)))
+}
+
+func (r *Recorder) Record(c *gin.Context, event Event) {
+	record := &storage.AuditEvent{
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		RequestID:  c.GetString("requestId"),
+		Outcome:    event.Outcome,
+		Details:    event.Details,
+	}
+	actorID := event.ActorID
+	if actorID == 0 {
+		actorID = c.GetInt64("userId")
+	}
+	if actorID != 0 {
+		record.ActorID = &actorID
+	}
+
+	// the event must be written even when the client has gone away
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), recordTimeout)
+	defer cancel()
+	if err := r.store.Create(ctx, record); err != nil {
+		r.logger.Error("failed to record audit event",
+			slog.String("error", err.Error()),
+			slog.String("action", record.Action),
+			slog.String("outcome", record.Outcome),
+		)
+	}
+}
@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AlexShmak/order-service/internal/encryption"
+	"github.com/AlexShmak/order-service/internal/storage"
+	storagemocks "github.com/AlexShmak/order-service/internal/storage/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func newTestRecorder(t *testing.T, logs *bytes.Buffer) (*Recorder, *storagemocks.MockAuditEvents) {
+	t.Helper()
+	hasher, err := encryption.NewHasher([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	store := storagemocks.NewMockAuditEvents(t)
+	return NewRecorder(store, slog.New(slog.NewTextHandler(logs, nil)), hasher), store
+}
+
+// newTestContext is a request from 10.0.0.1 of the user in userID, zero for
+// an anonymous request.
+func newTestContext(userID int64) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+	c.Request.RemoteAddr = "10.0.0.1:52000"
+	c.Request.Header.Set("User-Agent", "curl/8.5.0")
+	c.Set("requestId", "req-1")
+	if userID != 0 {
+		c.Set("userId", userID)
+	}
+	return c
+}
+
+func TestRecorderRecord(t *testing.T) {
+	t.Run("adds the request metadata", func(t *testing.T) {
+		recorder, store := newTestRecorder(t, &bytes.Buffer{})
+		actorID := int64(1)
+		store.EXPECT().Create(mock.Anything, &storage.AuditEvent{
+			ActorID:    &actorID,
+			Action:     ActionOrderCreate,
+			Outcome:    OutcomeSuccess,
+			TargetType: TargetOrder,
+			TargetID:   "b563feb7b2b84b6test",
+			IP:         "10.0.0.1",
+			UserAgent:  "curl/8.5.0",
+			RequestID:  "req-1",
+			Details:    map[string]any{"items": 1},
+		}).Return(nil).Once()
+
+		recorder.Record(newTestContext(1), Event{
+			Action:     ActionOrderCreate,
+			Outcome:    OutcomeSuccess,
+			TargetType: TargetOrder,
+			TargetID:   "b563feb7b2b84b6test",
+			Details:    map[string]any{"items": 1},
+		})
+	})
+
+	t.Run("prefers the actor of the event", func(t *testing.T) {
+		recorder, store := newTestRecorder(t, &bytes.Buffer{})
+		store.EXPECT().Create(mock.Anything, mock.MatchedBy(func(event *storage.AuditEvent) bool {
+			return event.ActorID != nil && *event.ActorID == 7
+		})).Return(nil).Once()
+
+		recorder.Record(newTestContext(1), Event{Action: ActionLogin, Outcome: OutcomeSuccess, ActorID: 7})
+	})
+
+	t.Run("leaves an anonymous actor empty", func(t *testing.T) {
+		recorder, store := newTestRecorder(t, &bytes.Buffer{})
+		store.EXPECT().Create(mock.Anything, mock.MatchedBy(func(event *storage.AuditEvent) bool {
+			return event.ActorID == nil
+		})).Return(nil).Once()
+
+		recorder.Record(newTestContext(0), Event{Action: ActionLogin, Outcome: OutcomeFailure})
+	})
+
+	t.Run("records after the client has gone away", func(t *testing.T) {
+		recorder, store := newTestRecorder(t, &bytes.Buffer{})
+		store.EXPECT().Create(mock.MatchedBy(func(ctx context.Context) bool {
+			_, hasDeadline := ctx.Deadline()
+			return ctx.Err() == nil && hasDeadline
+		}), mock.Anything).Return(nil).Once()
+
+		c := newTestContext(1)
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		recorder.Record(c, Event{Action: ActionLogout, Outcome: OutcomeSuccess})
+	})
+
+	t.Run("logs a failure to record", func(t *testing.T) {
+		var logs bytes.Buffer
+		recorder, store := newTestRecorder(t, &logs)
+		store.EXPECT().Create(mock.Anything, mock.Anything).Return(errors.New("connection refused")).Once()
+
+		recorder.Record(newTestContext(1), Event{Action: ActionLogout, Outcome: OutcomeSuccess})
+
+		assert.Contains(t, logs.String(), "failed to record audit event")
+		assert.Contains(t, logs.String(), "action="+ActionLogout)
+	})
+}
+
+func TestRecorderEmailHash(t *testing.T) {
+	recorder, _ := newTestRecorder(t, &bytes.Buffer{})
+
+	hash := recorder.EmailHash(" Test@Gmail.com")
+	assert.Equal(t, recorder.EmailHash("test@gmail.com"), hash, "addresses are normalized first")
+	assert.NotEqual(t, recorder.EmailHash("other@gmail.com"), hash)
+	assert.NotContains(t, hash, "test")
+}
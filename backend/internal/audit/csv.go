@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var csvHeader = []string{
+	"id", "occurred_at", "actor_id", "action", "outcome", "target_type", "target_id",
+	"ip", "user_agent", "request_id", "details",
+}
+
+// WriteCSV exports events for spreadsheets. Cells that a spreadsheet would
+// evaluate as a formula are prefixed with a quote, as user agents and emails
+// in the details are attacker controlled.
+func WriteCSV(w io.Writer, events []storage.AuditEvent) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		actorID := ""
+		if event.ActorID != nil {
+			actorID = strconv.FormatInt(*event.ActorID, 10)
+		}
+		details, err := json.Marshal(event.Details)
+		if err != nil {
+			return err
+		}
+
+		record := []string{
+			strconv.FormatInt(event.ID, 10),
+			event.OccurredAt.UTC().Format(time.RFC3339),
+			actorID,
+			event.Action,
+			event.Outcome,
+			event.TargetType,
+			event.TargetID,
+			event.IP,
+			event.UserAgent,
+			event.RequestID,
+			string(details),
+		}
+		for i := range record {
+			record[i] = escapeFormula(record[i])
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
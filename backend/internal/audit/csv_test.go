@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscapeFormula(t *testing.T) {
+	tests := map[string]string{
+		"=HYPERLINK(\"https://evil.com\")": "'=HYPERLINK(\"https://evil.com\")",
+		"+1+1":                             "'+1+1",
+		"-1+1":                             "'-1+1",
+		"@SUM(A1:A2)":                      "'@SUM(A1:A2)",
+		"\t=1+1":                           "'\t=1+1",
+		"\r=1+1":                           "'\r=1+1",
+		"":                                 "",
+		"curl/8.5.0":                       "curl/8.5.0",
+		"a=1":                              "a=1",
+	}
+	for value, want := range tests {
+		assert.Equal(t, want, escapeFormula(value), "%q", value)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	actorID := int64(7)
+	events := []storage.AuditEvent{
+		{
+			ID:         1,
+			OccurredAt: time.Date(2026, 10, 19, 12, 0, 0, 0, time.FixedZone("MSK", 3*60*60)),
+			ActorID:    &actorID,
+			Action:     ActionLogin,
+			Outcome:    OutcomeFailure,
+			TargetType: TargetUser,
+			TargetID:   "7",
+			IP:         "10.0.0.1",
+			UserAgent:  "=cmd|' /C calc'!A0",
+			RequestID:  "req-1",
+			Details:    map[string]any{"reason": "invalid password"},
+		},
+		{ID: 2, Action: ActionLogout, Outcome: OutcomeSuccess},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, events))
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, csvHeader, records[0])
+	assert.Equal(t, []string{
+		"1", "2026-10-19T09:00:00Z", "7", ActionLogin, OutcomeFailure, TargetUser, "7",
+		"10.0.0.1", "'=cmd|' /C calc'!A0", "req-1", `{"reason":"invalid password"}`,
+	}, records[1])
+	assert.Equal(t, "", records[2][2], "an unknown actor stays empty")
+}
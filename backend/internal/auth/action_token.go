@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// NewActionToken returns a random token signed with secret, and the hash that
+// should be stored instead of the token itself.
+func NewActionToken(secret string) (token string, hash []byte, err error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, fmt.Errorf("could not generate action token: %w", err)
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(nonce)
+	token = payload + "." + signActionToken(secret, payload)
+	return token, HashActionToken(token), nil
+}
+
+// VerifyActionToken checks the token signature, which lets obviously forged
+// tokens be rejected without a database lookup.
+func VerifyActionToken(secret string, token string) bool {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(signActionToken(secret, payload)))
+}
+
+func HashActionToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+func signActionToken(secret string, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
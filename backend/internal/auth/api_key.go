@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	ScopeOrdersRead  = "orders:read"
+	ScopeOrdersWrite = "orders:write"
+
+	apiKeyPrefix = "osk"
+)
+
+var APIKeyScopes = []string{ScopeOrdersRead, ScopeOrdersWrite}
+
+// GenerateAPIKey returns a new plaintext key together with its public prefix.
+// The plaintext key is only ever shown once; callers persist HashAPIKey(key).
+func GenerateAPIKey() (key string, prefix string, err error) {
+	prefixBytes := make([]byte, 4)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", "", fmt.Errorf("could not generate api key prefix: %w", err)
+	}
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", fmt.Errorf("could not generate api key secret: %w", err)
+	}
+
+	prefix = hex.EncodeToString(prefixBytes)
+	key = fmt.Sprintf("%s_%s_%s", apiKeyPrefix, prefix, base64.RawURLEncoding.EncodeToString(secretBytes))
+	return key, prefix, nil
+}
+
+func HashAPIKey(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
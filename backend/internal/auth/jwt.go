@@ -8,12 +8,22 @@ import (
 )
 
 type JWTService struct {
-	accessSecret  string
+	accessKeys    *KeySet
 	refreshSecret string
+	accessTTL     time.Duration
+	refreshTTL    time.Duration
 }
 
-func NewJWTService(accessSecret string, refreshSecret string) *JWTService {
-	return &JWTService{accessSecret: accessSecret, refreshSecret: refreshSecret}
+// NewJWTService signs access tokens with accessKeys so that other services can
+// verify them through the JWKS. Refresh tokens never leave this service and
+// stay HS256 with a shared secret.
+func NewJWTService(accessKeys *KeySet, refreshSecret string, accessTTL, refreshTTL time.Duration) *JWTService {
+	return &JWTService{
+		accessKeys:    accessKeys,
+		refreshSecret: refreshSecret,
+		accessTTL:     accessTTL,
+		refreshTTL:    refreshTTL,
+	}
 }
 
 func (s *JWTService) GenerateTokens(userId int64) (string, string, error) {
@@ -53,44 +63,40 @@ func (s *JWTService) createAccessToken(userId int64) (string, error) {
 	claims := jwt.MapClaims{
 		"sub": fmt.Sprintf("%d", userId),
 		"iat": time.Now().Unix(),
-		"exp": time.Now().Add(time.Minute * 15).Unix(),
+		"exp": time.Now().Add(s.accessTTL).Unix(),
 		"iss": "orders-service",
 		"aud": "orders-service-users",
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.accessSecret))
+	return s.accessKeys.sign(claims)
 }
 
 func (s *JWTService) createRefreshToken(userId int64) (string, error) {
 	claims := jwt.MapClaims{
 		"sub": fmt.Sprintf("%d", userId),
 		"iat": time.Now().Unix(),
-		"exp": time.Now().Add(time.Hour * 24 * 7).Unix(),
+		"exp": time.Now().Add(s.refreshTTL).Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(s.refreshSecret))
 }
 
-func (s *JWTService) validateToken(tokenString string, secret []byte) (*jwt.Token, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
+// ValidateAccessToken also returns the parsed token when validation fails, so
+// callers can still read the subject of an expired token.
+func (s *JWTService) ValidateAccessToken(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, s.accessKeys.keyFunc)
+}
+
+func (s *JWTService) ValidateRefreshToken(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return secret, nil
+		return []byte(s.refreshSecret), nil
 	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	return token, nil
 }
 
-func (s *JWTService) ValidateAccessToken(tokenString string) (*jwt.Token, error) {
-	return s.validateToken(tokenString, []byte(s.accessSecret))
-}
-func (s *JWTService) ValidateRefreshToken(tokenString string) (*jwt.Token, error) {
-	return s.validateToken(tokenString, []byte(s.refreshSecret))
+func (s *JWTService) JWKS() JWKS {
+	return s.accessKeys.JWKS()
 }
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const minRSAKeyBits = 2048
+
+type key struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   crypto.PrivateKey
+	verifyKey any
+	// public is nil for symmetric keys, which must never be published
+	public crypto.PublicKey
+}
+
+// KeySet holds the key used to sign new access tokens and every key that is
+// still accepted for verification, so that keys can be rotated without
+// invalidating tokens that were issued moments before.
+type KeySet struct {
+	signing *key
+	keys    map[string]*key
+}
+
+// NewHMACKeySet returns a key set that signs with HS256 using a shared secret.
+// It is meant for local development; its JWKS is always empty.
+func NewHMACKeySet(secret string) *KeySet {
+	k := &key{
+		id:        "hs256",
+		method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+	return &KeySet{signing: k, keys: map[string]*key{k.id: k}}
+}
+
+// LoadKeySet reads every *.pem file in dir. The file name without extension is
+// used as the key ID. Private keys (RSA or Ed25519) can sign and verify, public
+// keys are kept for verification only, which is how retired keys are phased out.
+func LoadKeySet(dir string, signingKeyID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("could not list key files: %w", err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no *.pem keys found in %s", dir)
+	}
+
+	ks := &KeySet{keys: make(map[string]*key, len(paths))}
+	var privateIDs []string
+	for _, path := range paths {
+		k, err := loadKey(path)
+		if err != nil {
+			return nil, err
+		}
+		ks.keys[k.id] = k
+		if k.signKey != nil {
+			privateIDs = append(privateIDs, k.id)
+		}
+	}
+
+	if signingKeyID == "" {
+		if len(privateIDs) != 1 {
+			return nil, fmt.Errorf("signing key ID must be set when %d private keys are present", len(privateIDs))
+		}
+		signingKeyID = privateIDs[0]
+	}
+
+	signing, ok := ks.keys[signingKeyID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q not found in %s", signingKeyID, dir)
+	}
+	if signing.signKey == nil {
+		return nil, fmt.Errorf("signing key %q is a public key", signingKeyID)
+	}
+	ks.signing = signing
+
+	return ks, nil
+}
+
+func loadKey(path string) (*key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read key file: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", path)
+	}
+
+	k := &key{id: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block type %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: could not parse key: %w", path, err)
+	}
+
+	switch v := parsed.(type) {
+	case *rsa.PrivateKey:
+		k.signKey, k.public = v, &v.PublicKey
+	case ed25519.PrivateKey:
+		k.signKey, k.public = v, v.Public()
+	case *rsa.PublicKey, ed25519.PublicKey:
+		k.public = v
+	default:
+		return nil, fmt.Errorf("%s: unsupported key type %T", path, parsed)
+	}
+
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("%s: RSA key must be at least %d bits", path, minRSAKeyBits)
+		}
+		k.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		k.method = jwt.SigningMethodEdDSA
+	}
+	k.verifyKey = k.public
+
+	return k, nil
+}
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.method, claims)
+	token.Header["kid"] = ks.signing.id
+	return token.SignedString(ks.signing.signKey)
+}
+
+func (ks *KeySet) keyFunc(token *jwt.Token) (any, error) {
+	k := ks.signing
+	if kid, ok := token.Header["kid"].(string); ok {
+		if k, ok = ks.keys[kid]; !ok {
+			return nil, fmt.Errorf("unknown key ID: %s", kid)
+		}
+	}
+	// the algorithm is bound to the key, never taken from the token alone
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return k.verifyKey, nil
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public part of every asymmetric key in the set.
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+	for _, k := range ks.keys {
+		jwk := JWK{Use: "sig", Alg: k.method.Alg(), Kid: k.id}
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePrivateKey stores key as <id>.pem in dir.
+func writePrivateKey(t *testing.T, dir, id string, key crypto.PrivateKey) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	writePEM(t, dir, id, "PRIVATE KEY", der)
+}
+
+// writePublicKey stores key as <id>.pem in dir, the way a retired key is kept.
+func writePublicKey(t *testing.T, dir, id string, key crypto.PublicKey) {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+	writePEM(t, dir, id, "PUBLIC KEY", der)
+}
+
+func writePEM(t *testing.T, dir, id, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, id+".pem"), data, 0o600))
+}
+
+func newRSAKey(t *testing.T, bits int) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	require.NoError(t, err)
+	return key
+}
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func testClaims() jwt.Claims {
+	return jwt.RegisteredClaims{Subject: "1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+}
+
+func TestLoadKeySet(t *testing.T) {
+	rsaKey, edKey := newRSAKey(t, minRSAKeyBits), newEd25519Key(t)
+
+	t.Run("signs with the only private key by default", func(t *testing.T) {
+		dir := t.TempDir()
+		writePrivateKey(t, dir, "2026-01", rsaKey)
+		writePublicKey(t, dir, "2025-06", edKey.Public())
+
+		ks, err := LoadKeySet(dir, "")
+		require.NoError(t, err)
+		assert.Equal(t, "2026-01", ks.signing.id)
+		assert.Equal(t, jwt.SigningMethodRS256, ks.signing.method)
+		assert.Equal(t, jwt.SigningMethodEdDSA, ks.keys["2025-06"].method)
+	})
+
+	invalid := map[string]struct {
+		setup        func(dir string)
+		signingKeyID string
+	}{
+		"no keys": {setup: func(string) {}},
+		"no signing key ID with several private keys": {setup: func(dir string) {
+			writePrivateKey(t, dir, "2026-01", rsaKey)
+			writePrivateKey(t, dir, "2026-06", edKey)
+		}},
+		"unknown signing key": {setup: func(dir string) {
+			writePrivateKey(t, dir, "2026-01", rsaKey)
+		}, signingKeyID: "2026-06"},
+		"public signing key": {setup: func(dir string) {
+			writePrivateKey(t, dir, "2026-01", rsaKey)
+			writePublicKey(t, dir, "2025-06", edKey.Public())
+		}, signingKeyID: "2025-06"},
+		"short RSA key": {setup: func(dir string) {
+			writePrivateKey(t, dir, "2026-01", newRSAKey(t, 1024))
+		}},
+		"not a key": {setup: func(dir string) {
+			writePEM(t, dir, "2026-01", "CERTIFICATE", []byte("not a key"))
+		}},
+	}
+	for name, tt := range invalid {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.setup(dir)
+			_, err := LoadKeySet(dir, tt.signingKeyID)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	oldKey, newKey := newRSAKey(t, minRSAKeyBits), newEd25519Key(t)
+
+	beforeDir := t.TempDir()
+	writePrivateKey(t, beforeDir, "2026-01", oldKey)
+	before, err := LoadKeySet(beforeDir, "")
+	require.NoError(t, err)
+	oldToken, err := before.sign(testClaims())
+	require.NoError(t, err)
+
+	// the new key signs, the old one is only kept to verify
+	afterDir := t.TempDir()
+	writePublicKey(t, afterDir, "2026-01", &oldKey.PublicKey)
+	writePrivateKey(t, afterDir, "2026-06", newKey)
+	after, err := LoadKeySet(afterDir, "2026-06")
+	require.NoError(t, err)
+	newToken, err := after.sign(testClaims())
+	require.NoError(t, err)
+
+	t.Run("accepts tokens of the retired key", func(t *testing.T) {
+		token, err := jwt.Parse(oldToken, after.keyFunc)
+		require.NoError(t, err)
+		assert.Equal(t, "2026-01", token.Header["kid"])
+	})
+
+	t.Run("signs with the new key", func(t *testing.T) {
+		token, err := jwt.Parse(newToken, after.keyFunc)
+		require.NoError(t, err)
+		assert.Equal(t, "2026-06", token.Header["kid"])
+		assert.Equal(t, jwt.SigningMethodEdDSA.Alg(), token.Method.Alg())
+	})
+
+	t.Run("rejects a token of a key dropped from the set", func(t *testing.T) {
+		_, err := jwt.Parse(newToken, before.keyFunc)
+		assert.ErrorContains(t, err, "unknown key ID")
+	})
+}
+
+func TestKeyFunc(t *testing.T) {
+	rsaKey := newRSAKey(t, minRSAKeyBits)
+	dir := t.TempDir()
+	writePrivateKey(t, dir, "2026-01", rsaKey)
+	ks, err := LoadKeySet(dir, "")
+	require.NoError(t, err)
+
+	t.Run("rejects an HMAC token signed with the public RSA key", func(t *testing.T) {
+		// the published key must not double as a shared secret
+		der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+		require.NoError(t, err)
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+		forged.Header["kid"] = "2026-01"
+		signed, err := forged.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+		require.NoError(t, err)
+
+		_, err = jwt.Parse(signed, ks.keyFunc)
+		assert.ErrorContains(t, err, "unexpected signing method")
+	})
+
+	t.Run("rejects an unknown key ID", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, testClaims())
+		token.Header["kid"] = "2025-06"
+		signed, err := token.SignedString(rsaKey)
+		require.NoError(t, err)
+
+		_, err = jwt.Parse(signed, ks.keyFunc)
+		assert.ErrorContains(t, err, "unknown key ID")
+	})
+
+	t.Run("rejects a token signed by another key", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, testClaims())
+		token.Header["kid"] = "2026-01"
+		signed, err := token.SignedString(newRSAKey(t, minRSAKeyBits))
+		require.NoError(t, err)
+
+		_, err = jwt.Parse(signed, ks.keyFunc)
+		assert.ErrorIs(t, err, jwt.ErrTokenSignatureInvalid)
+	})
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey, edKey := newRSAKey(t, minRSAKeyBits), newEd25519Key(t)
+	dir := t.TempDir()
+	writePrivateKey(t, dir, "2026-06", edKey)
+	writePublicKey(t, dir, "2026-01", &rsaKey.PublicKey)
+	ks, err := LoadKeySet(dir, "2026-06")
+	require.NoError(t, err)
+
+	set := ks.JWKS()
+	require.Len(t, set.Keys, 2)
+
+	rsaJWK := set.Keys[0]
+	assert.Equal(t, JWK{Kty: "RSA", Use: "sig", Alg: "RS256", Kid: "2026-01", N: rsaJWK.N, E: "AQAB"}, rsaJWK)
+	n, err := base64.RawURLEncoding.DecodeString(rsaJWK.N)
+	require.NoError(t, err)
+	assert.Zero(t, new(big.Int).SetBytes(n).Cmp(rsaKey.N))
+
+	edJWK := set.Keys[1]
+	assert.Equal(t, JWK{Kty: "OKP", Use: "sig", Alg: "EdDSA", Kid: "2026-06", Crv: "Ed25519", X: edJWK.X}, edJWK)
+	x, err := base64.RawURLEncoding.DecodeString(edJWK.X)
+	require.NoError(t, err)
+	assert.Equal(t, []byte(edKey.Public().(ed25519.PublicKey)), x)
+
+	assert.Empty(t, NewHMACKeySet("secret").JWKS().Keys, "a shared secret is never published")
+}
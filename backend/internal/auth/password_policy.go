@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// bcryptMaxBytes is the length after which bcrypt silently ignores the rest of a password.
+const bcryptMaxBytes = 72
+
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	// breached holds upper-case hex SHA-1 hashes of known breached passwords
+	breached map[string]struct{}
+}
+
+// NewPasswordPolicy loads the breached password list from breachedPath when it
+// is not empty. Each line is either a plaintext password or a SHA-1 hash in the
+// Have I Been Pwned format ("HASH" or "HASH:COUNT").
+func NewPasswordPolicy(minLength, maxLength int, breachedPath string) (*PasswordPolicy, error) {
+	if maxLength <= 0 || maxLength > bcryptMaxBytes {
+		maxLength = bcryptMaxBytes
+	}
+	if minLength > maxLength {
+		return nil, fmt.Errorf("password min length %d is greater than max length %d", minLength, maxLength)
+	}
+
+	policy := &PasswordPolicy{MinLength: minLength, MaxLength: maxLength, breached: make(map[string]struct{})}
+	if breachedPath == "" {
+		return policy, nil
+	}
+
+	f, err := os.Open(breachedPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open breached password list: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if hash, _, _ := strings.Cut(line, ":"); isSHA1Hex(hash) {
+			policy.breached[strings.ToUpper(hash)] = struct{}{}
+			continue
+		}
+		policy.breached[sha1Hex(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read breached password list: %w", err)
+	}
+
+	return policy, nil
+}
+
+var (
+	ErrPasswordTooShort = errors.New("password is too short")
+	ErrPasswordTooLong  = errors.New("password is too long")
+	ErrPasswordBreached = errors.New("password has appeared in a data breach, please choose another one")
+)
+
+// Validate returns an error whose message can be shown to the user as is.
+func (p *PasswordPolicy) Validate(password string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrPasswordTooShort, p.MinLength)
+	}
+	if len(password) > p.MaxLength {
+		return fmt.Errorf("%w: must be at most %d bytes", ErrPasswordTooLong, p.MaxLength)
+	}
+	if _, ok := p.breached[sha1Hex(password)]; ok {
+		return ErrPasswordBreached
+	}
+	return nil
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func isSHA1Hex(s string) bool {
+	if len(s) != 2*sha1.Size {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
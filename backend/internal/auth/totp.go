@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"github.com/AlexShmak/order-service/internal/encryption"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RFC 6238 parameters understood by every common authenticator app.
+const (
+	totpDigits     = 6
+	totpPeriod     = 30 * time.Second
+	totpSecretSize = 20
+	// totpSkew is the number of periods accepted before and after the current one
+	totpSkew = 1
+
+	recoveryCodeCount = 10
+	recoveryCodeBytes = 5
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPService generates and verifies TOTP codes and keeps the shared secrets
+// encrypted while they are at rest.
+type TOTPService struct {
+	cipher *encryption.Cipher
+	issuer string
+}
+
+func NewTOTPService(cipher *encryption.Cipher, issuer string) *TOTPService {
+	return &TOTPService{cipher: cipher, issuer: issuer}
+}
+
+func (s *TOTPService) GenerateSecret() ([]byte, error) {
+	secret := make([]byte, totpSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("could not generate TOTP secret: %w", err)
+	}
+	return secret, nil
+}
+
+func EncodeTOTPSecret(secret []byte) string {
+	return base32NoPadding.EncodeToString(secret)
+}
+
+// ProvisioningURI returns the otpauth:// URI that authenticator apps read from a QR code.
+func (s *TOTPService) ProvisioningURI(secret []byte, accountName string) string {
+	label := url.PathEscape(s.issuer) + ":" + url.PathEscape(accountName)
+	query := url.Values{}
+	query.Set("secret", EncodeTOTPSecret(secret))
+	query.Set("issuer", s.issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", strconv.Itoa(totpDigits))
+	query.Set("period", strconv.Itoa(int(totpPeriod.Seconds())))
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// Verify returns the time step the code belongs to. Callers must persist the
+// step and reject codes for steps that were already used, so that a code
+// cannot be replayed within its validity window.
+func (s *TOTPService) Verify(secret []byte, code string, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := now.Unix() / int64(totpPeriod.Seconds())
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func totpCode(secret []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation as defined in RFC 4226
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for range totpDigits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+// EncryptSecret binds the encrypted secret to its user.
+func (s *TOTPService) EncryptSecret(secret []byte, userID int64) ([]byte, error) {
+	return s.cipher.Encrypt(secret, userIDBytes(userID))
+}
+
+func (s *TOTPService) DecryptSecret(encrypted []byte, userID int64) ([]byte, error) {
+	return s.cipher.Decrypt(encrypted, userIDBytes(userID))
+}
+
+// GenerateRecoveryCodes returns codes to show to the user once and the hashes to store.
+func GenerateRecoveryCodes() ([]string, [][]byte, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([][]byte, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		raw := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, fmt.Errorf("could not generate recovery code: %w", err)
+		}
+		encoded := strings.ToLower(base32NoPadding.EncodeToString(raw))
+		code := encoded[:4] + "-" + encoded[4:]
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode ignores case, dashes and spaces, so codes can be typed loosely.
+func HashRecoveryCode(code string) []byte {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return sum[:]
+}
+
+func userIDBytes(userID int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(userID))
+}
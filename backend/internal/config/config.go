@@ -3,7 +3,10 @@ package config
 import (
 	"fmt"
 	"github.com/joho/godotenv"
+	"net/http"
+	"os"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
@@ -13,9 +16,125 @@ type Config struct {
 	Environment string `env:"ENV" env-default:"local"`
 	Database    DatabaseConfig
 	Server      ServerConfig
+	Cookies     CookieConfig
+	CORS        CORSConfig
 	JWT         JWT
 	Kafka       KafkaConfig
 	Redis       RedisConfig
+	BruteForce  BruteForceConfig
+	RateLimit   RateLimitConfig
+	Mailer      MailerConfig
+	Password    PasswordConfig
+	TOTP        TOTPConfig
+	OIDC        OIDCConfig
+	PII         PIIConfig
+}
+
+type OIDCConfig struct {
+	// Enabled turns on single sign-on for staff under /auth/sso
+	Enabled      bool     `env:"OIDC_ENABLED" env-default:"false"`
+	IssuerURL    string   `env:"OIDC_ISSUER_URL"`
+	ClientID     string   `env:"OIDC_CLIENT_ID"`
+	ClientSecret string   `env:"OIDC_CLIENT_SECRET"`
+	RedirectURL  string   `env:"OIDC_REDIRECT_URL" env-default:"http://localhost:8080/auth/sso/callback"`
+	Scopes       []string `env:"OIDC_SCOPES" env-default:"openid,email,profile"`
+	// RoleClaim names the ID token claim holding the user's groups or roles
+	RoleClaim string `env:"OIDC_ROLE_CLAIM" env-default:"groups"`
+	// RoleMapping maps values of RoleClaim to roles, e.g. "support-agents:support,admins:admin"
+	RoleMapping map[string]string `env:"OIDC_ROLE_MAPPING"`
+}
+
+type CORSConfig struct {
+	// AllowedOrigins may contain wildcard subdomains, e.g. https://*.example.com
+	AllowedOrigins []string      `env:"CORS_ALLOWED_ORIGINS" env-default:"http://localhost:3000,http://127.0.0.1:3000,http://localhost:8081"`
+	AllowedMethods []string      `env:"CORS_ALLOWED_METHODS" env-default:"GET,POST,PUT,PATCH,DELETE,OPTIONS"`
+	AllowedHeaders []string      `env:"CORS_ALLOWED_HEADERS" env-default:"Origin,Content-Type,Accept,Authorization,X-API-Key,X-CSRF-Token"`
+	MaxAge         time.Duration `env:"CORS_MAX_AGE" env-default:"12h"`
+}
+
+type CookieConfig struct {
+	// Secure must be enabled whenever the service is served over HTTPS
+	Secure bool   `env:"COOKIE_SECURE" env-default:"false"`
+	Domain string `env:"COOKIE_DOMAIN"`
+	// SameSite is one of lax, strict or none
+	SameSite string `env:"COOKIE_SAME_SITE" env-default:"lax"`
+}
+
+func (c CookieConfig) SameSiteMode() http.SameSite {
+	switch c.SameSite {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+type TOTPConfig struct {
+	// EncryptionKey is a base64 encoded 32-byte key for TOTP secrets at rest
+	EncryptionKey string `env:"TOTP_ENCRYPTION_KEY" env-required:"true"`
+	Issuer        string `env:"TOTP_ISSUER" env-default:"Orders Service"`
+}
+
+type PIIConfig struct {
+	// Keys are base64 encoded 32-byte key encryption keys by ID, e.g. "2025-01:<key>,2025-07:<key>"
+	Keys map[string]string `env:"PII_KEYS"`
+	// KeyringFile holds one "<id>:<key>" per line and is used instead of Keys when set
+	KeyringFile string `env:"PII_KEYRING_FILE"`
+	// ActiveKeyID encrypts new data, the other keys only decrypt until rotated out
+	ActiveKeyID string `env:"PII_ACTIVE_KEY_ID"`
+	// HashKey is a base64 encoded 32-byte key for the email lookup hash.
+	// Changing it breaks lookups of every existing row.
+	HashKey string `env:"PII_HASH_KEY" env-required:"true"`
+}
+
+type PasswordConfig struct {
+	MinLength int `env:"PASSWORD_MIN_LENGTH" env-default:"8"`
+	// MaxLength is capped at 72 bytes, the bcrypt input limit
+	MaxLength int `env:"PASSWORD_MAX_LENGTH" env-default:"72"`
+	// BreachedListPath points to a file with one breached password or SHA-1 hash per line
+	BreachedListPath string `env:"BREACHED_PASSWORDS_FILE"`
+}
+
+type MailerConfig struct {
+	// Driver is one of smtp, file or stdout
+	Driver       string `env:"MAILER_DRIVER" env-default:"stdout"`
+	From         string `env:"MAIL_FROM" env-default:"no-reply@orders-service.local"`
+	FilePath     string `env:"MAILER_FILE_PATH" env-default:"mail.log"`
+	SMTPHost     string `env:"SMTP_HOST"`
+	SMTPPort     string `env:"SMTP_PORT" env-default:"587"`
+	SMTPUsername string `env:"SMTP_USERNAME"`
+	SMTPPassword string `env:"SMTP_PASSWORD"`
+}
+
+// RateLimitConfig holds the token bucket limits per route group. A limit is
+// the allowed burst; the bucket refills at that many requests per window.
+type RateLimitConfig struct {
+	// Backend is memory for a single instance or redis for several
+	Backend string `env:"RATE_LIMIT_BACKEND" env-default:"redis"`
+	// API applies to every authenticated /api route
+	APIPerUser int           `env:"RATE_LIMIT_API_PER_USER" env-default:"300"`
+	APIPerIP   int           `env:"RATE_LIMIT_API_PER_IP" env-default:"600"`
+	APIWindow  time.Duration `env:"RATE_LIMIT_API_WINDOW" env-default:"1m"`
+	// Orders applies to order creation on /api and /partner. OrdersGlobal caps
+	// all clients together, protecting the Kafka topic behind it.
+	OrdersPerUser int           `env:"RATE_LIMIT_ORDERS_PER_USER" env-default:"10"`
+	OrdersPerIP   int           `env:"RATE_LIMIT_ORDERS_PER_IP" env-default:"30"`
+	OrdersGlobal  int           `env:"RATE_LIMIT_ORDERS_GLOBAL" env-default:"1000"`
+	OrdersWindow  time.Duration `env:"RATE_LIMIT_ORDERS_WINDOW" env-default:"1m"`
+}
+
+type BruteForceConfig struct {
+	LoginPerIP         int           `env:"LOGIN_LIMIT_PER_IP" env-default:"30"`
+	LoginPerAccount    int           `env:"LOGIN_LIMIT_PER_ACCOUNT" env-default:"10"`
+	LoginWindow        time.Duration `env:"LOGIN_LIMIT_WINDOW" env-default:"1m"`
+	RegisterPerIP      int           `env:"REGISTER_LIMIT_PER_IP" env-default:"10"`
+	RegisterWindow     time.Duration `env:"REGISTER_LIMIT_WINDOW" env-default:"1h"`
+	LockoutThreshold   int           `env:"LOCKOUT_THRESHOLD" env-default:"5"`
+	LockoutWindow      time.Duration `env:"LOCKOUT_WINDOW" env-default:"15m"`
+	LockoutBase        time.Duration `env:"LOCKOUT_BASE_DURATION" env-default:"1m"`
+	LockoutMaxDuration time.Duration `env:"LOCKOUT_MAX_DURATION" env-default:"1h"`
 }
 
 type RedisConfig struct {
@@ -27,25 +146,61 @@ type RedisConfig struct {
 type KafkaConfig struct {
 	Brokers []string `env:"KAFKA_BROKERS" env-required:"true"`
 	Topic   string   `env:"KAFKA_TOPIC" env-required:"true"`
+	// OrderEventVersion is the order.created payload version the API publishes,
+	// it stays on the old one until every worker reads the new one
+	OrderEventVersion int `env:"KAFKA_ORDER_EVENT_VERSION" env-default:"2"`
+	// Encoding is json or protobuf, the worker reads both as long as a schema
+	// registry is set, so it is switched to protobuf once every worker has one
+	Encoding string `env:"KAFKA_ENCODING" env-default:"json"`
+	// SchemaRegistryURL takes precedence over SchemaRegistryFile, a local
+	// stand-in for development
+	SchemaRegistryURL  string `env:"SCHEMA_REGISTRY_URL"`
+	SchemaRegistryFile string `env:"SCHEMA_REGISTRY_FILE"`
+	// WorkerConcurrency is the number of messages the worker processes at once
+	// per partition, messages of the same order are still processed in turn
+	WorkerConcurrency int `env:"KAFKA_WORKER_CONCURRENCY" env-default:"8"`
+	// WorkerBatchSize above 1 stores up to that many orders per transaction,
+	// each batch waits at most WorkerBatchWindow to fill up
+	WorkerBatchSize   int           `env:"KAFKA_WORKER_BATCH_SIZE" env-default:"1"`
+	WorkerBatchWindow time.Duration `env:"KAFKA_WORKER_BATCH_WINDOW" env-default:"50ms"`
+	// Idempotent producers have the broker drop the duplicates of retries
+	Idempotent bool `env:"KAFKA_IDEMPOTENT" env-default:"true"`
+	// TransactionalID makes the worker's producers transactional, every one
+	// of them appends its own suffix to it. The worker then emits its events
+	// and commits the offsets they result from in one transaction
+	TransactionalID string `env:"KAFKA_TRANSACTIONAL_ID"`
+	// StoredTopic receives an order.stored event for every order the worker
+	// stores, no events are emitted when it is empty
+	StoredTopic string `env:"KAFKA_STORED_TOPIC"`
 }
 
 type JWT struct {
-	AccessSecret  string `env:"ACCESS_SECRET" env-required:"true"`
+	// AccessSecret is only used for HS256 access tokens when KeysDir is empty
+	AccessSecret  string `env:"ACCESS_SECRET"`
 	RefreshSecret string `env:"REFRESH_SECRET" env-required:"true"`
+	KeysDir       string `env:"JWT_KEYS_DIR"`
+	SigningKeyID  string `env:"JWT_SIGNING_KEY_ID"`
+	// ActionTokenSecret signs email verification and password reset tokens
+	ActionTokenSecret string `env:"ACTION_TOKEN_SECRET" env-required:"true"`
+	// AccessTokenTTL and RefreshTokenTTL are also the lifetimes of the cookies holding the tokens
+	AccessTokenTTL  time.Duration `env:"ACCESS_TOKEN_TTL" env-default:"15m"`
+	RefreshTokenTTL time.Duration `env:"REFRESH_TOKEN_TTL" env-default:"168h"`
 }
 
 type DatabaseConfig struct {
-	Host            string        `env:"DB_HOST" env-default:"localhost"`
-	Port            string        `env:"DB_PORT" env-default:"5432"`
-	User            string        `env:"DB_USER" env-required:"true"`
-	Password        string        `env:"DB_PASSWORD" env-required:"true"`
-	DBName          string        `env:"DB_NAME" env-required:"true"`
-	SSLMode         string        `env:"SSL_MODE" env-default:"disable"`
-	MaxOpenConns    int           `env:"MAX_OPEN_CONNS" env-default:"25"`
-	MaxIdleConns    int           `env:"MAX_IDLE_CONNS" env-default:"25"`
-	ConnMaxLifetime time.Duration `env:"CONN_MAX_LIFETIME" env-default:"5m"`
-	ConnMaxIdleTime time.Duration `env:"CONN_MAX_IDLE_TIME" env-default:"1m"`
-	URL             string        `env:"PGSQL_URL" env-required:"true"`
+	Host     string `env:"DB_HOST" env-default:"localhost"`
+	Port     string `env:"DB_PORT" env-default:"5432"`
+	User     string `env:"DB_USER" env-required:"true"`
+	Password string `env:"DB_PASSWORD" env-required:"true"`
+	DBName   string `env:"DB_NAME" env-required:"true"`
+	SSLMode  string `env:"SSL_MODE" env-default:"disable"`
+	MaxConns int32  `env:"DB_MAX_CONNS" env-default:"25"`
+	// MinConns are kept open even when idle, so that bursts do not wait for new connections
+	MinConns          int32         `env:"DB_MIN_CONNS" env-default:"2"`
+	ConnMaxLifetime   time.Duration `env:"DB_CONN_MAX_LIFETIME" env-default:"5m"`
+	ConnMaxIdleTime   time.Duration `env:"DB_CONN_MAX_IDLE_TIME" env-default:"1m"`
+	HealthCheckPeriod time.Duration `env:"DB_HEALTH_CHECK_PERIOD" env-default:"1m"`
+	URL               string        `env:"PGSQL_URL" env-required:"true"`
 }
 
 type ServerConfig struct {
@@ -53,6 +208,23 @@ type ServerConfig struct {
 	Host         string        `env:"SERVER_HOST" env-default:"localhost"`
 	ReadTimeout  time.Duration `env:"READ_TIMEOUT" env-default:"30s"`
 	WriteTimeout time.Duration `env:"WRITE_TIMEOUT" env-default:"30s"`
+	// MaxBodyBytes caps every request body; larger requests are rejected with 413
+	MaxBodyBytes int64 `env:"MAX_BODY_BYTES" env-default:"1048576"`
+	// FrontendURL is the base for links sent by email
+	FrontendURL string `env:"FRONTEND_URL" env-default:"http://localhost:3000"`
+}
+
+// renamedEnv maps pool settings of the database/sql days, under the names
+// both the old config and the old example env used, to their replacement.
+// Idle connections do not translate one to one to pgxpool, so a setting under
+// an old name fails the start instead of being silently ignored.
+var renamedEnv = map[string]string{
+	"MAX_OPEN_CONNS":     "DB_MAX_CONNS",
+	"DB_MAX_OPEN_CONNS":  "DB_MAX_CONNS",
+	"MAX_IDLE_CONNS":     "DB_MIN_CONNS",
+	"DB_MAX_IDLE_CONNS":  "DB_MIN_CONNS",
+	"CONN_MAX_LIFETIME":  "DB_CONN_MAX_LIFETIME",
+	"CONN_MAX_IDLE_TIME": "DB_CONN_MAX_IDLE_TIME",
 }
 
 func LoadConfig() (*Config, error) {
@@ -62,6 +234,10 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("error loading .env file: %w", err)
 	}
 
+	if err := checkRenamedEnv(); err != nil {
+		return nil, err
+	}
+
 	if err := cleanenv.ReadEnv(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
@@ -73,6 +249,15 @@ func LoadConfig() (*Config, error) {
 	return &cfg, nil
 }
 
+func checkRenamedEnv() error {
+	for old, replacement := range renamedEnv {
+		if _, ok := os.LookupEnv(old); ok {
+			return fmt.Errorf("%s is no longer supported, set %s instead", old, replacement)
+		}
+	}
+	return nil
+}
+
 // Basic custom validation for config
 func (c *Config) validate() error {
 	validEnvironments := []string{"local", "dev", "prod"}
@@ -86,14 +271,150 @@ func (c *Config) validate() error {
 		return fmt.Errorf("invalid SSL mode: %s, must be one of %v", c.Database.SSLMode, validSSLModes)
 	}
 
-	if c.Database.MaxOpenConns <= 0 {
-		return fmt.Errorf("max open connections must be positive, got: %d", c.Database.MaxOpenConns)
+	if c.Server.MaxBodyBytes <= 0 {
+		return fmt.Errorf("max body size must be positive, got: %d", c.Server.MaxBodyBytes)
+	}
+
+	if c.Database.MaxConns <= 0 {
+		return fmt.Errorf("max connections must be positive, got: %d", c.Database.MaxConns)
+	}
+
+	if c.Database.MinConns < 0 || c.Database.MinConns > c.Database.MaxConns {
+		return fmt.Errorf("min connections must be between 0 and %d, got: %d", c.Database.MaxConns, c.Database.MinConns)
+	}
+
+	if c.BruteForce.LoginPerIP <= 0 || c.BruteForce.LoginPerAccount <= 0 || c.BruteForce.RegisterPerIP <= 0 {
+		return fmt.Errorf("login and register limits must be positive")
+	}
+
+	validRateLimitBackends := []string{"memory", "redis"}
+	if !slices.Contains(validRateLimitBackends, c.RateLimit.Backend) {
+		return fmt.Errorf("invalid rate limit backend: %s, must be one of %v", c.RateLimit.Backend, validRateLimitBackends)
+	}
+
+	rl := c.RateLimit
+	if rl.APIPerUser <= 0 || rl.APIPerIP <= 0 || rl.OrdersPerUser <= 0 || rl.OrdersPerIP <= 0 || rl.OrdersGlobal <= 0 {
+		return fmt.Errorf("rate limits must be positive")
+	}
+	if rl.APIWindow <= 0 || rl.OrdersWindow <= 0 {
+		return fmt.Errorf("rate limit windows must be positive")
+	}
+
+	if c.BruteForce.LockoutThreshold <= 0 {
+		return fmt.Errorf("lockout threshold must be positive, got: %d", c.BruteForce.LockoutThreshold)
 	}
 
-	if c.Database.MaxIdleConns <= 0 {
-		return fmt.Errorf("max idle connections must be positive, got: %d", c.Database.MaxIdleConns)
+	if c.Password.MinLength <= 0 {
+		return fmt.Errorf("password min length must be positive, got: %d", c.Password.MinLength)
 	}
 
+	validMailerDrivers := []string{"smtp", "file", "stdout"}
+	if !slices.Contains(validMailerDrivers, c.Mailer.Driver) {
+		return fmt.Errorf("invalid mailer driver: %s, must be one of %v", c.Mailer.Driver, validMailerDrivers)
+	}
+
+	if c.Mailer.Driver == "smtp" && c.Mailer.SMTPHost == "" {
+		return fmt.Errorf("SMTP_HOST must be set for the smtp mailer driver")
+	}
+
+	if c.JWT.KeysDir == "" && c.JWT.AccessSecret == "" {
+		return fmt.Errorf("either JWT_KEYS_DIR or ACCESS_SECRET must be set")
+	}
+
+	if c.TOTP.EncryptionKey == "" {
+		return fmt.Errorf("TOTP_ENCRYPTION_KEY must be set")
+	}
+
+	if len(c.PII.Keys) == 0 && c.PII.KeyringFile == "" {
+		return fmt.Errorf("either PII_KEYS or PII_KEYRING_FILE must be set")
+	}
+
+	if c.JWT.AccessTokenTTL <= 0 || c.JWT.RefreshTokenTTL <= 0 {
+		return fmt.Errorf("token lifetimes must be positive")
+	}
+
+	if c.OIDC.Enabled {
+		if c.OIDC.IssuerURL == "" || c.OIDC.ClientID == "" || c.OIDC.RedirectURL == "" {
+			return fmt.Errorf("OIDC_ISSUER_URL, OIDC_CLIENT_ID and OIDC_REDIRECT_URL must be set when OIDC is enabled")
+		}
+		if len(c.OIDC.RoleMapping) == 0 {
+			return fmt.Errorf("OIDC_ROLE_MAPPING must map at least one claim value to a role")
+		}
+		// single sign-on is for staff only, customers register with a password
+		validStaffRoles := []string{"support", "admin"}
+		for claimValue, role := range c.OIDC.RoleMapping {
+			if !slices.Contains(validStaffRoles, role) {
+				return fmt.Errorf("invalid role %s for %s in OIDC_ROLE_MAPPING, must be one of %v", role, claimValue, validStaffRoles)
+			}
+		}
+	}
+
+	if len(c.CORS.AllowedOrigins) == 0 {
+		return fmt.Errorf("at least one CORS origin must be allowed")
+	}
+	for _, origin := range c.CORS.AllowedOrigins {
+		if err := validateOrigin(origin); err != nil {
+			return err
+		}
+	}
+
+	validSameSiteModes := []string{"lax", "strict", "none"}
+	if !slices.Contains(validSameSiteModes, c.Cookies.SameSite) {
+		return fmt.Errorf("invalid cookie SameSite mode: %s, must be one of %v", c.Cookies.SameSite, validSameSiteModes)
+	}
+
+	// browsers drop SameSite=None cookies without the Secure attribute
+	if c.Cookies.SameSite == "none" && !c.Cookies.Secure {
+		return fmt.Errorf("COOKIE_SECURE must be enabled for SameSite=none cookies")
+	}
+
+	if c.Kafka.OrderEventVersion < 1 || c.Kafka.OrderEventVersion > 2 {
+		return fmt.Errorf("invalid order event version: %d, must be 1 or 2", c.Kafka.OrderEventVersion)
+	}
+
+	if c.Kafka.WorkerConcurrency <= 0 {
+		return fmt.Errorf("worker concurrency must be positive, got: %d", c.Kafka.WorkerConcurrency)
+	}
+
+	if c.Kafka.WorkerBatchSize <= 0 || c.Kafka.WorkerBatchWindow <= 0 {
+		return fmt.Errorf("worker batch size and window must be positive")
+	}
+
+	// the worker would consume its own events
+	if c.Kafka.StoredTopic != "" && c.Kafka.StoredTopic == c.Kafka.Topic {
+		return fmt.Errorf("KAFKA_STORED_TOPIC must differ from KAFKA_TOPIC")
+	}
+
+	validEncodings := []string{"json", "protobuf"}
+	if !slices.Contains(validEncodings, c.Kafka.Encoding) {
+		return fmt.Errorf("invalid kafka encoding: %s, must be one of %v", c.Kafka.Encoding, validEncodings)
+	}
+
+	if c.Kafka.Encoding == "protobuf" && c.Kafka.SchemaRegistryURL == "" && c.Kafka.SchemaRegistryFile == "" {
+		return fmt.Errorf("either SCHEMA_REGISTRY_URL or SCHEMA_REGISTRY_FILE must be set for the protobuf encoding")
+	}
+
+	if c.Environment == "prod" && !c.Cookies.Secure {
+		return fmt.Errorf("COOKIE_SECURE must be enabled in prod")
+	}
+
+	return nil
+}
+
+// validateOrigin only allows a wildcard as the leftmost label of the host.
+// Anything broader would expose the cookie authenticated API to any site.
+func validateOrigin(origin string) error {
+	scheme, host, ok := strings.Cut(origin, "://")
+	if !ok || (scheme != "http" && scheme != "https") || host == "" {
+		return fmt.Errorf("invalid CORS origin: %s, must be http(s)://host[:port]", origin)
+	}
+	if !strings.Contains(host, "*") {
+		return nil
+	}
+	rest, ok := strings.CutPrefix(host, "*.")
+	if !ok || strings.Contains(rest, "*") || !strings.Contains(rest, ".") {
+		return fmt.Errorf("invalid CORS origin: %s, a wildcard is only allowed as a subdomain, e.g. https://*.example.com", origin)
+	}
 	return nil
 }
 
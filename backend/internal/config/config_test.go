@@ -0,0 +1,52 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateOrigin(t *testing.T) {
+	valid := []string{
+		"http://localhost:3000",
+		"https://example.com",
+		"https://*.example.com",
+		"https://*.example.com:8443",
+	}
+	for _, origin := range valid {
+		t.Run(origin, func(t *testing.T) {
+			assert.NoError(t, validateOrigin(origin))
+		})
+	}
+
+	invalid := []string{
+		"*",
+		"example.com",
+		"ftp://example.com",
+		"https://",
+		"https://*",
+		"https://*.com",
+		"https://*.*.com",
+		"https://api.*.example.com",
+		"https://*example.com",
+		"https://example.*",
+	}
+	for _, origin := range invalid {
+		t.Run(origin, func(t *testing.T) {
+			assert.Error(t, validateOrigin(origin))
+		})
+	}
+}
+
+func TestCheckRenamedEnv(t *testing.T) {
+	assert.NoError(t, checkRenamedEnv())
+
+	for old, replacement := range renamedEnv {
+		t.Run(old, func(t *testing.T) {
+			t.Setenv(old, "10")
+			err := checkRenamedEnv()
+			assert.ErrorContains(t, err, old)
+			assert.ErrorContains(t, err, replacement)
+		})
+	}
+}
@@ -2,29 +2,38 @@ package db
 
 import (
 	"context"
-	"database/sql"
+	"fmt"
 	"time"
 
 	"github.com/AlexShmak/order-service/internal/config"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func Connect(config *config.Config) (*sql.DB, error) {
-	db, err := sql.Open("postgres", config.GetPostgresDSN())
+func Connect(config *config.Config) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(config.GetPostgresDSN())
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid database config: %w", err)
 	}
 
-	db.SetMaxOpenConns(config.Database.MaxOpenConns)
-	db.SetMaxIdleConns(config.Database.MaxIdleConns)
-	db.SetConnMaxLifetime(config.Database.ConnMaxLifetime)
-	db.SetConnMaxIdleTime(config.Database.ConnMaxIdleTime)
+	poolConfig.MaxConns = config.Database.MaxConns
+	poolConfig.MinConns = config.Database.MinConns
+	poolConfig.MaxConnLifetime = config.Database.ConnMaxLifetime
+	// spreads reconnects out, so that the whole pool is not recycled at once
+	poolConfig.MaxConnLifetimeJitter = config.Database.ConnMaxLifetime / 10
+	poolConfig.MaxConnIdleTime = config.Database.ConnMaxIdleTime
+	poolConfig.HealthCheckPeriod = config.Database.HealthCheckPeriod
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if err = db.PingContext(ctx); err != nil {
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err = pool.Ping(ctx); err != nil {
+		pool.Close()
 		return nil, err
 	}
 
-	return db, nil
+	return pool, nil
 }
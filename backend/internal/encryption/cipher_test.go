@@ -0,0 +1,62 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, KeySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return key
+}
+
+func encodeKey(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func TestCipher(t *testing.T) {
+	c, err := NewCipher(newTestKey(t))
+	require.NoError(t, err)
+
+	ciphertext, err := c.Encrypt([]byte("Ivan Petrov"), []byte("delivery:1"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(ciphertext), "Ivan Petrov")
+
+	again, err := c.Encrypt([]byte("Ivan Petrov"), []byte("delivery:1"))
+	require.NoError(t, err)
+	assert.NotEqual(t, ciphertext, again, "every encryption uses a fresh nonce")
+
+	plaintext, err := c.Decrypt(ciphertext, []byte("delivery:1"))
+	require.NoError(t, err)
+	assert.Equal(t, "Ivan Petrov", string(plaintext))
+
+	_, err = c.Decrypt(ciphertext, []byte("delivery:2"))
+	assert.Error(t, err, "a value copied to another row must not decrypt")
+
+	tampered := append([]byte(nil), ciphertext...)
+	tampered[len(tampered)-1] ^= 1
+	_, err = c.Decrypt(tampered, []byte("delivery:1"))
+	assert.Error(t, err)
+
+	_, err = c.Decrypt([]byte{1, 2, 3}, nil)
+	assert.ErrorIs(t, err, ErrMalformedCiphertext)
+}
+
+func TestParseKey(t *testing.T) {
+	key := newTestKey(t)
+	parsed, err := ParseKey(encodeKey(key))
+	require.NoError(t, err)
+	assert.Equal(t, key, parsed)
+
+	_, err = ParseKey("not base64!")
+	assert.Error(t, err)
+	_, err = ParseKey(encodeKey(key[:16]))
+	assert.Error(t, err)
+}
@@ -0,0 +1,28 @@
+package encryption
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+)
+
+// Hasher computes a keyed deterministic hash (HMAC-SHA256) of a value, so
+// that an encrypted column can still be looked up by equality. Without the
+// key the hash cannot be brute-forced from a list of known values.
+type Hasher struct {
+	key []byte
+}
+
+func NewHasher(key []byte) (*Hasher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("hash key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return &Hasher{key: key}, nil
+}
+
+// Hash does not normalize the value, callers have to do that first.
+func (h *Hasher) Hash(value string) []byte {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,25 @@
+package encryption
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasher(t *testing.T) {
+	hasher, err := NewHasher(newTestKey(t))
+	require.NoError(t, err)
+
+	hash := hasher.Hash("ivan.petrov@example.com")
+	assert.Len(t, hash, 32)
+	assert.Equal(t, hash, hasher.Hash("ivan.petrov@example.com"), "lookups need the same hash every time")
+	assert.NotEqual(t, hash, hasher.Hash("Ivan.Petrov@example.com"), "normalizing is up to the caller")
+
+	other, err := NewHasher(newTestKey(t))
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other.Hash("ivan.petrov@example.com"), "the hash depends on the key")
+
+	_, err = NewHasher(make([]byte, 16))
+	assert.Error(t, err)
+}
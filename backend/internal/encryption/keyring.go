@@ -0,0 +1,175 @@
+package encryption
+
+import (
+	"bufio"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const maxKeyIDLength = 255
+
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// Keyring holds key encryption keys by ID. New data is always encrypted with
+// the active key, the other keys are only kept to decrypt what has not been
+// rotated to the active key yet.
+//
+// Everything the keyring encrypts is prefixed with the ID of the key, so that
+// a key can be retired once nothing refers to it anymore.
+type Keyring struct {
+	activeID string
+	keys     map[string]*Cipher
+}
+
+// NewKeyring takes base64 encoded 256-bit keys by ID. activeID may be left
+// empty when there is only one key.
+func NewKeyring(keys map[string]string, activeID string) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("keyring has no keys")
+	}
+
+	k := &Keyring{keys: make(map[string]*Cipher, len(keys))}
+	for id, encoded := range keys {
+		if id == "" || len(id) > maxKeyIDLength {
+			return nil, fmt.Errorf("invalid key ID %q", id)
+		}
+		key, err := ParseKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		if k.keys[id], err = NewCipher(key); err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		if activeID == "" && len(keys) == 1 {
+			k.activeID = id
+		}
+	}
+
+	if activeID != "" {
+		if _, ok := k.keys[activeID]; !ok {
+			return nil, fmt.Errorf("active key %q is not in the keyring", activeID)
+		}
+		k.activeID = activeID
+	}
+	if k.activeID == "" {
+		return nil, fmt.Errorf("active key ID must be set when %d keys are present", len(keys))
+	}
+	return k, nil
+}
+
+// LoadKeyring reads a keyring file with one "<id>:<base64 key>" per line.
+// Blank lines and lines starting with # are ignored.
+func LoadKeyring(path string, activeID string) (*Keyring, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open keyring file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	keys := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected <id>:<base64 key>", path, lineNumber)
+		}
+		id = strings.TrimSpace(id)
+		if _, exists := keys[id]; exists {
+			return nil, fmt.Errorf("%s:%d: duplicate key ID %q", path, lineNumber, id)
+		}
+		keys[id] = strings.TrimSpace(encoded)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read keyring file: %w", err)
+	}
+
+	return NewKeyring(keys, activeID)
+}
+
+func (k *Keyring) ActiveKeyID() string {
+	return k.activeID
+}
+
+// Encrypt encrypts with the active key.
+func (k *Keyring) Encrypt(plaintext []byte, additionalData []byte) ([]byte, error) {
+	ciphertext, err := k.keys[k.activeID].Encrypt(plaintext, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, 1+len(k.activeID)+len(ciphertext))
+	out = append(out, byte(len(k.activeID)))
+	out = append(out, k.activeID...)
+	return append(out, ciphertext...), nil
+}
+
+// Decrypt decrypts with whichever key the data was encrypted with.
+func (k *Keyring) Decrypt(data []byte, additionalData []byte) ([]byte, error) {
+	id, ciphertext, err := splitKeyID(data)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	return key.Decrypt(ciphertext, additionalData)
+}
+
+// KeyID returns the ID of the key data was encrypted with.
+func (k *Keyring) KeyID(data []byte) (string, error) {
+	id, _, err := splitKeyID(data)
+	return id, err
+}
+
+func splitKeyID(data []byte) (string, []byte, error) {
+	if len(data) == 0 || int(data[0]) == 0 || len(data) < 1+int(data[0]) {
+		return "", nil, ErrMalformedCiphertext
+	}
+	n := 1 + int(data[0])
+	return string(data[1:n]), data[n:], nil
+}
+
+// NewDataKey generates a random data key for envelope encryption. The data
+// key encrypts the values of one record and is stored next to them, wrapped
+// by the active key of the keyring.
+func (k *Keyring) NewDataKey(additionalData []byte) (*Cipher, []byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, fmt.Errorf("could not generate data key: %w", err)
+	}
+	dataKey, err := NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	wrapped, err := k.Encrypt(key, additionalData)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not wrap data key: %w", err)
+	}
+	return dataKey, wrapped, nil
+}
+
+// OpenDataKey unwraps a data key created by NewDataKey.
+func (k *Keyring) OpenDataKey(wrapped []byte, additionalData []byte) (*Cipher, error) {
+	key, err := k.Decrypt(wrapped, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("could not unwrap data key: %w", err)
+	}
+	return NewCipher(key)
+}
+
+// RewrapDataKey wraps a data key with the active key. The values encrypted
+// with the data key stay as they are, which keeps key rotation cheap.
+func (k *Keyring) RewrapDataKey(wrapped []byte, additionalData []byte) ([]byte, error) {
+	key, err := k.Decrypt(wrapped, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("could not unwrap data key: %w", err)
+	}
+	return k.Encrypt(key, additionalData)
+}
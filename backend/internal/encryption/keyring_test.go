@@ -0,0 +1,132 @@
+package encryption
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKeyring(t *testing.T) {
+	key := encodeKey(newTestKey(t))
+
+	single, err := NewKeyring(map[string]string{"2024": key}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "2024", single.ActiveKeyID(), "a single key is active by default")
+
+	invalid := map[string]struct {
+		keys     map[string]string
+		activeID string
+	}{
+		"no keys":            {keys: map[string]string{}},
+		"empty ID":           {keys: map[string]string{"": key}},
+		"invalid key":        {keys: map[string]string{"2024": "not base64!"}},
+		"no active key":      {keys: map[string]string{"2024": key, "2025": key}},
+		"unknown active key": {keys: map[string]string{"2024": key}, activeID: "2025"},
+	}
+	for name, tt := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewKeyring(tt.keys, tt.activeID)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	oldKey, newKey := encodeKey(newTestKey(t)), encodeKey(newTestKey(t))
+	before, err := NewKeyring(map[string]string{"2024": oldKey}, "2024")
+	require.NoError(t, err)
+
+	ciphertext, err := before.Encrypt([]byte("Ploshad Mira 15"), []byte("delivery:1"))
+	require.NoError(t, err)
+	_, wrapped, err := before.NewDataKey([]byte("delivery:1"))
+	require.NoError(t, err)
+
+	after, err := NewKeyring(map[string]string{"2024": oldKey, "2025": newKey}, "2025")
+	require.NoError(t, err)
+
+	t.Run("decrypts under the old key ID", func(t *testing.T) {
+		id, err := after.KeyID(ciphertext)
+		require.NoError(t, err)
+		assert.Equal(t, "2024", id)
+
+		plaintext, err := after.Decrypt(ciphertext, []byte("delivery:1"))
+		require.NoError(t, err)
+		assert.Equal(t, "Ploshad Mira 15", string(plaintext))
+	})
+
+	t.Run("encrypts with the active key", func(t *testing.T) {
+		ciphertext, err := after.Encrypt([]byte("Ploshad Mira 15"), nil)
+		require.NoError(t, err)
+		id, err := after.KeyID(ciphertext)
+		require.NoError(t, err)
+		assert.Equal(t, "2025", id)
+
+		_, err = before.Decrypt(ciphertext, nil)
+		assert.ErrorIs(t, err, ErrUnknownKey)
+	})
+
+	t.Run("rewraps a data key with the active key", func(t *testing.T) {
+		dataKey, err := before.OpenDataKey(wrapped, []byte("delivery:1"))
+		require.NoError(t, err)
+		value, err := dataKey.Encrypt([]byte("+7 (912) 345-67-89"), nil)
+		require.NoError(t, err)
+
+		rewrapped, err := after.RewrapDataKey(wrapped, []byte("delivery:1"))
+		require.NoError(t, err)
+		id, err := after.KeyID(rewrapped)
+		require.NoError(t, err)
+		assert.Equal(t, "2025", id)
+
+		// the values stay as they are and open with the rewrapped key
+		newRing, err := NewKeyring(map[string]string{"2025": newKey}, "")
+		require.NoError(t, err)
+		dataKey, err = newRing.OpenDataKey(rewrapped, []byte("delivery:1"))
+		require.NoError(t, err)
+		plaintext, err := dataKey.Decrypt(value, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "+7 (912) 345-67-89", string(plaintext))
+	})
+}
+
+func TestEnvelopeEncryption(t *testing.T) {
+	keyring, err := NewKeyring(map[string]string{"2024": encodeKey(newTestKey(t))}, "")
+	require.NoError(t, err)
+
+	dataKey, wrapped, err := keyring.NewDataKey([]byte("delivery:1"))
+	require.NoError(t, err)
+	ciphertext, err := dataKey.Encrypt([]byte("ivan.petrov@example.com"), []byte("delivery:1"))
+	require.NoError(t, err)
+
+	opened, err := keyring.OpenDataKey(wrapped, []byte("delivery:1"))
+	require.NoError(t, err)
+	plaintext, err := opened.Decrypt(ciphertext, []byte("delivery:1"))
+	require.NoError(t, err)
+	assert.Equal(t, "ivan.petrov@example.com", string(plaintext))
+
+	_, err = keyring.OpenDataKey(wrapped, []byte("delivery:2"))
+	assert.Error(t, err, "a data key is bound to its row")
+
+	_, err = keyring.Decrypt(nil, nil)
+	assert.ErrorIs(t, err, ErrMalformedCiphertext)
+}
+
+func TestLoadKeyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring")
+	content := "# retired once rotated\n2024:" + encodeKey(newTestKey(t)) + "\n\n2025: " + encodeKey(newTestKey(t)) + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	keyring, err := LoadKeyring(path, "2025")
+	require.NoError(t, err)
+	assert.Equal(t, "2025", keyring.ActiveKeyID())
+
+	require.NoError(t, os.WriteFile(path, []byte(content+"2024:"+encodeKey(newTestKey(t))+"\n"), 0o600))
+	_, err = LoadKeyring(path, "2025")
+	assert.ErrorContains(t, err, "duplicate key ID")
+
+	require.NoError(t, os.WriteFile(path, []byte("no separator\n"), 0o600))
+	_, err = LoadKeyring(path, "")
+	assert.ErrorContains(t, err, ":1:")
+}
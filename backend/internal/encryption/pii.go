@@ -0,0 +1,31 @@
+package encryption
+
+import (
+	"fmt"
+	"github.com/AlexShmak/order-service/internal/config"
+)
+
+// NewPIIKeys builds the keyring for PII and the hasher for the email lookup
+// hash. A keyring file takes precedence over keys in the environment.
+func NewPIIKeys(cfg config.PIIConfig) (*Keyring, *Hasher, error) {
+	var keyring *Keyring
+	var err error
+	if cfg.KeyringFile != "" {
+		keyring, err = LoadKeyring(cfg.KeyringFile, cfg.ActiveKeyID)
+	} else {
+		keyring, err = NewKeyring(cfg.Keys, cfg.ActiveKeyID)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not load PII keyring: %w", err)
+	}
+
+	hashKey, err := ParseKey(cfg.HashKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid PII hash key: %w", err)
+	}
+	hasher, err := NewHasher(hashKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return keyring, hasher, nil
+}
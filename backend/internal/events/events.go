@@ -0,0 +1,63 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TypeOrderCreated = "order.created"
+	// TypeOrderStored is emitted by the worker once it has stored an order
+	TypeOrderStored = "order.stored"
+)
+
+var (
+	ErrUnknownType    = errors.New("unknown event type")
+	ErrUnknownVersion = errors.New("unknown event version")
+)
+
+// Event is the envelope of every message on the order topics. Type and
+// Version name the schema of Payload, so that consumers can handle old and
+// new payloads side by side while producers are rolled out.
+type Event struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Payload    json.RawMessage `json:"payload"`
+	// Key is sent as the message key rather than in the envelope, all events
+	// with the same key land on the same partition and keep their order
+	Key string `json:"-"`
+}
+
+func newEvent(eventType string, version int, key string, payload any) (*Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode %s v%d payload: %w", eventType, version, err)
+	}
+	return &Event{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		Version:    version,
+		OccurredAt: time.Now().UTC(),
+		Payload:    data,
+		Key:        key,
+	}, nil
+}
+
+// Decode parses an envelope. Messages published before there was an envelope
+// hold a bare order, they are read as order.created v1.
+func Decode(data []byte) (*Event, error) {
+	var event Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("could not decode event: %w", err)
+	}
+	if event.Type == "" && event.Payload == nil {
+		return &Event{Type: TypeOrderCreated, Version: 1, Payload: data}, nil
+	}
+	return &event, nil
+}
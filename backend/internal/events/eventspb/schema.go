@@ -0,0 +1,9 @@
+package eventspb
+
+import _ "embed"
+
+// Schema is the source of order_events.proto as it is registered with the
+// schema registry.
+//
+//go:embed order_events.proto
+var Schema string
@@ -0,0 +1,204 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/storage"
+)
+
+// OrderCreatedVersion is the newest order.created payload version.
+const OrderCreatedVersion = 2
+
+// OrderCreatedV1 is the order as the API used to put it on the queue, a
+// storage.Order with Go field names.
+type OrderCreatedV1 = storage.Order
+
+// OrderCreatedV2 is a documented snake_case schema that no longer follows
+// storage.Order. The payment time is RFC 3339 instead of a Unix timestamp.
+type OrderCreatedV2 struct {
+	OrderUID          string        `json:"order_uid"`
+	TrackNumber       string        `json:"track_number"`
+	Entry             string        `json:"entry"`
+	CustomerID        string        `json:"customer_id"`
+	Locale            string        `json:"locale"`
+	DeliveryService   string        `json:"delivery_service"`
+	ShardKey          string        `json:"shard_key"`
+	SmID              int64         `json:"sm_id"`
+	OofShard          string        `json:"oof_shard"`
+	CreatedAt         time.Time     `json:"created_at"`
+	Delivery          DeliveryV2    `json:"delivery"`
+	Payment           PaymentV2     `json:"payment"`
+	Items             []OrderItemV2 `json:"items"`
+	InternalSignature string        `json:"internal_signature,omitempty"`
+}
+
+type DeliveryV2 struct {
+	Name    string `json:"name"`
+	Phone   string `json:"phone"`
+	Zip     string `json:"zip"`
+	City    string `json:"city"`
+	Address string `json:"address"`
+	Region  string `json:"region"`
+	Email   string `json:"email"`
+}
+
+type PaymentV2 struct {
+	Transaction  string    `json:"transaction"`
+	RequestID    string    `json:"request_id,omitempty"`
+	Currency     string    `json:"currency"`
+	Provider     string    `json:"provider"`
+	Amount       int       `json:"amount"`
+	PaidAt       time.Time `json:"paid_at"`
+	Bank         string    `json:"bank"`
+	DeliveryCost int       `json:"delivery_cost"`
+	GoodsTotal   int       `json:"goods_total"`
+	CustomFee    int       `json:"custom_fee"`
+}
+
+type OrderItemV2 struct {
+	ChrtID      int    `json:"chrt_id"`
+	TrackNumber string `json:"track_number"`
+	Price       int    `json:"price"`
+	RID         string `json:"rid"`
+	Name        string `json:"name"`
+	Sale        int    `json:"sale"`
+	Size        string `json:"size"`
+	TotalPrice  int    `json:"total_price"`
+	NmID        int    `json:"nm_id"`
+	Brand       string `json:"brand"`
+	Status      int    `json:"status"`
+}
+
+// NewOrderCreated wraps order in an order.created event of the given payload
+// version, keyed by its UID.
+func NewOrderCreated(order *storage.Order, version int) (*Event, error) {
+	switch version {
+	case 1:
+		return newEvent(TypeOrderCreated, 1, order.OrderUID, order)
+	case 2:
+		return newEvent(TypeOrderCreated, 2, order.OrderUID, orderCreatedV2(order))
+	default:
+		return nil, fmt.Errorf("%w: %s v%d", ErrUnknownVersion, TypeOrderCreated, version)
+	}
+}
+
+// Order returns the order an order.created event carries, whatever its
+// payload version.
+func (e *Event) Order() (*storage.Order, error) {
+	if e.Type != TypeOrderCreated {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownType, e.Type)
+	}
+
+	switch e.Version {
+	case 1:
+		var order OrderCreatedV1
+		if err := json.Unmarshal(e.Payload, &order); err != nil {
+			return nil, fmt.Errorf("could not decode %s v1 payload: %w", e.Type, err)
+		}
+		return &order, nil
+	case 2:
+		var payload OrderCreatedV2
+		if err := json.Unmarshal(e.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("could not decode %s v2 payload: %w", e.Type, err)
+		}
+		return payload.order(), nil
+	default:
+		return nil, fmt.Errorf("%w: %s v%d", ErrUnknownVersion, e.Type, e.Version)
+	}
+}
+
+func orderCreatedV2(order *storage.Order) *OrderCreatedV2 {
+	items := make([]OrderItemV2, 0, len(order.Items))
+	for _, item := range order.Items {
+		items = append(items, OrderItemV2{
+			ChrtID:      item.ChrtID,
+			TrackNumber: item.TrackNumber,
+			Price:       item.Price,
+			RID:         item.RID,
+			Name:        item.Name,
+			Sale:        item.Sale,
+			Size:        item.Size,
+			TotalPrice:  item.TotalPrice,
+			NmID:        item.NMID,
+			Brand:       item.Brand,
+			Status:      item.Status,
+		})
+	}
+
+	return &OrderCreatedV2{
+		OrderUID:          order.OrderUID,
+		TrackNumber:       order.TrackNumber,
+		Entry:             order.Entry,
+		CustomerID:        order.CustomerID,
+		Locale:            order.Locale,
+		DeliveryService:   order.DeliveryService,
+		ShardKey:          order.ShardKey,
+		SmID:              order.SmID,
+		OofShard:          order.OofShard,
+		CreatedAt:         order.DateCreated,
+		InternalSignature: order.InternalSignature,
+		Delivery:          DeliveryV2(order.Delivery),
+		Payment: PaymentV2{
+			Transaction:  order.Payment.Transaction,
+			RequestID:    order.Payment.RequestID,
+			Currency:     order.Payment.Currency,
+			Provider:     order.Payment.Provider,
+			Amount:       order.Payment.Amount,
+			PaidAt:       time.Unix(order.Payment.PaymentDt, 0).UTC(),
+			Bank:         order.Payment.Bank,
+			DeliveryCost: order.Payment.DeliveryCost,
+			GoodsTotal:   order.Payment.GoodsTotal,
+			CustomFee:    order.Payment.CustomFee,
+		},
+		Items: items,
+	}
+}
+
+func (p *OrderCreatedV2) order() *storage.Order {
+	items := make([]storage.Item, 0, len(p.Items))
+	for _, item := range p.Items {
+		items = append(items, storage.Item{
+			ChrtID:      item.ChrtID,
+			TrackNumber: item.TrackNumber,
+			Price:       item.Price,
+			RID:         item.RID,
+			Name:        item.Name,
+			Sale:        item.Sale,
+			Size:        item.Size,
+			TotalPrice:  item.TotalPrice,
+			NMID:        item.NmID,
+			Brand:       item.Brand,
+			Status:      item.Status,
+		})
+	}
+
+	return &storage.Order{
+		OrderUID:    p.OrderUID,
+		TrackNumber: p.TrackNumber,
+		Entry:       p.Entry,
+		Delivery:    storage.Delivery(p.Delivery),
+		Payment: storage.Payment{
+			Transaction:  p.Payment.Transaction,
+			RequestID:    p.Payment.RequestID,
+			Currency:     p.Payment.Currency,
+			Provider:     p.Payment.Provider,
+			Amount:       p.Payment.Amount,
+			PaymentDt:    p.Payment.PaidAt.Unix(),
+			Bank:         p.Payment.Bank,
+			DeliveryCost: p.Payment.DeliveryCost,
+			GoodsTotal:   p.Payment.GoodsTotal,
+			CustomFee:    p.Payment.CustomFee,
+		},
+		Items:             items,
+		Locale:            p.Locale,
+		InternalSignature: p.InternalSignature,
+		CustomerID:        p.CustomerID,
+		DeliveryService:   p.DeliveryService,
+		ShardKey:          p.ShardKey,
+		SmID:              p.SmID,
+		DateCreated:       p.CreatedAt,
+		OofShard:          p.OofShard,
+	}
+}
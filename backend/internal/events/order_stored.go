@@ -0,0 +1,52 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/google/uuid"
+)
+
+// OrderStoredVersion is the newest order.stored payload version.
+const OrderStoredVersion = 1
+
+// OrderStoredV1 tells downstream services that an order is stored. It leaves
+// out the customer's delivery and payment data, which they read from the API.
+type OrderStoredV1 struct {
+	OrderUID    string `json:"order_uid"`
+	TrackNumber string `json:"track_number"`
+	CustomerID  string `json:"customer_id"`
+}
+
+// NewOrderStored wraps order in an order.stored event keyed by its UID. The
+// event ID follows from the UID, so that consumers can drop the copies a
+// redelivered order.created event leads to.
+func NewOrderStored(order *storage.Order) (*Event, error) {
+	event, err := newEvent(TypeOrderStored, 1, order.OrderUID, &OrderStoredV1{
+		OrderUID:    order.OrderUID,
+		TrackNumber: order.TrackNumber,
+		CustomerID:  order.CustomerID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	event.ID = uuid.NewSHA1(uuid.NameSpaceURL, []byte(TypeOrderStored+"/"+order.OrderUID)).String()
+	return event, nil
+}
+
+// OrderStored returns the payload of an order.stored event.
+func (e *Event) OrderStored() (*OrderStoredV1, error) {
+	if e.Type != TypeOrderStored {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownType, e.Type)
+	}
+	if e.Version != 1 {
+		return nil, fmt.Errorf("%w: %s v%d", ErrUnknownVersion, e.Type, e.Version)
+	}
+
+	var payload OrderStoredV1
+	if err := json.Unmarshal(e.Payload, &payload); err != nil {
+		return nil, fmt.Errorf("could not decode %s v1 payload: %w", e.Type, err)
+	}
+	return &payload, nil
+}
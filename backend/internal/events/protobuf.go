@@ -0,0 +1,199 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/AlexShmak/order-service/internal/events/eventspb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Proto converts e for the protobuf encoding. The order.created protobuf
+// payload is the v2 schema, so a v1 event is upgraded on the way.
+func (e *Event) Proto() (*eventspb.OrderEvent, error) {
+	if e.Type == TypeOrderStored {
+		return e.orderStoredProto()
+	}
+
+	order, err := e.Order()
+	if err != nil {
+		return nil, err
+	}
+	payload := orderCreatedV2(order)
+
+	items := make([]*eventspb.Item, 0, len(payload.Items))
+	for _, item := range payload.Items {
+		items = append(items, &eventspb.Item{
+			ChrtId:      int64(item.ChrtID),
+			TrackNumber: item.TrackNumber,
+			Price:       int64(item.Price),
+			Rid:         item.RID,
+			Name:        item.Name,
+			Sale:        int64(item.Sale),
+			Size:        item.Size,
+			TotalPrice:  int64(item.TotalPrice),
+			NmId:        int64(item.NmID),
+			Brand:       item.Brand,
+			Status:      int32(item.Status),
+		})
+	}
+
+	return &eventspb.OrderEvent{
+		Id:         e.ID,
+		Type:       e.Type,
+		Version:    2,
+		OccurredAt: timestamppb.New(e.OccurredAt),
+		Payload: &eventspb.OrderEvent_OrderCreated{OrderCreated: &eventspb.OrderCreated{
+			OrderUid:          payload.OrderUID,
+			TrackNumber:       payload.TrackNumber,
+			Entry:             payload.Entry,
+			CustomerId:        payload.CustomerID,
+			Locale:            payload.Locale,
+			DeliveryService:   payload.DeliveryService,
+			ShardKey:          payload.ShardKey,
+			SmId:              payload.SmID,
+			OofShard:          payload.OofShard,
+			CreatedAt:         timestamppb.New(payload.CreatedAt),
+			InternalSignature: payload.InternalSignature,
+			Delivery: &eventspb.Delivery{
+				Name:    payload.Delivery.Name,
+				Phone:   payload.Delivery.Phone,
+				Zip:     payload.Delivery.Zip,
+				City:    payload.Delivery.City,
+				Address: payload.Delivery.Address,
+				Region:  payload.Delivery.Region,
+				Email:   payload.Delivery.Email,
+			},
+			Payment: &eventspb.Payment{
+				Transaction:  payload.Payment.Transaction,
+				RequestId:    payload.Payment.RequestID,
+				Currency:     payload.Payment.Currency,
+				Provider:     payload.Payment.Provider,
+				Amount:       int64(payload.Payment.Amount),
+				PaidAt:       timestamppb.New(payload.Payment.PaidAt),
+				Bank:         payload.Payment.Bank,
+				DeliveryCost: int64(payload.Payment.DeliveryCost),
+				GoodsTotal:   int64(payload.Payment.GoodsTotal),
+				CustomFee:    int64(payload.Payment.CustomFee),
+			},
+			Items: items,
+		}},
+	}, nil
+}
+
+// FromProto converts an event of the protobuf encoding into one with the JSON
+// payload version the protobuf payload matches.
+func FromProto(msg *eventspb.OrderEvent) (*Event, error) {
+	if stored := msg.GetOrderStored(); msg.GetType() == TypeOrderStored && stored != nil {
+		return orderStoredFromProto(msg, stored)
+	}
+
+	created := msg.GetOrderCreated()
+	if msg.GetType() != TypeOrderCreated || created == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownType, msg.GetType())
+	}
+
+	items := make([]OrderItemV2, 0, len(created.GetItems()))
+	for _, item := range created.GetItems() {
+		items = append(items, OrderItemV2{
+			ChrtID:      int(item.GetChrtId()),
+			TrackNumber: item.GetTrackNumber(),
+			Price:       int(item.GetPrice()),
+			RID:         item.GetRid(),
+			Name:        item.GetName(),
+			Sale:        int(item.GetSale()),
+			Size:        item.GetSize(),
+			TotalPrice:  int(item.GetTotalPrice()),
+			NmID:        int(item.GetNmId()),
+			Brand:       item.GetBrand(),
+			Status:      int(item.GetStatus()),
+		})
+	}
+
+	delivery, payment := created.GetDelivery(), created.GetPayment()
+	payload, err := json.Marshal(&OrderCreatedV2{
+		OrderUID:          created.GetOrderUid(),
+		TrackNumber:       created.GetTrackNumber(),
+		Entry:             created.GetEntry(),
+		CustomerID:        created.GetCustomerId(),
+		Locale:            created.GetLocale(),
+		DeliveryService:   created.GetDeliveryService(),
+		ShardKey:          created.GetShardKey(),
+		SmID:              created.GetSmId(),
+		OofShard:          created.GetOofShard(),
+		CreatedAt:         created.GetCreatedAt().AsTime(),
+		InternalSignature: created.GetInternalSignature(),
+		Delivery: DeliveryV2{
+			Name:    delivery.GetName(),
+			Phone:   delivery.GetPhone(),
+			Zip:     delivery.GetZip(),
+			City:    delivery.GetCity(),
+			Address: delivery.GetAddress(),
+			Region:  delivery.GetRegion(),
+			Email:   delivery.GetEmail(),
+		},
+		Payment: PaymentV2{
+			Transaction:  payment.GetTransaction(),
+			RequestID:    payment.GetRequestId(),
+			Currency:     payment.GetCurrency(),
+			Provider:     payment.GetProvider(),
+			Amount:       int(payment.GetAmount()),
+			PaidAt:       payment.GetPaidAt().AsTime(),
+			Bank:         payment.GetBank(),
+			DeliveryCost: int(payment.GetDeliveryCost()),
+			GoodsTotal:   int(payment.GetGoodsTotal()),
+			CustomFee:    int(payment.GetCustomFee()),
+		},
+		Items: items,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not encode %s v2 payload: %w", TypeOrderCreated, err)
+	}
+
+	return &Event{
+		ID:         msg.GetId(),
+		Type:       msg.GetType(),
+		Version:    2,
+		OccurredAt: msg.GetOccurredAt().AsTime(),
+		Payload:    payload,
+		Key:        created.GetOrderUid(),
+	}, nil
+}
+
+func (e *Event) orderStoredProto() (*eventspb.OrderEvent, error) {
+	payload, err := e.OrderStored()
+	if err != nil {
+		return nil, err
+	}
+	return &eventspb.OrderEvent{
+		Id:         e.ID,
+		Type:       e.Type,
+		Version:    1,
+		OccurredAt: timestamppb.New(e.OccurredAt),
+		Payload: &eventspb.OrderEvent_OrderStored{OrderStored: &eventspb.OrderStored{
+			OrderUid:    payload.OrderUID,
+			TrackNumber: payload.TrackNumber,
+			CustomerId:  payload.CustomerID,
+		}},
+	}, nil
+}
+
+func orderStoredFromProto(msg *eventspb.OrderEvent, stored *eventspb.OrderStored) (*Event, error) {
+	payload, err := json.Marshal(&OrderStoredV1{
+		OrderUID:    stored.GetOrderUid(),
+		TrackNumber: stored.GetTrackNumber(),
+		CustomerID:  stored.GetCustomerId(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not encode %s v1 payload: %w", TypeOrderStored, err)
+	}
+
+	return &Event{
+		ID:         msg.GetId(),
+		Type:       msg.GetType(),
+		Version:    1,
+		OccurredAt: msg.GetOccurredAt().AsTime(),
+		Payload:    payload,
+		Key:        stored.GetOrderUid(),
+	}, nil
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AlexShmak/order-service/internal/auth"
+	"github.com/AlexShmak/order-service/internal/mailer"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	emailVerificationTTL = 24 * time.Hour
+	passwordResetTTL     = time.Hour
+)
+
+// errInvalidActionToken aborts a transaction whose action token is unknown,
+// used or expired.
+var errInvalidActionToken = errors.New("invalid or expired action token")
+
+func (h *Handler) VerifyEmailHandler(c *gin.Context) {
+	var request struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		message := "token is required"
+		if bindingErrorStatus(err) == http.StatusRequestEntityTooLarge {
+			message = bindingErrorMessage(err)
+		}
+		c.IndentedJSON(bindingErrorStatus(err), gin.H{"error": message})
+		return
+	}
+
+	token, ok := h.consumeActionToken(c, request.Token, storage.PurposeEmailVerification)
+	if !ok {
+		return
+	}
+
+	if err := h.Storage.Users.MarkEmailVerified(c.Request.Context(), token.UserID); err != nil {
+		h.Logger.Error("failed to mark email as verified", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to verify email"})
+		return
+	}
+
+	h.Logger.Info("email verified", slog.Int64("id", token.UserID))
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "email verified"})
+}
+
+func (h *Handler) ForgotPasswordHandler(c *gin.Context) {
+	var request struct {
+		Email string `json:"email" binding:"required,email,max=255"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		message := "a valid email is required"
+		if bindingErrorStatus(err) == http.StatusRequestEntityTooLarge {
+			message = bindingErrorMessage(err)
+		}
+		c.IndentedJSON(bindingErrorStatus(err), gin.H{"error": message})
+		return
+	}
+
+	// the response is the same whether the account exists or not, so that the
+	// endpoint cannot be used to find out which emails are registered
+	response := gin.H{"message": "if the account exists, a reset link has been sent"}
+
+	user, err := h.Storage.Users.GetByEmail(c.Request.Context(), storage.NormalizeEmail(request.Email))
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			h.Logger.Error("failed to get user for password reset", slog.String("error", err.Error()))
+		}
+		c.IndentedJSON(http.StatusAccepted, response)
+		return
+	}
+
+	// staff using single sign-on must not be able to set up a local password
+	if !user.HasPassword() {
+		c.IndentedJSON(http.StatusAccepted, response)
+		return
+	}
+
+	// a new link replaces the ones sent before
+	var link string
+	err = h.Storage.Tx.WithinTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.Storage.ActionTokens.RevokeByUser(ctx, user.ID, storage.PurposePasswordReset); err != nil {
+			return err
+		}
+		link, err = h.issueActionLink(ctx, user.ID, storage.PurposePasswordReset, passwordResetTTL, "/reset-password")
+		return err
+	})
+	if err != nil {
+		h.Logger.Error("failed to issue password reset token", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusAccepted, response)
+		return
+	}
+
+	if err := h.Mailer.Send(c.Request.Context(), mailer.PasswordResetEmail(user.Email, user.Name, link)); err != nil {
+		h.Logger.Error("failed to send password reset email", slog.String("error", err.Error()))
+	}
+
+	h.Logger.Info("password reset requested", slog.Int64("id", user.ID))
+	c.IndentedJSON(http.StatusAccepted, response)
+}
+
+func (h *Handler) ResetPasswordHandler(c *gin.Context) {
+	var request struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.IndentedJSON(bindingErrorStatus(err), gin.H{"error": bindingErrorMessage(err)})
+		return
+	}
+
+	if err := h.PasswordPolicy.Validate(request.Password); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.verifyActionToken(c, request.Token, storage.PurposePasswordReset) {
+		return
+	}
+
+	// the token is consumed in the same transaction as the password change,
+	// so it stays valid for another attempt if the change fails. Whoever knew
+	// the old password must not stay logged in, so the sessions are revoked
+	// together with the change or not at all
+	var user *storage.User
+	err := h.Storage.Tx.WithinTx(c.Request.Context(), func(ctx context.Context) error {
+		token, err := h.Storage.ActionTokens.Consume(ctx, auth.HashActionToken(request.Token), storage.PurposePasswordReset)
+		if err != nil {
+			return err
+		}
+		if token == nil {
+			return errInvalidActionToken
+		}
+
+		user, err = h.Storage.Users.GetByID(ctx, token.UserID)
+		if err != nil {
+			return err
+		}
+		user.Password = request.Password
+		if err := h.Storage.Users.UpdatePassword(ctx, user); err != nil {
+			return err
+		}
+		// the other reset links sent to the user are void now as well
+		if err := h.Storage.ActionTokens.RevokeByUser(ctx, user.ID, storage.PurposePasswordReset); err != nil {
+			return err
+		}
+		return h.Storage.Tokens.DeleteByUserID(ctx, user.ID)
+	})
+	if errors.Is(err, errInvalidActionToken) {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid or expired token"})
+		return
+	}
+	if err != nil {
+		h.Logger.Error("failed to reset password", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+
+	// the reset link was delivered to the mailbox, which proves ownership of it
+	if !user.IsEmailVerified() {
+		if err := h.Storage.Users.MarkEmailVerified(c.Request.Context(), user.ID); err != nil {
+			h.Logger.Error("failed to mark email as verified", slog.String("error", err.Error()))
+		}
+	}
+
+	h.Logger.Info("password reset", slog.Int64("id", user.ID))
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "password has been reset"})
+}
+
+// sendVerificationEmail failures are only logged: the account is already
+// created, and a password reset also verifies the email.
+func (h *Handler) sendVerificationEmail(ctx context.Context, user *storage.User) {
+	link, err := h.issueActionLink(ctx, user.ID, storage.PurposeEmailVerification, emailVerificationTTL, "/verify-email")
+	if err != nil {
+		h.Logger.Error("failed to issue email verification token", slog.String("error", err.Error()))
+		return
+	}
+	if err := h.Mailer.Send(ctx, mailer.VerificationEmail(user.Email, user.Name, link)); err != nil {
+		h.Logger.Error("failed to send verification email", slog.String("error", err.Error()))
+	}
+}
+
+// issueActionLink stores a new single-use token and returns the frontend link that carries it.
+func (h *Handler) issueActionLink(ctx context.Context, userID int64, purpose string, ttl time.Duration, path string) (string, error) {
+	tokenString, hash, err := auth.NewActionToken(h.Config.JWT.ActionTokenSecret)
+	if err != nil {
+		return "", err
+	}
+
+	token := &storage.ActionToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := h.Storage.ActionTokens.Create(ctx, token); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%s?token=%s", h.Config.Server.FrontendURL, path, url.QueryEscape(tokenString)), nil
+}
+
+// verifyActionToken checks the signature of the token before it is looked up.
+func (h *Handler) verifyActionToken(c *gin.Context, tokenString string, purpose string) bool {
+	if !auth.VerifyActionToken(h.Config.JWT.ActionTokenSecret, tokenString) {
+		h.Logger.Warn("action token with invalid signature", slog.String("purpose", purpose), slog.String("ip", c.ClientIP()))
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid or expired token"})
+		return false
+	}
+	return true
+}
+
+func (h *Handler) consumeActionToken(c *gin.Context, tokenString string, purpose string) (*storage.ActionToken, bool) {
+	if !h.verifyActionToken(c, tokenString, purpose) {
+		return nil, false
+	}
+
+	token, err := h.Storage.ActionTokens.Consume(c.Request.Context(), auth.HashActionToken(tokenString), purpose)
+	if err != nil {
+		h.Logger.Error("failed to consume action token", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return nil, false
+	}
+	if token == nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid or expired token"})
+		return nil, false
+	}
+	return token, true
+}
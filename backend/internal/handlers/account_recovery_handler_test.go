@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/auth"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func resetRequest(t *testing.T, h *testHandler) (body string, hash []byte) {
+	t.Helper()
+	token, hash, err := auth.NewActionToken(h.Config.JWT.ActionTokenSecret)
+	require.NoError(t, err)
+	return fmt.Sprintf(`{"token": %q, "password": "correct horse"}`, token), hash
+}
+
+func TestForgotPasswordHandler(t *testing.T) {
+	t.Run("replaces the reset links sent before", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.users.EXPECT().GetByEmail(mock.Anything, "test@gmail.com").Return(newTestUser(t, 1), nil).Once()
+		revoked := h.actionTokens.EXPECT().RevokeByUser(inTx(), int64(1), storage.PurposePasswordReset).Return(nil).Once()
+		h.actionTokens.EXPECT().Create(inTx(), mock.MatchedBy(func(token *storage.ActionToken) bool {
+			return token.UserID == 1 && token.Purpose == storage.PurposePasswordReset
+		})).Return(nil).Once().NotBefore(revoked)
+
+		rec := serve(http.MethodPost, "/auth/forgot-password", "/auth/forgot-password", `{"email": "Test@Gmail.com"}`, nil, h.ForgotPasswordHandler)
+
+		assert.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	})
+}
+
+func TestResetPasswordHandler(t *testing.T) {
+	t.Run("consumes the token with the password change", func(t *testing.T) {
+		h := newTestHandler(t)
+		body, hash := resetRequest(t, h)
+		user := newTestUser(t, 1)
+		verifiedAt := time.Now()
+		user.EmailVerifiedAt = &verifiedAt
+
+		h.actionTokens.EXPECT().Consume(inTx(), hash, storage.PurposePasswordReset).Return(&storage.ActionToken{UserID: 1}, nil).Once()
+		h.users.EXPECT().GetByID(inTx(), int64(1)).Return(user, nil).Once()
+		h.users.EXPECT().UpdatePassword(inTx(), mock.MatchedBy(func(user *storage.User) bool {
+			return user.Password == "correct horse"
+		})).Return(nil).Once()
+		h.actionTokens.EXPECT().RevokeByUser(inTx(), int64(1), storage.PurposePasswordReset).Return(nil).Once()
+		h.tokens.EXPECT().DeleteByUserID(inTx(), int64(1)).Return(nil).Once()
+
+		rec := serve(http.MethodPost, "/auth/reset-password", "/auth/reset-password", body, nil, h.ResetPasswordHandler)
+
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	})
+
+	t.Run("keeps the token when the change fails", func(t *testing.T) {
+		h := newTestHandler(t)
+		body, hash := resetRequest(t, h)
+
+		// the consume is rolled back with the failed update
+		h.actionTokens.EXPECT().Consume(inTx(), hash, storage.PurposePasswordReset).Return(&storage.ActionToken{UserID: 1}, nil).Once()
+		h.users.EXPECT().GetByID(inTx(), int64(1)).Return(newTestUser(t, 1), nil).Once()
+		h.users.EXPECT().UpdatePassword(inTx(), mock.Anything).Return(errors.New("connection refused")).Once()
+
+		rec := serve(http.MethodPost, "/auth/reset-password", "/auth/reset-password", body, nil, h.ResetPasswordHandler)
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "connection refused")
+	})
+
+	t.Run("rejects a used token", func(t *testing.T) {
+		h := newTestHandler(t)
+		body, hash := resetRequest(t, h)
+		h.actionTokens.EXPECT().Consume(inTx(), hash, storage.PurposePasswordReset).Return(nil, nil).Once()
+
+		rec := serve(http.MethodPost, "/auth/reset-password", "/auth/reset-password", body, nil, h.ResetPasswordHandler)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("rejects a forged token without looking it up", func(t *testing.T) {
+		h := newTestHandler(t)
+
+		rec := serve(http.MethodPost, "/auth/reset-password", "/auth/reset-password",
+			`{"token": "forged", "password": "correct horse"}`, nil, h.ResetPasswordHandler)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/AlexShmak/order-service/internal/auth"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const defaultAPIKeyRateLimit = 60
+
+func (h *Handler) CreateAPIKeyHandler(c *gin.Context) {
+	var request struct {
+		Name      string     `json:"name" binding:"required"`
+		OwnerID   int64      `json:"owner_id" binding:"required"`
+		Scopes    []string   `json:"scopes" binding:"required,gt=0,dive,oneof=orders:read orders:write"`
+		RateLimit int        `json:"rate_limit" binding:"gte=0"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.Logger.Error("invalid api key request", slog.String("error", err.Error()))
+		c.IndentedJSON(bindingErrorStatus(err), gin.H{"error": bindingErrorMessage(err)})
+		return
+	}
+
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	if _, err := h.Storage.Users.GetByID(c.Request.Context(), request.OwnerID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "owner not found"})
+			return
+		}
+		h.Logger.Error("failed to get api key owner", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to create api key"})
+		return
+	}
+
+	rawKey, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		h.Logger.Error("failed to generate api key", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to create api key"})
+		return
+	}
+
+	rateLimit := request.RateLimit
+	if rateLimit == 0 {
+		rateLimit = defaultAPIKeyRateLimit
+	}
+
+	key := &storage.APIKey{
+		Name:      request.Name,
+		OwnerID:   request.OwnerID,
+		Prefix:    prefix,
+		KeyHash:   auth.HashAPIKey(rawKey),
+		Scopes:    request.Scopes,
+		RateLimit: rateLimit,
+		ExpiresAt: request.ExpiresAt,
+	}
+	if err := h.Storage.APIKeys.Create(c.Request.Context(), key); err != nil {
+		h.Logger.Error("failed to save api key", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to create api key"})
+		return
+	}
+
+	h.Logger.Info("api key created", slog.Int64("id", key.ID), slog.Int64("owner_id", key.OwnerID))
+	// the plaintext key is returned only once and never stored
+	c.IndentedJSON(http.StatusCreated, gin.H{"api_key": key, "key": rawKey})
+}
+
+func (h *Handler) ListAPIKeysHandler(c *gin.Context) {
+	keys, err := h.Storage.APIKeys.List(c.Request.Context())
+	if err != nil {
+		h.Logger.Error("failed to list api keys", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to list api keys"})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, keys)
+}
+
+func (h *Handler) RevokeAPIKeyHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid api key ID"})
+		return
+	}
+
+	if err := h.Storage.APIKeys.Revoke(c.Request.Context(), id); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+			return
+		}
+		h.Logger.Error("failed to revoke api key", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke api key"})
+		return
+	}
+
+	h.Logger.Info("api key revoked", slog.Int64("id", id))
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "api key revoked"})
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/auth"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateAPIKeyHandler(t *testing.T) {
+	t.Run("returns the key once and stores its hash", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.users.EXPECT().GetByID(mock.Anything, int64(1)).Return(newTestUser(t, 1), nil).Once()
+		var stored *storage.APIKey
+		h.apiKeys.EXPECT().Create(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, key *storage.APIKey) error {
+			stored = key
+			key.ID = 3
+			return nil
+		}).Once()
+
+		rec := serve(http.MethodPost, "/admin/api-keys", "/admin/api-keys",
+			`{"name": "partner", "owner_id": 1, "scopes": ["orders:read"]}`, nil, h.CreateAPIKeyHandler)
+
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		rawKey, _ := decodeBody(t, rec)["key"].(string)
+		require.NotEmpty(t, rawKey)
+		assert.Equal(t, auth.HashAPIKey(rawKey), stored.KeyHash)
+		assert.Equal(t, defaultAPIKeyRateLimit, stored.RateLimit)
+		assert.NotContains(t, rec.Body.String(), "key_hash")
+	})
+
+	t.Run("rejects an unknown scope", func(t *testing.T) {
+		h := newTestHandler(t)
+		rec := serve(http.MethodPost, "/admin/api-keys", "/admin/api-keys",
+			`{"name": "partner", "owner_id": 1, "scopes": ["orders:delete"]}`, nil, h.CreateAPIKeyHandler)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("rejects a key that has already expired", func(t *testing.T) {
+		h := newTestHandler(t)
+		body := `{"name": "partner", "owner_id": 1, "scopes": ["orders:read"], "expires_at": "` + time.Now().Add(-time.Hour).Format(time.RFC3339) + `"}`
+		rec := serve(http.MethodPost, "/admin/api-keys", "/admin/api-keys", body, nil, h.CreateAPIKeyHandler)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("rejects an unknown owner", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.users.EXPECT().GetByID(mock.Anything, int64(9)).Return(nil, storage.ErrNotFound).Once()
+
+		rec := serve(http.MethodPost, "/admin/api-keys", "/admin/api-keys",
+			`{"name": "partner", "owner_id": 9, "scopes": ["orders:read"]}`, nil, h.CreateAPIKeyHandler)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "owner not found", decodeBody(t, rec)["error"])
+	})
+}
+
+func TestRevokeAPIKeyHandler(t *testing.T) {
+	t.Run("revokes the key", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.apiKeys.EXPECT().Revoke(mock.Anything, int64(3)).Return(nil).Once()
+		rec := serve(http.MethodDelete, "/admin/api-keys/:id", "/admin/api-keys/3", "", nil, h.RevokeAPIKeyHandler)
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	})
+
+	t.Run("answers an unknown key with 404", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.apiKeys.EXPECT().Revoke(mock.Anything, int64(4)).Return(storage.ErrNotFound).Once()
+		rec := serve(http.MethodDelete, "/admin/api-keys/:id", "/admin/api-keys/4", "", nil, h.RevokeAPIKeyHandler)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
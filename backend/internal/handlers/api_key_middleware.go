@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"github.com/AlexShmak/order-service/internal/auth"
+	"github.com/AlexShmak/order-service/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const apiKeyRateWindow = time.Minute
+
+// apiKeyTouchInterval is how stale last_used_at may get, so that a busy
+// partner does not cost an UPDATE per request.
+const apiKeyTouchInterval = time.Minute
+
+// APIKeyMiddleware authenticates partner systems by the X-API-Key header and maps
+// the key to its owner, so that downstream handlers can rely on "userId" as usual.
+func (h *Handler) APIKeyMiddleware(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rawKey := c.GetHeader("X-API-Key")
+		if rawKey == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing api key"})
+			return
+		}
+
+		key, err := h.Storage.APIKeys.GetByHash(c.Request.Context(), auth.HashAPIKey(rawKey))
+		if err != nil {
+			h.Logger.Error("failed to look up api key", slog.String("error", err.Error()))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		now := time.Now()
+		if key == nil || !key.IsActive(now) {
+			h.Logger.Warn("invalid api key used", slog.String("ip", c.ClientIP()))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
+			return
+		}
+
+		if !key.HasScope(scope) {
+			h.Logger.Warn("api key lacks scope", slog.Int64("key_id", key.ID), slog.String("scope", scope))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient scope"})
+			return
+		}
+
+		res, err := h.Limiter.Allow(c.Request.Context(), "apikey:"+strconv.FormatInt(key.ID, 10), key.RateLimit, apiKeyRateWindow)
+		if err != nil {
+			h.Logger.Error("failed to check api key rate limit", slog.String("error", err.Error()))
+		} else {
+			ratelimit.SetHeaders(c, res, apiKeyRateWindow)
+			if !res.Allowed {
+				ratelimit.SetRetryAfter(c, res.RetryAfter)
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+				return
+			}
+		}
+
+		if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+			if err := h.Storage.APIKeys.Touch(c.Request.Context(), key.ID); err != nil {
+				h.Logger.Error("failed to update api key usage", slog.String("error", err.Error()))
+			}
+		}
+
+		c.Set("userId", key.OwnerID)
+		c.Set("apiKeyId", key.ID)
+		c.Next()
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/auth"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testAPIKey = "osk_0a1b2c3d_secret"
+
+// newTestAPIKey returns an active key of user 1 that may read orders.
+func newTestAPIKey() *storage.APIKey {
+	return &storage.APIKey{ID: 3, OwnerID: 1, KeyHash: auth.HashAPIKey(testAPIKey), Scopes: []string{auth.ScopeOrdersRead}, RateLimit: 2}
+}
+
+func servePartner(h *testHandler, apiKey string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.GET("/partner/orders/:id", h.APIKeyMiddleware(auth.ScopeOrdersRead), echoUser)
+
+	req := httptest.NewRequest(http.MethodGet, "/partner/orders/b563feb7b2b84b6test", nil)
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	t.Run("authenticates as the owner of the key", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.apiKeys.EXPECT().GetByHash(mock.Anything, auth.HashAPIKey(testAPIKey)).Return(newTestAPIKey(), nil).Once()
+		h.apiKeys.EXPECT().Touch(mock.Anything, int64(3)).Return(nil).Once()
+
+		rec := servePartner(h, testAPIKey)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.EqualValues(t, 1, decodeBody(t, rec)["user_id"])
+		assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	})
+
+	t.Run("records the use of a key at most once a minute", func(t *testing.T) {
+		h := newTestHandler(t)
+		key := newTestAPIKey()
+		lastUsedAt := time.Now().Add(-10 * time.Second)
+		key.LastUsedAt = &lastUsedAt
+		// the mock fails the test on a Touch
+		h.apiKeys.EXPECT().GetByHash(mock.Anything, mock.Anything).Return(key, nil).Once()
+
+		rec := servePartner(h, testAPIKey)
+
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	})
+
+	t.Run("rejects a request without a key", func(t *testing.T) {
+		h := newTestHandler(t)
+		rec := servePartner(h, "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "missing api key", decodeBody(t, rec)["error"])
+	})
+
+	t.Run("rejects an unknown key", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.apiKeys.EXPECT().GetByHash(mock.Anything, mock.Anything).Return(nil, nil).Once()
+
+		rec := servePartner(h, "osk_0a1b2c3d_guessed")
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "invalid api key", decodeBody(t, rec)["error"])
+	})
+
+	t.Run("rejects a revoked key", func(t *testing.T) {
+		h := newTestHandler(t)
+		key := newTestAPIKey()
+		revokedAt := time.Now().Add(-time.Hour)
+		key.RevokedAt = &revokedAt
+		h.apiKeys.EXPECT().GetByHash(mock.Anything, mock.Anything).Return(key, nil).Once()
+
+		rec := servePartner(h, testAPIKey)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("rejects an expired key", func(t *testing.T) {
+		h := newTestHandler(t)
+		key := newTestAPIKey()
+		expiresAt := time.Now().Add(-time.Second)
+		key.ExpiresAt = &expiresAt
+		h.apiKeys.EXPECT().GetByHash(mock.Anything, mock.Anything).Return(key, nil).Once()
+
+		rec := servePartner(h, testAPIKey)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("rejects a key without the scope", func(t *testing.T) {
+		h := newTestHandler(t)
+		key := newTestAPIKey()
+		key.Scopes = []string{auth.ScopeOrdersWrite}
+		h.apiKeys.EXPECT().GetByHash(mock.Anything, mock.Anything).Return(key, nil).Once()
+
+		rec := servePartner(h, testAPIKey)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, "insufficient scope", decodeBody(t, rec)["error"])
+	})
+
+	t.Run("limits the requests of a key to its rate", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.apiKeys.EXPECT().GetByHash(mock.Anything, mock.Anything).Return(newTestAPIKey(), nil).Times(3)
+		h.apiKeys.EXPECT().Touch(mock.Anything, int64(3)).Return(nil).Twice()
+
+		for range 2 {
+			require.Equal(t, http.StatusOK, servePartner(h, testAPIKey).Code)
+		}
+		rec := servePartner(h, testAPIKey)
+
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+	})
+}
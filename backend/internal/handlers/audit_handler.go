@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/AlexShmak/order-service/internal/audit"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultAuditPageSize = 100
+	maxAuditPageSize     = 1000
+	// a CSV export is meant to be complete, so it may be much larger than a page
+	maxAuditExportSize = 50000
+)
+
+// ListAuditEventsHandler supports the filters actor_id, action, outcome,
+// target_type, target_id, ip, from and to (RFC 3339), paging with limit and
+// offset, and format=csv for an export.
+func (h *Handler) ListAuditEventsHandler(c *gin.Context) {
+	export := c.Query("format") == "csv"
+	filter, err := parseAuditFilter(c, export)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	events, err := h.Storage.AuditEvents.List(c.Request.Context(), filter)
+	if err != nil {
+		h.Logger.Error("failed to list audit events", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to list audit events"})
+		return
+	}
+
+	if export {
+		filename := fmt.Sprintf("audit-events-%s.csv", time.Now().UTC().Format("20060102-150405"))
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Status(http.StatusOK)
+		if err := audit.WriteCSV(c.Writer, events); err != nil {
+			h.Logger.Error("failed to write audit CSV", slog.String("error", err.Error()))
+		}
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"events": events, "limit": filter.Limit, "offset": filter.Offset})
+}
+
+func parseAuditFilter(c *gin.Context, export bool) (storage.AuditFilter, error) {
+	filter := storage.AuditFilter{
+		Action:     c.Query("action"),
+		Outcome:    c.Query("outcome"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		IP:         c.Query("ip"),
+		Limit:      defaultAuditPageSize,
+	}
+	maxLimit := maxAuditPageSize
+	if export {
+		filter.Limit, maxLimit = maxAuditExportSize, maxAuditExportSize
+	}
+
+	if value := c.Query("actor_id"); value != "" {
+		actorID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("actor_id must be an integer")
+		}
+		filter.ActorID = &actorID
+	}
+
+	for name, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := c.Query(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+			}
+			*target = &t
+		}
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+		filter.Limit = limit
+	}
+	if value := c.Query("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return filter, fmt.Errorf("offset must be a non-negative integer")
+		}
+		filter.Offset = offset
+	}
+
+	return filter, nil
+}
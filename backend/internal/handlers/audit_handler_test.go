@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func listAuditEvents(h *testHandler, query string) *httptest.ResponseRecorder {
+	return serve(http.MethodGet, "/admin/audit-events", "/admin/audit-events?"+query, "", nil, withUser(7), h.ListAuditEventsHandler)
+}
+
+func TestListAuditEventsHandler(t *testing.T) {
+	t.Run("passes the filters on", func(t *testing.T) {
+		h := newTestHandler(t)
+		var filter storage.AuditFilter
+		h.auditEvents.EXPECT().List(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, f storage.AuditFilter) ([]storage.AuditEvent, error) {
+			filter = f
+			return []storage.AuditEvent{{ID: 1}}, nil
+		}).Once()
+
+		rec := listAuditEvents(h, "actor_id=1&action=auth.login&outcome=failure&target_type=user&target_id=1&ip=10.0.0.1"+
+			"&from=2026-10-01T00:00:00Z&to=2026-10-19T12:00:00%2B03:00&limit=50&offset=100")
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		body := decodeBody(t, rec)
+		assert.Len(t, body["events"], 1)
+		assert.EqualValues(t, 50, body["limit"])
+		assert.EqualValues(t, 100, body["offset"])
+
+		require.NotNil(t, filter.ActorID)
+		assert.Equal(t, int64(1), *filter.ActorID)
+		assert.Equal(t, storage.AuditFilter{
+			ActorID: filter.ActorID, Action: "auth.login", Outcome: "failure", TargetType: "user", TargetID: "1", IP: "10.0.0.1",
+			From: filter.From, To: filter.To, Limit: 50, Offset: 100,
+		}, filter)
+		require.NotNil(t, filter.From)
+		require.NotNil(t, filter.To)
+		assert.True(t, filter.From.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)))
+		assert.True(t, filter.To.Equal(time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)), "the UTC offset is applied")
+	})
+
+	t.Run("pages by default", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.auditEvents.EXPECT().List(mock.Anything, storage.AuditFilter{Limit: defaultAuditPageSize}).Return(nil, nil).Once()
+
+		assert.Equal(t, http.StatusOK, listAuditEvents(h, "").Code)
+	})
+
+	invalid := map[string]string{
+		"actor ID":           "actor_id=admin",
+		"from":               "from=2026-10-01",
+		"to":                 "to=yesterday",
+		"zero limit":         "limit=0",
+		"limit above a page": "limit=1001",
+		"limit":              "limit=ten",
+		"negative offset":    "offset=-1",
+		"offset":             "offset=ten",
+	}
+	for name, query := range invalid {
+		t.Run("rejects an invalid "+name, func(t *testing.T) {
+			h := newTestHandler(t)
+
+			rec := listAuditEvents(h, query)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			h.auditEvents.AssertNotCalled(t, "List", mock.Anything, mock.Anything)
+		})
+	}
+
+	t.Run("exports CSV beyond the page size", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.auditEvents.EXPECT().List(mock.Anything, mock.MatchedBy(func(filter storage.AuditFilter) bool {
+			return filter.Limit == 5000
+		})).Return([]storage.AuditEvent{{ID: 1, UserAgent: "=1+1"}}, nil).Once()
+
+		rec := listAuditEvents(h, "format=csv&limit=5000")
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Disposition"), `attachment; filename="audit-events-`))
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, "'=1+1", records[1][8])
+	})
+
+	t.Run("caps the export", func(t *testing.T) {
+		h := newTestHandler(t)
+
+		assert.Equal(t, http.StatusBadRequest, listAuditEvents(h, "format=csv&limit=50001").Code)
+	})
+
+	t.Run("hides storage errors", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.auditEvents.EXPECT().List(mock.Anything, mock.Anything).Return(nil, errors.New("connection refused")).Once()
+
+		rec := listAuditEvents(h, "")
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "connection refused")
+	})
+}
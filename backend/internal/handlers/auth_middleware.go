@@ -1,18 +1,29 @@
 package handlers
 
 import (
-	"database/sql"
 	"errors"
+	"github.com/AlexShmak/order-service/internal/audit"
 	"github.com/AlexShmak/order-service/internal/storage"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 )
 
 func (h *Handler) handleTokenRefresh(c *gin.Context, originalUserID int64) (int64, bool) {
-	oldRefreshTokenString, err := c.Cookie("refresh_token")
+	auditFailure := func(reason string) {
+		h.Audit.Record(c, audit.Event{
+			Action:     audit.ActionTokenRefresh,
+			Outcome:    audit.OutcomeFailure,
+			TargetType: audit.TargetUser,
+			TargetID:   strconv.FormatInt(originalUserID, 10),
+			Details:    map[string]any{"reason": reason},
+		})
+	}
+
+	oldRefreshTokenString, err := c.Cookie(refreshTokenCookie)
 	if err != nil {
 		h.Logger.Error("no refresh token found in cookies", slog.String("error", err.Error()))
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "session expired"})
@@ -20,11 +31,12 @@ func (h *Handler) handleTokenRefresh(c *gin.Context, originalUserID int64) (int6
 	}
 
 	if _, err = h.Storage.Tokens.GetByToken(c.Request.Context(), oldRefreshTokenString); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		if errors.Is(err, storage.ErrNotFound) {
 			h.Logger.Warn("refresh token not found in db", slog.String("token", oldRefreshTokenString))
 		} else {
 			h.Logger.Error("failed to validate refresh token", slog.String("error", err.Error()))
 		}
+		auditFailure("unknown or expired refresh token")
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid session"})
 		return 0, false
 	}
@@ -39,6 +51,7 @@ func (h *Handler) handleTokenRefresh(c *gin.Context, originalUserID int64) (int6
 
 	if refreshUserID != originalUserID {
 		h.Logger.Error("token refresh user ID mismatch", slog.Int64("original_user", originalUserID), slog.Int64("refresh_user", refreshUserID))
+		auditFailure("user mismatch")
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token mismatch"})
 		return 0, false
 	}
@@ -59,7 +72,7 @@ func (h *Handler) handleTokenRefresh(c *gin.Context, originalUserID int64) (int6
 	newRefreshToken := &storage.RefreshToken{
 		UserID:    refreshUserID,
 		Token:     newRefreshTokenString,
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
+		ExpiresAt: time.Now().Add(h.Config.JWT.RefreshTokenTTL),
 	}
 	if err := h.Storage.Tokens.Create(c.Request.Context(), newRefreshToken); err != nil {
 		h.Logger.Error("failed to save new refresh token", slog.String("error", err.Error()))
@@ -67,20 +80,37 @@ func (h *Handler) handleTokenRefresh(c *gin.Context, originalUserID int64) (int6
 		return 0, false
 	}
 
-	c.SetCookie("access_token", newAccessTokenString, 15*60, "/", "", false, true)
-	c.SetCookie("refresh_token", newRefreshTokenString, 7*24*60*60, "/", "", false, true)
+	if err := h.setSessionCookies(c, newAccessTokenString, newRefreshTokenString); err != nil {
+		h.Logger.Error("failed to set session cookies", slog.String("error", err.Error()))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "could not refresh session"})
+		return 0, false
+	}
+	h.Audit.Record(c, audit.Event{
+		Action:     audit.ActionTokenRefresh,
+		Outcome:    audit.OutcomeSuccess,
+		ActorID:    refreshUserID,
+		TargetType: audit.TargetUser,
+		TargetID:   strconv.FormatInt(refreshUserID, 10),
+	})
 	h.Logger.Info("tokens refreshed successfully", slog.Int64("userID", refreshUserID))
 
 	return refreshUserID, true
 }
 
+// SessionMiddleware authenticates a browser session. The CSRF check runs
+// first, since AuthMiddleware may rotate the refresh token and a forged
+// request must not get that far.
+func (h *Handler) SessionMiddleware() gin.HandlersChain {
+	return gin.HandlersChain{h.CSRFMiddleware(), h.AuthMiddleware()}
+}
+
 func (h *Handler) AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenString, err := c.Cookie("access_token")
+		tokenString, err := c.Cookie(accessTokenCookie)
 		if err != nil {
 			h.Logger.Info("access token not found, attempting refresh with refresh token")
 
-			refreshTokenString, refreshErr := c.Cookie("refresh_token")
+			refreshTokenString, refreshErr := c.Cookie(refreshTokenCookie)
 			if refreshErr != nil {
 				h.Logger.Error("no access or refresh token found in cookies", slog.String("error", refreshErr.Error()))
 				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
@@ -143,3 +173,54 @@ func (h *Handler) AuthMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// RequireRole must be chained after AuthMiddleware.
+func (h *Handler) RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		user, err := h.Storage.Users.GetByID(c.Request.Context(), userId.(int64))
+		if err != nil {
+			h.Logger.Error("could not get user for role check", slog.String("error", err.Error()))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		if user.Role != role {
+			h.Logger.Warn("forbidden access attempt", slog.Int64("userID", user.ID), slog.String("required_role", role))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmail must be chained after AuthMiddleware or APIKeyMiddleware.
+func (h *Handler) RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, exists := c.Get("userId")
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		user, err := h.Storage.Users.GetByID(c.Request.Context(), userId.(int64))
+		if err != nil {
+			h.Logger.Error("could not get user for email verification check", slog.String("error", err.Error()))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		if !user.IsEmailVerified() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "email address is not verified"})
+			return
+		}
+
+		c.Next()
+	}
+}
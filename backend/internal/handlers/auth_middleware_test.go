@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/auth"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// echoUser answers with the user AuthMiddleware has put in the context.
+func echoUser(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"user_id": c.GetInt64("userId")})
+}
+
+func sessionCookies(t *testing.T, accessTTL time.Duration, userID int64) (access, refresh *http.Cookie) {
+	t.Helper()
+	accessToken, refreshToken, err := newTestJWTService(accessTTL).GenerateTokens(userID)
+	require.NoError(t, err)
+	return &http.Cookie{Name: accessTokenCookie, Value: accessToken}, &http.Cookie{Name: refreshTokenCookie, Value: refreshToken}
+}
+
+func serveAuthenticated(h *testHandler, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	return serve(http.MethodGet, "/api/me", "/api/me", "", cookies, h.AuthMiddleware(), echoUser)
+}
+
+// expectRefresh expects the refresh token of userID to be rotated. The new
+// token is not compared to the old one, tokens issued within the same second
+// are identical.
+func expectRefresh(h *testHandler, oldToken string, userID int64) {
+	h.tokens.EXPECT().GetByToken(mock.Anything, oldToken).
+		Return(&storage.RefreshToken{UserID: userID, Token: oldToken, ExpiresAt: time.Now().Add(time.Hour)}, nil).Once()
+	h.tokens.EXPECT().Delete(mock.Anything, oldToken).Return(nil).Once()
+	h.tokens.EXPECT().Create(mock.Anything, mock.MatchedBy(func(token *storage.RefreshToken) bool {
+		return token.UserID == userID && token.Token != ""
+	})).Return(nil).Once()
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	t.Run("accepts a valid access token", func(t *testing.T) {
+		h := newTestHandler(t)
+		access, refresh := sessionCookies(t, time.Minute, 1)
+
+		rec := serveAuthenticated(h, access, refresh)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.EqualValues(t, 1, decodeBody(t, rec)["user_id"])
+		assert.Nil(t, responseCookie(rec, accessTokenCookie), "a valid session is not refreshed")
+	})
+
+	t.Run("refreshes an expired access token", func(t *testing.T) {
+		h := newTestHandler(t)
+		access, refresh := sessionCookies(t, -time.Minute, 1)
+		expectRefresh(h, refresh.Value, 1)
+
+		rec := serveAuthenticated(h, access, refresh)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.EqualValues(t, 1, decodeBody(t, rec)["user_id"])
+		newAccess := responseCookie(rec, accessTokenCookie)
+		require.NotNil(t, newAccess)
+		_, err := h.JWTService.ValidateAccessToken(newAccess.Value)
+		assert.NoError(t, err)
+		assert.NotNil(t, responseCookie(rec, refreshTokenCookie))
+	})
+
+	t.Run("refreshes when there is only a refresh token", func(t *testing.T) {
+		h := newTestHandler(t)
+		_, refresh := sessionCookies(t, time.Minute, 1)
+		expectRefresh(h, refresh.Value, 1)
+
+		rec := serveAuthenticated(h, refresh)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.EqualValues(t, 1, decodeBody(t, rec)["user_id"])
+		assert.NotNil(t, responseCookie(rec, accessTokenCookie))
+	})
+
+	t.Run("rejects a refresh token of another user", func(t *testing.T) {
+		h := newTestHandler(t)
+		access, _ := sessionCookies(t, -time.Minute, 1)
+		_, refresh := sessionCookies(t, time.Minute, 2)
+		h.tokens.EXPECT().GetByToken(mock.Anything, refresh.Value).
+			Return(&storage.RefreshToken{UserID: 2, Token: refresh.Value, ExpiresAt: time.Now().Add(time.Hour)}, nil).Once()
+
+		rec := serveAuthenticated(h, access, refresh)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "token mismatch", decodeBody(t, rec)["error"])
+		assert.Nil(t, responseCookie(rec, accessTokenCookie))
+	})
+
+	t.Run("rejects a revoked refresh token", func(t *testing.T) {
+		h := newTestHandler(t)
+		access, refresh := sessionCookies(t, -time.Minute, 1)
+		h.tokens.EXPECT().GetByToken(mock.Anything, refresh.Value).Return(nil, storage.ErrNotFound).Once()
+
+		rec := serveAuthenticated(h, access, refresh)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "invalid session", decodeBody(t, rec)["error"])
+	})
+
+	t.Run("rejects an expired access token without a refresh token", func(t *testing.T) {
+		h := newTestHandler(t)
+		access, _ := sessionCookies(t, -time.Minute, 1)
+
+		rec := serveAuthenticated(h, access)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("rejects a forged access token", func(t *testing.T) {
+		h := newTestHandler(t)
+		access, refresh := sessionCookies(t, time.Minute, 1)
+		access.Value += "x"
+
+		rec := serveAuthenticated(h, access, refresh)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("rejects a request without a session", func(t *testing.T) {
+		h := newTestHandler(t)
+		rec := serveAuthenticated(h)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestSessionMiddleware(t *testing.T) {
+	serveSession := func(h *testHandler, csrfHeader string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.POST("/api/me/password", append(h.SessionMiddleware(), echoUser)...)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/me/password", nil)
+		if csrfHeader != "" {
+			req.Header.Set(csrfTokenHeader, csrfHeader)
+		}
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	// csrfCookie returns a CSRF token issued for the refresh token
+	csrfCookie := func(t *testing.T, h *testHandler, refresh *http.Cookie) *http.Cookie {
+		t.Helper()
+		token, err := auth.GenerateCSRFToken(h.Config.JWT.RefreshSecret, refresh.Value)
+		require.NoError(t, err)
+		return &http.Cookie{Name: csrfTokenCookie, Value: token}
+	}
+
+	t.Run("rejects a forged request before refreshing the session", func(t *testing.T) {
+		// the mocks fail the test if the refresh token is looked up or rotated
+		h := newTestHandler(t)
+		access, refresh := sessionCookies(t, -time.Minute, 1)
+
+		rec := serveSession(h, "", access, refresh, csrfCookie(t, h, refresh))
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Nil(t, responseCookie(rec, refreshTokenCookie))
+	})
+
+	t.Run("rejects a CSRF token of another session", func(t *testing.T) {
+		// e.g. planted by a sibling domain, which can set cookies but not read them
+		h := newTestHandler(t)
+		access, refresh := sessionCookies(t, time.Minute, 1)
+		_, otherRefresh := sessionCookies(t, time.Minute, 2)
+		csrf := csrfCookie(t, h, otherRefresh)
+
+		rec := serveSession(h, csrf.Value, access, refresh, csrf)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("refreshes the session of a request with the CSRF token", func(t *testing.T) {
+		h := newTestHandler(t)
+		access, refresh := sessionCookies(t, -time.Minute, 1)
+		expectRefresh(h, refresh.Value, 1)
+		csrf := csrfCookie(t, h, refresh)
+
+		rec := serveSession(h, csrf.Value, access, refresh, csrf)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		newRefresh := responseCookie(rec, refreshTokenCookie)
+		require.NotNil(t, newRefresh)
+		newCSRF := responseCookie(rec, csrfTokenCookie)
+		require.NotNil(t, newCSRF)
+		assert.True(t, auth.VerifyCSRFToken(h.Config.JWT.RefreshSecret, newRefresh.Value, newCSRF.Value),
+			"the CSRF token follows the rotated refresh token")
+	})
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"github.com/AlexShmak/order-service/internal/auth"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+const (
+	accessTokenCookie  = "access_token"
+	refreshTokenCookie = "refresh_token"
+	// csrfTokenCookie is readable by scripts, which echo it in csrfTokenHeader
+	csrfTokenCookie = "csrf_token"
+	csrfTokenHeader = "X-CSRF-Token"
+)
+
+// setCookie applies the cookie attributes configured for the environment.
+func (h *Handler) setCookie(c *gin.Context, name, value string, maxAge int, httpOnly bool) {
+	cookies := h.Config.Cookies
+	c.SetSameSite(cookies.SameSiteMode())
+	c.SetCookie(name, value, maxAge, "/", cookies.Domain, cookies.Secure, httpOnly)
+}
+
+// setSessionCookies sets the token cookies and a new CSRF token bound to the
+// refresh token, which lives as long as the refresh token. A token carried
+// over from before the login would let whoever planted it forge requests.
+func (h *Handler) setSessionCookies(c *gin.Context, accessToken, refreshToken string) error {
+	csrfToken, err := auth.GenerateCSRFToken(h.Config.JWT.RefreshSecret, refreshToken)
+	if err != nil {
+		return err
+	}
+
+	h.setCookie(c, accessTokenCookie, accessToken, seconds(h.Config.JWT.AccessTokenTTL), true)
+	h.setCookie(c, refreshTokenCookie, refreshToken, seconds(h.Config.JWT.RefreshTokenTTL), true)
+	h.setCookie(c, csrfTokenCookie, csrfToken, seconds(h.Config.JWT.RefreshTokenTTL), false)
+	return nil
+}
+
+func (h *Handler) clearSessionCookies(c *gin.Context) {
+	h.setCookie(c, accessTokenCookie, "", -1, true)
+	h.setCookie(c, refreshTokenCookie, "", -1, true)
+	h.setCookie(c, csrfTokenCookie, "", -1, false)
+}
+
+func seconds(d time.Duration) int {
+	return int(d / time.Second)
+}
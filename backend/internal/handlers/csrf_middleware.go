@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"github.com/AlexShmak/order-service/internal/auth"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+)
+
+// CSRFMiddleware implements the double-submit cookie pattern: a state-changing
+// request must repeat the value of the CSRF cookie in the X-CSRF-Token header.
+// A cross-site page can make the browser send the cookie, but cannot read it.
+// The token must also be signed for the refresh token of the session, so that
+// a cookie planted by a sibling domain does not pass.
+func (h *Handler) CSRFMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		cookieToken, err := c.Cookie(csrfTokenCookie)
+		headerToken := c.GetHeader(csrfTokenHeader)
+		refreshToken, _ := c.Cookie(refreshTokenCookie)
+		if err != nil || cookieToken == "" || headerToken == "" ||
+			subtle.ConstantTimeCompare([]byte(cookieToken), []byte(headerToken)) != 1 ||
+			!auth.VerifyCSRFToken(h.Config.JWT.RefreshSecret, refreshToken, cookieToken) {
+			h.Logger.Warn("CSRF token mismatch", slog.String("path", c.FullPath()), slog.String("ip", c.ClientIP()))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "invalid CSRF token"})
+			return
+		}
+
+		c.Next()
+	}
+}
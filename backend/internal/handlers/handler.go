@@ -1,21 +1,33 @@
 package handlers
 
 import (
+	"github.com/AlexShmak/order-service/internal/audit"
 	"github.com/AlexShmak/order-service/internal/auth"
 	"github.com/AlexShmak/order-service/internal/config"
 	"github.com/AlexShmak/order-service/internal/kafka"
+	"github.com/AlexShmak/order-service/internal/mailer"
+	"github.com/AlexShmak/order-service/internal/oidc"
+	"github.com/AlexShmak/order-service/internal/ratelimit"
 	"github.com/AlexShmak/order-service/internal/storage"
 	"github.com/AlexShmak/order-service/internal/storage/cache"
 	"log/slog"
 )
 
 type Handler struct {
-	Config        *config.Config
-	Storage       *storage.PostgresStorage
-	Logger        *slog.Logger
-	JWTService    *auth.JWTService
-	KafkaProducer *kafka.Producer
-	Cache         *cache.RedisStorage
+	Config         *config.Config
+	Storage        *storage.PostgresStorage
+	Logger         *slog.Logger
+	JWTService     *auth.JWTService
+	KafkaProducer  kafka.OrderPublisher
+	Cache          *cache.RedisStorage
+	Limiter        ratelimit.Limiter
+	Lockout        *ratelimit.Lockout
+	Mailer         mailer.Mailer
+	PasswordPolicy *auth.PasswordPolicy
+	TOTP           *auth.TOTPService
+	// OIDC is nil unless single sign-on is enabled
+	OIDC  *oidc.Client
+	Audit *audit.Recorder
 }
 
 func NewHandler(
@@ -23,15 +35,29 @@ func NewHandler(
 	logger *slog.Logger,
 	jwtService *auth.JWTService,
 	cfg *config.Config,
-	producer *kafka.Producer,
+	producer kafka.OrderPublisher,
 	redisCache *cache.RedisStorage,
+	limiter ratelimit.Limiter,
+	lockout *ratelimit.Lockout,
+	mailer mailer.Mailer,
+	passwordPolicy *auth.PasswordPolicy,
+	totp *auth.TOTPService,
+	oidcClient *oidc.Client,
+	auditRecorder *audit.Recorder,
 ) *Handler {
 	return &Handler{
-		Config:        cfg,
-		Storage:       storage,
-		Logger:        logger,
-		JWTService:    jwtService,
-		KafkaProducer: producer,
-		Cache:         redisCache,
+		Config:         cfg,
+		Storage:        storage,
+		Logger:         logger,
+		JWTService:     jwtService,
+		KafkaProducer:  producer,
+		Cache:          redisCache,
+		Limiter:        limiter,
+		Lockout:        lockout,
+		Mailer:         mailer,
+		PasswordPolicy: passwordPolicy,
+		TOTP:           totp,
+		OIDC:           oidcClient,
+		Audit:          auditRecorder,
 	}
 }
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/audit"
+	"github.com/AlexShmak/order-service/internal/auth"
+	"github.com/AlexShmak/order-service/internal/config"
+	"github.com/AlexShmak/order-service/internal/encryption"
+	"github.com/AlexShmak/order-service/internal/events"
+	"github.com/AlexShmak/order-service/internal/mailer"
+	"github.com/AlexShmak/order-service/internal/ratelimit"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/AlexShmak/order-service/internal/storage/cache"
+	storagemocks "github.com/AlexShmak/order-service/internal/storage/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// fakePublisher records the events instead of sending them to Kafka.
+type fakePublisher struct {
+	mu        sync.Mutex
+	err       error
+	published []*events.Event
+}
+
+func (p *fakePublisher) Publish(_ string, event *events.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, event)
+	return nil
+}
+
+// fakeOrderCache keeps the orders by UID and counts the writes.
+type fakeOrderCache struct {
+	orders map[string]*storage.Order
+	err    error
+	sets   int
+}
+
+func (c *fakeOrderCache) Get(_ context.Context, uid string) (*storage.Order, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.orders[uid], nil
+}
+
+func (c *fakeOrderCache) Set(_ context.Context, order *storage.Order) error {
+	c.sets++
+	c.orders[order.OrderUID] = order
+	return nil
+}
+
+func (c *fakeOrderCache) Delete(_ context.Context, uids ...string) error {
+	for _, uid := range uids {
+		delete(c.orders, uid)
+	}
+	return nil
+}
+
+// fakeTx marks the context it passes on, so that tests can check which calls
+// run inside the transaction.
+type fakeTx struct{}
+
+type inTxKey struct{}
+
+func (fakeTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(context.WithValue(ctx, inTxKey{}, true))
+}
+
+// inTx matches a context of fakeTx.
+func inTx() any {
+	return mock.MatchedBy(func(ctx context.Context) bool { return ctx.Value(inTxKey{}) != nil })
+}
+
+// fakeLockoutStore is the part of Redis that ratelimit.Lockout uses below its
+// threshold. Every key counts as never locked.
+type fakeLockoutStore struct {
+	redis.Cmdable
+	failures map[string]int64
+}
+
+func (s *fakeLockoutStore) PTTL(ctx context.Context, _ string) *redis.DurationCmd {
+	cmd := redis.NewDurationCmd(ctx, time.Millisecond)
+	cmd.SetVal(-2 * time.Nanosecond)
+	return cmd
+}
+
+func (s *fakeLockoutStore) Incr(ctx context.Context, key string) *redis.IntCmd {
+	s.failures[key]++
+	cmd := redis.NewIntCmd(ctx)
+	cmd.SetVal(s.failures[key])
+	return cmd
+}
+
+func (s *fakeLockoutStore) PExpire(ctx context.Context, _ string, _ time.Duration) *redis.BoolCmd {
+	cmd := redis.NewBoolCmd(ctx)
+	cmd.SetVal(true)
+	return cmd
+}
+
+func (s *fakeLockoutStore) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	for _, key := range keys {
+		delete(s.failures, key)
+	}
+	cmd := redis.NewIntCmd(ctx)
+	cmd.SetVal(int64(len(keys)))
+	return cmd
+}
+
+type testHandler struct {
+	*Handler
+	users        *storagemocks.MockUsers
+	orders       *storagemocks.MockOrders
+	tokens       *storagemocks.MockTokens
+	apiKeys      *storagemocks.MockAPIKeys
+	actionTokens *storagemocks.MockActionTokens
+	totp         *storagemocks.MockTOTP
+	identities   *storagemocks.MockIdentities
+	auditEvents  *storagemocks.MockAuditEvents
+	publisher    *fakePublisher
+	cache        *fakeOrderCache
+	lockout      *fakeLockoutStore
+	// audited holds the recorded audit events in order
+	audited []*storage.AuditEvent
+}
+
+func newTestHandler(t *testing.T) *testHandler {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := &config.Config{
+		JWT: config.JWT{
+			RefreshSecret:     "refresh-secret",
+			ActionTokenSecret: "action-token-secret",
+			AccessTokenTTL:    15 * time.Minute,
+			RefreshTokenTTL:   24 * time.Hour,
+		},
+		Kafka: config.KafkaConfig{Topic: "orders", OrderEventVersion: events.OrderCreatedVersion},
+	}
+	policy, err := auth.NewPasswordPolicy(8, 72, "")
+	require.NoError(t, err)
+
+	hasher, err := encryption.NewHasher([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+
+	th := &testHandler{
+		users:        storagemocks.NewMockUsers(t),
+		orders:       storagemocks.NewMockOrders(t),
+		tokens:       storagemocks.NewMockTokens(t),
+		apiKeys:      storagemocks.NewMockAPIKeys(t),
+		actionTokens: storagemocks.NewMockActionTokens(t),
+		totp:         storagemocks.NewMockTOTP(t),
+		identities:   storagemocks.NewMockIdentities(t),
+		auditEvents:  storagemocks.NewMockAuditEvents(t),
+		publisher:    &fakePublisher{},
+		cache:        &fakeOrderCache{orders: make(map[string]*storage.Order)},
+		lockout:      &fakeLockoutStore{failures: make(map[string]int64)},
+	}
+	th.auditEvents.EXPECT().Create(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, event *storage.AuditEvent) error {
+		th.audited = append(th.audited, event)
+		return nil
+	}).Maybe()
+	pgStorage := &storage.PostgresStorage{
+		Users:        th.users,
+		Orders:       th.orders,
+		Tokens:       th.tokens,
+		APIKeys:      th.apiKeys,
+		ActionTokens: th.actionTokens,
+		TOTP:         th.totp,
+		Identities:   th.identities,
+		AuditEvents:  th.auditEvents,
+		Tx:           fakeTx{},
+	}
+	th.Handler = NewHandler(
+		pgStorage,
+		logger,
+		newTestJWTService(cfg.JWT.AccessTokenTTL),
+		cfg,
+		th.publisher,
+		&cache.RedisStorage{Orders: th.cache},
+		ratelimit.NewMemoryTokenBucket(),
+		ratelimit.NewLockout(th.lockout, ratelimit.LockoutPolicy{Threshold: 5, Window: time.Minute, BaseDuration: time.Minute, MaxDuration: time.Hour}),
+		mailer.NewWriterMailer(io.Discard, "orders@example.com"),
+		policy,
+		nil,
+		nil,
+		audit.NewRecorder(th.auditEvents, logger, hasher),
+	)
+	return th
+}
+
+// newTestJWTService signs with the same keys as the handler, a negative
+// accessTTL issues access tokens that have already expired.
+func newTestJWTService(accessTTL time.Duration) *auth.JWTService {
+	return auth.NewJWTService(auth.NewHMACKeySet("access-secret"), "refresh-secret", accessTTL, 24*time.Hour)
+}
+
+// newTestUser returns a user whose password is "password".
+func newTestUser(t *testing.T, id int64) *storage.User {
+	t.Helper()
+	user := &storage.User{ID: id, Name: "Test Testov", Email: "test@gmail.com", Password: "password", Role: storage.RoleCustomer}
+	require.NoError(t, user.HashPassword())
+	return user
+}
+
+// serve runs a single request through handlers, the last of which is
+// registered for path.
+func serve(method, path, target, body string, cookies []*http.Cookie, handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Handle(method, path, handlers...)
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// withUser stands in for AuthMiddleware.
+func withUser(userID int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("userId", userID)
+	}
+}
+
+func responseCookie(rec *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body), rec.Body.String())
+	return body
+}
@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// JWKSHandler publishes the public access token keys so that other services
+// can verify our tokens offline.
+func (h *Handler) JWKSHandler(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.JWTService.JWKS())
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWKSHandler(t *testing.T) {
+	t.Run("publishes the public keys", func(t *testing.T) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		require.NoError(t, err)
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "2026-06.pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+		keys, err := auth.LoadKeySet(dir, "")
+		require.NoError(t, err)
+
+		h := newTestHandler(t)
+		h.JWTService = auth.NewJWTService(keys, "refresh-secret", time.Minute, time.Hour)
+
+		rec := serve(http.MethodGet, "/.well-known/jwks.json", "/.well-known/jwks.json", "", nil, h.JWKSHandler)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "public, max-age=300", rec.Header().Get("Cache-Control"))
+		var set auth.JWKS
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &set))
+		require.Len(t, set.Keys, 1)
+		assert.Equal(t, "2026-06", set.Keys[0].Kid)
+		assert.Equal(t, "OKP", set.Keys[0].Kty)
+		assert.NotContains(t, rec.Body.String(), `"d"`, "the private part stays on the server")
+	})
+
+	t.Run("publishes no shared secret", func(t *testing.T) {
+		h := newTestHandler(t)
+
+		rec := serve(http.MethodGet, "/.well-known/jwks.json", "/.well-known/jwks.json", "", nil, h.JWKSHandler)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"keys": []}`, rec.Body.String())
+	})
+}
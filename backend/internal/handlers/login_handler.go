@@ -1,59 +1,191 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"errors"
+	"github.com/AlexShmak/order-service/internal/audit"
+	"github.com/AlexShmak/order-service/internal/ratelimit"
 	"github.com/AlexShmak/order-service/internal/storage"
 	"github.com/gin-gonic/gin"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 )
 
 func (h *Handler) LoginHandler(c *gin.Context) {
 	var loginRequest struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email    string `json:"email" binding:"required,max=255"`
+		Password string `json:"password" binding:"required,max=255"`
 	}
 
-	if err := c.BindJSON(&loginRequest); err != nil {
+	if err := c.ShouldBindJSON(&loginRequest); err != nil {
 		h.Logger.Error("cannot bind JSON", slog.String("error", err.Error()))
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid email or password"})
+		c.IndentedJSON(bindingErrorStatus(err), gin.H{"error": bindingErrorMessage(err)})
 		return
 	}
 
-	user, err := h.Storage.Users.GetByEmail(c.Request.Context(), loginRequest.Email)
+	loginRequest.Email = storage.NormalizeEmail(loginRequest.Email)
+	lockoutKey := "login:" + loginRequest.Email
+	lockedFor, err := h.Lockout.Check(c.Request.Context(), lockoutKey)
 	if err != nil {
-		h.Logger.Error("user not found", slog.String("error", err.Error()))
+		h.Logger.Error("failed to check account lockout", slog.String("error", err.Error()))
+	} else if lockedFor > 0 {
+		h.Audit.Record(c, audit.Event{
+			Action:  audit.ActionLogin,
+			Outcome: audit.OutcomeDenied,
+			Details: map[string]any{"email_hash": h.Audit.EmailHash(loginRequest.Email), "reason": "locked out"},
+		})
+		ratelimit.SetRetryAfter(c, lockedFor)
+		c.IndentedJSON(http.StatusTooManyRequests, gin.H{"error": "too many failed login attempts"})
+		return
+	}
+
+	user, err := h.Storage.Users.GetByEmail(c.Request.Context(), loginRequest.Email)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		h.Logger.Error("failed to get user", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+		return
+	}
+	if user == nil {
+		checkPassword(nil, loginRequest.Password)
+		h.Logger.Info("login with an unknown email")
+		h.Audit.Record(c, audit.Event{
+			Action:  audit.ActionLogin,
+			Outcome: audit.OutcomeFailure,
+			Details: map[string]any{"email_hash": h.Audit.EmailHash(loginRequest.Email), "reason": "unknown account"},
+		})
+		h.registerLoginFailure(c, lockoutKey, audit.Event{Details: map[string]any{"email_hash": h.Audit.EmailHash(loginRequest.Email)}})
 		c.IndentedJSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password"})
 		return
 	}
 
-	if !user.CheckPasswordHash(loginRequest.Password) {
+	if !checkPassword(user, loginRequest.Password) {
 		h.Logger.Error("invalid password")
+		h.Audit.Record(c, audit.Event{
+			Action:     audit.ActionLogin,
+			Outcome:    audit.OutcomeFailure,
+			TargetType: audit.TargetUser,
+			TargetID:   strconv.FormatInt(user.ID, 10),
+			Details:    map[string]any{"reason": "invalid password"},
+		})
+		h.registerLoginFailure(c, lockoutKey, audit.Event{
+			TargetType: audit.TargetUser,
+			TargetID:   strconv.FormatInt(user.ID, 10),
+			Details:    map[string]any{"email_hash": h.Audit.EmailHash(loginRequest.Email)},
+		})
 		c.IndentedJSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password"})
 		return
 	}
 
-	accessTokenString, refreshTokenString, err := h.JWTService.GenerateTokens(user.ID)
+	if err := h.Lockout.Reset(c.Request.Context(), lockoutKey); err != nil {
+		h.Logger.Error("failed to reset account lockout", slog.String("error", err.Error()))
+	}
+
+	cred, err := h.Storage.TOTP.Get(c.Request.Context(), user.ID)
+	if err != nil {
+		h.Logger.Error("failed to get TOTP credential", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+		return
+	}
+	if cred.IsEnabled() {
+		challengeToken, err := h.issueMFAChallenge(c.Request.Context(), user.ID)
+		if err != nil {
+			h.Logger.Error("failed to create MFA challenge", slog.String("error", err.Error()))
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, gin.H{"mfa_required": true, "challenge_token": challengeToken})
+		return
+	}
+
+	if !h.startSession(c, user.ID) {
+		return
+	}
+
+	h.Audit.Record(c, audit.Event{
+		Action:     audit.ActionLogin,
+		Outcome:    audit.OutcomeSuccess,
+		ActorID:    user.ID,
+		TargetType: audit.TargetUser,
+		TargetID:   strconv.FormatInt(user.ID, 10),
+		Details:    map[string]any{"method": "password"},
+	})
+	h.Logger.Info("user logged in", slog.Int64("id", user.ID))
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "logged in"})
+}
+
+// dummyUser has a password hash of the same cost as those of real users.
+var dummyUser = sync.OnceValue(func() *storage.User {
+	user := &storage.User{Password: rand.Text()}
+	if err := user.HashPassword(); err != nil {
+		panic(err)
+	}
+	return user
+})
+
+// checkPassword compares password with the hash of user. Without a user, or
+// for a user who only signs in through single sign-on, it compares with a
+// dummy hash and fails, so that the response time does not tell which emails
+// are registered.
+func checkPassword(user *storage.User, password string) bool {
+	if user == nil || !user.HasPassword() {
+		dummyUser().CheckPasswordHash(password)
+		return false
+	}
+	return user.CheckPasswordHash(password)
+}
+
+// startSession issues a new token pair and sets the cookies. It writes the
+// error response itself and reports whether the session was started.
+func (h *Handler) startSession(c *gin.Context, userID int64) bool {
+	accessTokenString, refreshTokenString, err := h.JWTService.GenerateTokens(userID)
 	if err != nil {
 		h.Logger.Error("failed to generate tokens", slog.String("error", err.Error()))
 		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to generate tokens"})
-		return
+		return false
 	}
 
 	refreshToken := &storage.RefreshToken{
-		UserID:    user.ID,
+		UserID:    userID,
 		Token:     refreshTokenString,
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
+		ExpiresAt: time.Now().Add(h.Config.JWT.RefreshTokenTTL),
 	}
 	if err := h.Storage.Tokens.Create(c.Request.Context(), refreshToken); err != nil {
 		h.Logger.Error("failed to save refresh token", slog.String("error", err.Error()))
 		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
-		return
+		return false
 	}
 
-	c.SetCookie("access_token", accessTokenString, 15*60, "/", "", false, true)
-	c.SetCookie("refresh_token", refreshTokenString, 7*24*60*60, "/", "", false, true)
+	if err := h.setSessionCookies(c, accessTokenString, refreshTokenString); err != nil {
+		h.Logger.Error("failed to set session cookies", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+		return false
+	}
+	return true
+}
 
-	h.Logger.Info("user logged in", slog.Int64("id", user.ID))
-	c.IndentedJSON(http.StatusOK, gin.H{"message": "logged in"})
+// registerLoginFailure counts a failure under lockoutKey. The lockout event
+// identifies the account by subject, as the key may hold the email address,
+// which must not end up in the exported audit log.
+func (h *Handler) registerLoginFailure(c *gin.Context, lockoutKey string, subject audit.Event) {
+	lockedFor, err := h.Lockout.RegisterFailure(c.Request.Context(), lockoutKey)
+	if err != nil {
+		h.Logger.Error("failed to register login failure", slog.String("error", err.Error()))
+		return
+	}
+	if lockedFor > 0 {
+		details := map[string]any{"duration": lockedFor.String()}
+		for key, value := range subject.Details {
+			details[key] = value
+		}
+		h.Audit.Record(c, audit.Event{
+			Action:     audit.ActionLoginLockout,
+			Outcome:    audit.OutcomeSuccess,
+			TargetType: subject.TargetType,
+			TargetID:   subject.TargetID,
+			Details:    details,
+		})
+	}
 }
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/audit"
+	"github.com/AlexShmak/order-service/internal/auth"
+	"github.com/AlexShmak/order-service/internal/ratelimit"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginHandler(t *testing.T) {
+	t.Run("starts a session", func(t *testing.T) {
+		h := newTestHandler(t)
+		user := newTestUser(t, 1)
+		h.users.EXPECT().GetByEmail(mock.Anything, "test@gmail.com").Return(user, nil).Once()
+		h.totp.EXPECT().Get(mock.Anything, int64(1)).Return(nil, nil).Once()
+		h.tokens.EXPECT().Create(mock.Anything, mock.MatchedBy(func(token *storage.RefreshToken) bool {
+			return token.UserID == 1 && token.Token != ""
+		})).Return(nil).Once()
+
+		// a CSRF cookie from before the login, possibly planted, is replaced
+		rec := serve(http.MethodPost, "/auth/login", "/auth/login",
+			`{"email": " Test@Gmail.com", "password": "password"}`,
+			[]*http.Cookie{{Name: csrfTokenCookie, Value: "planted"}}, h.LoginHandler)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		for _, name := range []string{accessTokenCookie, refreshTokenCookie, csrfTokenCookie} {
+			cookie := responseCookie(rec, name)
+			require.NotNil(t, cookie, name)
+			assert.NotEmpty(t, cookie.Value, name)
+		}
+		assert.True(t, responseCookie(rec, accessTokenCookie).HttpOnly)
+		assert.False(t, responseCookie(rec, csrfTokenCookie).HttpOnly, "scripts have to echo the CSRF token")
+		assert.True(t, auth.VerifyCSRFToken(h.Config.JWT.RefreshSecret,
+			responseCookie(rec, refreshTokenCookie).Value, responseCookie(rec, csrfTokenCookie).Value))
+
+		token, err := h.JWTService.ValidateAccessToken(responseCookie(rec, accessTokenCookie).Value)
+		require.NoError(t, err)
+		userID, err := h.JWTService.GetUserIdFromToken(token)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), userID)
+	})
+
+	t.Run("asks for the second factor", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.users.EXPECT().GetByEmail(mock.Anything, "test@gmail.com").Return(newTestUser(t, 1), nil).Once()
+		h.totp.EXPECT().Get(mock.Anything, int64(1)).Return(&storage.TOTPCredential{ConfirmedAt: new(time.Time)}, nil).Once()
+		h.actionTokens.EXPECT().Create(mock.Anything, mock.MatchedBy(func(token *storage.ActionToken) bool {
+			return token.UserID == 1 && token.Purpose == storage.PurposeMFAChallenge && len(token.TokenHash) > 0
+		})).Return(nil).Once()
+
+		rec := serve(http.MethodPost, "/auth/login", "/auth/login",
+			`{"email": "test@gmail.com", "password": "password"}`, nil, h.LoginHandler)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		body := decodeBody(t, rec)
+		assert.Equal(t, true, body["mfa_required"])
+		assert.NotEmpty(t, body["challenge_token"])
+		assert.Nil(t, responseCookie(rec, accessTokenCookie), "no session before the second factor")
+	})
+
+	t.Run("rejects a wrong password", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.users.EXPECT().GetByEmail(mock.Anything, "test@gmail.com").Return(newTestUser(t, 1), nil).Once()
+
+		rec := serve(http.MethodPost, "/auth/login", "/auth/login",
+			`{"email": "test@gmail.com", "password": "wrong password"}`, nil, h.LoginHandler)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Nil(t, responseCookie(rec, accessTokenCookie))
+		assert.Equal(t, int64(1), h.lockout.failures["lockout:failures:login:test@gmail.com"])
+	})
+
+	t.Run("answers an unknown email like a wrong password", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.users.EXPECT().GetByEmail(mock.Anything, "nobody@gmail.com").Return(nil, storage.ErrNotFound).Once()
+
+		rec := serve(http.MethodPost, "/auth/login", "/auth/login",
+			`{"email": "nobody@gmail.com", "password": "password"}`, nil, h.LoginHandler)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "invalid email or password", decodeBody(t, rec)["error"])
+
+		require.Len(t, h.audited, 1)
+		details := h.audited[0].Details
+		assert.Equal(t, h.Audit.EmailHash("Nobody@Gmail.com"), details["email_hash"])
+		assert.NotContains(t, fmt.Sprint(details), "nobody@gmail.com", "audit events are exported and must not hold the address")
+	})
+
+	t.Run("does not count a storage error as a failed login", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.users.EXPECT().GetByEmail(mock.Anything, "test@gmail.com").Return(nil, errors.New("connection refused")).Once()
+
+		rec := serve(http.MethodPost, "/auth/login", "/auth/login",
+			`{"email": "test@gmail.com", "password": "password"}`, nil, h.LoginHandler)
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "connection refused")
+		assert.Empty(t, h.lockout.failures)
+		assert.Empty(t, h.audited)
+	})
+
+	t.Run("rejects a single sign-on account", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.users.EXPECT().GetByEmail(mock.Anything, "admin@example.com").Return(&storage.User{ID: 7, Email: "admin@example.com"}, nil).Once()
+
+		rec := serve(http.MethodPost, "/auth/login", "/auth/login",
+			`{"email": "admin@example.com", "password": "password"}`, nil, h.LoginHandler)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "invalid email or password", decodeBody(t, rec)["error"])
+	})
+
+	t.Run("records a lockout without the email address", func(t *testing.T) {
+		h := newTestHandler(t)
+		rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		h.Lockout = ratelimit.NewLockout(rdb, ratelimit.LockoutPolicy{Threshold: 1, Window: time.Minute, BaseDuration: time.Minute, MaxDuration: time.Hour})
+		h.users.EXPECT().GetByEmail(mock.Anything, "nobody@gmail.com").Return(nil, storage.ErrNotFound).Once()
+
+		rec := serve(http.MethodPost, "/auth/login", "/auth/login",
+			`{"email": "nobody@gmail.com", "password": "password"}`, nil, h.LoginHandler)
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		var lockout *storage.AuditEvent
+		for _, event := range h.audited {
+			if event.Action == audit.ActionLoginLockout {
+				lockout = event
+			}
+		}
+		require.NotNil(t, lockout)
+		assert.Equal(t, h.Audit.EmailHash("nobody@gmail.com"), lockout.Details["email_hash"])
+		assert.NotContains(t, fmt.Sprint(lockout.Details), "nobody@gmail.com")
+	})
+
+	t.Run("rejects a request without a password", func(t *testing.T) {
+		h := newTestHandler(t)
+		rec := serve(http.MethodPost, "/auth/login", "/auth/login", `{"email": "test@gmail.com"}`, nil, h.LoginHandler)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -1,21 +1,36 @@
 package handlers
 
 import (
+	"github.com/AlexShmak/order-service/internal/audit"
 	"github.com/gin-gonic/gin"
 	"log/slog"
 	"net/http"
+	"strconv"
 )
 
 func (h *Handler) LogoutHandler(c *gin.Context) {
-	refreshTokenString, err := c.Cookie("refresh_token")
+	refreshTokenString, err := c.Cookie(refreshTokenCookie)
 	if err == nil {
+		// the route is not behind AuthMiddleware, so the actor comes from the session itself
+		var actorID int64
+		if token, err := h.Storage.Tokens.GetByToken(c.Request.Context(), refreshTokenString); err == nil {
+			actorID = token.UserID
+		}
 		if err := h.Storage.Tokens.Delete(c.Request.Context(), refreshTokenString); err != nil {
 			h.Logger.Error("failed to delete refresh token on logout", slog.String("error", err.Error()))
 		}
+		if actorID != 0 {
+			h.Audit.Record(c, audit.Event{
+				Action:     audit.ActionLogout,
+				Outcome:    audit.OutcomeSuccess,
+				ActorID:    actorID,
+				TargetType: audit.TargetUser,
+				TargetID:   strconv.FormatInt(actorID, 10),
+			})
+		}
 	}
 
-	c.SetCookie("access_token", "", -1, "/", "", false, true)
-	c.SetCookie("refresh_token", "", -1, "/", "", false, true)
+	h.clearSessionCookies(c)
 
 	h.Logger.Info("user logged out")
 	c.IndentedJSON(http.StatusOK, gin.H{"message": "logged out"})
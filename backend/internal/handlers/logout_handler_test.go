@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogoutHandler(t *testing.T) {
+	t.Run("revokes the refresh token and clears the cookies", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.tokens.EXPECT().GetByToken(mock.Anything, "refresh").
+			Return(&storage.RefreshToken{UserID: 1, Token: "refresh", ExpiresAt: time.Now().Add(time.Hour)}, nil).Once()
+		h.tokens.EXPECT().Delete(mock.Anything, "refresh").Return(nil).Once()
+
+		rec := serve(http.MethodPost, "/auth/logout", "/auth/logout", "",
+			[]*http.Cookie{{Name: refreshTokenCookie, Value: "refresh"}}, h.LogoutHandler)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		for _, name := range []string{accessTokenCookie, refreshTokenCookie, csrfTokenCookie} {
+			cookie := responseCookie(rec, name)
+			require.NotNil(t, cookie, name)
+			assert.Empty(t, cookie.Value, name)
+			assert.Negative(t, cookie.MaxAge, name)
+		}
+	})
+
+	t.Run("succeeds without a session", func(t *testing.T) {
+		h := newTestHandler(t)
+		rec := serve(http.MethodPost, "/auth/logout", "/auth/logout", "", nil, h.LogoutHandler)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
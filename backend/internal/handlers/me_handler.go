@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AlexShmak/order-service/internal/audit"
+	"github.com/AlexShmak/order-service/internal/ratelimit"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func (h *Handler) GetMeHandler(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	c.IndentedJSON(http.StatusOK, profileResponse(user))
+}
+
+func (h *Handler) UpdateMeHandler(c *gin.Context) {
+	var request struct {
+		Name  *string `json:"name" binding:"omitempty,max=255"`
+		Email *string `json:"email" binding:"omitempty,email,max=255"`
+		// CurrentPassword is required to change the email, which is where
+		// password reset links go
+		CurrentPassword string `json:"current_password"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.IndentedJSON(bindingErrorStatus(err), gin.H{"error": bindingErrorMessage(err)})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if request.Name != nil {
+		user.Name = strings.TrimSpace(*request.Name)
+		if user.Name == "" {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "name must not be empty"})
+			return
+		}
+	}
+	emailChanged := false
+	if request.Email != nil {
+		email := storage.NormalizeEmail(*request.Email)
+		emailChanged = email != user.Email
+		user.Email = email
+	}
+	if emailChanged {
+		if !user.HasPassword() {
+			c.IndentedJSON(http.StatusForbidden, gin.H{"error": "the email of a single sign-on account is managed by the identity provider"})
+			return
+		}
+		if !h.verifyCurrentPassword(c, user, request.CurrentPassword) {
+			return
+		}
+	}
+
+	// links sent to the old address must not work once it is replaced
+	err := h.Storage.Tx.WithinTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.Storage.Users.UpdateProfile(ctx, user); err != nil {
+			return err
+		}
+		if !emailChanged {
+			return nil
+		}
+		for _, purpose := range []string{storage.PurposePasswordReset, storage.PurposeEmailVerification} {
+			if err := h.Storage.ActionTokens.RevokeByUser(ctx, user.ID, purpose); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrEmailTaken) {
+			c.IndentedJSON(http.StatusConflict, gin.H{"error": "email is already registered"})
+			return
+		}
+		h.Logger.Error("failed to update profile", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to update profile"})
+		return
+	}
+
+	if emailChanged {
+		h.sendVerificationEmail(c.Request.Context(), user)
+	}
+
+	h.Logger.Info("profile updated", slog.Int64("id", user.ID))
+	c.IndentedJSON(http.StatusOK, profileResponse(user))
+}
+
+func (h *Handler) ChangePasswordHandler(c *gin.Context) {
+	var request struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.IndentedJSON(bindingErrorStatus(err), gin.H{"error": bindingErrorMessage(err)})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !h.verifyCurrentPassword(c, user, request.CurrentPassword) {
+		return
+	}
+
+	if err := h.PasswordPolicy.Validate(request.NewPassword); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the current session stays, every other one has to log in with the new password
+	currentRefreshToken, _ := c.Cookie(refreshTokenCookie)
+	user.Password = request.NewPassword
+	err := h.Storage.Tx.WithinTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.Storage.Users.UpdatePassword(ctx, user); err != nil {
+			return err
+		}
+		return h.Storage.Tokens.DeleteByUserIDExcept(ctx, user.ID, currentRefreshToken)
+	})
+	if err != nil {
+		h.Logger.Error("failed to update password", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to change password"})
+		return
+	}
+
+	h.Logger.Info("password changed", slog.Int64("id", user.ID))
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "password changed"})
+}
+
+func (h *Handler) DeleteMeHandler(c *gin.Context) {
+	var request struct {
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.IndentedJSON(bindingErrorStatus(err), gin.H{"error": bindingErrorMessage(err)})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	// a single sign-on account has no password to confirm with, its session
+	// was authenticated by the identity provider
+	if user.HasPassword() && !h.verifyCurrentPassword(c, user, request.Password) {
+		return
+	}
+
+	// the orders keep no PII of a deleted user, and a user is never deleted
+	// with PII left behind
+	var orderUIDs []string
+	err := h.Storage.Tx.WithinTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if orderUIDs, err = h.Storage.Orders.AnonymizeByCustomer(ctx, user.ID); err != nil {
+			return fmt.Errorf("could not anonymize orders: %w", err)
+		}
+		return h.Storage.Users.Delete(ctx, user.ID)
+	})
+	if err != nil {
+		h.Logger.Error("failed to delete user", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to delete account"})
+		return
+	}
+	if err := h.Cache.Orders.Delete(c.Request.Context(), orderUIDs...); err != nil {
+		h.Logger.Error("failed to evict anonymized orders from cache", slog.String("error", err.Error()))
+	}
+
+	h.clearSessionCookies(c)
+
+	h.Logger.Info("account deleted", slog.Int64("id", user.ID), slog.Int("anonymized_orders", len(orderUIDs)))
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "account deleted"})
+}
+
+// verifyCurrentPassword guards account changes behind the password. Wrong
+// guesses count towards a lockout per user, so that a stolen session cannot
+// try passwords at the rate of the API limit. It writes the error response
+// itself.
+func (h *Handler) verifyCurrentPassword(c *gin.Context, user *storage.User, password string) bool {
+	lockoutKey := fmt.Sprintf("password:%d", user.ID)
+	lockedFor, err := h.Lockout.Check(c.Request.Context(), lockoutKey)
+	if err != nil {
+		h.Logger.Error("failed to check account lockout", slog.String("error", err.Error()))
+	} else if lockedFor > 0 {
+		ratelimit.SetRetryAfter(c, lockedFor)
+		c.IndentedJSON(http.StatusTooManyRequests, gin.H{"error": "too many failed password attempts"})
+		return false
+	}
+
+	if !user.CheckPasswordHash(password) {
+		h.registerLoginFailure(c, lockoutKey, audit.Event{TargetType: audit.TargetUser, TargetID: strconv.FormatInt(user.ID, 10)})
+		c.IndentedJSON(http.StatusForbidden, gin.H{"error": "current password is incorrect"})
+		return false
+	}
+
+	if err := h.Lockout.Reset(c.Request.Context(), lockoutKey); err != nil {
+		h.Logger.Error("failed to reset account lockout", slog.String("error", err.Error()))
+	}
+	return true
+}
+
+func profileResponse(user *storage.User) gin.H {
+	return gin.H{
+		"id":                user.ID,
+		"name":              user.Name,
+		"email":             user.Email,
+		"role":              user.Role,
+		"email_verified_at": user.EmailVerifiedAt,
+		"created_at":        user.CreatedAt,
+	}
+}
+
+// currentUser loads the user set by AuthMiddleware and writes the error response itself.
+func (h *Handler) currentUser(c *gin.Context) (*storage.User, bool) {
+	userId, exists := c.Get("userId")
+	if !exists {
+		c.IndentedJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return nil, false
+	}
+
+	user, err := h.Storage.Users.GetByID(c.Request.Context(), userId.(int64))
+	if err != nil {
+		h.Logger.Error("failed to get current user", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateMeHandler(t *testing.T) {
+	t.Run("requires the password to change the email", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.users.EXPECT().GetByID(mock.Anything, int64(1)).Return(newTestUser(t, 1), nil).Once()
+
+		rec := serve(http.MethodPatch, "/me", "/me", `{"email": "new@example.com"}`, nil, withUser(1), h.UpdateMeHandler)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+		assert.EqualValues(t, 1, h.lockout.failures["lockout:failures:password:1"])
+	})
+
+	t.Run("revokes the links sent to the old email with the change", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.users.EXPECT().GetByID(mock.Anything, int64(1)).Return(newTestUser(t, 1), nil).Once()
+		updated := h.users.EXPECT().UpdateProfile(inTx(), mock.MatchedBy(func(user *storage.User) bool {
+			return user.Email == "new@example.com"
+		})).Return(nil).Once()
+		h.actionTokens.EXPECT().RevokeByUser(inTx(), int64(1), storage.PurposePasswordReset).Return(nil).Once().NotBefore(updated)
+		revoked := h.actionTokens.EXPECT().RevokeByUser(inTx(), int64(1), storage.PurposeEmailVerification).Return(nil).Once().NotBefore(updated)
+		h.actionTokens.EXPECT().Create(mock.Anything, mock.MatchedBy(func(token *storage.ActionToken) bool {
+			return token.UserID == 1 && token.Purpose == storage.PurposeEmailVerification
+		})).Return(nil).Once().NotBefore(revoked)
+
+		body := `{"email": "New@Example.com", "current_password": "password"}`
+		rec := serve(http.MethodPatch, "/me", "/me", body, nil, withUser(1), h.UpdateMeHandler)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "new@example.com", decodeBody(t, rec)["email"])
+	})
+
+	t.Run("changes the name without the password", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.users.EXPECT().GetByID(mock.Anything, int64(1)).Return(newTestUser(t, 1), nil).Once()
+		h.users.EXPECT().UpdateProfile(inTx(), mock.MatchedBy(func(user *storage.User) bool {
+			return user.Name == "Test Testovich" && user.Email == "test@gmail.com"
+		})).Return(nil).Once()
+
+		body := `{"name": " Test Testovich ", "email": "Test@Gmail.com"}`
+		rec := serve(http.MethodPatch, "/me", "/me", body, nil, withUser(1), h.UpdateMeHandler)
+
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	})
+
+	t.Run("leaves the email of a single sign-on account alone", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.users.EXPECT().GetByID(mock.Anything, int64(7)).Return(&storage.User{ID: 7, Email: "admin@example.com", Role: storage.RoleAdmin}, nil).Once()
+
+		rec := serve(http.MethodPatch, "/me", "/me", `{"email": "new@example.com"}`, nil, withUser(7), h.UpdateMeHandler)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+	})
+}
+
+func TestChangePasswordHandler(t *testing.T) {
+	t.Run("counts wrong passwords towards the lockout", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.users.EXPECT().GetByID(mock.Anything, int64(1)).Return(newTestUser(t, 1), nil).Twice()
+
+		body := `{"current_password": "wrong", "new_password": "correct horse"}`
+		for range 2 {
+			rec := serve(http.MethodPost, "/me/password", "/me/password", body, nil, withUser(1), h.ChangePasswordHandler)
+			assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+		}
+		assert.EqualValues(t, 2, h.lockout.failures["lockout:failures:password:1"])
+	})
+
+	t.Run("keeps the current session", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.lockout.failures["lockout:failures:password:1"] = 2
+		h.users.EXPECT().GetByID(mock.Anything, int64(1)).Return(newTestUser(t, 1), nil).Once()
+		h.users.EXPECT().UpdatePassword(inTx(), mock.MatchedBy(func(user *storage.User) bool {
+			return user.Password == "correct horse"
+		})).Return(nil).Once()
+		h.tokens.EXPECT().DeleteByUserIDExcept(inTx(), int64(1), "current-refresh").Return(nil).Once()
+
+		body := `{"current_password": "password", "new_password": "correct horse"}`
+		cookies := []*http.Cookie{{Name: refreshTokenCookie, Value: "current-refresh"}}
+		rec := serve(http.MethodPost, "/me/password", "/me/password", body, cookies, withUser(1), h.ChangePasswordHandler)
+
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.NotContains(t, h.lockout.failures, "lockout:failures:password:1", "a correct password resets the count")
+	})
+}
+
+func TestDeleteMeHandler(t *testing.T) {
+	expectDelete := func(h *testHandler, userID int64) {
+		h.orders.EXPECT().AnonymizeByCustomer(inTx(), userID).Return([]string{"b563feb7b2b84b6test"}, nil).Once()
+		h.users.EXPECT().Delete(inTx(), userID).Return(nil).Once()
+	}
+
+	t.Run("rejects a wrong password", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.users.EXPECT().GetByID(mock.Anything, int64(1)).Return(newTestUser(t, 1), nil).Once()
+
+		rec := serve(http.MethodDelete, "/me", "/me", `{"password": "wrong"}`, nil, withUser(1), h.DeleteMeHandler)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+		assert.EqualValues(t, 1, h.lockout.failures["lockout:failures:password:1"])
+	})
+
+	t.Run("anonymizes the orders and evicts them from the cache", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.cache.orders["b563feb7b2b84b6test"] = &storage.Order{OrderUID: "b563feb7b2b84b6test"}
+		h.users.EXPECT().GetByID(mock.Anything, int64(1)).Return(newTestUser(t, 1), nil).Once()
+		expectDelete(h, 1)
+
+		rec := serve(http.MethodDelete, "/me", "/me", `{"password": "password"}`, nil, withUser(1), h.DeleteMeHandler)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Empty(t, h.cache.orders)
+		cookie := responseCookie(rec, refreshTokenCookie)
+		require.NotNil(t, cookie)
+		assert.Negative(t, cookie.MaxAge)
+	})
+
+	t.Run("deletes a single sign-on account without a password", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.users.EXPECT().GetByID(mock.Anything, int64(7)).Return(&storage.User{ID: 7, Email: "admin@example.com", Role: storage.RoleAdmin}, nil).Once()
+		expectDelete(h, 7)
+
+		rec := serve(http.MethodDelete, "/me", "/me", `{}`, nil, withUser(7), h.DeleteMeHandler)
+
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	})
+}
@@ -1,7 +1,8 @@
 package handlers
 
 import (
-	"encoding/json"
+	"github.com/AlexShmak/order-service/internal/audit"
+	"github.com/AlexShmak/order-service/internal/events"
 	"github.com/AlexShmak/order-service/internal/storage"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -26,9 +27,11 @@ func (h *Handler) GetOrderByIDHandler(c *gin.Context) {
 	}
 
 	// Check if order exists in cache
+	// the cache is shared by all customers; someone else's order falls through
+	// to the query, which answers not found
 	order, err := h.Cache.Orders.Get(c.Request.Context(), orderUID)
 	if err == nil {
-		if order != nil {
+		if order != nil && order.IsOwnedBy(userId.(int64)) {
 			h.Logger.Info("order found in cache", "id", orderUID)
 			c.IndentedJSON(http.StatusOK, order)
 			return
@@ -64,39 +67,39 @@ func (h *Handler) CreateOrderHandler(c *gin.Context) {
 
 	var orderRequest struct {
 		DeliveryInfo struct {
-			Name    string `json:"name" binding:"required"`
-			Phone   string `json:"phone" binding:"required"`
-			Zip     string `json:"zip" binding:"required"`
-			City    string `json:"city" binding:"required"`
-			Address string `json:"address" binding:"required"`
-			Region  string `json:"region" binding:"required"`
-			Email   string `json:"email" binding:"required,email"`
+			Name    string `json:"name" binding:"required,max=255"`
+			Phone   string `json:"phone" binding:"required,max=255"`
+			Zip     string `json:"zip" binding:"required,max=255"`
+			City    string `json:"city" binding:"required,max=255"`
+			Address string `json:"address" binding:"required,max=255"`
+			Region  string `json:"region" binding:"required,max=255"`
+			Email   string `json:"email" binding:"required,email,max=255"`
 		} `json:"delivery" binding:"required"`
 		Payment struct {
-			Currency     string `json:"currency" binding:"required"`
-			Provider     string `json:"provider" binding:"required"`
-			Bank         string `json:"bank" binding:"required"`
+			Currency     string `json:"currency" binding:"required,max=255"`
+			Provider     string `json:"provider" binding:"required,max=255"`
+			Bank         string `json:"bank" binding:"required,max=255"`
 			DeliveryCost int    `json:"delivery_cost" binding:"required"`
 			GoodsTotal   int    `json:"goods_total" binding:"required"`
 			CustomFee    int    `json:"custom_fee" binding:"gte=0"`
 		} `json:"payment" binding:"required"`
 		Items []struct {
 			ChrtID     int    `json:"chrt_id" binding:"required"`
-			Name       string `json:"name" binding:"required"`
+			Name       string `json:"name" binding:"required,max=255"`
 			Price      int    `json:"price" binding:"required"`
 			Sale       int    `json:"sale" binding:"required,min=0,max=100"`
-			Size       string `json:"size" binding:"required"`
-			Brand      string `json:"brand" binding:"required"`
+			Size       string `json:"size" binding:"required,max=255"`
+			Brand      string `json:"brand" binding:"required,max=255"`
 			TotalPrice int    `json:"total_price" binding:"required"`
 			NmID       int    `json:"nm_id" binding:"required"`
-		} `json:"items" binding:"required,gt=0,dive"`
-		Locale          string `json:"locale" binding:"required"`
-		DeliveryService string `json:"delivery_service" binding:"required"`
+		} `json:"items" binding:"required,gt=0,max=100,dive"`
+		Locale          string `json:"locale" binding:"required,max=255"`
+		DeliveryService string `json:"delivery_service" binding:"required,max=255"`
 	}
 
 	if err := c.ShouldBindJSON(&orderRequest); err != nil {
 		h.Logger.Error("invalid order request", "error", err.Error())
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(bindingErrorStatus(err), gin.H{"error": bindingErrorMessage(err)})
 		return
 	}
 
@@ -161,21 +164,41 @@ func (h *Handler) CreateOrderHandler(c *gin.Context) {
 		OofShard:          "1",
 	}
 
-	orderInBytes, err := json.Marshal(order)
+	event, err := events.NewOrderCreated(order, h.Config.Kafka.OrderEventVersion)
 	if err != nil {
-		h.Logger.Error("failed to marshal order", "error", err.Error())
+		h.Logger.Error("failed to encode order event", "error", err.Error())
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
 		return
 	}
 
-	// Send encoded order to kafka
-	err = h.KafkaProducer.PushOrderToQueue(h.Config.Kafka.Topic, orderInBytes)
+	// Send the order event to kafka
+	// orders from partner systems also name the API key they came through
+	auditDetails := map[string]any{"items": len(order.Items), "amount": order.Payment.Amount, "currency": order.Payment.Currency}
+	if apiKeyID, ok := c.Get("apiKeyId"); ok {
+		auditDetails["api_key_id"] = apiKeyID
+	}
+
+	err = h.KafkaProducer.Publish(h.Config.Kafka.Topic, event)
 	if err != nil {
 		h.Logger.Error("failed to push order to kafka", "error", err.Error())
+		h.Audit.Record(c, audit.Event{
+			Action:     audit.ActionOrderCreate,
+			Outcome:    audit.OutcomeFailure,
+			TargetType: audit.TargetOrder,
+			TargetID:   orderUID,
+			Details:    auditDetails,
+		})
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
 		return
 	}
 
+	h.Audit.Record(c, audit.Event{
+		Action:     audit.ActionOrderCreate,
+		Outcome:    audit.OutcomeSuccess,
+		TargetType: audit.TargetOrder,
+		TargetID:   orderUID,
+		Details:    auditDetails,
+	})
 	h.Logger.Info("order placed in queue successfully", "info", orderUID)
 	c.JSON(http.StatusCreated, gin.H{
 		"message":      "Order placed successfully",
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AlexShmak/order-service/internal/events"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testOrderUID = "b563feb7-b2b8-4b6e-9f2c-000000000000"
+
+func testOrder(customerID string) *storage.Order {
+	return &storage.Order{
+		OrderUID:    testOrderUID,
+		TrackNumber: "WBILMTESTTRACK",
+		CustomerID:  customerID,
+		Delivery:    storage.Delivery{Name: "Test Testov", Email: "test@gmail.com"},
+		Items:       []storage.Item{{ChrtID: 9934930, Name: "Mascaras"}},
+	}
+}
+
+func getOrder(h *testHandler, userID int64, uid string) *httptest.ResponseRecorder {
+	return serve(http.MethodGet, "/api/orders/:id", "/api/orders/"+uid, "", nil, withUser(userID), h.GetOrderByIDHandler)
+}
+
+func TestGetOrderByIDHandler(t *testing.T) {
+	t.Run("serves a cache hit without storage", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.cache.orders[testOrderUID] = testOrder("1")
+
+		rec := getOrder(h, 1, testOrderUID)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, testOrderUID, decodeBody(t, rec)["OrderUID"])
+	})
+
+	t.Run("loads a cache miss from storage and caches it", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.orders.EXPECT().GetByID(mock.Anything, testOrderUID, int64(1)).Return(testOrder("1"), nil).Once()
+
+		rec := getOrder(h, 1, testOrderUID)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, testOrderUID, decodeBody(t, rec)["OrderUID"])
+		assert.Equal(t, 1, h.cache.sets)
+		assert.Contains(t, h.cache.orders, testOrderUID)
+	})
+
+	t.Run("falls back to storage when the cache fails", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.cache.err = errors.New("connection refused")
+		h.orders.EXPECT().GetByID(mock.Anything, testOrderUID, int64(1)).Return(testOrder("1"), nil).Once()
+
+		rec := getOrder(h, 1, testOrderUID)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("answers not found", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.orders.EXPECT().GetByID(mock.Anything, testOrderUID, int64(1)).Return(nil, nil).Once()
+
+		rec := getOrder(h, 1, testOrderUID)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Zero(t, h.cache.sets)
+	})
+
+	t.Run("hides a cached order of another customer", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.cache.orders[testOrderUID] = testOrder("2")
+		h.orders.EXPECT().GetByID(mock.Anything, testOrderUID, int64(1)).Return(nil, nil).Once()
+
+		rec := getOrder(h, 1, testOrderUID)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "Test Testov")
+	})
+
+	t.Run("fails when storage fails", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.orders.EXPECT().GetByID(mock.Anything, testOrderUID, int64(1)).Return(nil, errors.New("connection refused")).Once()
+
+		rec := getOrder(h, 1, testOrderUID)
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
+
+const validOrderRequest = `{
+	"delivery": {
+		"name": "Test Testov",
+		"phone": "+9720000000",
+		"zip": "2639809",
+		"city": "Kiryat Mozkin",
+		"address": "Ploshad Mira 15",
+		"region": "Kraiot",
+		"email": "test@gmail.com"
+	},
+	"payment": {"currency": "USD", "provider": "wbpay", "bank": "alpha", "delivery_cost": 1500, "goods_total": 317},
+	"items": [{"chrt_id": 9934930, "name": "Mascaras", "price": 453, "sale": 30, "size": "0", "brand": "Vivienne Sabo", "total_price": 317, "nm_id": 2389212}],
+	"locale": "en",
+	"delivery_service": "meest"
+}`
+
+func createOrder(h *testHandler, body string) *httptest.ResponseRecorder {
+	return serve(http.MethodPost, "/api/orders", "/api/orders", body, nil, withUser(1), h.CreateOrderHandler)
+}
+
+func TestCreateOrderHandler(t *testing.T) {
+	t.Run("publishes a valid order", func(t *testing.T) {
+		h := newTestHandler(t)
+
+		rec := createOrder(h, validOrderRequest)
+
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		require.Len(t, h.publisher.published, 1)
+		event := h.publisher.published[0]
+		assert.Equal(t, events.TypeOrderCreated, event.Type)
+		assert.Equal(t, events.OrderCreatedVersion, event.Version)
+		assert.Equal(t, decodeBody(t, rec)["order_uid"], event.Key, "orders are keyed by their UID")
+		order, err := event.Order()
+		require.NoError(t, err)
+		assert.Equal(t, event.Key, order.OrderUID)
+		assert.Equal(t, "1", order.CustomerID)
+		assert.Equal(t, 1817, order.Payment.Amount)
+	})
+
+	t.Run("fails when the order cannot be published", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.publisher.err = errors.New("kafka: client has run out of available brokers")
+
+		rec := createOrder(h, validOrderRequest)
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+
+	invalid := map[string]func(string) string{
+		"missing delivery": func(body string) string {
+			return strings.Replace(body, `"delivery"`, `"shipping"`, 1)
+		},
+		"invalid email": func(body string) string {
+			return strings.Replace(body, `"test@gmail.com"`, `"test"`, 1)
+		},
+		"no items": func(body string) string {
+			return body[:strings.Index(body, `"items"`)] + `"items": [], "locale": "en", "delivery_service": "meest"}`
+		},
+		"sale above 100": func(body string) string {
+			return strings.Replace(body, `"sale": 30`, `"sale": 101`, 1)
+		},
+		"negative custom fee": func(body string) string {
+			return strings.Replace(body, `"goods_total": 317}`, `"goods_total": 317, "custom_fee": -1}`, 1)
+		},
+		"overlong name": func(body string) string {
+			return strings.Replace(body, `"Test Testov"`, `"`+strings.Repeat("a", 256)+`"`, 1)
+		},
+		"malformed JSON": func(body string) string {
+			return body[:len(body)/2]
+		},
+	}
+	for name, modify := range invalid {
+		t.Run("rejects "+name, func(t *testing.T) {
+			h := newTestHandler(t)
+
+			rec := createOrder(h, modify(validOrderRequest))
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+			assert.Empty(t, h.publisher.published)
+		})
+	}
+}
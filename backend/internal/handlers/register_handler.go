@@ -1,27 +1,71 @@
 package handlers
 
 import (
+	"errors"
+	"github.com/AlexShmak/order-service/internal/audit"
 	"github.com/AlexShmak/order-service/internal/storage"
 	"github.com/gin-gonic/gin"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
+type registerRequest struct {
+	Name     string `json:"name" binding:"required,max=255"`
+	Email    string `json:"email" binding:"required,email,max=255"`
+	Password string `json:"password" binding:"required"`
+}
+
 func (h *Handler) RegisterHandler(c *gin.Context) {
-	var user storage.User
+	var request registerRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.Logger.Error("invalid register request", slog.String("error", err.Error()))
+		c.IndentedJSON(bindingErrorStatus(err), gin.H{"error": bindingErrorMessage(err)})
+		return
+	}
 
-	if err := c.BindJSON(&user); err != nil {
-		h.Logger.Error("cannot bind JSON", slog.String("error", err.Error()))
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+	user := storage.User{
+		Name:     strings.TrimSpace(request.Name),
+		Email:    storage.NormalizeEmail(request.Email),
+		Password: request.Password,
+	}
+	if user.Name == "" {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	if err := h.PasswordPolicy.Validate(user.Password); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	if err := h.Storage.Users.Create(c.Request.Context(), &user); err != nil {
+		if errors.Is(err, storage.ErrEmailTaken) {
+			h.Audit.Record(c, audit.Event{
+				Action:  audit.ActionRegister,
+				Outcome: audit.OutcomeFailure,
+				Details: map[string]any{"email_hash": h.Audit.EmailHash(user.Email), "reason": "email taken"},
+			})
+			c.IndentedJSON(http.StatusConflict, gin.H{"error": "email is already registered"})
+			return
+		}
 		h.Logger.Error("cannot create user", slog.String("error", err.Error()))
 		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to create user"})
 		return
 	}
 
+	h.Audit.Record(c, audit.Event{
+		Action:     audit.ActionRegister,
+		Outcome:    audit.OutcomeSuccess,
+		ActorID:    user.ID,
+		TargetType: audit.TargetUser,
+		TargetID:   strconv.FormatInt(user.ID, 10),
+	})
+
+	h.sendVerificationEmail(c.Request.Context(), &user)
+
 	h.Logger.Info("user created", slog.Int64("id", user.ID))
 	c.IndentedJSON(http.StatusCreated, gin.H{"id": user.ID, "name": user.Name, "email": user.Email, "created_at": user.CreatedAt})
 }
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterHandler(t *testing.T) {
+	t.Run("creates the user and sends the verification email", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.users.EXPECT().Create(mock.Anything, mock.MatchedBy(func(user *storage.User) bool {
+			return user.Email == "test@gmail.com" && user.Name == "Test Testov"
+		})).RunAndReturn(func(_ context.Context, user *storage.User) error {
+			user.ID = 1
+			return nil
+		}).Once()
+		h.actionTokens.EXPECT().Create(mock.Anything, mock.MatchedBy(func(token *storage.ActionToken) bool {
+			return token.UserID == 1 && token.Purpose == storage.PurposeEmailVerification
+		})).Return(nil).Once()
+
+		rec := serve(http.MethodPost, "/auth/register", "/auth/register",
+			`{"name": " Test Testov ", "email": "Test@Gmail.com", "password": "correct horse"}`, nil, h.RegisterHandler)
+
+		assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		body := decodeBody(t, rec)
+		assert.EqualValues(t, 1, body["id"])
+		assert.Equal(t, "test@gmail.com", body["email"])
+		assert.NotContains(t, rec.Body.String(), "correct horse")
+	})
+
+	t.Run("rejects a taken email", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.users.EXPECT().Create(mock.Anything, mock.Anything).Return(storage.ErrEmailTaken).Once()
+
+		rec := serve(http.MethodPost, "/auth/register", "/auth/register",
+			`{"name": "Test Testov", "email": "test@gmail.com", "password": "correct horse"}`, nil, h.RegisterHandler)
+
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		require.Len(t, h.audited, 1)
+		assert.Equal(t, h.Audit.EmailHash("test@gmail.com"), h.audited[0].Details["email_hash"])
+		assert.NotContains(t, fmt.Sprint(h.audited[0].Details), "test@gmail.com")
+	})
+
+	t.Run("fails when the user cannot be stored", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.users.EXPECT().Create(mock.Anything, mock.Anything).Return(errors.New("connection refused")).Once()
+
+		rec := serve(http.MethodPost, "/auth/register", "/auth/register",
+			`{"name": "Test Testov", "email": "test@gmail.com", "password": "correct horse"}`, nil, h.RegisterHandler)
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "connection refused")
+	})
+
+	invalid := map[string]string{
+		"missing email":  `{"name": "Test Testov", "password": "correct horse"}`,
+		"invalid email":  `{"name": "Test Testov", "email": "test", "password": "correct horse"}`,
+		"blank name":     `{"name": "   ", "email": "test@gmail.com", "password": "correct horse"}`,
+		"short password": `{"name": "Test Testov", "email": "test@gmail.com", "password": "short"}`,
+		"malformed JSON": `{"name": `,
+	}
+	for name, body := range invalid {
+		t.Run("rejects "+name, func(t *testing.T) {
+			// the mocks fail the test if the handler gets as far as storage
+			h := newTestHandler(t)
+			rec := serve(http.MethodPost, "/auth/register", "/auth/register", body, nil, h.RegisterHandler)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"github.com/AlexShmak/order-service/internal/audit"
+	"github.com/AlexShmak/order-service/internal/oidc"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ssoStateCookie = "sso_state"
+	ssoStateTTL    = 10 * time.Minute
+)
+
+// SSOLoginHandler redirects to the issuer. The state, the nonce and the PKCE
+// code verifier are kept in a short-lived cookie until the callback.
+func (h *Handler) SSOLoginHandler(c *gin.Context) {
+	values := make([]string, 3)
+	for i := range values {
+		value, err := oidc.RandomString()
+		if err != nil {
+			h.Logger.Error("failed to generate SSO state", slog.String("error", err.Error()))
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to start single sign-on"})
+			return
+		}
+		values[i] = value
+	}
+	state, nonce, codeVerifier := values[0], values[1], values[2]
+
+	authURL, err := h.OIDC.AuthCodeURL(c.Request.Context(), state, nonce, codeVerifier)
+	if err != nil {
+		h.Logger.Error("failed to build authorization URL", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusBadGateway, gin.H{"error": "identity provider is unavailable"})
+		return
+	}
+
+	h.setSSOStateCookie(c, strings.Join(values, "."), seconds(ssoStateTTL))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+func (h *Handler) SSOCallbackHandler(c *gin.Context) {
+	stored, err := c.Cookie(ssoStateCookie)
+	h.setSSOStateCookie(c, "", -1)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "single sign-on session expired, please try again"})
+		return
+	}
+	values := strings.Split(stored, ".")
+	if len(values) != 3 {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "single sign-on session expired, please try again"})
+		return
+	}
+	state, nonce, codeVerifier := values[0], values[1], values[2]
+
+	if errorCode := c.Query("error"); errorCode != "" {
+		h.Logger.Warn("identity provider returned an error", slog.String("error", errorCode), slog.String("description", c.Query("error_description")))
+		c.IndentedJSON(http.StatusUnauthorized, gin.H{"error": "single sign-on was not completed"})
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(c.Query("state")), []byte(state)) != 1 {
+		h.Logger.Warn("SSO state mismatch", slog.String("ip", c.ClientIP()))
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid single sign-on state"})
+		return
+	}
+
+	claims, err := h.OIDC.Exchange(c.Request.Context(), c.Query("code"), codeVerifier, nonce)
+	if err != nil {
+		h.Logger.Error("failed to complete SSO code exchange", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusUnauthorized, gin.H{"error": "single sign-on failed"})
+		return
+	}
+
+	role, ok := h.staffRole(claims)
+	if !ok {
+		h.Logger.Warn("SSO user has no staff role", slog.String("subject", claims.Subject))
+		if !h.deprovisionSSOUser(c, claims.Subject) {
+			return
+		}
+		c.IndentedJSON(http.StatusForbidden, gin.H{"error": "your account is not allowed to sign in here"})
+		return
+	}
+
+	name := strings.TrimSpace(claims.Name)
+	if name == "" {
+		name = claims.Email
+	}
+	user, err := h.Storage.Identities.FindOrCreateUser(c.Request.Context(), &storage.ExternalIdentity{
+		Issuer:        h.OIDC.Issuer(),
+		Subject:       claims.Subject,
+		Email:         storage.NormalizeEmail(claims.Email),
+		EmailVerified: claims.EmailVerified,
+		Name:          name,
+		Role:          role,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrEmailTaken) {
+			c.IndentedJSON(http.StatusConflict, gin.H{"error": "an account with this email already exists and is not linked to single sign-on"})
+			return
+		}
+		h.Logger.Error("failed to provision SSO user", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "single sign-on failed"})
+		return
+	}
+
+	// the identity provider stands in for the password, not for the second
+	// factor the user enrolled here
+	cred, err := h.Storage.TOTP.Get(c.Request.Context(), user.ID)
+	if err != nil {
+		h.Logger.Error("failed to get TOTP credential", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "single sign-on failed"})
+		return
+	}
+	if cred.IsEnabled() {
+		challengeToken, err := h.issueMFAChallenge(c.Request.Context(), user.ID)
+		if err != nil {
+			h.Logger.Error("failed to create MFA challenge", slog.String("error", err.Error()))
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "single sign-on failed"})
+			return
+		}
+		// a fragment is not sent to any server, so the challenge stays out of access logs
+		c.Redirect(http.StatusFound, h.Config.Server.FrontendURL+"/login#challenge_token="+url.QueryEscape(challengeToken))
+		return
+	}
+
+	if !h.startSession(c, user.ID) {
+		return
+	}
+
+	h.Audit.Record(c, audit.Event{
+		Action:     audit.ActionLogin,
+		Outcome:    audit.OutcomeSuccess,
+		ActorID:    user.ID,
+		TargetType: audit.TargetUser,
+		TargetID:   strconv.FormatInt(user.ID, 10),
+		Details:    map[string]any{"method": "sso", "role": user.Role},
+	})
+
+	h.Logger.Info("user logged in through SSO", slog.Int64("id", user.ID), slog.String("role", user.Role))
+	c.Redirect(http.StatusFound, h.Config.Server.FrontendURL+"/order")
+}
+
+// deprovisionSSOUser takes the staff role and the sessions away from the user
+// linked to subject, once the issuer no longer maps it to a role. It writes
+// the error response itself.
+func (h *Handler) deprovisionSSOUser(c *gin.Context, subject string) bool {
+	var user *storage.User
+	err := h.Storage.Tx.WithinTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if user, err = h.Storage.Identities.Deprovision(ctx, h.OIDC.Issuer(), subject); err != nil || user == nil {
+			return err
+		}
+		return h.Storage.Tokens.DeleteByUserID(ctx, user.ID)
+	})
+	if err != nil {
+		h.Logger.Error("failed to deprovision SSO user", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "single sign-on failed"})
+		return false
+	}
+
+	if user != nil {
+		h.Audit.Record(c, audit.Event{
+			Action:     audit.ActionLogin,
+			Outcome:    audit.OutcomeDenied,
+			TargetType: audit.TargetUser,
+			TargetID:   strconv.FormatInt(user.ID, 10),
+			Details:    map[string]any{"method": "sso", "reason": "no staff role", "role": user.Role},
+		})
+		h.Logger.Info("SSO user deprovisioned", slog.Int64("id", user.ID))
+	}
+	return true
+}
+
+// staffRole maps the configured claim to a role. When several values match,
+// the most privileged role wins.
+func (h *Handler) staffRole(claims *oidc.Claims) (string, bool) {
+	role := ""
+	for _, value := range claims.StringValues(h.Config.OIDC.RoleClaim) {
+		switch h.Config.OIDC.RoleMapping[value] {
+		case storage.RoleAdmin:
+			return storage.RoleAdmin, true
+		case storage.RoleSupport:
+			role = storage.RoleSupport
+		}
+	}
+	return role, role != ""
+}
+
+// setSSOStateCookie always uses SameSite=Lax, as the cookie has to survive the
+// top-level redirect back from the issuer, which Strict would block.
+func (h *Handler) setSSOStateCookie(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(ssoStateCookie, value, maxAge, "/auth/sso", h.Config.Cookies.Domain, h.Config.Cookies.Secure, true)
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/config"
+	"github.com/AlexShmak/order-service/internal/oidc"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testFrontendURL = "http://localhost:3000"
+
+// newSSOTestHandler points the handler at a MockIssuer whose only user is an
+// admin.
+func newSSOTestHandler(t *testing.T) *testHandler {
+	t.Helper()
+	var issuer http.Handler
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issuer.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	mockIssuer, err := oidc.NewMockIssuer(server.URL, "orders-service", "mock-secret", []oidc.MockUser{
+		{Subject: "mock-admin", Email: "Admin@Example.com", Name: "Admin", Groups: []string{"admins"}},
+	})
+	require.NoError(t, err)
+	issuer = mockIssuer.Handler()
+
+	h := newTestHandler(t)
+	h.Config.Server.FrontendURL = testFrontendURL
+	h.Config.OIDC = config.OIDCConfig{
+		Enabled:      true,
+		IssuerURL:    server.URL,
+		ClientID:     "orders-service",
+		ClientSecret: "mock-secret",
+		RedirectURL:  "http://localhost:8080/auth/sso/callback",
+		Scopes:       []string{"openid", "email", "profile"},
+		RoleClaim:    "groups",
+		RoleMapping:  map[string]string{"admins": storage.RoleAdmin},
+	}
+	h.OIDC = oidc.NewClient(oidc.Config{
+		IssuerURL:    server.URL,
+		ClientID:     "orders-service",
+		ClientSecret: "mock-secret",
+		RedirectURL:  h.Config.OIDC.RedirectURL,
+		Scopes:       h.Config.OIDC.Scopes,
+	})
+	return h
+}
+
+// completeSSO logs in at the mock issuer and returns the response of the
+// callback.
+func completeSSO(t *testing.T, h *testHandler) *httptest.ResponseRecorder {
+	t.Helper()
+	router := gin.New()
+	router.GET("/auth/sso/login", h.SSOLoginHandler)
+	router.GET("/auth/sso/callback", h.SSOCallbackHandler)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/sso/login", nil))
+	require.Equal(t, http.StatusFound, rec.Code, rec.Body.String())
+	stateCookie := responseCookie(rec, ssoStateCookie)
+	require.NotNil(t, stateCookie)
+
+	// pick the user on the login page of the issuer
+	authURL, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	form := url.Values{"user": {"0"}}
+	for _, name := range []string{"redirect_uri", "state", "nonce", "code_challenge"} {
+		form.Set(name, authURL.Query().Get(name))
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.PostForm(authURL.Scheme+"://"+authURL.Host+authURL.Path, form)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	callbackURL, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, callbackURL.RequestURI(), nil)
+	req.AddCookie(stateCookie)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSSOCallbackHandler(t *testing.T) {
+	admin := &storage.User{ID: 7, Email: "admin@example.com", Role: storage.RoleAdmin}
+	expectIdentity := func(h *testHandler) {
+		h.identities.EXPECT().FindOrCreateUser(mock.Anything, mock.MatchedBy(func(identity *storage.ExternalIdentity) bool {
+			return identity.Subject == "mock-admin" && identity.Email == "admin@example.com" && identity.Role == storage.RoleAdmin
+		})).Return(admin, nil).Once()
+	}
+
+	t.Run("starts a session", func(t *testing.T) {
+		h := newSSOTestHandler(t)
+		expectIdentity(h)
+		h.totp.EXPECT().Get(mock.Anything, int64(7)).Return(nil, nil).Once()
+		h.tokens.EXPECT().Create(mock.Anything, mock.Anything).Return(nil).Once()
+
+		rec := completeSSO(t, h)
+
+		require.Equal(t, http.StatusFound, rec.Code, rec.Body.String())
+		assert.Equal(t, testFrontendURL+"/order", rec.Header().Get("Location"))
+		assert.NotNil(t, responseCookie(rec, accessTokenCookie))
+	})
+
+	t.Run("asks for the second factor of an account with TOTP", func(t *testing.T) {
+		h := newSSOTestHandler(t)
+		expectIdentity(h)
+		h.totp.EXPECT().Get(mock.Anything, int64(7)).Return(&storage.TOTPCredential{UserID: 7, ConfirmedAt: new(time.Time)}, nil).Once()
+		h.actionTokens.EXPECT().Create(mock.Anything, mock.MatchedBy(func(token *storage.ActionToken) bool {
+			return token.UserID == 7 && token.Purpose == storage.PurposeMFAChallenge
+		})).Return(nil).Once()
+
+		rec := completeSSO(t, h)
+
+		require.Equal(t, http.StatusFound, rec.Code, rec.Body.String())
+		location := rec.Header().Get("Location")
+		assert.True(t, strings.HasPrefix(location, testFrontendURL+"/login#challenge_token="), location)
+		assert.Nil(t, responseCookie(rec, accessTokenCookie), "no session before the second factor")
+		assert.Nil(t, responseCookie(rec, refreshTokenCookie))
+	})
+
+	t.Run("takes the staff role away once the issuer no longer grants it", func(t *testing.T) {
+		h := newSSOTestHandler(t)
+		h.Config.OIDC.RoleMapping = map[string]string{"managers": storage.RoleAdmin}
+		h.identities.EXPECT().Deprovision(inTx(), h.OIDC.Issuer(), "mock-admin").
+			Return(&storage.User{ID: 7, Email: "admin@example.com", Role: storage.RoleCustomer}, nil).Once()
+		h.tokens.EXPECT().DeleteByUserID(inTx(), int64(7)).Return(nil).Once()
+
+		rec := completeSSO(t, h)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+		assert.Nil(t, responseCookie(rec, accessTokenCookie))
+		require.Len(t, h.audited, 1)
+		assert.Equal(t, "7", h.audited[0].TargetID)
+	})
+
+	t.Run("rejects a user without a staff role who never signed in", func(t *testing.T) {
+		h := newSSOTestHandler(t)
+		h.Config.OIDC.RoleMapping = map[string]string{"managers": storage.RoleAdmin}
+		h.identities.EXPECT().Deprovision(inTx(), h.OIDC.Issuer(), "mock-admin").Return(nil, nil).Once()
+
+		rec := completeSSO(t, h)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+		assert.Empty(t, h.audited)
+	})
+}
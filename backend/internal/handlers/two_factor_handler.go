@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AlexShmak/order-service/internal/audit"
+	"github.com/AlexShmak/order-service/internal/auth"
+	"github.com/AlexShmak/order-service/internal/ratelimit"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// EnrollTOTPHandler starts enrollment. The secret only becomes active once a
+// code generated from it is confirmed, so an abandoned enrollment is harmless.
+func (h *Handler) EnrollTOTPHandler(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	secret, err := h.TOTP.GenerateSecret()
+	if err != nil {
+		h.Logger.Error("failed to generate TOTP secret", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to enroll"})
+		return
+	}
+	encrypted, err := h.TOTP.EncryptSecret(secret, user.ID)
+	if err != nil {
+		h.Logger.Error("failed to encrypt TOTP secret", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to enroll"})
+		return
+	}
+
+	if err := h.Storage.TOTP.SavePending(c.Request.Context(), user.ID, encrypted); err != nil {
+		if errors.Is(err, storage.ErrTOTPAlreadyEnabled) {
+			c.IndentedJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		h.Logger.Error("failed to save TOTP secret", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to enroll"})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"secret":           auth.EncodeTOTPSecret(secret),
+		"provisioning_uri": h.TOTP.ProvisioningURI(secret, user.Email),
+	})
+}
+
+// ConfirmTOTPHandler enables two-factor authentication and returns the
+// recovery codes. They are shown only this once.
+func (h *Handler) ConfirmTOTPHandler(c *gin.Context) {
+	var request struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.IndentedJSON(bindingErrorStatus(err), gin.H{"error": bindingErrorMessage(err)})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	cred, err := h.Storage.TOTP.Get(c.Request.Context(), user.ID)
+	if err != nil {
+		h.Logger.Error("failed to get TOTP credential", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to confirm two-factor authentication"})
+		return
+	}
+	if cred == nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "two-factor authentication enrollment was not started"})
+		return
+	}
+	if cred.IsEnabled() {
+		c.IndentedJSON(http.StatusConflict, gin.H{"error": storage.ErrTOTPAlreadyEnabled.Error()})
+		return
+	}
+
+	valid, err := h.verifyTOTPCode(c, cred, request.Code)
+	if err != nil {
+		h.Logger.Error("failed to verify TOTP code", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to confirm two-factor authentication"})
+		return
+	}
+	if !valid {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid code"})
+		return
+	}
+
+	codes, hashes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		h.Logger.Error("failed to generate recovery codes", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to confirm two-factor authentication"})
+		return
+	}
+	if err := h.Storage.TOTP.Confirm(c.Request.Context(), user.ID, hashes); err != nil {
+		h.Logger.Error("failed to confirm TOTP", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to confirm two-factor authentication"})
+		return
+	}
+
+	h.Logger.Info("two-factor authentication enabled", slog.Int64("id", user.ID))
+	c.IndentedJSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableTOTPHandler requires the password and a second factor, so that a
+// stolen session alone cannot turn two-factor authentication off.
+func (h *Handler) DisableTOTPHandler(c *gin.Context) {
+	var request struct {
+		Password     string `json:"password" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.IndentedJSON(bindingErrorStatus(err), gin.H{"error": bindingErrorMessage(err)})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	// guesses count towards the lockout of the second step of a login, a
+	// stolen session must not get more of them than the password does
+	lockoutKey := twoFactorLockoutKey(user.ID)
+	lockedFor, err := h.Lockout.Check(c.Request.Context(), lockoutKey)
+	if err != nil {
+		h.Logger.Error("failed to check account lockout", slog.String("error", err.Error()))
+	} else if lockedFor > 0 {
+		ratelimit.SetRetryAfter(c, lockedFor)
+		c.IndentedJSON(http.StatusTooManyRequests, gin.H{"error": "too many failed attempts"})
+		return
+	}
+	subject := audit.Event{TargetType: audit.TargetUser, TargetID: strconv.FormatInt(user.ID, 10)}
+
+	if !user.CheckPasswordHash(request.Password) {
+		h.registerLoginFailure(c, lockoutKey, subject)
+		c.IndentedJSON(http.StatusForbidden, gin.H{"error": "password is incorrect"})
+		return
+	}
+
+	cred, err := h.Storage.TOTP.Get(c.Request.Context(), user.ID)
+	if err != nil {
+		h.Logger.Error("failed to get TOTP credential", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to disable two-factor authentication"})
+		return
+	}
+	if !cred.IsEnabled() {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "two-factor authentication is not enabled"})
+		return
+	}
+
+	valid, err := h.verifySecondFactor(c, cred, request.Code, request.RecoveryCode)
+	if err != nil {
+		h.Logger.Error("failed to verify second factor", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to disable two-factor authentication"})
+		return
+	}
+	if !valid {
+		h.registerLoginFailure(c, lockoutKey, subject)
+		c.IndentedJSON(http.StatusForbidden, gin.H{"error": "invalid code"})
+		return
+	}
+
+	if err := h.Storage.TOTP.Delete(c.Request.Context(), user.ID); err != nil {
+		h.Logger.Error("failed to delete TOTP credential", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to disable two-factor authentication"})
+		return
+	}
+	if err := h.Lockout.Reset(c.Request.Context(), lockoutKey); err != nil {
+		h.Logger.Error("failed to reset account lockout", slog.String("error", err.Error()))
+	}
+
+	h.Logger.Info("two-factor authentication disabled", slog.Int64("id", user.ID))
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+}
+
+// twoFactorLockoutKey is the lockout of the guesses at the second factor of
+// a user.
+func twoFactorLockoutKey(userID int64) string {
+	return fmt.Sprintf("2fa:%d", userID)
+}
+
+// mfaChallengeTTL is how long the second step of a login may take.
+const mfaChallengeTTL = 5 * time.Minute
+
+// issueMFAChallenge hands out the token that proves the password step of a
+// two-step login. Like the emailed action tokens it is stored hashed and can
+// be used only once.
+func (h *Handler) issueMFAChallenge(ctx context.Context, userID int64) (string, error) {
+	tokenString, hash, err := auth.NewActionToken(h.Config.JWT.ActionTokenSecret)
+	if err != nil {
+		return "", err
+	}
+	token := &storage.ActionToken{
+		UserID:    userID,
+		Purpose:   storage.PurposeMFAChallenge,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(mfaChallengeTTL),
+	}
+	if err := h.Storage.ActionTokens.Create(ctx, token); err != nil {
+		return "", err
+	}
+	return tokenString, nil
+}
+
+// LoginTOTPHandler is the second step of the login of a user with
+// two-factor authentication enabled.
+func (h *Handler) LoginTOTPHandler(c *gin.Context) {
+	var request struct {
+		ChallengeToken string `json:"challenge_token" binding:"required"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.IndentedJSON(bindingErrorStatus(err), gin.H{"error": bindingErrorMessage(err)})
+		return
+	}
+
+	// the challenge is used up by the first attempt, right or wrong, so each
+	// password login buys a single guess at the code
+	if !auth.VerifyActionToken(h.Config.JWT.ActionTokenSecret, request.ChallengeToken) {
+		c.IndentedJSON(http.StatusUnauthorized, gin.H{"error": "login challenge is invalid or expired"})
+		return
+	}
+	challenge, err := h.Storage.ActionTokens.Consume(c.Request.Context(), auth.HashActionToken(request.ChallengeToken), storage.PurposeMFAChallenge)
+	if err != nil {
+		h.Logger.Error("failed to consume login challenge", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+		return
+	}
+	if challenge == nil {
+		c.IndentedJSON(http.StatusUnauthorized, gin.H{"error": "login challenge is invalid or expired"})
+		return
+	}
+	userID := challenge.UserID
+
+	// the code space is small, so guessing is throttled per account as well
+	lockoutKey := twoFactorLockoutKey(userID)
+	lockedFor, err := h.Lockout.Check(c.Request.Context(), lockoutKey)
+	if err != nil {
+		h.Logger.Error("failed to check account lockout", slog.String("error", err.Error()))
+	} else if lockedFor > 0 {
+		ratelimit.SetRetryAfter(c, lockedFor)
+		c.IndentedJSON(http.StatusTooManyRequests, gin.H{"error": "too many failed login attempts"})
+		return
+	}
+
+	cred, err := h.Storage.TOTP.Get(c.Request.Context(), userID)
+	if err != nil {
+		h.Logger.Error("failed to get TOTP credential", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+		return
+	}
+	if !cred.IsEnabled() {
+		// disabled between the two steps; the challenge is no longer meaningful
+		c.IndentedJSON(http.StatusUnauthorized, gin.H{"error": "login challenge is invalid or expired"})
+		return
+	}
+
+	valid, err := h.verifySecondFactor(c, cred, request.Code, request.RecoveryCode)
+	if err != nil {
+		h.Logger.Error("failed to verify second factor", slog.String("error", err.Error()))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+		return
+	}
+	if !valid {
+		h.Audit.Record(c, audit.Event{
+			Action:     audit.ActionLogin,
+			Outcome:    audit.OutcomeFailure,
+			TargetType: audit.TargetUser,
+			TargetID:   strconv.FormatInt(userID, 10),
+			Details:    map[string]any{"reason": "invalid second factor"},
+		})
+		h.registerLoginFailure(c, lockoutKey, audit.Event{TargetType: audit.TargetUser, TargetID: strconv.FormatInt(userID, 10)})
+		c.IndentedJSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+		return
+	}
+
+	if err := h.Lockout.Reset(c.Request.Context(), lockoutKey); err != nil {
+		h.Logger.Error("failed to reset account lockout", slog.String("error", err.Error()))
+	}
+
+	if !h.startSession(c, userID) {
+		return
+	}
+
+	method := "totp"
+	if request.RecoveryCode != "" {
+		method = "recovery_code"
+	}
+	h.Audit.Record(c, audit.Event{
+		Action:     audit.ActionLogin,
+		Outcome:    audit.OutcomeSuccess,
+		ActorID:    userID,
+		TargetType: audit.TargetUser,
+		TargetID:   strconv.FormatInt(userID, 10),
+		Details:    map[string]any{"method": method},
+	})
+
+	h.Logger.Info("user logged in", slog.Int64("id", userID), slog.Bool("recovery_code", request.RecoveryCode != ""))
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "logged in"})
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code.
+func (h *Handler) verifySecondFactor(c *gin.Context, cred *storage.TOTPCredential, code, recoveryCode string) (bool, error) {
+	switch {
+	case code != "":
+		return h.verifyTOTPCode(c, cred, code)
+	case recoveryCode != "":
+		return h.Storage.TOTP.UseRecoveryCode(c.Request.Context(), cred.UserID, auth.HashRecoveryCode(recoveryCode))
+	default:
+		return false, nil
+	}
+}
+
+// verifyTOTPCode also rejects a code whose time step was already used.
+func (h *Handler) verifyTOTPCode(c *gin.Context, cred *storage.TOTPCredential, code string) (bool, error) {
+	secret, err := h.TOTP.DecryptSecret(cred.SecretEncrypted, cred.UserID)
+	if err != nil {
+		return false, err
+	}
+	step, ok := h.TOTP.Verify(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return h.Storage.TOTP.MarkStepUsed(c.Request.Context(), cred.UserID, step)
+}
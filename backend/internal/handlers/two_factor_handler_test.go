@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/auth"
+	"github.com/AlexShmak/order-service/internal/ratelimit"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestChallenge issues a login challenge for userID and returns it with
+// its hash, by which the handler consumes it.
+func newTestChallenge(t *testing.T, h *testHandler, userID int64) (string, []byte) {
+	t.Helper()
+	var hash []byte
+	h.actionTokens.EXPECT().Create(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, token *storage.ActionToken) error {
+		hash = token.TokenHash
+		return nil
+	}).Once()
+	challenge, err := h.issueMFAChallenge(context.Background(), userID)
+	require.NoError(t, err)
+	require.Equal(t, auth.HashActionToken(challenge), hash)
+	return challenge, hash
+}
+
+func TestLoginTOTPHandler(t *testing.T) {
+	enabled := &storage.TOTPCredential{UserID: 1, ConfirmedAt: new(time.Time)}
+
+	t.Run("starts a session for a valid recovery code", func(t *testing.T) {
+		h := newTestHandler(t)
+		challenge, hash := newTestChallenge(t, h, 1)
+		h.actionTokens.EXPECT().Consume(mock.Anything, hash, storage.PurposeMFAChallenge).
+			Return(&storage.ActionToken{UserID: 1, Purpose: storage.PurposeMFAChallenge}, nil).Once()
+		h.totp.EXPECT().Get(mock.Anything, int64(1)).Return(enabled, nil).Once()
+		h.totp.EXPECT().UseRecoveryCode(mock.Anything, int64(1), auth.HashRecoveryCode("a1b2-c3d4")).Return(true, nil).Once()
+		h.tokens.EXPECT().Create(mock.Anything, mock.Anything).Return(nil).Once()
+
+		rec := serve(http.MethodPost, "/auth/login/2fa", "/auth/login/2fa",
+			`{"challenge_token": "`+challenge+`", "recovery_code": "a1b2-c3d4"}`, nil, h.LoginTOTPHandler)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.NotNil(t, responseCookie(rec, accessTokenCookie))
+	})
+
+	t.Run("uses the challenge up on a wrong code", func(t *testing.T) {
+		h := newTestHandler(t)
+		challenge, hash := newTestChallenge(t, h, 1)
+		h.actionTokens.EXPECT().Consume(mock.Anything, hash, storage.PurposeMFAChallenge).
+			Return(&storage.ActionToken{UserID: 1, Purpose: storage.PurposeMFAChallenge}, nil).Once()
+		h.totp.EXPECT().Get(mock.Anything, int64(1)).Return(enabled, nil).Once()
+		h.totp.EXPECT().UseRecoveryCode(mock.Anything, int64(1), mock.Anything).Return(false, nil).Once()
+
+		rec := serve(http.MethodPost, "/auth/login/2fa", "/auth/login/2fa",
+			`{"challenge_token": "`+challenge+`", "recovery_code": "wrong"}`, nil, h.LoginTOTPHandler)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, int64(1), h.lockout.failures["lockout:failures:2fa:1"])
+
+		// the second guess finds the challenge consumed and never reaches the code
+		h.actionTokens.EXPECT().Consume(mock.Anything, hash, storage.PurposeMFAChallenge).Return(nil, nil).Once()
+		rec = serve(http.MethodPost, "/auth/login/2fa", "/auth/login/2fa",
+			`{"challenge_token": "`+challenge+`", "recovery_code": "a1b2-c3d4"}`, nil, h.LoginTOTPHandler)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "login challenge is invalid or expired", decodeBody(t, rec)["error"])
+		assert.Nil(t, responseCookie(rec, accessTokenCookie))
+	})
+
+	t.Run("rejects a forged challenge without a lookup", func(t *testing.T) {
+		h := newTestHandler(t)
+		forged, _, err := auth.NewActionToken("another-secret")
+		require.NoError(t, err)
+
+		rec := serve(http.MethodPost, "/auth/login/2fa", "/auth/login/2fa",
+			`{"challenge_token": "`+forged+`", "code": "123456"}`, nil, h.LoginTOTPHandler)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestDisableTOTPHandler(t *testing.T) {
+	enabled := &storage.TOTPCredential{UserID: 1, ConfirmedAt: new(time.Time)}
+	disable := func(h *testHandler, body string) *httptest.ResponseRecorder {
+		return serve(http.MethodPost, "/me/2fa/disable", "/me/2fa/disable", body, nil, withUser(1), h.DisableTOTPHandler)
+	}
+
+	t.Run("counts a wrong password towards the lockout of the second factor", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.users.EXPECT().GetByID(mock.Anything, int64(1)).Return(newTestUser(t, 1), nil).Once()
+
+		rec := disable(h, `{"password": "wrong", "recovery_code": "a1b2-c3d4"}`)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+		assert.Equal(t, int64(1), h.lockout.failures["lockout:failures:2fa:1"])
+	})
+
+	t.Run("counts a wrong code towards the lockout of the second factor", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.users.EXPECT().GetByID(mock.Anything, int64(1)).Return(newTestUser(t, 1), nil).Once()
+		h.totp.EXPECT().Get(mock.Anything, int64(1)).Return(enabled, nil).Once()
+		h.totp.EXPECT().UseRecoveryCode(mock.Anything, int64(1), mock.Anything).Return(false, nil).Once()
+
+		rec := disable(h, `{"password": "password", "recovery_code": "wrong"}`)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+		assert.Equal(t, int64(1), h.lockout.failures["lockout:failures:2fa:1"])
+	})
+
+	t.Run("rejects a locked out account before checking anything", func(t *testing.T) {
+		h := newTestHandler(t)
+		rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		h.Lockout = ratelimit.NewLockout(rdb, ratelimit.LockoutPolicy{Threshold: 1, Window: time.Minute, BaseDuration: time.Minute, MaxDuration: time.Hour})
+		_, err := h.Lockout.RegisterFailure(context.Background(), "2fa:1")
+		require.NoError(t, err)
+		h.users.EXPECT().GetByID(mock.Anything, int64(1)).Return(newTestUser(t, 1), nil).Once()
+
+		rec := disable(h, `{"password": "password", "recovery_code": "a1b2-c3d4"}`)
+
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code, rec.Body.String())
+		assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	})
+
+	t.Run("disables two-factor authentication", func(t *testing.T) {
+		h := newTestHandler(t)
+		h.lockout.failures["lockout:failures:2fa:1"] = 2
+		h.users.EXPECT().GetByID(mock.Anything, int64(1)).Return(newTestUser(t, 1), nil).Once()
+		h.totp.EXPECT().Get(mock.Anything, int64(1)).Return(enabled, nil).Once()
+		h.totp.EXPECT().UseRecoveryCode(mock.Anything, int64(1), auth.HashRecoveryCode("a1b2-c3d4")).Return(true, nil).Once()
+		h.totp.EXPECT().Delete(mock.Anything, int64(1)).Return(nil).Once()
+
+		rec := disable(h, `{"password": "password", "recovery_code": "a1b2-c3d4"}`)
+
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Empty(t, h.lockout.failures)
+	})
+}
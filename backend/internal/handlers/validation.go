@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+func init() {
+	// a misspelled optional field would otherwise be dropped without notice
+	binding.EnableDecoderDisallowUnknownFields = true
+
+	// report JSON field names instead of Go struct field names in validation errors
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
+
+// bindingErrorStatus is the response status for an error from ShouldBindJSON.
+// bodyLimit only rejects bodies that declare their length up front, a chunked
+// body is cut off while it is decoded and has to be answered here.
+func bindingErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// unknownField returns the quoted name of the field rejected by a decoder with
+// DisallowUnknownFields. encoding/json reports it with an unexported error type,
+// so its message is all there is to go on.
+func unknownField(err error) (string, bool) {
+	return strings.CutPrefix(err.Error(), "json: unknown field ")
+}
+
+// bindingErrorMessage turns errors from ShouldBindJSON into a message that
+// names the offending field and the rule it broke.
+func bindingErrorMessage(err error) string {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		messages := make([]string, 0, len(validationErrors))
+		for _, fe := range validationErrors {
+			messages = append(messages, fieldErrorMessage(fe))
+		}
+		return strings.Join(messages, "; ")
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Sprintf("%s must be of type %s", typeErr.Field, typeErr.Type.String())
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return fmt.Sprintf("request body must not exceed %d bytes", maxBytesErr.Limit)
+	}
+
+	if field, ok := unknownField(err); ok {
+		return fmt.Sprintf("unknown field %s", field)
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return "request body is not valid JSON"
+	}
+	if errors.Is(err, io.EOF) {
+		return "request body is empty"
+	}
+
+	return err.Error()
+}
+
+func fieldErrorMessage(fe validator.FieldError) string {
+	// drop the name of a named request struct, e.g. "registerRequest.email". It is
+	// the same in both namespaces, while field names differ between JSON and Go;
+	// anonymous request structs have no such prefix.
+	field := fe.Namespace()
+	root, rest, ok := strings.Cut(field, ".")
+	if structRoot, _, _ := strings.Cut(fe.StructNamespace(), "."); ok && root == structRoot {
+		field = rest
+	}
+
+	unit := "characters"
+	if kind := fe.Kind(); kind == reflect.Slice || kind == reflect.Array || kind == reflect.Map {
+		unit = "items"
+	}
+
+	switch fe.Tag() {
+	case "required":
+		return field + " is required"
+	case "email":
+		return field + " must be a valid email address"
+	case "min":
+		if fe.Kind() == reflect.String || unit == "items" {
+			return fmt.Sprintf("%s must be at least %s %s", field, fe.Param(), unit)
+		}
+		return fmt.Sprintf("%s must be at least %s", field, fe.Param())
+	case "max":
+		if fe.Kind() == reflect.String || unit == "items" {
+			return fmt.Sprintf("%s must be at most %s %s", field, fe.Param(), unit)
+		}
+		return fmt.Sprintf("%s must be at most %s", field, fe.Param())
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, fe.Param())
+	case "gte":
+		return fmt.Sprintf("%s must be greater than or equal to %s", field, fe.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, fe.Param())
+	default:
+		return fmt.Sprintf("%s failed the %q rule", field, fe.Tag())
+	}
+}
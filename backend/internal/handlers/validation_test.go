@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chunkedReader hides the length of the body, so that httptest sends it like
+// a chunked request without a Content-Length.
+type chunkedReader struct {
+	io.Reader
+}
+
+func TestBindingErrorStatus(t *testing.T) {
+	t.Run("rejects a chunked body over the limit as too large", func(t *testing.T) {
+		h := newTestHandler(t)
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 64)
+		})
+		router.POST("/auth/login", h.LoginHandler)
+
+		body := `{"email": "test@gmail.com", "password": "` + strings.Repeat("a", 128) + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", chunkedReader{strings.NewReader(body)})
+		req.Header.Set("Content-Type", "application/json")
+		require.EqualValues(t, -1, req.ContentLength)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code, rec.Body.String())
+		assert.Equal(t, "request body must not exceed 64 bytes", decodeBody(t, rec)["error"])
+	})
+
+	t.Run("rejects other binding errors as bad requests", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, bindingErrorStatus(errors.New("json: cannot unmarshal")))
+	})
+}
+
+func TestUnknownField(t *testing.T) {
+	// pins the message format of encoding/json, which has no error type for it
+	decoder := json.NewDecoder(strings.NewReader(`{"name": "Test", "nmae": "Test"}`))
+	decoder.DisallowUnknownFields()
+	var request struct {
+		Name string `json:"name"`
+	}
+	err := decoder.Decode(&request)
+	require.Error(t, err)
+
+	field, ok := unknownField(err)
+	require.True(t, ok, err.Error())
+	assert.Equal(t, `"nmae"`, field)
+	assert.Equal(t, `unknown field "nmae"`, bindingErrorMessage(err))
+
+	_, ok = unknownField(errors.New("json: cannot unmarshal string into Go value of type int"))
+	assert.False(t, ok)
+}
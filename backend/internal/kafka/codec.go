@@ -0,0 +1,246 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/AlexShmak/order-service/internal/config"
+	"github.com/AlexShmak/order-service/internal/events"
+	"github.com/AlexShmak/order-service/internal/events/eventspb"
+	"github.com/IBM/sarama"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+
+	// magicByte starts every value in the Confluent wire format, followed by
+	// the schema ID as a big-endian uint32
+	magicByte = 0
+)
+
+var ErrUnknownContentType = errors.New("unknown content type")
+
+// Codec turns events into message values and back. Its content type goes
+// into the content_type header, so that consumers know how to decode.
+type Codec interface {
+	ContentType() string
+	Encode(topic string, event *events.Event) ([]byte, error)
+	Decode(topic string, value []byte) (*events.Event, error)
+}
+
+// JSONCodec writes the envelope as plain JSON, which workers without a schema
+// registry can read.
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (JSONCodec) Encode(_ string, event *events.Event) ([]byte, error) {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode event: %w", err)
+	}
+	return value, nil
+}
+
+func (JSONCodec) Decode(_ string, value []byte) (*events.Event, error) {
+	return events.Decode(value)
+}
+
+// ProtobufCodec writes eventspb.OrderEvent in the Confluent wire format, so
+// that Confluent deserializers and tools read the messages as well. The
+// schema is registered under the value subject of the topic on first use.
+type ProtobufCodec struct {
+	registry SchemaRegistry
+
+	mu         sync.Mutex
+	subjectIDs map[string]int
+	// knownIDs are the schema IDs found in the registry, decoding checks
+	// every other one
+	knownIDs map[int]bool
+}
+
+func NewProtobufCodec(registry SchemaRegistry) *ProtobufCodec {
+	return &ProtobufCodec{
+		registry:   registry,
+		subjectIDs: make(map[string]int),
+		knownIDs:   make(map[int]bool),
+	}
+}
+
+func (c *ProtobufCodec) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (c *ProtobufCodec) Encode(topic string, event *events.Event) ([]byte, error) {
+	msg, err := event.Proto()
+	if err != nil {
+		return nil, err
+	}
+	id, err := c.schemaID(ValueSubject(topic))
+	if err != nil {
+		return nil, err
+	}
+
+	value := []byte{magicByte}
+	value = binary.BigEndian.AppendUint32(value, uint32(id))
+	// the message indexes, a single 0 stands for the first message of the
+	// schema, OrderEvent
+	value = append(value, 0)
+	value, err = proto.MarshalOptions{}.MarshalAppend(value, msg)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode event: %w", err)
+	}
+	return value, nil
+}
+
+func (c *ProtobufCodec) Decode(_ string, value []byte) (*events.Event, error) {
+	if len(value) < 5 || value[0] != magicByte {
+		return nil, fmt.Errorf("could not decode event: not in the Confluent wire format")
+	}
+	id := int(binary.BigEndian.Uint32(value[1:5]))
+	if err := c.checkSchema(id); err != nil {
+		return nil, err
+	}
+
+	indexes, rest, err := readMessageIndexes(value[5:])
+	if err != nil {
+		return nil, err
+	}
+	if len(indexes) != 1 || indexes[0] != 0 {
+		return nil, fmt.Errorf("could not decode event: unexpected message indexes %v", indexes)
+	}
+
+	var msg eventspb.OrderEvent
+	if err := proto.Unmarshal(rest, &msg); err != nil {
+		return nil, fmt.Errorf("could not decode event: %w", err)
+	}
+	return events.FromProto(&msg)
+}
+
+func (c *ProtobufCodec) schemaID(subject string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if id, ok := c.subjectIDs[subject]; ok {
+		return id, nil
+	}
+	id, err := c.registry.Register(context.Background(), subject, Schema{Type: SchemaTypeProtobuf, Schema: eventspb.Schema})
+	if err != nil {
+		return 0, err
+	}
+	c.subjectIDs[subject] = id
+	c.knownIDs[id] = true
+	return id, nil
+}
+
+// checkSchema makes sure that id is a protobuf schema of the registry, an
+// unknown ID means the message was not written by a registered producer.
+func (c *ProtobufCodec) checkSchema(id int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.knownIDs[id] {
+		return nil
+	}
+	schema, err := c.registry.Schema(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	if schema.Type != SchemaTypeProtobuf {
+		return fmt.Errorf("schema %d is not a protobuf schema", id)
+	}
+	c.knownIDs[id] = true
+	return nil
+}
+
+// readMessageIndexes reads the path to the message type in the schema, a
+// count followed by that many indexes as zigzag varints. A count of zero
+// stands for [0].
+func readMessageIndexes(data []byte) ([]int64, []byte, error) {
+	count, n := binary.Varint(data)
+	if n <= 0 || count < 0 {
+		return nil, nil, fmt.Errorf("could not decode event: invalid message indexes")
+	}
+	data = data[n:]
+	if count == 0 {
+		return []int64{0}, data, nil
+	}
+
+	indexes := make([]int64, 0, min(count, 8))
+	for range count {
+		index, n := binary.Varint(data)
+		if n <= 0 {
+			return nil, nil, fmt.Errorf("could not decode event: invalid message indexes")
+		}
+		indexes = append(indexes, index)
+		data = data[n:]
+	}
+	return indexes, data, nil
+}
+
+// Codecs holds the codec producers encode with and every codec consumers can
+// decode with. Consumers pick one per message by its content_type header, so
+// that they read both encodings while producers are switched over.
+type Codecs struct {
+	encoder       Codec
+	byContentType map[string]Codec
+}
+
+func NewCodecs(encoder Codec, decoders ...Codec) *Codecs {
+	codecs := &Codecs{encoder: encoder, byContentType: make(map[string]Codec)}
+	for _, codec := range append(decoders, encoder) {
+		codecs.byContentType[codec.ContentType()] = codec
+	}
+	return codecs
+}
+
+// LoadCodecs encodes with the codec KAFKA_ENCODING names. Protobuf is
+// decoded whenever a schema registry is configured.
+func LoadCodecs(cfg *config.Config) (*Codecs, error) {
+	var registry SchemaRegistry
+	switch {
+	case cfg.Kafka.SchemaRegistryURL != "":
+		registry = NewHTTPSchemaRegistry(cfg.Kafka.SchemaRegistryURL)
+	case cfg.Kafka.SchemaRegistryFile != "":
+		registry = NewFileSchemaRegistry(cfg.Kafka.SchemaRegistryFile)
+	}
+
+	if registry == nil {
+		if cfg.Kafka.Encoding == "protobuf" {
+			return nil, errors.New("protobuf encoding needs a schema registry")
+		}
+		return NewCodecs(JSONCodec{}), nil
+	}
+
+	protobuf := NewProtobufCodec(registry)
+	if cfg.Kafka.Encoding == "protobuf" {
+		return NewCodecs(protobuf, JSONCodec{}), nil
+	}
+	return NewCodecs(JSONCodec{}, protobuf), nil
+}
+
+func (c *Codecs) Encoder() Codec {
+	return c.encoder
+}
+
+// Decode reads message with the codec of its content type. Messages without
+// one predate the header and are JSON.
+func (c *Codecs) Decode(message *sarama.ConsumerMessage) (*events.Event, error) {
+	contentType := Header(message, HeaderContentType)
+	if contentType == "" {
+		contentType = ContentTypeJSON
+	}
+	codec, ok := c.byContentType[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownContentType, contentType)
+	}
+	return codec.Decode(message.Topic, message.Value)
+}
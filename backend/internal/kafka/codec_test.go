@@ -0,0 +1,162 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/events"
+	"github.com/AlexShmak/order-service/internal/events/eventspb"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEvent(t *testing.T, version int) *events.Event {
+	t.Helper()
+	order := &storage.Order{
+		OrderUID:    "b563feb7-b2b8-4b6e-9f2c-000000000000",
+		TrackNumber: "WBILMTESTTRACK",
+		CustomerID:  "1",
+		Delivery:    storage.Delivery{Name: "Test Testov", Email: "test@gmail.com"},
+		Payment:     storage.Payment{Currency: "USD", Amount: 1817, PaymentDt: 1637907727},
+		Items:       []storage.Item{{ChrtID: 9934930, Name: "Mascaras", Price: 453}},
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+	}
+	event, err := events.NewOrderCreated(order, version)
+	require.NoError(t, err)
+	return event
+}
+
+func newTestRegistry(t *testing.T) *FileSchemaRegistry {
+	return NewFileSchemaRegistry(filepath.Join(t.TempDir(), "schemas.json"))
+}
+
+func TestProtobufCodec(t *testing.T) {
+	registry := newTestRegistry(t)
+	codec := NewProtobufCodec(registry)
+
+	for _, version := range []int{1, 2} {
+		event := testEvent(t, version)
+		value, err := codec.Encode("orders", event)
+		require.NoError(t, err)
+
+		id, err := registry.Register(context.Background(), "orders-value", Schema{Type: SchemaTypeProtobuf, Schema: eventspb.Schema})
+		require.NoError(t, err)
+		assert.Equal(t, byte(0), value[0], "magic byte")
+		assert.Equal(t, uint32(id), binary.BigEndian.Uint32(value[1:5]), "schema ID")
+		assert.Equal(t, byte(0), value[5], "message indexes of the first message")
+
+		// a second codec knows the schema only from the registry
+		decoded, err := NewProtobufCodec(registry).Decode("orders", value)
+		require.NoError(t, err)
+		assert.Equal(t, event.ID, decoded.ID)
+		assert.Equal(t, 2, decoded.Version, "protobuf carries the v2 payload")
+		assert.True(t, event.OccurredAt.Equal(decoded.OccurredAt))
+
+		want, err := event.Order()
+		require.NoError(t, err)
+		got, err := decoded.Order()
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+}
+
+func TestProtobufCodecOrderStored(t *testing.T) {
+	codec := NewProtobufCodec(newTestRegistry(t))
+	order, err := testEvent(t, 2).Order()
+	require.NoError(t, err)
+	event, err := events.NewOrderStored(order)
+	require.NoError(t, err)
+
+	value, err := codec.Encode("orders-stored", event)
+	require.NoError(t, err)
+	decoded, err := codec.Decode("orders-stored", value)
+	require.NoError(t, err)
+	assert.Equal(t, event.ID, decoded.ID)
+	assert.Equal(t, event.Key, decoded.Key)
+
+	want, err := event.OrderStored()
+	require.NoError(t, err)
+	got, err := decoded.OrderStored()
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestProtobufCodecRejectsUnknownSchemas(t *testing.T) {
+	codec := NewProtobufCodec(newTestRegistry(t))
+
+	_, err := codec.Decode("orders", []byte{0, 0, 0, 0, 42, 0})
+	assert.ErrorIs(t, err, ErrSchemaNotFound)
+
+	_, err = codec.Decode("orders", []byte(`{"id": "1"}`))
+	assert.Error(t, err)
+}
+
+func TestCodecsDecodeByContentType(t *testing.T) {
+	protobuf := NewProtobufCodec(newTestRegistry(t))
+	codecs := NewCodecs(JSONCodec{}, protobuf)
+	event := testEvent(t, 2)
+
+	for _, codec := range []Codec{JSONCodec{}, protobuf} {
+		msg, err := NewMessage("orders", event, codec)
+		require.NoError(t, err)
+		value, err := msg.Value.Encode()
+		require.NoError(t, err)
+
+		message := &sarama.ConsumerMessage{Topic: "orders", Value: value}
+		for _, header := range msg.Headers {
+			message.Headers = append(message.Headers, &header)
+		}
+		decoded, err := codecs.Decode(message)
+		require.NoError(t, err, codec.ContentType())
+		assert.Equal(t, event.ID, decoded.ID, codec.ContentType())
+	}
+
+	t.Run("reads messages without a content type as JSON", func(t *testing.T) {
+		value, err := JSONCodec{}.Encode("orders", event)
+		require.NoError(t, err)
+		decoded, err := codecs.Decode(&sarama.ConsumerMessage{Topic: "orders", Value: value})
+		require.NoError(t, err)
+		assert.Equal(t, event.ID, decoded.ID)
+	})
+
+	t.Run("rejects protobuf without a registry", func(t *testing.T) {
+		msg, err := NewMessage("orders", event, protobuf)
+		require.NoError(t, err)
+		value, err := msg.Value.Encode()
+		require.NoError(t, err)
+		message := &sarama.ConsumerMessage{Topic: "orders", Value: value, Headers: []*sarama.RecordHeader{
+			{Key: []byte(HeaderContentType), Value: []byte(ContentTypeProtobuf)},
+		}}
+
+		_, err = NewCodecs(JSONCodec{}).Decode(message)
+		assert.ErrorIs(t, err, ErrUnknownContentType)
+	})
+}
+
+func TestReadMessageIndexes(t *testing.T) {
+	tests := map[string]struct {
+		data []byte
+		want []int64
+	}{
+		"shortcut for the first message": {data: []byte{0, 0xff}, want: []int64{0}},
+		// zigzag varints, 2 stands for 1 and 4 for 2
+		"explicit first message": {data: []byte{2, 0, 0xff}, want: []int64{0}},
+		"nested message":         {data: []byte{4, 2, 4, 0xff}, want: []int64{1, 2}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			indexes, rest, err := readMessageIndexes(tt.data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, indexes)
+			assert.Equal(t, []byte{0xff}, rest)
+		})
+	}
+
+	_, _, err := readMessageIndexes([]byte{0x80})
+	assert.Error(t, err)
+}
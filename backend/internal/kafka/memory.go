@@ -0,0 +1,279 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/events"
+	"github.com/IBM/sarama"
+)
+
+// ErrBrokerClosed is returned by a MemoryBroker and its consumer groups once
+// the broker is closed.
+var ErrBrokerClosed = errors.New("memory broker is closed")
+
+// MemoryBroker is an in-process stand-in for Kafka, so that the path from the
+// API through the queue to the worker runs in tests without a cluster. Every
+// topic has a single partition, and consumer groups commit marked offsets
+// right away.
+type MemoryBroker struct {
+	mu     sync.Mutex
+	topics map[string][]*sarama.ConsumerMessage
+	// offsets holds the next offset to consume per group and topic
+	offsets map[string]map[string]int64
+	// published is closed and replaced on every message to wake up consumers
+	published chan struct{}
+	closed    bool
+	codec     Codec
+}
+
+var _ OrderPublisher = (*MemoryBroker)(nil)
+
+func NewMemoryBroker(codec Codec) *MemoryBroker {
+	return &MemoryBroker{
+		topics:    make(map[string][]*sarama.ConsumerMessage),
+		offsets:   make(map[string]map[string]int64),
+		published: make(chan struct{}),
+		codec:     codec,
+	}
+}
+
+// Publish encodes event with the codec of the broker like Producer does.
+func (b *MemoryBroker) Publish(topic string, event *events.Event) error {
+	msg, err := NewMessage(topic, event, b.codec)
+	if err != nil {
+		return err
+	}
+	return b.Send(msg)
+}
+
+// Send appends msg to its topic as it is, for tests that need messages the
+// API would not publish.
+func (b *MemoryBroker) Send(msg *sarama.ProducerMessage) error {
+	message := &sarama.ConsumerMessage{Topic: msg.Topic, Partition: 0, Timestamp: time.Now()}
+	var err error
+	if msg.Key != nil {
+		if message.Key, err = msg.Key.Encode(); err != nil {
+			return err
+		}
+	}
+	if msg.Value != nil {
+		if message.Value, err = msg.Value.Encode(); err != nil {
+			return err
+		}
+	}
+	for _, header := range msg.Headers {
+		message.Headers = append(message.Headers, &sarama.RecordHeader{Key: header.Key, Value: header.Value})
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrBrokerClosed
+	}
+	message.Offset = int64(len(b.topics[msg.Topic]))
+	b.topics[msg.Topic] = append(b.topics[msg.Topic], message)
+	close(b.published)
+	b.published = make(chan struct{})
+	return nil
+}
+
+// Messages returns everything published to topic so far.
+func (b *MemoryBroker) Messages(topic string) []*sarama.ConsumerMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*sarama.ConsumerMessage(nil), b.topics[topic]...)
+}
+
+// Close stops every consumer group session and rejects further messages.
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.closed {
+		b.closed = true
+		close(b.published)
+	}
+	return nil
+}
+
+// ConsumerGroup joins the group with the given ID. Consumers of the same
+// group share the committed offsets, but unlike Kafka every one of them
+// claims all partitions.
+func (b *MemoryBroker) ConsumerGroup(groupID string) sarama.ConsumerGroup {
+	return &memoryConsumerGroup{broker: b, groupID: groupID, errors: make(chan error)}
+}
+
+// next waits for the message at offset, it returns nil when ctx is done or
+// the broker is closed.
+func (b *MemoryBroker) next(ctx context.Context, topic string, offset int64) *sarama.ConsumerMessage {
+	for {
+		b.mu.Lock()
+		messages, published, closed := b.topics[topic], b.published, b.closed
+		b.mu.Unlock()
+
+		if closed {
+			return nil
+		}
+		if offset < int64(len(messages)) {
+			return messages[offset]
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-published:
+		}
+	}
+}
+
+func (b *MemoryBroker) committed(groupID, topic string) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.offsets[groupID][topic]
+}
+
+// commit stores offset for the group, only resetting moves it backwards.
+func (b *MemoryBroker) commit(groupID, topic string, offset int64, reset bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.offsets[groupID] == nil {
+		b.offsets[groupID] = make(map[string]int64)
+	}
+	if reset || offset > b.offsets[groupID][topic] {
+		b.offsets[groupID][topic] = offset
+	}
+}
+
+type memoryConsumerGroup struct {
+	broker    *MemoryBroker
+	groupID   string
+	errors    chan error
+	closeOnce sync.Once
+}
+
+// Consume runs one session over all topics until ctx is done or the broker
+// is closed, like a session of a Kafka consumer group that ends on rebalance.
+func (g *memoryConsumerGroup) Consume(ctx context.Context, topics []string, handler sarama.ConsumerGroupHandler) error {
+	g.broker.mu.Lock()
+	closed := g.broker.closed
+	g.broker.mu.Unlock()
+	if closed {
+		return ErrBrokerClosed
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	session := &memorySession{ctx: ctx, group: g, claims: make(map[string][]int32, len(topics))}
+	for _, topic := range topics {
+		session.claims[topic] = []int32{0}
+	}
+
+	if err := handler.Setup(session); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(topics))
+	for _, topic := range topics {
+		claim := &memoryClaim{
+			broker:        g.broker,
+			topic:         topic,
+			initialOffset: g.broker.committed(g.groupID, topic),
+			messages:      make(chan *sarama.ConsumerMessage),
+		}
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			defer close(claim.messages)
+			for offset := claim.initialOffset; ; offset++ {
+				message := g.broker.next(ctx, topic, offset)
+				if message == nil {
+					return
+				}
+				select {
+				case claim.messages <- message:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			// the session ends as soon as one claim stops, as it does in sarama
+			defer cancel()
+			if err := handler.ConsumeClaim(session, claim); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	if err := handler.Cleanup(session); err != nil {
+		return err
+	}
+	return <-errs
+}
+
+func (g *memoryConsumerGroup) Errors() <-chan error {
+	return g.errors
+}
+
+func (g *memoryConsumerGroup) Close() error {
+	g.closeOnce.Do(func() { close(g.errors) })
+	return nil
+}
+
+// Pause and Resume are not supported, the partitions are always consumed.
+func (g *memoryConsumerGroup) Pause(map[string][]int32)  {}
+func (g *memoryConsumerGroup) Resume(map[string][]int32) {}
+func (g *memoryConsumerGroup) PauseAll()                 {}
+func (g *memoryConsumerGroup) ResumeAll()                {}
+
+type memorySession struct {
+	ctx    context.Context
+	group  *memoryConsumerGroup
+	claims map[string][]int32
+}
+
+func (s *memorySession) Claims() map[string][]int32 { return s.claims }
+func (s *memorySession) MemberID() string           { return s.group.groupID + "-member" }
+func (s *memorySession) GenerationID() int32        { return 1 }
+func (s *memorySession) Context() context.Context   { return s.ctx }
+func (s *memorySession) Commit()                    {}
+
+func (s *memorySession) MarkOffset(topic string, _ int32, offset int64, _ string) {
+	s.group.broker.commit(s.group.groupID, topic, offset, false)
+}
+
+// ResetOffset takes effect from the next session on.
+func (s *memorySession) ResetOffset(topic string, _ int32, offset int64, _ string) {
+	s.group.broker.commit(s.group.groupID, topic, offset, true)
+}
+
+func (s *memorySession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.MarkOffset(msg.Topic, msg.Partition, msg.Offset+1, metadata)
+}
+
+type memoryClaim struct {
+	broker        *MemoryBroker
+	topic         string
+	initialOffset int64
+	messages      chan *sarama.ConsumerMessage
+}
+
+func (c *memoryClaim) Topic() string                            { return c.topic }
+func (c *memoryClaim) Partition() int32                         { return 0 }
+func (c *memoryClaim) InitialOffset() int64                     { return c.initialOffset }
+func (c *memoryClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+// HighWaterMarkOffset is the offset the next published message will get.
+func (c *memoryClaim) HighWaterMarkOffset() int64 {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	return int64(len(c.broker.topics[c.topic]))
+}
@@ -2,43 +2,127 @@ package kafka
 
 import (
 	"github.com/AlexShmak/order-service/internal/config"
+	"github.com/AlexShmak/order-service/internal/events"
 	"github.com/IBM/sarama"
 	"log/slog"
+	"strconv"
 )
 
+// The envelope metadata is repeated in headers, so that consumers and tools
+// can route and filter messages without decoding them.
+const (
+	HeaderEventID      = "event_id"
+	HeaderEventType    = "event_type"
+	HeaderEventVersion = "event_version"
+	HeaderContentType  = "content_type"
+)
+
+// OrderPublisher puts events on the queue the worker consumes. It is
+// implemented by Producer and, for tests, by MemoryBroker.
+type OrderPublisher interface {
+	Publish(topic string, event *events.Event) error
+}
+
 type Producer struct {
 	SyncProducer sarama.SyncProducer
+	codec        Codec
 	logger       *slog.Logger
 }
 
-func NewProducer(cfg *config.Config, logger *slog.Logger) (*Producer, error) {
+// NewProducer connects the producer the API publishes with. It is idempotent
+// but never transactional: every request publishes a single event, and a
+// transaction per event would serialize all requests on one producer.
+func NewProducer(cfg *config.Config, codec Codec, logger *slog.Logger) (*Producer, error) {
+	syncProducer, err := sarama.NewSyncProducer(cfg.Kafka.Brokers, apiProducerConfig(cfg.Kafka))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Producer{SyncProducer: syncProducer, codec: codec, logger: logger}, nil
+}
+
+// apiProducerConfig is idempotent whatever cfg says, and ignores the
+// transactional ID, which is the worker's.
+func apiProducerConfig(cfg config.KafkaConfig) *sarama.Config {
+	cfg.Idempotent = true
+	return newProducerConfig(cfg, "")
+}
+
+// NewSyncProducer connects a producer with the delivery guarantees cfg asks
+// for. The broker drops the duplicates an idempotent producer's retries would
+// write. Given a transactionalID, the producer is transactional, and its
+// messages are only read by read_committed consumers once committed.
+func NewSyncProducer(cfg config.KafkaConfig, transactionalID string) (sarama.SyncProducer, error) {
+	return sarama.NewSyncProducer(cfg.Brokers, newProducerConfig(cfg, transactionalID))
+}
+
+func newProducerConfig(cfg config.KafkaConfig, transactionalID string) *sarama.Config {
 	producerConfig := sarama.NewConfig()
 	producerConfig.Producer.Return.Successes = true
 	producerConfig.Producer.RequiredAcks = sarama.WaitForAll
 	producerConfig.Producer.Retry.Max = 5
 
-	syncProducer, err := sarama.NewSyncProducer(cfg.Kafka.Brokers, producerConfig)
-	if err != nil {
-		return nil, err
+	if cfg.Idempotent || transactionalID != "" {
+		producerConfig.Producer.Idempotent = true
+		// sarama only keeps retried requests in order with one in flight
+		producerConfig.Net.MaxOpenRequests = 1
 	}
-
-	return &Producer{SyncProducer: syncProducer, logger: logger}, nil
+	if transactionalID != "" {
+		producerConfig.Producer.Transaction.ID = transactionalID
+	}
+	return producerConfig
 }
 
-func (p *Producer) PushOrderToQueue(topic string, message []byte) error {
-	msg := &sarama.ProducerMessage{
-		Topic: topic,
-		Value: sarama.ByteEncoder(message),
+// Publish sends event keyed by event.Key, so that the events of one order
+// keep their order on a single partition.
+func (p *Producer) Publish(topic string, event *events.Event) error {
+	msg, err := NewMessage(topic, event, p.codec)
+	if err != nil {
+		return err
 	}
 
 	partition, offset, err := p.SyncProducer.SendMessage(msg)
 	if err != nil {
 		return err
 	}
-	p.logger.Info("Message sent", "partition", partition, "offset", offset)
+	p.logger.Info("Message sent", "event_id", event.ID, "event_type", event.Type, "partition", partition, "offset", offset)
 	return nil
 }
 
+// NewMessage encodes event with codec into a message for topic, with the
+// envelope metadata in headers.
+func NewMessage(topic string, event *events.Event, codec Codec) (*sarama.ProducerMessage, error) {
+	value, err := codec.Encode(topic, event)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.ByteEncoder(value),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte(HeaderEventID), Value: []byte(event.ID)},
+			{Key: []byte(HeaderEventType), Value: []byte(event.Type)},
+			{Key: []byte(HeaderEventVersion), Value: []byte(strconv.Itoa(event.Version))},
+			{Key: []byte(HeaderContentType), Value: []byte(codec.ContentType())},
+		},
+	}
+	if event.Key != "" {
+		msg.Key = sarama.StringEncoder(event.Key)
+	}
+	return msg, nil
+}
+
+// Header returns the value of the header named key, or "" if message has none.
+func Header(message *sarama.ConsumerMessage, key string) string {
+	for _, header := range message.Headers {
+		if header != nil && string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
 func (p *Producer) Close() error {
 	if err := p.SyncProducer.Close(); err != nil {
 		p.logger.Error("Failed to close producer", "error", err)
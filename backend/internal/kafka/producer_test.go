@@ -0,0 +1,76 @@
+package kafka
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/AlexShmak/order-service/internal/config"
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProducerConfig(t *testing.T) {
+	plain := newProducerConfig(config.KafkaConfig{}, "")
+	require.NoError(t, plain.Validate())
+	assert.False(t, plain.Producer.Idempotent)
+
+	idempotent := newProducerConfig(config.KafkaConfig{Idempotent: true}, "")
+	require.NoError(t, idempotent.Validate())
+	assert.True(t, idempotent.Producer.Idempotent)
+	assert.Empty(t, idempotent.Producer.Transaction.ID)
+
+	// a transactional ID turns idempotence on by itself
+	transactional := newProducerConfig(config.KafkaConfig{}, "orders-tx-api-1")
+	require.NoError(t, transactional.Validate())
+	assert.True(t, transactional.Producer.Idempotent)
+	assert.Equal(t, "orders-tx-api-1", transactional.Producer.Transaction.ID)
+}
+
+func TestAPIProducerConfig(t *testing.T) {
+	producerConfig := apiProducerConfig(config.KafkaConfig{Idempotent: false, TransactionalID: "orders-tx"})
+	require.NoError(t, producerConfig.Validate())
+	assert.True(t, producerConfig.Producer.Idempotent)
+	assert.Equal(t, sarama.WaitForAll, producerConfig.Producer.RequiredAcks)
+	assert.Equal(t, 1, producerConfig.Net.MaxOpenRequests)
+	assert.Empty(t, producerConfig.Producer.Transaction.ID, "transactions are the worker's")
+}
+
+// txnRecorder records the transactions around the messages of the mock.
+type txnRecorder struct {
+	*mocks.SyncProducer
+	calls []string
+}
+
+func (p *txnRecorder) BeginTxn() error {
+	p.calls = append(p.calls, "begin")
+	return p.SyncProducer.BeginTxn()
+}
+
+func (p *txnRecorder) CommitTxn() error {
+	p.calls = append(p.calls, "commit")
+	return p.SyncProducer.CommitTxn()
+}
+
+func (p *txnRecorder) AbortTxn() error {
+	p.calls = append(p.calls, "abort")
+	return p.SyncProducer.AbortTxn()
+}
+
+func (p *txnRecorder) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	p.calls = append(p.calls, "send "+msg.Topic)
+	return p.SyncProducer.SendMessage(msg)
+}
+
+func TestProducerPublishesWithoutTransactions(t *testing.T) {
+	syncProducer := &txnRecorder{SyncProducer: mocks.NewSyncProducer(t, apiProducerConfig(config.KafkaConfig{}))}
+	producer := &Producer{SyncProducer: syncProducer, codec: JSONCodec{}, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	syncProducer.ExpectSendMessageAndSucceed()
+	require.NoError(t, producer.Publish("orders", testEvent(t, 2)))
+	syncProducer.ExpectSendMessageAndFail(sarama.ErrNotEnoughReplicas)
+	require.ErrorIs(t, producer.Publish("orders", testEvent(t, 2)), sarama.ErrNotEnoughReplicas)
+	assert.Equal(t, []string{"send orders", "send orders"}, syncProducer.calls)
+}
@@ -0,0 +1,231 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const SchemaTypeProtobuf = "PROTOBUF"
+
+var ErrSchemaNotFound = errors.New("schema not found")
+
+type Schema struct {
+	// Type is empty for Avro, as in the Confluent API
+	Type   string `json:"schemaType,omitempty"`
+	Schema string `json:"schema"`
+}
+
+// SchemaRegistry stores the schemas of message values under IDs, which
+// messages in the Confluent wire format carry instead of the schema.
+type SchemaRegistry interface {
+	// Register returns the ID of schema, registering it under subject if it
+	// is new. The registry rejects schemas incompatible with the subject's
+	// previous ones.
+	Register(ctx context.Context, subject string, schema Schema) (int, error)
+	Schema(ctx context.Context, id int) (*Schema, error)
+}
+
+// ValueSubject is the subject of the value schema of topic under the default
+// TopicNameStrategy of Confluent serializers.
+func ValueSubject(topic string) string {
+	return topic + "-value"
+}
+
+// HTTPSchemaRegistry is a client for the REST API of Confluent Schema
+// Registry and compatible ones. Credentials can be given in the URL.
+type HTTPSchemaRegistry struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewHTTPSchemaRegistry(baseURL string) *HTTPSchemaRegistry {
+	return &HTTPSchemaRegistry{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (r *HTTPSchemaRegistry) Register(ctx context.Context, subject string, schema Schema) (int, error) {
+	var registered struct {
+		ID int `json:"id"`
+	}
+	if err := r.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", schema, &registered); err != nil {
+		return 0, fmt.Errorf("could not register schema for %s: %w", subject, err)
+	}
+	return registered.ID, nil
+}
+
+func (r *HTTPSchemaRegistry) Schema(ctx context.Context, id int) (*Schema, error) {
+	var schema Schema
+	if err := r.do(ctx, http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, &schema); err != nil {
+		return nil, fmt.Errorf("could not get schema %d: %w", id, err)
+	}
+	return &schema, nil
+}
+
+func (r *HTTPSchemaRegistry) do(ctx context.Context, method, path string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, r.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			ErrorCode int    `json:"error_code"`
+			Message   string `json:"message"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&apiErr)
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("%w: %s", ErrSchemaNotFound, apiErr.Message)
+		}
+		return fmt.Errorf("schema registry answered %d: %s", resp.StatusCode, apiErr.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// FileSchemaRegistry keeps the schemas in a JSON file, a stand-in for a
+// registry in local development and tests. Unlike a real registry it does not
+// check compatibility, and processes sharing the file must not register at
+// the same time.
+type FileSchemaRegistry struct {
+	path string
+	mu   sync.Mutex
+}
+
+type registryFile struct {
+	Schemas []registeredSchema `json:"schemas"`
+}
+
+type registeredSchema struct {
+	ID      int    `json:"id"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+	Schema
+}
+
+func NewFileSchemaRegistry(path string) *FileSchemaRegistry {
+	return &FileSchemaRegistry{path: path}
+}
+
+// Register gives a schema that is already registered, under any subject, its
+// existing ID like Confluent Schema Registry does.
+func (r *FileSchemaRegistry) Register(_ context.Context, subject string, schema Schema) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	file, err := r.load()
+	if err != nil {
+		return 0, err
+	}
+
+	id, version := 0, 0
+	maxID := 0
+	for _, registered := range file.Schemas {
+		maxID = max(maxID, registered.ID)
+		if registered.Schema != schema {
+			if registered.Subject == subject {
+				version = max(version, registered.Version)
+			}
+			continue
+		}
+		if registered.Subject == subject {
+			return registered.ID, nil
+		}
+		id = registered.ID
+	}
+	if id == 0 {
+		id = maxID + 1
+	}
+
+	file.Schemas = append(file.Schemas, registeredSchema{ID: id, Subject: subject, Version: version + 1, Schema: schema})
+	if err := r.save(file); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *FileSchemaRegistry) Schema(_ context.Context, id int) (*Schema, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	file, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, registered := range file.Schemas {
+		if registered.ID == id {
+			return &registered.Schema, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %d", ErrSchemaNotFound, id)
+}
+
+func (r *FileSchemaRegistry) load() (*registryFile, error) {
+	var file registryFile
+	data, err := os.ReadFile(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &file, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read schema registry file: %w", err)
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("could not parse schema registry file: %w", err)
+	}
+	return &file, nil
+}
+
+// save replaces the file in one step, readers never see half of it.
+func (r *FileSchemaRegistry) save(file *registryFile) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*")
+	if err != nil {
+		return fmt.Errorf("could not write schema registry file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write schema registry file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write schema registry file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("could not write schema registry file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSchemaRegistry(t *testing.T) {
+	ctx := context.Background()
+	registry := newTestRegistry(t)
+	v1 := Schema{Type: SchemaTypeProtobuf, Schema: `syntax = "proto3"; message A {}`}
+	v2 := Schema{Type: SchemaTypeProtobuf, Schema: `syntax = "proto3"; message A { string a = 1; }`}
+
+	id1, err := registry.Register(ctx, "orders-value", v1)
+	require.NoError(t, err)
+	again, err := registry.Register(ctx, "orders-value", v1)
+	require.NoError(t, err)
+	assert.Equal(t, id1, again, "registering is idempotent")
+
+	id2, err := registry.Register(ctx, "orders-value", v2)
+	require.NoError(t, err)
+	assert.NotEqual(t, id1, id2)
+
+	other, err := registry.Register(ctx, "orders-retry-value", v2)
+	require.NoError(t, err)
+	assert.Equal(t, id2, other, "the same schema keeps its ID under another subject")
+
+	// the schemas survive in the file
+	schema, err := NewFileSchemaRegistry(registry.path).Schema(ctx, id2)
+	require.NoError(t, err)
+	assert.Equal(t, v2, *schema)
+
+	_, err = registry.Schema(ctx, 42)
+	assert.ErrorIs(t, err, ErrSchemaNotFound)
+}
+
+func TestHTTPSchemaRegistry(t *testing.T) {
+	schema := Schema{Type: SchemaTypeProtobuf, Schema: `syntax = "proto3"; message A {}`}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/subjects/orders-value/versions":
+			var got Schema
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+			assert.Equal(t, schema, got)
+			_, _ = w.Write([]byte(`{"id": 7}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/schemas/ids/7":
+			_ = json.NewEncoder(w).Encode(schema)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code": 40403, "message": "Schema not found"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	registry := NewHTTPSchemaRegistry(server.URL + "/")
+
+	id, err := registry.Register(context.Background(), "orders-value", schema)
+	require.NoError(t, err)
+	assert.Equal(t, 7, id)
+
+	got, err := registry.Schema(context.Background(), 7)
+	require.NoError(t, err)
+	assert.Equal(t, schema, *got)
+
+	_, err = registry.Schema(context.Background(), 8)
+	assert.ErrorIs(t, err, ErrSchemaNotFound)
+}
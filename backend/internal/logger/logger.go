@@ -14,21 +14,24 @@ const (
 	envProd  = "prod"
 )
 
+// SetupLogger returns a logger that masks PII, see RedactingHandler.
 func SetupLogger(env string) *slog.Logger {
-	var logger *slog.Logger
+	var handler slog.Handler
 
 	switch env {
 	case envLocal:
-		logger = slog.New(tint.NewHandler(os.Stdout, &tint.Options{
+		handler = tint.NewHandler(os.Stdout, &tint.Options{
 			Level:      slog.LevelDebug,
 			TimeFormat: time.Kitchen,
 			AddSource:  true,
-		}))
+		})
 	case envDev:
-		logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})
 	case envProd:
-		logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})
+	default:
+		return nil
 	}
 
-	return logger
+	return slog.New(NewRedactingHandler(handler, DefaultSensitiveKeys...))
 }
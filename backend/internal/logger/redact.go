@@ -0,0 +1,119 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// DefaultSensitiveKeys are attribute keys whose values are never logged. A key
+// also matches as the suffix after an underscore, e.g. "customer_email"
+// matches "email". One-time codes are listed by their full names, a bare
+// "code" would hide "status_code" and the like.
+var DefaultSensitiveKeys = []string{
+	"email", "phone", "address",
+	"password", "secret", "token", "api_key", "authorization", "cookie",
+	"totp_code", "recovery_code", "authorization_code",
+}
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	// international numbers with a leading + and Russian domestic numbers
+	// starting with 8, both with or without separators
+	phonePattern = regexp.MustCompile(`\+\d(?:[\s().-]{0,2}\d){7,14}|\b8[\s(-]*\d{3}[\s)-]*\d{3}[\s-]?\d{2}[\s-]?\d{2}\b`)
+)
+
+// RedactingHandler masks PII before a record reaches the wrapped handler:
+// values of sensitive keys are replaced as a whole, and emails and phone
+// numbers are masked wherever else they show up, including the message and
+// errors.
+type RedactingHandler struct {
+	next slog.Handler
+	keys []string
+}
+
+func NewRedactingHandler(next slog.Handler, sensitiveKeys ...string) *RedactingHandler {
+	keys := make([]string, 0, len(sensitiveKeys))
+	for _, key := range sensitiveKeys {
+		keys = append(keys, strings.ToLower(key))
+	}
+	return &RedactingHandler{next: next, keys: keys}
+}
+
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *RedactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	masked := slog.NewRecord(record.Time, record.Level, MaskString(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		masked.AddAttrs(h.redact(attr))
+		return true
+	})
+	return h.next.Handle(ctx, masked)
+}
+
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	masked := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		masked = append(masked, h.redact(attr))
+	}
+	return &RedactingHandler{next: h.next.WithAttrs(masked), keys: h.keys}
+}
+
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{next: h.next.WithGroup(name), keys: h.keys}
+}
+
+func (h *RedactingHandler) redact(attr slog.Attr) slog.Attr {
+	attr.Value = attr.Value.Resolve()
+	if h.isSensitive(attr.Key) {
+		return slog.String(attr.Key, redacted)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, MaskString(attr.Value.String()))
+	case slog.KindGroup:
+		group := attr.Value.Group()
+		masked := make([]any, 0, len(group))
+		for _, a := range group {
+			masked = append(masked, h.redact(a))
+		}
+		return slog.Group(attr.Key, masked...)
+	case slog.KindAny:
+		switch value := attr.Value.Any().(type) {
+		case error:
+			return slog.String(attr.Key, MaskString(value.Error()))
+		case []byte:
+			return slog.String(attr.Key, MaskString(string(value)))
+		default:
+			// structs and maps are only replaced when they would print PII
+			text := fmt.Sprint(value)
+			if masked := MaskString(text); masked != text {
+				return slog.String(attr.Key, masked)
+			}
+		}
+	}
+	return attr
+}
+
+func (h *RedactingHandler) isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range h.keys {
+		if key == sensitive || strings.HasSuffix(key, "_"+sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskString replaces emails and phone numbers in s.
+func MaskString(s string) string {
+	s = emailPattern.ReplaceAllString(s, "[EMAIL]")
+	return phonePattern.ReplaceAllString(s, "[PHONE]")
+}
@@ -0,0 +1,157 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testEmail   = "ivan.petrov@example.com"
+	testPhone   = "+7 (912) 345-67-89"
+	testAddress = "Ploshad Mira 15"
+)
+
+func newTestLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(NewRedactingHandler(slog.NewJSONHandler(buf, nil), DefaultSensitiveKeys...))
+}
+
+type delivery struct {
+	Name  string
+	Phone string
+	Email string
+}
+
+type deliveryValuer struct {
+	email string
+}
+
+func (d deliveryValuer) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("contact", d.email))
+}
+
+func TestRedactingHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(l *slog.Logger)
+		want string
+	}{
+		{
+			name: "sensitive keys",
+			log: func(l *slog.Logger) {
+				l.Info("user registered", "email", testEmail, "customer_phone", testPhone, "Address", testAddress)
+			},
+			want: `"email":"[REDACTED]","customer_phone":"[REDACTED]","Address":"[REDACTED]"`,
+		},
+		{
+			name: "one-time codes",
+			log: func(l *slog.Logger) {
+				l.Info("second factor", "totp_code", "123456", "recovery_code", "a1b2-c3d4", "authorization_code", "SplxlOBeZQQYbYS6WxSbIA")
+			},
+			want: `"totp_code":"[REDACTED]","recovery_code":"[REDACTED]","authorization_code":"[REDACTED]"`,
+		},
+		{
+			name: "message",
+			log: func(l *slog.Logger) {
+				l.Info("sending mail to " + testEmail + " and calling " + testPhone)
+			},
+			want: `"msg":"sending mail to [EMAIL] and calling [PHONE]"`,
+		},
+		{
+			name: "error",
+			log: func(l *slog.Logger) {
+				err := fmt.Errorf("could not create user: %w", errors.New(`duplicate key (email)=(`+testEmail+`)`))
+				l.Error("failed to register", "error", err)
+			},
+			want: `"error":"could not create user: duplicate key (email)=([EMAIL])"`,
+		},
+		{
+			name: "raw message value",
+			log: func(l *slog.Logger) {
+				l.Info("message claimed", "payload", []byte(`{"phone":"`+testPhone+`","email":"`+testEmail+`"}`))
+			},
+			want: `"payload":"{\"phone\":\"[PHONE]\",\"email\":\"[EMAIL]\"}"`,
+		},
+		{
+			name: "struct",
+			log: func(l *slog.Logger) {
+				l.Info("order", "delivery", delivery{Name: "Ivan", Phone: testPhone, Email: testEmail})
+			},
+			want: `"delivery":"{Ivan [PHONE] [EMAIL]}"`,
+		},
+		{
+			name: "group and LogValuer",
+			log: func(l *slog.Logger) {
+				l.Info("order", slog.Group("customer", "email", testEmail, "note", "call "+testPhone), "d", deliveryValuer{email: testEmail})
+			},
+			want: `"customer":{"email":"[REDACTED]","note":"call [PHONE]"},"d":{"contact":"[EMAIL]"}`,
+		},
+		{
+			name: "With and WithGroup",
+			log: func(l *slog.Logger) {
+				l.With("email", testEmail).WithGroup("request").Info("login", "user", testEmail)
+			},
+			want: `"email":"[REDACTED]","request":{"user":"[EMAIL]"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.log(newTestLogger(&buf))
+
+			out := buf.String()
+			assert.Contains(t, out, tt.want)
+			for _, pii := range []string{testEmail, testPhone, testAddress} {
+				assert.NotContains(t, out, pii)
+			}
+		})
+	}
+}
+
+func TestRedactingHandlerKeepsOtherValues(t *testing.T) {
+	var buf bytes.Buffer
+	newTestLogger(&buf).Info("order created",
+		"order_uid", "b563feb7-b2b8-4b6e-9f2c-000000000000",
+		"track_number", "WBILMTESTTRACK",
+		"date_created", "2021-11-26T06:22:19Z",
+		"amount", 1817,
+		"ip", "192.168.1.10",
+		"created_at_ms", 1637907739000,
+		"status_code", 401,
+		"error_code", "invalid_grant",
+	)
+
+	out := buf.String()
+	for _, want := range []string{
+		`"order_uid":"b563feb7-b2b8-4b6e-9f2c-000000000000"`,
+		`"track_number":"WBILMTESTTRACK"`,
+		`"date_created":"2021-11-26T06:22:19Z"`,
+		`"amount":1817`,
+		`"ip":"192.168.1.10"`,
+		`"created_at_ms":1637907739000`,
+		`"status_code":401`,
+		`"error_code":"invalid_grant"`,
+	} {
+		assert.Contains(t, out, want)
+	}
+}
+
+func TestMaskString(t *testing.T) {
+	tests := map[string]string{
+		"a.b+tag@mail.example.co.uk": "[EMAIL]",
+		"+9720000000":                "[PHONE]",
+		"+7 912 345 67 89":           "[PHONE]",
+		"8 (912) 345-67-89":          "[PHONE]",
+		"89123456789":                "[PHONE]",
+		"order 18 has 3 items":       "order 18 has 3 items",
+		"2021-11-26 06:22:19":        "2021-11-26 06:22:19",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, MaskString(in), in)
+	}
+}
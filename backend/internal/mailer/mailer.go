@@ -0,0 +1,36 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"github.com/AlexShmak/order-service/internal/config"
+	"os"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New picks the mailer implementation configured by MAILER_DRIVER.
+func New(cfg *config.Config) (Mailer, error) {
+	switch cfg.Mailer.Driver {
+	case "smtp":
+		return NewSMTPMailer(cfg.Mailer.SMTPHost, cfg.Mailer.SMTPPort, cfg.Mailer.SMTPUsername, cfg.Mailer.SMTPPassword, cfg.Mailer.From), nil
+	case "file":
+		f, err := os.OpenFile(cfg.Mailer.FilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("could not open mail file: %w", err)
+		}
+		return NewWriterMailer(f, cfg.Mailer.From), nil
+	case "stdout":
+		return NewWriterMailer(os.Stdout, cfg.Mailer.From), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer driver: %s", cfg.Mailer.Driver)
+	}
+}
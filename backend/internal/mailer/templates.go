@@ -0,0 +1,25 @@
+package mailer
+
+import "fmt"
+
+func VerificationEmail(to string, name string, link string) Message {
+	return Message{
+		To:      to,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf(
+			"Hello, %s!\n\nPlease confirm your email address by following the link below:\n\n%s\n\nThe link is valid for 24 hours.\n",
+			name, link,
+		),
+	}
+}
+
+func PasswordResetEmail(to string, name string, link string) Message {
+	return Message{
+		To:      to,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hello, %s!\n\nSomeone requested a password reset for your account. If it was you, follow the link below:\n\n%s\n\nThe link is valid for 1 hour. If you did not request a reset, ignore this email.\n",
+			name, link,
+		),
+	}
+}
@@ -0,0 +1,30 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// WriterMailer writes messages to a writer instead of sending them. It is used
+// for local development, where the links from emails are copied from stdout or a file.
+type WriterMailer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+func NewWriterMailer(w io.Writer, from string) *WriterMailer {
+	return &WriterMailer{w: w, from: from}
+}
+
+func (m *WriterMailer) Send(_ context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.w.Write(append(format(m.from, msg), "\r\n\r\n"...)); err != nil {
+		return fmt.Errorf("could not write email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const mockKeyID = "mock-issuer"
+
+// MockUser is an identity offered on the login page of the MockIssuer.
+type MockUser struct {
+	Subject string
+	Email   string
+	Name    string
+	Groups  []string
+}
+
+// DefaultMockUsers cover a support agent, an admin and a user without any
+// staff group, which has to be rejected.
+var DefaultMockUsers = []MockUser{
+	{Subject: "mock-agent", Email: "agent@example.com", Name: "Support Agent", Groups: []string{"support-agents"}},
+	{Subject: "mock-admin", Email: "admin@example.com", Name: "Admin", Groups: []string{"admins"}},
+	{Subject: "mock-outsider", Email: "outsider@example.com", Name: "Outsider", Groups: []string{"contractors"}},
+}
+
+type mockAuthRequest struct {
+	redirectURI   string
+	codeChallenge string
+	nonce         string
+	user          MockUser
+	expiresAt     time.Time
+}
+
+// MockIssuer is a minimal OpenID Provider for local development. It lets
+// anyone log in as one of its users by clicking a button, so it must never
+// be exposed outside a developer machine.
+type MockIssuer struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	users        []MockUser
+	key          *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockAuthRequest
+}
+
+func NewMockIssuer(issuer, clientID, clientSecret string, users []MockUser) (*MockIssuer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate signing key: %w", err)
+	}
+	return &MockIssuer{
+		issuer:       issuer,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		users:        users,
+		key:          key,
+		codes:        make(map[string]mockAuthRequest),
+	}, nil
+}
+
+func (m *MockIssuer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("GET /jwks", m.jwks)
+	mux.HandleFunc("GET /authorize", m.loginPage)
+	mux.HandleFunc("POST /authorize", m.authorize)
+	mux.HandleFunc("POST /token", m.token)
+	return mux
+}
+
+func (m *MockIssuer) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                m.issuer,
+		"authorization_endpoint":                m.issuer + "/authorize",
+		"token_endpoint":                        m.issuer + "/token",
+		"jwks_uri":                              m.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (m *MockIssuer) jwks(w http.ResponseWriter, _ *http.Request) {
+	pub := m.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": mockKeyID,
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+var mockLoginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Mock OIDC issuer</title></head>
+<body>
+<h1>Mock OIDC issuer</h1>
+<p>Sign in as:</p>
+{{range $i, $u := .Users}}
+<form method="post" action="/authorize">
+	{{range $name, $value := $.Params}}<input type="hidden" name="{{$name}}" value="{{$value}}">{{end}}
+	<input type="hidden" name="user" value="{{$i}}">
+	<button type="submit">{{$u.Name}} &lt;{{$u.Email}}&gt; {{$u.Groups}}</button>
+</form>
+{{end}}
+</body>
+</html>`))
+
+func (m *MockIssuer) loginPage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if err := m.checkAuthRequest(query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	params := map[string]string{}
+	for _, name := range []string{"redirect_uri", "state", "nonce", "code_challenge"} {
+		params[name] = query.Get(name)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = mockLoginPage.Execute(w, map[string]any{"Users": m.users, "Params": params})
+}
+
+func (m *MockIssuer) checkAuthRequest(query url.Values) error {
+	switch {
+	case query.Get("client_id") != m.clientID:
+		return fmt.Errorf("unknown client_id")
+	case query.Get("response_type") != "code":
+		return fmt.Errorf("only response_type=code is supported")
+	case query.Get("redirect_uri") == "":
+		return fmt.Errorf("redirect_uri is required")
+	case query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256":
+		return fmt.Errorf("PKCE with S256 is required")
+	}
+	return nil
+}
+
+func (m *MockIssuer) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	index, err := strconv.Atoi(r.PostForm.Get("user"))
+	if err != nil || index < 0 || index >= len(m.users) {
+		http.Error(w, "unknown user", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(r.PostForm.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	code, err := RandomString()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	m.mu.Lock()
+	m.codes[code] = mockAuthRequest{
+		redirectURI:   redirectURI.String(),
+		codeChallenge: r.PostForm.Get("code_challenge"),
+		nonce:         r.PostForm.Get("nonce"),
+		user:          m.users[index],
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+	m.mu.Unlock()
+
+	query := redirectURI.Query()
+	query.Set("code", code)
+	query.Set("state", r.PostForm.Get("state"))
+	redirectURI.RawQuery = query.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (m *MockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != m.clientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(m.clientSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	// codes are single use, even when the exchange fails
+	code := r.PostForm.Get("code")
+	m.mu.Lock()
+	request, found := m.codes[code]
+	delete(m.codes, code)
+	m.mu.Unlock()
+
+	if !found || time.Now().After(request.expiresAt) ||
+		request.redirectURI != r.PostForm.Get("redirect_uri") ||
+		CodeChallenge(r.PostForm.Get("code_verifier")) != request.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            m.issuer,
+		"sub":            request.user.Subject,
+		"aud":            m.clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          request.nonce,
+		"email":          request.user.Email,
+		"email_verified": true,
+		"name":           request.user.Name,
+		"groups":         request.user.Groups,
+	})
+	idToken.Header["kid"] = mockKeyID
+	signed, err := idToken.SignedString(m.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	accessToken, err := RandomString()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     signed,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
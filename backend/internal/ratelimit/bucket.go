@@ -0,0 +1,158 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"math"
+	"sync"
+	"time"
+)
+
+// Both token bucket limiters interpret Allow's limit as the bucket capacity,
+// i.e. the allowed burst, and refill it at limit tokens per window. Unlike the
+// window based limiters, a client that has used up its burst gets requests
+// through again at the refill rate instead of waiting for the whole window.
+
+// bucketSweepInterval is how often idle buckets are looked for. It does not
+// depend on the windows of the callers, which differ between routes.
+const bucketSweepInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// limit and window are the ones of the last Allow for the key, the bucket
+	// refills at their rate until it is used again
+	limit  int
+	window time.Duration
+}
+
+// rate is in tokens per nanosecond.
+func (b *bucket) rate() float64 {
+	return float64(b.limit) / float64(b.window)
+}
+
+// isFull reports whether b has refilled by now, after which it is no
+// different from a bucket never seen.
+func (b *bucket) isFull(now time.Time) bool {
+	return b.tokens+float64(now.Sub(b.updated))*b.rate() >= float64(b.limit)
+}
+
+// MemoryTokenBucket keeps buckets in process memory.
+// It is only suitable for a single instance deployment.
+type MemoryTokenBucket struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryTokenBucket() *MemoryTokenBucket {
+	return &MemoryTokenBucket{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (l *MemoryTokenBucket) Allow(_ context.Context, key string, limit int, window time.Duration) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit), updated: now}
+		l.buckets[key] = b
+	}
+	b.limit, b.window = limit, window
+	rate := b.rate()
+	b.tokens = math.Min(float64(limit), b.tokens+float64(now.Sub(b.updated))*rate)
+	b.updated = now
+
+	res := Result{Limit: limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration(math.Ceil((1 - b.tokens) / rate))
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = time.Duration(math.Ceil((float64(limit) - b.tokens) / rate))
+	return res, nil
+}
+
+// sweep drops the buckets that have refilled at their own rate.
+func (l *MemoryTokenBucket) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < bucketSweepInterval {
+		return
+	}
+	for key, b := range l.buckets {
+		if b.isFull(now) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// tokenBucketScript stores the token count and the time of the last update
+// in a hash. The key expires once the bucket would be full again.
+var tokenBucketScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local rate = limit / window
+
+local state = redis.call('HMGET', key, 'tokens', 'updated')
+local tokens = tonumber(state[1])
+local updated = tonumber(state[2])
+if tokens == nil or updated == nil then
+	tokens = limit
+	updated = now
+end
+tokens = math.min(limit, tokens + math.max(0, now - updated) * rate)
+
+local allowed = 0
+local retry_after = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry_after = math.ceil((1 - tokens) / rate)
+end
+
+local reset = math.ceil((limit - tokens) / rate)
+redis.call('HSET', key, 'tokens', tostring(tokens), 'updated', now)
+redis.call('PEXPIRE', key, math.max(reset, 1))
+return {allowed, math.floor(tokens), retry_after, reset}
+`)
+
+// RedisTokenBucket is a token bucket limiter shared by every instance of the service.
+type RedisTokenBucket struct {
+	rdb redis.Cmdable
+	now func() time.Time
+}
+
+func NewRedisTokenBucket(rdb redis.Cmdable) *RedisTokenBucket {
+	return &RedisTokenBucket{rdb: rdb, now: time.Now}
+}
+
+func (l *RedisTokenBucket) Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error) {
+	res, err := tokenBucketScript.Run(ctx, l.rdb,
+		[]string{"tokenbucket:" + key},
+		l.now().UnixMilli(), window.Milliseconds(), limit,
+	).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("could not evaluate rate limit: %w", err)
+	}
+
+	return Result{
+		Allowed:    res[0] == 1,
+		Limit:      limit,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+		Reset:      time.Duration(res[3]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,156 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is the now of a limiter under test.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// testTokenBucket runs the behavior both token bucket limiters share. key
+// must not have been used before.
+func testTokenBucket(t *testing.T, limiter Limiter, clock *fakeClock, key string) {
+	ctx := context.Background()
+	allow := func() Result {
+		t.Helper()
+		res, err := limiter.Allow(ctx, key, 3, 3*time.Second)
+		require.NoError(t, err)
+		return res
+	}
+
+	// a new client gets the whole burst at once
+	for remaining := 2; remaining >= 0; remaining-- {
+		res := allow()
+		require.True(t, res.Allowed)
+		assert.Equal(t, 3, res.Limit)
+		assert.Equal(t, remaining, res.Remaining)
+	}
+
+	res := allow()
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Equal(t, time.Second, res.RetryAfter, "one token refills per second")
+	assert.Equal(t, 3*time.Second, res.Reset)
+
+	// after a second one more request gets through, not the whole burst
+	clock.Advance(time.Second)
+	assert.True(t, allow().Allowed)
+	assert.False(t, allow().Allowed)
+
+	// the bucket never holds more than the burst
+	clock.Advance(time.Hour)
+	res = allow()
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 2, res.Remaining)
+	assert.Equal(t, time.Second, res.Reset)
+}
+
+func TestMemoryTokenBucket(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	limiter := NewMemoryTokenBucket()
+	limiter.now = clock.Now
+
+	testTokenBucket(t, limiter, clock, "user:1")
+}
+
+func TestMemoryTokenBucketSweep(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Now()}
+	limiter := NewMemoryTokenBucket()
+	limiter.now = clock.Now
+
+	// an hourly bucket drained by one client, a per-second bucket by another
+	_, err := limiter.Allow(ctx, "slow", 1, time.Hour)
+	require.NoError(t, err)
+	_, err = limiter.Allow(ctx, "fast", 1, time.Second)
+	require.NoError(t, err)
+
+	// a call with a short window must not judge the hourly bucket by it
+	clock.Advance(2 * bucketSweepInterval)
+	_, err = limiter.Allow(ctx, "other", 1, time.Second)
+	require.NoError(t, err)
+
+	assert.NotContains(t, limiter.buckets, "fast", "a refilled bucket is dropped")
+	require.Contains(t, limiter.buckets, "slow")
+	res, err := limiter.Allow(ctx, "slow", 1, time.Hour)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed, "the hourly bucket is still empty")
+}
+
+// TestRedisTokenBucket needs a Redis it may write to, e.g.
+//
+//	TEST_REDIS_ADDR=localhost:6379 go test ./internal/ratelimit/
+func TestRedisTokenBucket(t *testing.T) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR is not set")
+	}
+
+	rdb := redis.NewClient(&redis.Options{Addr: addr, Password: os.Getenv("TEST_REDIS_PASSWORD")})
+	t.Cleanup(func() { _ = rdb.Close() })
+	require.NoError(t, rdb.Ping(context.Background()).Err())
+
+	clock := &fakeClock{now: time.Now()}
+	limiter := NewRedisTokenBucket(rdb)
+	limiter.now = clock.Now
+
+	suffix := make([]byte, 8)
+	_, _ = rand.Read(suffix)
+	key := "test:" + hex.EncodeToString(suffix)
+	t.Cleanup(func() { _ = rdb.Del(context.Background(), "tokenbucket:"+key).Err() })
+
+	testTokenBucket(t, limiter, clock, key)
+}
+
+func TestMiddlewareHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	clock := &fakeClock{now: time.Now()}
+	limiter := NewMemoryTokenBucket()
+	limiter.now = clock.Now
+
+	router := gin.New()
+	router.GET("/api/orders", Middleware(limiter, slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Rule{Name: "api-ip", Limit: 10, Window: time.Minute, Key: ByIP},
+		Rule{Name: "api-global", Limit: 2, Window: time.Minute, Key: Global},
+	), func(c *gin.Context) { c.Status(http.StatusOK) })
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/orders", nil))
+		return rec
+	}
+
+	// the headers describe the rule closest to being exceeded
+	rec := get()
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", rec.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "2;w=60", rec.Header().Get("RateLimit-Policy"))
+
+	require.Equal(t, http.StatusOK, get().Code)
+	rec = get()
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "60", rec.Header().Get("RateLimit-Reset"))
+}
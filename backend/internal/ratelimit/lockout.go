@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// levelTTL is how long past lockouts are remembered when picking the next lockout duration.
+const levelTTL = 24 * time.Hour
+
+type LockoutPolicy struct {
+	// Threshold is the number of failures within Window that triggers a lockout
+	Threshold int
+	Window    time.Duration
+	// BaseDuration doubles with every consecutive lockout up to MaxDuration
+	BaseDuration time.Duration
+	MaxDuration  time.Duration
+}
+
+// Lockout tracks failed attempts per key and locks the key out for a
+// progressively longer time once the policy threshold is reached.
+type Lockout struct {
+	rdb    redis.Cmdable
+	policy LockoutPolicy
+}
+
+func NewLockout(rdb redis.Cmdable, policy LockoutPolicy) *Lockout {
+	return &Lockout{rdb: rdb, policy: policy}
+}
+
+// Check returns the remaining lockout time for key, or zero when it is not locked.
+func (l *Lockout) Check(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := l.rdb.PTTL(ctx, lockKey(key)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("could not check lockout: %w", err)
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// RegisterFailure records a failed attempt and returns the lockout duration if
+// this failure locked the key, or zero otherwise.
+func (l *Lockout) RegisterFailure(ctx context.Context, key string) (time.Duration, error) {
+	failures, err := l.rdb.Incr(ctx, failuresKey(key)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("could not register failure: %w", err)
+	}
+	if failures == 1 {
+		if err := l.rdb.PExpire(ctx, failuresKey(key), l.policy.Window).Err(); err != nil {
+			return 0, fmt.Errorf("could not register failure: %w", err)
+		}
+	}
+	if failures < int64(l.policy.Threshold) {
+		return 0, nil
+	}
+
+	level, err := l.rdb.Incr(ctx, levelKey(key)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("could not register lockout: %w", err)
+	}
+
+	duration := l.policy.BaseDuration
+	for i := int64(1); i < level && duration < l.policy.MaxDuration; i++ {
+		duration *= 2
+	}
+	duration = min(duration, l.policy.MaxDuration)
+
+	pipe := l.rdb.TxPipeline()
+	pipe.Expire(ctx, levelKey(key), levelTTL)
+	pipe.Set(ctx, lockKey(key), level, duration)
+	pipe.Del(ctx, failuresKey(key))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("could not register lockout: %w", err)
+	}
+
+	return duration, nil
+}
+
+// Reset forgets failures and past lockouts of key, e.g. after a successful login.
+func (l *Lockout) Reset(ctx context.Context, key string) error {
+	if err := l.rdb.Del(ctx, failuresKey(key), levelKey(key)).Err(); err != nil {
+		return fmt.Errorf("could not reset lockout: %w", err)
+	}
+	return nil
+}
+
+func failuresKey(key string) string { return "lockout:failures:" + key }
+func levelKey(key string) string    { return "lockout:level:" + key }
+func lockKey(key string) string     { return "lockout:lock:" + key }
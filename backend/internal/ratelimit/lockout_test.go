@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockout(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	lockout := NewLockout(redis.NewClient(&redis.Options{Addr: server.Addr()}), LockoutPolicy{
+		Threshold:    3,
+		Window:       time.Minute,
+		BaseDuration: time.Minute,
+		MaxDuration:  3 * time.Minute,
+	})
+	// fail registers failures up to the threshold and returns the lockout
+	fail := func(key string) time.Duration {
+		t.Helper()
+		for i := 1; i < 3; i++ {
+			lockedFor, err := lockout.RegisterFailure(ctx, key)
+			require.NoError(t, err)
+			require.Zero(t, lockedFor, "below the threshold")
+		}
+		lockedFor, err := lockout.RegisterFailure(ctx, key)
+		require.NoError(t, err)
+		return lockedFor
+	}
+	check := func(key string) time.Duration {
+		t.Helper()
+		lockedFor, err := lockout.Check(ctx, key)
+		require.NoError(t, err)
+		return lockedFor
+	}
+
+	t.Run("doubles the lockout up to the maximum", func(t *testing.T) {
+		assert.Zero(t, check("login:a"))
+		for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+			require.Equal(t, want, fail("login:a"))
+			assert.Equal(t, want, check("login:a"))
+			server.FastForward(want)
+			assert.Zero(t, check("login:a"), "the lockout ends")
+		}
+	})
+
+	t.Run("forgets failures outside the window", func(t *testing.T) {
+		for range 2 {
+			_, err := lockout.RegisterFailure(ctx, "login:b")
+			require.NoError(t, err)
+		}
+		server.FastForward(time.Minute)
+
+		lockedFor, err := lockout.RegisterFailure(ctx, "login:b")
+		require.NoError(t, err)
+		assert.Zero(t, lockedFor)
+	})
+
+	t.Run("forgets past lockouts on reset", func(t *testing.T) {
+		require.Equal(t, time.Minute, fail("login:c"))
+		server.FastForward(time.Minute)
+		require.NoError(t, lockout.Reset(ctx, "login:c"))
+
+		assert.Equal(t, time.Minute, fail("login:c"), "the lockout starts over at the base duration")
+	})
+
+	t.Run("keeps keys apart", func(t *testing.T) {
+		require.Equal(t, time.Minute, fail("2fa:1"))
+		assert.Zero(t, check("2fa:2"))
+	})
+}
@@ -0,0 +1,118 @@
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyFunc extracts the value a rule is keyed by. Returning false skips the rule.
+type KeyFunc func(c *gin.Context) (string, bool)
+
+type Rule struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+	Key    KeyFunc
+}
+
+// Middleware rejects requests with 429 as soon as any of the rules is exceeded.
+// The RateLimit-* headers describe the rule closest to being exceeded.
+// Limiter errors are logged and the request is let through, so that an
+// unavailable Redis does not take the whole API down with it.
+func Middleware(limiter Limiter, logger *slog.Logger, rules ...Rule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var tightest *Result
+		var tightestWindow time.Duration
+		for _, rule := range rules {
+			value, ok := rule.Key(c)
+			if !ok {
+				continue
+			}
+
+			res, err := limiter.Allow(c.Request.Context(), rule.Name+":"+value, rule.Limit, rule.Window)
+			if err != nil {
+				logger.Error("failed to check rate limit", slog.String("rule", rule.Name), slog.String("error", err.Error()))
+				continue
+			}
+			if !res.Allowed {
+				logger.Warn("rate limit exceeded", slog.String("rule", rule.Name), slog.String("ip", c.ClientIP()))
+				SetHeaders(c, res, rule.Window)
+				SetRetryAfter(c, res.RetryAfter)
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
+				return
+			}
+			if tightest == nil || res.Remaining < tightest.Remaining {
+				tightest, tightestWindow = &res, rule.Window
+			}
+		}
+		if tightest != nil {
+			SetHeaders(c, *tightest, tightestWindow)
+		}
+		c.Next()
+	}
+}
+
+// SetHeaders sets the RateLimit-* headers of the IETF HTTP API rate limit draft.
+func SetHeaders(c *gin.Context, res Result, window time.Duration) {
+	c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(int(math.Ceil(res.Reset.Seconds()))))
+	c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", res.Limit, int(window.Seconds())))
+}
+
+// SetRetryAfter sets the Retry-After header rounded up to whole seconds.
+func SetRetryAfter(c *gin.Context, d time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+}
+
+func ByIP(c *gin.Context) (string, bool) {
+	return c.ClientIP(), true
+}
+
+// Global keys every request the same, capping the route as a whole.
+func Global(_ *gin.Context) (string, bool) {
+	return "all", true
+}
+
+// ByUserID must be used after the auth middleware has set "userId".
+func ByUserID(c *gin.Context) (string, bool) {
+	userId, exists := c.Get("userId")
+	if !exists {
+		return "", false
+	}
+	return fmt.Sprint(userId), true
+}
+
+// ByJSONField keys by a string field of the JSON body, e.g. the email on login.
+// The body is restored afterwards so that the handler can still bind it.
+func ByJSONField(field string) KeyFunc {
+	return func(c *gin.Context) (string, bool) {
+		if c.Request.Body == nil {
+			return "", false
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return "", false
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var fields map[string]any
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return "", false
+		}
+		value, ok := fields[field].(string)
+		if !ok || value == "" {
+			return "", false
+		}
+		return strings.ToLower(strings.TrimSpace(value)), true
+	}
+}
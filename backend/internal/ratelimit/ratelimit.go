@@ -0,0 +1,21 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Result describes the outcome of a single Allow call.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	// Reset is the time until the full limit is available again
+	Reset time.Duration
+}
+
+type Limiter interface {
+	// Allow records one hit for key and reports whether it fits into limit hits per window.
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error)
+}
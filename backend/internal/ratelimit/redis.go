@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// slidingWindowScript keeps one sorted set entry per hit, scored by its time in
+// milliseconds, and counts the entries that are still inside the window.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	redis.call('PEXPIRE', key, window)
+	return {1, limit - count - 1, 0, window}
+end
+
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+local newest = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+return {0, 0, tonumber(oldest[2]) + window - now, tonumber(newest[2]) + window - now}
+`)
+
+// RedisLimiter is a sliding-window limiter shared by every instance of the service.
+type RedisLimiter struct {
+	rdb redis.Cmdable
+	now func() time.Time
+}
+
+func NewRedisLimiter(rdb redis.Cmdable) *RedisLimiter {
+	return &RedisLimiter{rdb: rdb, now: time.Now}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error) {
+	res, err := slidingWindowScript.Run(ctx, l.rdb,
+		[]string{"ratelimit:" + key},
+		l.now().UnixMilli(), window.Milliseconds(), limit, uuid.NewString(),
+	).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("could not evaluate rate limit: %w", err)
+	}
+
+	return Result{
+		Allowed:    res[0] == 1,
+		Limit:      limit,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+		Reset:      time.Duration(res[3]) * time.Millisecond,
+	}, nil
+}
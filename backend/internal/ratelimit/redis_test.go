@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisLimiter(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	clock := &fakeClock{now: time.Now()}
+	limiter := NewRedisLimiter(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	limiter.now = clock.Now
+	allow := func() Result {
+		t.Helper()
+		res, err := limiter.Allow(ctx, "ip:1", 3, time.Minute)
+		require.NoError(t, err)
+		return res
+	}
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		res := allow()
+		require.True(t, res.Allowed)
+		assert.Equal(t, 3, res.Limit)
+		assert.Equal(t, remaining, res.Remaining)
+		clock.Advance(10 * time.Second)
+	}
+
+	// the window slides, the first hit leaves it 30 seconds from now and the
+	// last one a minute after it was made
+	res := allow()
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 30*time.Second, res.RetryAfter)
+	assert.Equal(t, 50*time.Second, res.Reset)
+
+	clock.Advance(30 * time.Second)
+	res = allow()
+	assert.True(t, res.Allowed, "the first hit has left the window")
+	assert.Equal(t, 0, res.Remaining)
+	assert.False(t, allow().Allowed)
+
+	// a rejected request is not counted
+	clock.Advance(10 * time.Second)
+	assert.True(t, allow().Allowed)
+
+	// the key expires with the window
+	assert.True(t, server.Exists("ratelimit:ip:1"))
+	server.FastForward(time.Minute)
+	assert.False(t, server.Exists("ratelimit:ip:1"))
+}
@@ -0,0 +1,74 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AlexShmak/order-service/internal/config"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func serveWith(middleware gin.HandlerFunc, origin string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Use(middleware)
+	router.GET("/api/orders", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	t.Run("pins HTTPS outside local", func(t *testing.T) {
+		for _, environment := range []string{"dev", "prod"} {
+			header := serveWith(securityHeaders(environment), "").Header()
+			assert.Equal(t, "max-age=63072000; includeSubDomains", header.Get("Strict-Transport-Security"), environment)
+			assert.Equal(t, "no-referrer", header.Get("Referrer-Policy"), environment)
+			assert.Equal(t, "nosniff", header.Get("X-Content-Type-Options"), environment)
+			assert.Equal(t, "DENY", header.Get("X-Frame-Options"), environment)
+		}
+	})
+
+	t.Run("leaves HSTS out locally", func(t *testing.T) {
+		header := serveWith(securityHeaders("local"), "").Header()
+		assert.Empty(t, header.Get("Strict-Transport-Security"))
+		assert.Equal(t, "strict-origin-when-cross-origin", header.Get("Referrer-Policy"))
+		assert.Contains(t, header.Get("Content-Security-Policy"), "frame-ancestors 'none'")
+	})
+}
+
+func TestCORSMiddlewareWildcard(t *testing.T) {
+	middleware := corsMiddleware(config.CORSConfig{
+		AllowedOrigins: []string{"https://*.example.com"},
+		AllowedMethods: []string{http.MethodGet},
+	})
+
+	for origin, allowed := range map[string]bool{
+		"https://shop.example.com":      true,
+		"https://evil.com":              false,
+		"https://example.com.evil.com":  false,
+		"http://shop.example.com":       false,
+		"https://shop.example.com.evil": false,
+	} {
+		t.Run(origin, func(t *testing.T) {
+			rec := serveWith(middleware, origin)
+			if allowed {
+				assert.Equal(t, origin, rec.Header().Get("Access-Control-Allow-Origin"))
+				assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+			} else {
+				assert.Equal(t, http.StatusForbidden, rec.Code)
+				assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+			}
+		})
+	}
+}
@@ -1,48 +1,105 @@
 package router
 
 import (
+	"github.com/AlexShmak/order-service/internal/audit"
 	"github.com/AlexShmak/order-service/internal/config"
 	"github.com/AlexShmak/order-service/internal/kafka"
+	"github.com/AlexShmak/order-service/internal/mailer"
+	"github.com/AlexShmak/order-service/internal/oidc"
+	"github.com/AlexShmak/order-service/internal/ratelimit"
 	"github.com/AlexShmak/order-service/internal/storage/cache"
 	"log/slog"
-	"time"
 
 	"github.com/AlexShmak/order-service/internal/auth"
 	"github.com/AlexShmak/order-service/internal/handlers"
 
 	"github.com/AlexShmak/order-service/internal/storage"
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-func NewRouter(storage *storage.PostgresStorage, logger *slog.Logger, jwtService *auth.JWTService, cfg *config.Config, producer *kafka.Producer, redisCache *cache.RedisStorage) *gin.Engine {
+func NewRouter(postgresStorage *storage.PostgresStorage, logger *slog.Logger, jwtService *auth.JWTService, cfg *config.Config, producer kafka.OrderPublisher, redisCache *cache.RedisStorage, limiter ratelimit.Limiter, routeLimiter ratelimit.Limiter, lockout *ratelimit.Lockout, mailer mailer.Mailer, passwordPolicy *auth.PasswordPolicy, totp *auth.TOTPService, oidcClient *oidc.Client, auditRecorder *audit.Recorder) *gin.Engine {
 	router := gin.Default()
 
-	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000", "http://127.0.0.1:3000", "http://localhost:8081"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
+	router.Use(requestID())
+	router.Use(corsMiddleware(cfg.CORS))
+	router.Use(securityHeaders(cfg.Environment))
+	router.Use(bodyLimit(cfg.Server.MaxBodyBytes))
 
 	router.Use(gin.Recovery())
 
-	handler := handlers.NewHandler(storage, logger, jwtService, cfg, producer, redisCache)
+	handler := handlers.NewHandler(postgresStorage, logger, jwtService, cfg, producer, redisCache, limiter, lockout, mailer, passwordPolicy, totp, oidcClient, auditRecorder)
+
+	router.GET("/.well-known/jwks.json", handler.JWKSHandler)
+
+	bruteForce := cfg.BruteForce
+	loginLimit := ratelimit.Middleware(limiter, logger,
+		ratelimit.Rule{Name: "login-ip", Limit: bruteForce.LoginPerIP, Window: bruteForce.LoginWindow, Key: ratelimit.ByIP},
+		ratelimit.Rule{Name: "login-account", Limit: bruteForce.LoginPerAccount, Window: bruteForce.LoginWindow, Key: ratelimit.ByJSONField("email")},
+	)
+	registerLimit := ratelimit.Middleware(limiter, logger,
+		ratelimit.Rule{Name: "register-ip", Limit: bruteForce.RegisterPerIP, Window: bruteForce.RegisterWindow, Key: ratelimit.ByIP},
+	)
+
+	rateLimit := cfg.RateLimit
+	apiLimit := ratelimit.Middleware(routeLimiter, logger,
+		ratelimit.Rule{Name: "api-user", Limit: rateLimit.APIPerUser, Window: rateLimit.APIWindow, Key: ratelimit.ByUserID},
+		ratelimit.Rule{Name: "api-ip", Limit: rateLimit.APIPerIP, Window: rateLimit.APIWindow, Key: ratelimit.ByIP},
+	)
+	ordersLimit := ratelimit.Middleware(routeLimiter, logger,
+		ratelimit.Rule{Name: "orders-user", Limit: rateLimit.OrdersPerUser, Window: rateLimit.OrdersWindow, Key: ratelimit.ByUserID},
+		ratelimit.Rule{Name: "orders-ip", Limit: rateLimit.OrdersPerIP, Window: rateLimit.OrdersWindow, Key: ratelimit.ByIP},
+		ratelimit.Rule{Name: "orders-global", Limit: rateLimit.OrdersGlobal, Window: rateLimit.OrdersWindow, Key: ratelimit.Global},
+	)
 
 	authGroup := router.Group("/auth")
 	{
-		authGroup.POST("/register", handler.RegisterHandler)
-		authGroup.POST("/login", handler.LoginHandler)
-		authGroup.POST("/logout", handler.LogoutHandler)
+		authGroup.POST("/register", registerLimit, handler.RegisterHandler)
+		authGroup.POST("/login", loginLimit, handler.LoginHandler)
+		authGroup.POST("/login/2fa", loginLimit, handler.LoginTOTPHandler)
+		authGroup.POST("/logout", handler.CSRFMiddleware(), handler.LogoutHandler)
+		authGroup.POST("/verify-email", handler.VerifyEmailHandler)
+		authGroup.POST("/forgot-password", loginLimit, handler.ForgotPasswordHandler)
+		authGroup.POST("/reset-password", handler.ResetPasswordHandler)
+
+		if cfg.OIDC.Enabled {
+			authGroup.GET("/sso/login", handler.SSOLoginHandler)
+			authGroup.GET("/sso/callback", handler.SSOCallbackHandler)
+		}
 	}
 
 	api := router.Group("/api")
-	api.Use(handler.AuthMiddleware())
+	api.Use(handler.SessionMiddleware()...)
+	api.Use(apiLimit)
 	{
 		api.GET("/orders/:id", handler.GetOrderByIDHandler)
-		api.POST("/orders", handler.CreateOrderHandler)
+		api.POST("/orders", ordersLimit, handler.RequireVerifiedEmail(), handler.CreateOrderHandler)
+
+		api.GET("/me", handler.GetMeHandler)
+		api.PATCH("/me", handler.UpdateMeHandler)
+		api.POST("/me/password", handler.ChangePasswordHandler)
+		api.DELETE("/me", handler.DeleteMeHandler)
+
+		api.POST("/me/2fa/enroll", handler.EnrollTOTPHandler)
+		api.POST("/me/2fa/confirm", handler.ConfirmTOTPHandler)
+		api.DELETE("/me/2fa", handler.DisableTOTPHandler)
+	}
+
+	admin := router.Group("/admin")
+	admin.Use(handler.SessionMiddleware()...)
+	admin.Use(handler.RequireRole(storage.RoleAdmin))
+	{
+		admin.POST("/api-keys", handler.CreateAPIKeyHandler)
+		admin.GET("/api-keys", handler.ListAPIKeysHandler)
+		admin.DELETE("/api-keys/:id", handler.RevokeAPIKeyHandler)
+
+		admin.GET("/audit-events", handler.ListAuditEventsHandler)
+	}
+
+	// machine-to-machine order ingestion for partner systems
+	partner := router.Group("/partner")
+	{
+		partner.GET("/orders/:id", handler.APIKeyMiddleware(auth.ScopeOrdersRead), handler.GetOrderByIDHandler)
+		partner.POST("/orders", handler.APIKeyMiddleware(auth.ScopeOrdersWrite), ordersLimit, handler.RequireVerifiedEmail(), handler.CreateOrderHandler)
 	}
 
 	return router
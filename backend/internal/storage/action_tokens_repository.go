@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+const (
+	PurposeEmailVerification = "email_verification"
+	PurposePasswordReset     = "password_reset"
+	// PurposeMFAChallenge proves the password step of a two-step login
+	PurposeMFAChallenge = "mfa_challenge"
+)
+
+// ActionToken is a single-use token, sent to the user by email or handed out
+// as a login challenge. Only the hash of the token is stored.
+type ActionToken struct {
+	ID        int64
+	UserID    int64
+	Purpose   string
+	TokenHash []byte
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type ActionTokensRepository struct {
+	db *TxManager
+}
+
+func (r *ActionTokensRepository) Create(ctx context.Context, token *ActionToken) error {
+	query := `
+		INSERT INTO orders_service.action_tokens (user_id, purpose, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4) RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(ctx, query, token.UserID, token.Purpose, token.TokenHash, token.ExpiresAt).Scan(&token.ID, &token.CreatedAt); err != nil {
+		return fmt.Errorf("could not create action token: %w", err)
+	}
+	return nil
+}
+
+// Consume marks an unused, unexpired token as used and returns it. It returns
+// nil without an error when no such token exists, so a token can be redeemed
+// only once even under concurrent requests.
+func (r *ActionTokensRepository) Consume(ctx context.Context, hash []byte, purpose string) (*ActionToken, error) {
+	query := `
+		UPDATE orders_service.action_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING id, user_id, purpose, token_hash, expires_at, used_at, created_at
+	`
+	var token ActionToken
+	if err := r.db.QueryRow(ctx, query, hash, purpose).Scan(
+		&token.ID, &token.UserID, &token.Purpose, &token.TokenHash, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not consume action token: %w", err)
+	}
+	return &token, nil
+}
+
+// RevokeByUser marks the unused tokens of the user for purpose as used, so
+// that a newer token replaces them.
+func (r *ActionTokensRepository) RevokeByUser(ctx context.Context, userID int64, purpose string) error {
+	query := `
+		UPDATE orders_service.action_tokens SET used_at = NOW()
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+	`
+	if _, err := r.db.Exec(ctx, query, userID, purpose); err != nil {
+		return fmt.Errorf("could not revoke action tokens: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	OwnerID    int64      `json:"owner_id"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    []byte     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	RateLimit  int        `json:"rate_limit"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// IsActive reports whether the key is neither revoked nor expired at the given moment.
+func (k *APIKey) IsActive(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+type APIKeysRepository struct {
+	db *TxManager
+}
+
+const apiKeyColumns = `id, name, owner_id, prefix, key_hash, scopes, rate_limit, expires_at, revoked_at, last_used_at, created_at`
+
+func (r *APIKeysRepository) Create(ctx context.Context, key *APIKey) error {
+	query := `
+		INSERT INTO orders_service.api_keys (name, owner_id, prefix, key_hash, scopes, rate_limit, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at
+	`
+	if err := r.db.QueryRow(ctx, query, key.Name, key.OwnerID, key.Prefix, key.KeyHash, key.Scopes, key.RateLimit, key.ExpiresAt).Scan(&key.ID, &key.CreatedAt); err != nil {
+		return fmt.Errorf("could not create api key: %w", err)
+	}
+	return nil
+}
+
+// GetByHash returns nil without an error when no key matches the hash.
+func (r *APIKeysRepository) GetByHash(ctx context.Context, hash []byte) (*APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM orders_service.api_keys WHERE key_hash = $1`
+
+	key, err := scanAPIKey(r.db.QueryRow(ctx, query, hash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not get api key by hash: %w", err)
+	}
+	return key, nil
+}
+
+func (r *APIKeysRepository) List(ctx context.Context) ([]APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM orders_service.api_keys ORDER BY id`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("could not list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan api key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not list api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Revoke returns ErrNotFound when there is no active key with the given ID.
+func (r *APIKeysRepository) Revoke(ctx context.Context, id int64) error {
+	query := `UPDATE orders_service.api_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`
+
+	tag, err := r.db.Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("could not revoke api key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *APIKeysRepository) Touch(ctx context.Context, id int64) error {
+	query := `UPDATE orders_service.api_keys SET last_used_at = NOW() WHERE id = $1`
+	if _, err := r.db.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("could not update api key usage: %w", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var key APIKey
+	if err := row.Scan(
+		&key.ID, &key.Name, &key.OwnerID, &key.Prefix, &key.KeyHash, &key.Scopes, &key.RateLimit,
+		&key.ExpiresAt, &key.RevokedAt, &key.LastUsedAt, &key.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type AuditEvent struct {
+	ID         int64          `json:"id"`
+	OccurredAt time.Time      `json:"occurred_at"`
+	ActorID    *int64         `json:"actor_id"`
+	Action     string         `json:"action"`
+	TargetType string         `json:"target_type"`
+	TargetID   string         `json:"target_id"`
+	IP         string         `json:"ip"`
+	UserAgent  string         `json:"user_agent"`
+	RequestID  string         `json:"request_id"`
+	Outcome    string         `json:"outcome"`
+	Details    map[string]any `json:"details"`
+}
+
+// AuditFilter narrows down AuditEventsRepository.List. Zero values match everything.
+type AuditFilter struct {
+	ActorID    *int64
+	Action     string
+	Outcome    string
+	TargetType string
+	TargetID   string
+	IP         string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
+
+type AuditEventsRepository struct {
+	db *TxManager
+}
+
+func (r *AuditEventsRepository) Create(ctx context.Context, event *AuditEvent) error {
+	details, err := json.Marshal(event.Details)
+	if err != nil {
+		return fmt.Errorf("could not encode audit details: %w", err)
+	}
+	if event.Details == nil {
+		details = []byte("{}")
+	}
+
+	query := `
+		INSERT INTO orders_service.audit_events
+			(actor_id, action, target_type, target_id, ip, user_agent, request_id, outcome, details)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, occurred_at
+	`
+	if err := r.db.QueryRow(ctx, query,
+		event.ActorID, event.Action, event.TargetType, event.TargetID,
+		event.IP, event.UserAgent, event.RequestID, event.Outcome, details,
+	).Scan(&event.ID, &event.OccurredAt); err != nil {
+		return fmt.Errorf("could not create audit event: %w", err)
+	}
+	return nil
+}
+
+// List returns matching events, newest first.
+func (r *AuditEventsRepository) List(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	var conditions []string
+	var args []any
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ActorID != nil {
+		where("actor_id = $%d", *filter.ActorID)
+	}
+	if filter.Action != "" {
+		where("action = $%d", filter.Action)
+	}
+	if filter.Outcome != "" {
+		where("outcome = $%d", filter.Outcome)
+	}
+	if filter.TargetType != "" {
+		where("target_type = $%d", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		where("target_id = $%d", filter.TargetID)
+	}
+	if filter.IP != "" {
+		where("ip = $%d", filter.IP)
+	}
+	if filter.From != nil {
+		where("occurred_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		where("occurred_at < $%d", *filter.To)
+	}
+
+	query := `
+		SELECT id, occurred_at, actor_id, action, target_type, target_id, ip, user_agent, request_id, outcome, details
+		FROM orders_service.audit_events
+	`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY occurred_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not list audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]AuditEvent, 0)
+	for rows.Next() {
+		var event AuditEvent
+		var details []byte
+		if err := rows.Scan(
+			&event.ID, &event.OccurredAt, &event.ActorID, &event.Action, &event.TargetType, &event.TargetID,
+			&event.IP, &event.UserAgent, &event.RequestID, &event.Outcome, &details,
+		); err != nil {
+			return nil, fmt.Errorf("could not scan audit event: %w", err)
+		}
+		if err := json.Unmarshal(details, &event.Details); err != nil {
+			return nil, fmt.Errorf("could not decode audit details: %w", err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/storage"
+)
+
+type memoryEntry struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+// MemoryOrders stores orders serialized like RedisOrders does, so that
+// callers never share the cached value. Expired entries are dropped on read.
+type MemoryOrders struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]memoryEntry
+}
+
+func NewMemoryOrders(ttl time.Duration) *MemoryOrders {
+	return &MemoryOrders{ttl: ttl, entries: make(map[string]memoryEntry)}
+}
+
+func (m *MemoryOrders) Set(_ context.Context, order *storage.Order) error {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[order.OrderUID] = memoryEntry{data: data, expiresAt: time.Now().Add(m.ttl)}
+	return nil
+}
+
+func (m *MemoryOrders) Get(_ context.Context, uid string) (*storage.Order, error) {
+	m.mu.Lock()
+	entry, ok := m.entries[uid]
+	if ok && !time.Now().Before(entry.expiresAt) {
+		delete(m.entries, uid)
+		ok = false
+	}
+	m.mu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+
+	var order storage.Order
+	if err := json.Unmarshal(entry.data, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (m *MemoryOrders) Delete(_ context.Context, uids ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, uid := range uids {
+		delete(m.entries, uid)
+	}
+	return nil
+}
@@ -5,13 +5,20 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/AlexShmak/order-service/internal/encryption"
 	"github.com/AlexShmak/order-service/internal/storage"
 	"github.com/redis/go-redis/v9"
 	"time"
 )
 
+// orderTTL bounds how long an order may be served from the cache.
+const orderTTL = time.Hour
+
+// RedisOrders keeps orders encrypted with the PII keyring, as they contain
+// the delivery data. Each entry is bound to its key.
 type RedisOrders struct {
-	rdb *redis.Client
+	rdb     *redis.Client
+	keyring *encryption.Keyring
 }
 
 func (r *RedisOrders) Set(ctx context.Context, order *storage.Order) error {
@@ -20,7 +27,11 @@ func (r *RedisOrders) Set(ctx context.Context, order *storage.Order) error {
 	if err != nil {
 		return err
 	}
-	return r.rdb.SetEx(ctx, cacheKey, data, time.Hour).Err()
+	encrypted, err := r.keyring.Encrypt(data, []byte(cacheKey))
+	if err != nil {
+		return err
+	}
+	return r.rdb.SetEx(ctx, cacheKey, encrypted, orderTTL).Err()
 }
 
 func (r *RedisOrders) Get(ctx context.Context, uid string) (*storage.Order, error) {
@@ -31,11 +42,26 @@ func (r *RedisOrders) Get(ctx context.Context, uid string) (*storage.Order, erro
 	} else if err != nil {
 		return nil, err
 	}
+	plaintext, err := r.keyring.Decrypt([]byte(data), []byte(cacheKey))
+	if err != nil {
+		// entries written before encryption or with a retired key are dropped
+		_ = r.rdb.Del(ctx, cacheKey).Err()
+		return nil, nil
+	}
 	var order storage.Order
-	if data != "" {
-		if err := json.Unmarshal([]byte(data), &order); err != nil {
-			return nil, err
-		}
+	if err := json.Unmarshal(plaintext, &order); err != nil {
+		return nil, err
 	}
 	return &order, nil
 }
+
+func (r *RedisOrders) Delete(ctx context.Context, uids ...string) error {
+	if len(uids) == 0 {
+		return nil
+	}
+	cacheKeys := make([]string, 0, len(uids))
+	for _, uid := range uids {
+		cacheKeys = append(cacheKeys, fmt.Sprintf("order-%v", uid))
+	}
+	return r.rdb.Del(ctx, cacheKeys...).Err()
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/encryption"
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/AlexShmak/order-service/internal/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryOrdersContract(t *testing.T) {
+	storagetest.RunOrderCache(t, func(*testing.T) storagetest.OrderCache {
+		return NewMemoryOrders(orderTTL)
+	})
+}
+
+// TestRedisOrdersContract needs a Redis it may write to, e.g.
+//
+//	TEST_REDIS_ADDR=localhost:6379 go test ./internal/storage/cache/
+func TestRedisOrdersContract(t *testing.T) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR is not set")
+	}
+
+	rdb := NewRedisClient(addr, os.Getenv("TEST_REDIS_PASSWORD"), 0)
+	t.Cleanup(func() { _ = rdb.Close() })
+	require.NoError(t, rdb.Ping(context.Background()).Err())
+
+	key := make([]byte, encryption.KeySize)
+	_, _ = rand.Read(key)
+	keyring, err := encryption.NewKeyring(map[string]string{"test": base64.StdEncoding.EncodeToString(key)}, "")
+	require.NoError(t, err)
+
+	storagetest.RunOrderCache(t, func(*testing.T) storagetest.OrderCache {
+		return &RedisOrders{rdb: rdb, keyring: keyring}
+	})
+}
+
+func TestMemoryOrdersExpire(t *testing.T) {
+	ctx := context.Background()
+	orders := NewMemoryOrders(time.Millisecond)
+	require.NoError(t, orders.Set(ctx, &storage.Order{OrderUID: "expiring"}))
+
+	time.Sleep(5 * time.Millisecond)
+	got, err := orders.Get(ctx, "expiring")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
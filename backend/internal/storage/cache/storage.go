@@ -2,19 +2,31 @@ package cache
 
 import (
 	"context"
+	"github.com/AlexShmak/order-service/internal/encryption"
 	"github.com/AlexShmak/order-service/internal/storage"
 	"github.com/redis/go-redis/v9"
 )
 
+// Orders caches orders by UID. A miss is returned as nil without an error.
+type Orders interface {
+	Get(context.Context, string) (*storage.Order, error)
+	Set(context.Context, *storage.Order) error
+	Delete(context.Context, ...string) error
+}
+
 type RedisStorage struct {
-	Orders interface {
-		Get(context.Context, string) (*storage.Order, error)
-		Set(context.Context, *storage.Order) error
+	Orders Orders
+}
+
+func NewRedisStorage(rdb *redis.Client, piiKeyring *encryption.Keyring) *RedisStorage {
+	return &RedisStorage{
+		Orders: &RedisOrders{rdb: rdb, keyring: piiKeyring},
 	}
 }
 
-func NewRedisStorage(rdb *redis.Client) *RedisStorage {
+// NewMemoryStorage keeps the orders in process, for tests and local development.
+func NewMemoryStorage() *RedisStorage {
 	return &RedisStorage{
-		Orders: &RedisOrders{rdb: rdb},
+		Orders: NewMemoryOrders(orderTTL),
 	}
 }
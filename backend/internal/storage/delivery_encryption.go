@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// deliveryKeyAD binds the wrapped data key of a delivery to the deliveries
+// table and to its order. Every field is additionally bound to the order and
+// its column, so that encrypted values can neither be copied to the delivery
+// of another order nor swapped between columns.
+func deliveryKeyAD(orderUID string) []byte {
+	return []byte("orders_service.deliveries:" + orderUID)
+}
+
+func deliveryFieldAD(orderUID, column string) []byte {
+	return []byte(orderUID + ":" + column)
+}
+
+// encryptedDelivery holds the columns that replace the plaintext PII of a
+// delivery. Zip, city and region are not considered identifying on their own
+// and stay in plaintext.
+type encryptedDelivery struct {
+	keyID     string
+	dataKey   []byte
+	name      []byte
+	phone     []byte
+	address   []byte
+	email     []byte
+	emailHash []byte
+}
+
+// storedDelivery is the PII of a delivery row as it is stored. Rows written
+// before encryption was introduced have no data key and keep their plaintext
+// until RotateDeliveryKeys encrypts them.
+type storedDelivery struct {
+	name             pgtype.Text
+	phone            pgtype.Text
+	address          pgtype.Text
+	email            pgtype.Text
+	dataKey          []byte
+	nameEncrypted    []byte
+	phoneEncrypted   []byte
+	addressEncrypted []byte
+	emailEncrypted   []byte
+}
+
+func (r *OrdersRepository) encryptDelivery(orderUID string, delivery *Delivery) (*encryptedDelivery, error) {
+	dataKey, wrapped, err := r.keyring.NewDataKey(deliveryKeyAD(orderUID))
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := &encryptedDelivery{
+		keyID:     r.keyring.ActiveKeyID(),
+		dataKey:   wrapped,
+		emailHash: r.emailHash(delivery.Email),
+	}
+	fields := []struct {
+		column string
+		value  string
+		target *[]byte
+	}{
+		{"name", delivery.Name, &encrypted.name},
+		{"phone", delivery.Phone, &encrypted.phone},
+		{"address", delivery.Address, &encrypted.address},
+		{"email", delivery.Email, &encrypted.email},
+	}
+	for _, field := range fields {
+		if *field.target, err = dataKey.Encrypt([]byte(field.value), deliveryFieldAD(orderUID, field.column)); err != nil {
+			return nil, fmt.Errorf("could not encrypt delivery %s: %w", field.column, err)
+		}
+	}
+	return encrypted, nil
+}
+
+// decryptDelivery fills in the PII of the delivery of an order from the
+// stored row.
+func (r *OrdersRepository) decryptDelivery(orderUID string, stored *storedDelivery, delivery *Delivery) error {
+	if stored.dataKey == nil {
+		delivery.Name = stored.name.String
+		delivery.Phone = stored.phone.String
+		delivery.Address = stored.address.String
+		delivery.Email = stored.email.String
+		return nil
+	}
+
+	dataKey, err := r.keyring.OpenDataKey(stored.dataKey, deliveryKeyAD(orderUID))
+	if err != nil {
+		return err
+	}
+	fields := []struct {
+		column string
+		value  []byte
+		target *string
+	}{
+		{"name", stored.nameEncrypted, &delivery.Name},
+		{"phone", stored.phoneEncrypted, &delivery.Phone},
+		{"address", stored.addressEncrypted, &delivery.Address},
+		{"email", stored.emailEncrypted, &delivery.Email},
+	}
+	for _, field := range fields {
+		plaintext, err := dataKey.Decrypt(field.value, deliveryFieldAD(orderUID, field.column))
+		if err != nil {
+			return fmt.Errorf("could not decrypt delivery %s: %w", field.column, err)
+		}
+		*field.target = string(plaintext)
+	}
+	return nil
+}
+
+func (r *OrdersRepository) emailHash(email string) []byte {
+	return r.hasher.Hash(NormalizeEmail(email))
+}
@@ -0,0 +1,60 @@
+package storage_test
+
+import (
+	"context"
+	"math/rand/v2"
+	"strconv"
+	"testing"
+
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/AlexShmak/order-service/internal/storage/pgtest"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDeliveryOrder(customerID int64, email string) *storage.Order {
+	uid := uuid.NewString()
+	return &storage.Order{
+		OrderUID:    uid,
+		TrackNumber: "WBIL" + uid,
+		Entry:       "WBIL",
+		Delivery:    storage.Delivery{Name: "Test Testov", Phone: "+9720000000", Address: "Ploshad Mira 15", Email: email},
+		Payment:     storage.Payment{Transaction: uid, Currency: "USD", Provider: "wbpay"},
+		CustomerID:  strconv.FormatInt(customerID, 10),
+	}
+}
+
+// TestDeliveryEncryptionIsBoundToTheOrder needs a Postgres it may write to,
+// see package pgtest.
+func TestDeliveryEncryptionIsBoundToTheOrder(t *testing.T) {
+	pool, keyring, hasher := pgtest.Open(t)
+	s := storage.NewPostgresStorage(pool, keyring, hasher)
+	ctx := context.Background()
+
+	customerID := rand.Int64N(1<<40) + 1<<40
+	victim := newDeliveryOrder(customerID, "victim@example.com")
+	copied := newDeliveryOrder(customerID, "copied@example.com")
+	require.NoError(t, s.Orders.Create(ctx, victim))
+	require.NoError(t, s.Orders.Create(ctx, copied))
+
+	// whoever can write the table copies the encrypted delivery of the
+	// victim, wrapped data key included, to an order they can read
+	_, err := pool.Exec(ctx, `
+		UPDATE orders_service.deliveries d
+		SET key_id = v.key_id, data_key = v.data_key, name_encrypted = v.name_encrypted, phone_encrypted = v.phone_encrypted,
+		    address_encrypted = v.address_encrypted, email_encrypted = v.email_encrypted
+		FROM orders_service.orders vo
+		JOIN orders_service.deliveries v ON v.id = vo.delivery_data_id,
+		     orders_service.orders co
+		WHERE vo.order_uid = $1 AND co.order_uid = $2 AND d.id = co.delivery_data_id
+	`, victim.OrderUID, copied.OrderUID)
+	require.NoError(t, err)
+
+	got, err := s.Orders.GetByID(ctx, victim.OrderUID, customerID)
+	require.NoError(t, err)
+	assert.Equal(t, "victim@example.com", got.Delivery.Email)
+
+	_, err = s.Orders.GetByID(ctx, copied.OrderUID, customerID)
+	assert.Error(t, err, "the delivery does not decrypt for another order")
+}
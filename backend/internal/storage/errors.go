@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrEmailTaken  = errors.New("email is already taken")
+	ErrOrderExists = errors.New("order already exists")
+	// ErrNotFound is returned by lookups that fail when the row does not exist.
+	ErrNotFound = pgx.ErrNoRows
+)
+
+// Postgres SQLSTATE codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	ClassDataException                = "22"
+	ClassIntegrityConstraintViolation = "23"
+
+	CodeUniqueViolation      = "23505"
+	CodeForeignKeyViolation  = "23503"
+	CodeSerializationFailure = "40001"
+	CodeDeadlockDetected     = "40P01"
+)
+
+// PgErrorCode returns the SQLSTATE of a Postgres error, or "" for other errors.
+func PgErrorCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+func IsUniqueViolation(err error) bool {
+	return PgErrorCode(err) == CodeUniqueViolation
+}
+
+// IsUniqueViolationOf reports whether err violates the unique constraint
+// with the given name. Tables with several unique keys need it to tell them
+// apart.
+func IsUniqueViolationOf(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == CodeUniqueViolation && pgErr.ConstraintName == constraint
+}
+
+func IsForeignKeyViolation(err error) bool {
+	return PgErrorCode(err) == CodeForeignKeyViolation
+}
+
+// IsRetryable reports whether the transaction failed only because it
+// conflicted with a concurrent one and may succeed when run again.
+func IsRetryable(err error) bool {
+	code := PgErrorCode(err)
+	return code == CodeSerializationFailure || code == CodeDeadlockDetected
+}
+
+// IsDataError reports whether the statement failed on the data it was given,
+// e.g. a value too long or a violated constraint. Unlike a connection or
+// server error, running it again fails the same way.
+func IsDataError(err error) bool {
+	code := PgErrorCode(err)
+	return strings.HasPrefix(code, ClassDataException) || strings.HasPrefix(code, ClassIntegrityConstraintViolation)
+}
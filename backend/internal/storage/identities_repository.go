@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+)
+
+// ExternalIdentity is a user as asserted by a single sign-on issuer.
+type ExternalIdentity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Role          string
+}
+
+type IdentitiesRepository struct {
+	db *TxManager
+}
+
+// FindOrCreateUser returns the user linked to the identity, updating its name
+// and role from the issuer, or provisions a new user without a password.
+// An existing account with the same email is never linked implicitly, as that
+// would let whoever controls the issuer take it over; ErrEmailTaken is
+// returned instead.
+func (r *IdentitiesRepository) FindOrCreateUser(ctx context.Context, identity *ExternalIdentity) (*User, error) {
+	var user *User
+	err := r.db.WithinTx(ctx, func(ctx context.Context) error {
+		var userID int64
+		err := r.db.QueryRow(ctx,
+			`SELECT user_id FROM orders_service.user_identities WHERE issuer = $1 AND subject = $2`,
+			identity.Issuer, identity.Subject,
+		).Scan(&userID)
+		switch {
+		case err == nil:
+			user, err = scanUser(r.db.QueryRow(ctx, `
+				UPDATE orders_service.users SET name = $1, role = $2
+				WHERE id = $3
+				RETURNING id, name, password, email, role, email_verified_at, created_at
+			`, identity.Name, identity.Role, userID))
+			if err != nil {
+				return fmt.Errorf("could not update user from identity: %w", err)
+			}
+		case errors.Is(err, pgx.ErrNoRows):
+			user, err = scanUser(r.db.QueryRow(ctx, `
+				INSERT INTO orders_service.users (name, password, email, role, email_verified_at)
+				VALUES ($1, NULL, $2, $3, CASE WHEN $4 THEN NOW() END)
+				RETURNING id, name, password, email, role, email_verified_at, created_at
+			`, identity.Name, identity.Email, identity.Role, identity.EmailVerified))
+			if err != nil {
+				if IsUniqueViolation(err) {
+					return ErrEmailTaken
+				}
+				return fmt.Errorf("could not create user for identity: %w", err)
+			}
+			if _, err = r.db.Exec(ctx,
+				`INSERT INTO orders_service.user_identities (issuer, subject, user_id) VALUES ($1, $2, $3)`,
+				identity.Issuer, identity.Subject, user.ID,
+			); err != nil {
+				return fmt.Errorf("could not link identity: %w", err)
+			}
+		default:
+			return fmt.Errorf("could not get identity: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Deprovision drops the staff role of the user linked to the identity, for
+// when the issuer no longer grants one. It returns nil if no user is linked.
+func (r *IdentitiesRepository) Deprovision(ctx context.Context, issuer, subject string) (*User, error) {
+	user, err := scanUser(r.db.QueryRow(ctx, `
+		UPDATE orders_service.users SET role = $1
+		WHERE id = (SELECT user_id FROM orders_service.user_identities WHERE issuer = $2 AND subject = $3)
+		RETURNING id, name, password, email, role, email_verified_at, created_at
+	`, RoleCustomer, issuer, subject))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not deprovision identity: %w", err)
+	}
+	return user, nil
+}
@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/storage"
+)
+
+// errTrackNumberTaken stands in for the unique violation Postgres reports,
+// unlike storage.ErrOrderExists it is a failure.
+var errTrackNumberTaken = errors.New("track number is already taken")
+
+// anonymizedValue matches what the Postgres repository writes over the PII.
+const anonymizedValue = "[deleted]"
+
+// Orders keeps the delivery data in plaintext, there is nothing to encrypt
+// it at rest for.
+type Orders struct {
+	*store
+}
+
+func (s *Orders) GetByID(_ context.Context, uid string, userID int64) (*storage.Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	order, ok := s.orders[uid]
+	if !ok || order.CustomerID != strconv.FormatInt(userID, 10) {
+		return nil, nil
+	}
+	order.Items = slices.Clone(order.Items)
+	return &order, nil
+}
+
+func (s *Orders) Create(_ context.Context, order *storage.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[order.OrderUID]; ok {
+		return storage.ErrOrderExists
+	}
+	if s.trackNumberTaken(order.TrackNumber) {
+		return fmt.Errorf("%w: %s", errTrackNumberTaken, order.TrackNumber)
+	}
+	stored := *order
+	stored.Items = slices.Clone(order.Items)
+	stored.DateCreated = time.Now()
+	s.orders[order.OrderUID] = stored
+	return nil
+}
+
+// CreateBatch stores all orders or, if one of them cannot be stored, none.
+func (s *Orders) CreateBatch(_ context.Context, orders []*storage.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool, len(orders))
+	seenTrackNumbers := make(map[string]bool, len(orders))
+	for _, order := range orders {
+		if _, ok := s.orders[order.OrderUID]; ok || seen[order.OrderUID] {
+			return storage.ErrOrderExists
+		}
+		if s.trackNumberTaken(order.TrackNumber) || seenTrackNumbers[order.TrackNumber] {
+			return fmt.Errorf("%w: %s", errTrackNumberTaken, order.TrackNumber)
+		}
+		seen[order.OrderUID] = true
+		seenTrackNumbers[order.TrackNumber] = true
+	}
+	for _, order := range orders {
+		stored := *order
+		stored.Items = slices.Clone(order.Items)
+		stored.DateCreated = time.Now()
+		s.orders[order.OrderUID] = stored
+	}
+	return nil
+}
+
+// trackNumberTaken mirrors the unique key of orders.track_number in Postgres.
+func (s *Orders) trackNumberTaken(trackNumber string) bool {
+	for _, order := range s.orders {
+		if order.TrackNumber == trackNumber {
+			return true
+		}
+	}
+	return false
+}
+
+// AnonymizeByCustomer wipes the delivery PII of every order of the customer
+// and returns the UIDs of the affected orders.
+func (s *Orders) AnonymizeByCustomer(_ context.Context, customerID int64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var uids []string
+	for uid, order := range s.orders {
+		if order.CustomerID != strconv.FormatInt(customerID, 10) {
+			continue
+		}
+		order.Delivery = storage.Delivery{
+			Name:    anonymizedValue,
+			Phone:   anonymizedValue,
+			Zip:     anonymizedValue,
+			City:    anonymizedValue,
+			Address: anonymizedValue,
+			Region:  anonymizedValue,
+			Email:   anonymizedValue,
+		}
+		s.orders[uid] = order
+		uids = append(uids, uid)
+	}
+	return uids, nil
+}
+
+// FindUIDsByDeliveryEmail returns the newest orders first.
+func (s *Orders) FindUIDsByDeliveryEmail(_ context.Context, email string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found []storage.Order
+	for _, order := range s.orders {
+		if storage.NormalizeEmail(order.Delivery.Email) == storage.NormalizeEmail(email) {
+			found = append(found, order)
+		}
+	}
+	slices.SortFunc(found, func(a, b storage.Order) int {
+		return b.DateCreated.Compare(a.DateCreated)
+	})
+
+	uids := make([]string, 0, len(found))
+	for _, order := range found {
+		uids = append(uids, order.OrderUID)
+	}
+	return uids, nil
+}
+
+// RotateDeliveryKeys has nothing to rotate.
+func (s *Orders) RotateDeliveryKeys(context.Context, int) (int, error) {
+	return 0, nil
+}
@@ -0,0 +1,47 @@
+// Package memory implements the storage interfaces in memory, with the same
+// semantics as the Postgres repositories. It is meant for tests and local
+// development, nothing is persisted.
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/AlexShmak/order-service/internal/storage"
+)
+
+// store is shared by the repositories, so that deleting a user also deletes
+// its sessions like the foreign key does in Postgres.
+type store struct {
+	mu sync.RWMutex
+
+	users       map[int64]storage.User
+	lastUserID  int64
+	orders      map[string]storage.Order
+	tokens      map[string]storage.RefreshToken
+	lastTokenID int64
+}
+
+// NewStorage returns the users, orders and tokens repositories. The other
+// repositories have no in-memory implementation and are left nil.
+func NewStorage() *storage.PostgresStorage {
+	s := &store{
+		users:  make(map[int64]storage.User),
+		orders: make(map[string]storage.Order),
+		tokens: make(map[string]storage.RefreshToken),
+	}
+	return &storage.PostgresStorage{
+		Users:  &Users{store: s},
+		Orders: &Orders{store: s},
+		Tokens: &Tokens{store: s},
+		Tx:     Tx{},
+	}
+}
+
+// Tx runs fn right away. There is no isolation, and changes made before fn
+// fails are not undone.
+type Tx struct{}
+
+func (Tx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
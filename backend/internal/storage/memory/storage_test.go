@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/AlexShmak/order-service/internal/storage/storagetest"
+)
+
+func TestContract(t *testing.T) {
+	storagetest.Run(t, func(*testing.T) *storage.PostgresStorage {
+		return NewStorage()
+	})
+}
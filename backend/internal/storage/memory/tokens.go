@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/storage"
+)
+
+type Tokens struct {
+	*store
+}
+
+func (s *Tokens) Create(_ context.Context, token *storage.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[token.UserID]; !ok {
+		return fmt.Errorf("could not create refresh token: user %d does not exist", token.UserID)
+	}
+	if _, ok := s.tokens[token.Token]; ok {
+		return errors.New("could not create refresh token: token already exists")
+	}
+	s.lastTokenID++
+	token.ID = s.lastTokenID
+	token.CreatedAt = time.Now()
+	s.tokens[token.Token] = *token
+	return nil
+}
+
+func (s *Tokens) GetByToken(_ context.Context, tokenString string) (*storage.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token, ok := s.tokens[tokenString]
+	if !ok || !token.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("refresh token not found or expired: %w", storage.ErrNotFound)
+	}
+	return &token, nil
+}
+
+func (s *Tokens) Delete(_ context.Context, tokenString string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.tokens, tokenString)
+	return nil
+}
+
+func (s *Tokens) DeleteByUserID(_ context.Context, userID int64) error {
+	return s.deleteByUserID(userID, "")
+}
+
+// DeleteByUserIDExcept revokes every session of the user but the one with the given token.
+func (s *Tokens) DeleteByUserIDExcept(_ context.Context, userID int64, tokenString string) error {
+	return s.deleteByUserID(userID, tokenString)
+}
+
+func (s *Tokens) deleteByUserID(userID int64, except string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, token := range s.tokens {
+		if token.UserID == userID && (except == "" || key != except) {
+			delete(s.tokens, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/storage"
+)
+
+type Users struct {
+	*store
+}
+
+func (s *Users) Create(_ context.Context, user *storage.User) error {
+	if err := user.HashPassword(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.emailTaken(user.Email, 0) {
+		return storage.ErrEmailTaken
+	}
+	s.lastUserID++
+	user.ID = s.lastUserID
+	user.Role = storage.RoleCustomer
+	user.CreatedAt = time.Now()
+
+	stored := *user
+	stored.Password = ""
+	s.users[user.ID] = stored
+	return nil
+}
+
+func (s *Users) GetByEmail(_ context.Context, email string) (*storage.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if storage.NormalizeEmail(user.Email) == storage.NormalizeEmail(email) {
+			return &user, nil
+		}
+	}
+	return nil, storage.ErrNotFound
+}
+
+func (s *Users) GetByID(_ context.Context, id int64) (*storage.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return &user, nil
+}
+
+func (s *Users) MarkEmailVerified(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok || user.EmailVerifiedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	s.users[id] = user
+	return nil
+}
+
+// UpdatePassword hashes user.Password and stores the new hash.
+func (s *Users) UpdatePassword(_ context.Context, user *storage.User) error {
+	if err := user.HashPassword(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.users[user.ID]
+	if !ok {
+		return nil
+	}
+	// the hash is unexported, so it is taken along with a copy of user
+	updated := *user
+	updated.Password = ""
+	updated.Name = stored.Name
+	updated.Email = stored.Email
+	updated.Role = stored.Role
+	updated.EmailVerifiedAt = stored.EmailVerifiedAt
+	updated.CreatedAt = stored.CreatedAt
+	s.users[user.ID] = updated
+	return nil
+}
+
+// UpdateProfile stores the name and email of the user. Changing the email
+// resets its verification.
+func (s *Users) UpdateProfile(_ context.Context, user *storage.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.users[user.ID]
+	if !ok {
+		return storage.ErrNotFound
+	}
+	if s.emailTaken(user.Email, user.ID) {
+		return storage.ErrEmailTaken
+	}
+	if storage.NormalizeEmail(stored.Email) != storage.NormalizeEmail(user.Email) {
+		stored.EmailVerifiedAt = nil
+	}
+	stored.Name = user.Name
+	stored.Email = user.Email
+	s.users[user.ID] = stored
+
+	user.EmailVerifiedAt = stored.EmailVerifiedAt
+	return nil
+}
+
+// Delete removes the user together with its sessions.
+func (s *Users) Delete(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.users, id)
+	for key, token := range s.tokens {
+		if token.UserID == id {
+			delete(s.tokens, key)
+		}
+	}
+	return nil
+}
+
+// emailTaken matches case-insensitively like the unique index on lower(email).
+func (s *store) emailTaken(email string, exceptID int64) bool {
+	for id, user := range s.users {
+		if id != exceptID && storage.NormalizeEmail(user.Email) == storage.NormalizeEmail(email) {
+			return true
+		}
+	}
+	return false
+}
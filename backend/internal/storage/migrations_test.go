@@ -0,0 +1,62 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/storage/pgtest"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationCaseInsensitiveEmails(t *testing.T) {
+	databaseURL := pgtest.NewDatabase(t)
+	m := pgtest.Migrate(t, databaseURL)
+	require.NoError(t, m.Migrate(8))
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, databaseURL)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	// two accounts that only differ in case, the older one keeps the address
+	created := time.Now().Add(-time.Hour)
+	var ids []int64
+	for i, email := range []string{"Foo@Example.com", "foo@example.com", "Bar@Example.com"} {
+		var id int64
+		require.NoError(t, pool.QueryRow(ctx,
+			`INSERT INTO orders_service.users (name, password, email, created_at) VALUES ('Test Testov', '\x00', $1, $2) RETURNING id`,
+			email, created.Add(time.Duration(i)*time.Minute),
+		).Scan(&id))
+		ids = append(ids, id)
+	}
+
+	require.NoError(t, m.Migrate(9), "duplicates must not stop the migration")
+
+	emails := func() []string {
+		var emails []string
+		for _, id := range ids {
+			var email string
+			require.NoError(t, pool.QueryRow(ctx, `SELECT email FROM orders_service.users WHERE id = $1`, id).Scan(&email))
+			emails = append(emails, email)
+		}
+		return emails
+	}
+	assert.Equal(t, []string{"foo@example.com", fmt.Sprintf("email-conflict-%d@invalid", ids[1]), "bar@example.com"}, emails())
+
+	var conflict string
+	require.NoError(t, pool.QueryRow(ctx, `SELECT email FROM orders_service.users_email_conflicts WHERE user_id = $1`, ids[1]).Scan(&conflict))
+	assert.Equal(t, "foo@example.com", conflict)
+
+	_, err = pool.Exec(ctx, `INSERT INTO orders_service.users (name, password, email) VALUES ('Test Testov', '\x00', 'BAR@example.com')`)
+	assert.Error(t, err, "the emails are unique ignoring case")
+
+	// down keeps the parked addresses, and up can run again
+	require.NoError(t, m.Migrate(8))
+	require.NoError(t, pool.QueryRow(ctx, `SELECT email FROM orders_service.users_email_conflicts WHERE user_id = $1`, ids[1]).Scan(&conflict))
+	require.NoError(t, m.Migrate(9))
+	assert.Equal(t, []string{"foo@example.com", fmt.Sprintf("email-conflict-%d@invalid", ids[1]), "bar@example.com"}, emails())
+}
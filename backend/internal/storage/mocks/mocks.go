@@ -95,6 +95,63 @@ func (_c *MockUsers_Create_Call) RunAndReturn(run func(context1 context.Context,
 	return _c
 }
 
+// Delete provides a mock function for the type MockUsers
+func (_mock *MockUsers) Delete(context1 context.Context, n int64) error {
+	ret := _mock.Called(context1, n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Delete")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = returnFunc(context1, n)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockUsers_Delete_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Delete'
+type MockUsers_Delete_Call struct {
+	*mock.Call
+}
+
+// Delete is a helper method to define mock.On call
+//   - context1 context.Context
+//   - n int64
+func (_e *MockUsers_Expecter) Delete(context1 interface{}, n interface{}) *MockUsers_Delete_Call {
+	return &MockUsers_Delete_Call{Call: _e.mock.On("Delete", context1, n)}
+}
+
+func (_c *MockUsers_Delete_Call) Run(run func(context1 context.Context, n int64)) *MockUsers_Delete_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 int64
+		if args[1] != nil {
+			arg1 = args[1].(int64)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockUsers_Delete_Call) Return(err error) *MockUsers_Delete_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockUsers_Delete_Call) RunAndReturn(run func(context1 context.Context, n int64) error) *MockUsers_Delete_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // GetByEmail provides a mock function for the type MockUsers
 func (_mock *MockUsers) GetByEmail(context1 context.Context, s string) (*storage.User, error) {
 	ret := _mock.Called(context1, s)
@@ -111,196 +168,1994 @@ func (_mock *MockUsers) GetByEmail(context1 context.Context, s string) (*storage
 	if returnFunc, ok := ret.Get(0).(func(context.Context, string) *storage.User); ok {
 		r0 = returnFunc(context1, s)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*storage.User)
-		}
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storage.User)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(context1, s)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockUsers_GetByEmail_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetByEmail'
+type MockUsers_GetByEmail_Call struct {
+	*mock.Call
+}
+
+// GetByEmail is a helper method to define mock.On call
+//   - context1 context.Context
+//   - s string
+func (_e *MockUsers_Expecter) GetByEmail(context1 interface{}, s interface{}) *MockUsers_GetByEmail_Call {
+	return &MockUsers_GetByEmail_Call{Call: _e.mock.On("GetByEmail", context1, s)}
+}
+
+func (_c *MockUsers_GetByEmail_Call) Run(run func(context1 context.Context, s string)) *MockUsers_GetByEmail_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockUsers_GetByEmail_Call) Return(user *storage.User, err error) *MockUsers_GetByEmail_Call {
+	_c.Call.Return(user, err)
+	return _c
+}
+
+func (_c *MockUsers_GetByEmail_Call) RunAndReturn(run func(context1 context.Context, s string) (*storage.User, error)) *MockUsers_GetByEmail_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetByID provides a mock function for the type MockUsers
+func (_mock *MockUsers) GetByID(context1 context.Context, n int64) (*storage.User, error) {
+	ret := _mock.Called(context1, n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByID")
+	}
+
+	var r0 *storage.User
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64) (*storage.User, error)); ok {
+		return returnFunc(context1, n)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64) *storage.User); ok {
+		r0 = returnFunc(context1, n)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storage.User)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = returnFunc(context1, n)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockUsers_GetByID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetByID'
+type MockUsers_GetByID_Call struct {
+	*mock.Call
+}
+
+// GetByID is a helper method to define mock.On call
+//   - context1 context.Context
+//   - n int64
+func (_e *MockUsers_Expecter) GetByID(context1 interface{}, n interface{}) *MockUsers_GetByID_Call {
+	return &MockUsers_GetByID_Call{Call: _e.mock.On("GetByID", context1, n)}
+}
+
+func (_c *MockUsers_GetByID_Call) Run(run func(context1 context.Context, n int64)) *MockUsers_GetByID_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 int64
+		if args[1] != nil {
+			arg1 = args[1].(int64)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockUsers_GetByID_Call) Return(user *storage.User, err error) *MockUsers_GetByID_Call {
+	_c.Call.Return(user, err)
+	return _c
+}
+
+func (_c *MockUsers_GetByID_Call) RunAndReturn(run func(context1 context.Context, n int64) (*storage.User, error)) *MockUsers_GetByID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// MarkEmailVerified provides a mock function for the type MockUsers
+func (_mock *MockUsers) MarkEmailVerified(context1 context.Context, n int64) error {
+	ret := _mock.Called(context1, n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkEmailVerified")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = returnFunc(context1, n)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockUsers_MarkEmailVerified_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MarkEmailVerified'
+type MockUsers_MarkEmailVerified_Call struct {
+	*mock.Call
+}
+
+// MarkEmailVerified is a helper method to define mock.On call
+//   - context1 context.Context
+//   - n int64
+func (_e *MockUsers_Expecter) MarkEmailVerified(context1 interface{}, n interface{}) *MockUsers_MarkEmailVerified_Call {
+	return &MockUsers_MarkEmailVerified_Call{Call: _e.mock.On("MarkEmailVerified", context1, n)}
+}
+
+func (_c *MockUsers_MarkEmailVerified_Call) Run(run func(context1 context.Context, n int64)) *MockUsers_MarkEmailVerified_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 int64
+		if args[1] != nil {
+			arg1 = args[1].(int64)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockUsers_MarkEmailVerified_Call) Return(err error) *MockUsers_MarkEmailVerified_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockUsers_MarkEmailVerified_Call) RunAndReturn(run func(context1 context.Context, n int64) error) *MockUsers_MarkEmailVerified_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UpdatePassword provides a mock function for the type MockUsers
+func (_mock *MockUsers) UpdatePassword(context1 context.Context, user *storage.User) error {
+	ret := _mock.Called(context1, user)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdatePassword")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *storage.User) error); ok {
+		r0 = returnFunc(context1, user)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockUsers_UpdatePassword_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdatePassword'
+type MockUsers_UpdatePassword_Call struct {
+	*mock.Call
+}
+
+// UpdatePassword is a helper method to define mock.On call
+//   - context1 context.Context
+//   - user *storage.User
+func (_e *MockUsers_Expecter) UpdatePassword(context1 interface{}, user interface{}) *MockUsers_UpdatePassword_Call {
+	return &MockUsers_UpdatePassword_Call{Call: _e.mock.On("UpdatePassword", context1, user)}
+}
+
+func (_c *MockUsers_UpdatePassword_Call) Run(run func(context1 context.Context, user *storage.User)) *MockUsers_UpdatePassword_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 *storage.User
+		if args[1] != nil {
+			arg1 = args[1].(*storage.User)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockUsers_UpdatePassword_Call) Return(err error) *MockUsers_UpdatePassword_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockUsers_UpdatePassword_Call) RunAndReturn(run func(context1 context.Context, user *storage.User) error) *MockUsers_UpdatePassword_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UpdateProfile provides a mock function for the type MockUsers
+func (_mock *MockUsers) UpdateProfile(context1 context.Context, user *storage.User) error {
+	ret := _mock.Called(context1, user)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateProfile")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *storage.User) error); ok {
+		r0 = returnFunc(context1, user)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockUsers_UpdateProfile_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdateProfile'
+type MockUsers_UpdateProfile_Call struct {
+	*mock.Call
+}
+
+// UpdateProfile is a helper method to define mock.On call
+//   - context1 context.Context
+//   - user *storage.User
+func (_e *MockUsers_Expecter) UpdateProfile(context1 interface{}, user interface{}) *MockUsers_UpdateProfile_Call {
+	return &MockUsers_UpdateProfile_Call{Call: _e.mock.On("UpdateProfile", context1, user)}
+}
+
+func (_c *MockUsers_UpdateProfile_Call) Run(run func(context1 context.Context, user *storage.User)) *MockUsers_UpdateProfile_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 *storage.User
+		if args[1] != nil {
+			arg1 = args[1].(*storage.User)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockUsers_UpdateProfile_Call) Return(err error) *MockUsers_UpdateProfile_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockUsers_UpdateProfile_Call) RunAndReturn(run func(context1 context.Context, user *storage.User) error) *MockUsers_UpdateProfile_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockOrders creates a new instance of MockOrders. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockOrders(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockOrders {
+	mock := &MockOrders{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// MockOrders is an autogenerated mock type for the Orders type
+type MockOrders struct {
+	mock.Mock
+}
+
+type MockOrders_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockOrders) EXPECT() *MockOrders_Expecter {
+	return &MockOrders_Expecter{mock: &_m.Mock}
+}
+
+// AnonymizeByCustomer provides a mock function for the type MockOrders
+func (_mock *MockOrders) AnonymizeByCustomer(context1 context.Context, n int64) ([]string, error) {
+	ret := _mock.Called(context1, n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AnonymizeByCustomer")
+	}
+
+	var r0 []string
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64) ([]string, error)); ok {
+		return returnFunc(context1, n)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64) []string); ok {
+		r0 = returnFunc(context1, n)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = returnFunc(context1, n)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockOrders_AnonymizeByCustomer_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'AnonymizeByCustomer'
+type MockOrders_AnonymizeByCustomer_Call struct {
+	*mock.Call
+}
+
+// AnonymizeByCustomer is a helper method to define mock.On call
+//   - context1 context.Context
+//   - n int64
+func (_e *MockOrders_Expecter) AnonymizeByCustomer(context1 interface{}, n interface{}) *MockOrders_AnonymizeByCustomer_Call {
+	return &MockOrders_AnonymizeByCustomer_Call{Call: _e.mock.On("AnonymizeByCustomer", context1, n)}
+}
+
+func (_c *MockOrders_AnonymizeByCustomer_Call) Run(run func(context1 context.Context, n int64)) *MockOrders_AnonymizeByCustomer_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 int64
+		if args[1] != nil {
+			arg1 = args[1].(int64)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockOrders_AnonymizeByCustomer_Call) Return(strings []string, err error) *MockOrders_AnonymizeByCustomer_Call {
+	_c.Call.Return(strings, err)
+	return _c
+}
+
+func (_c *MockOrders_AnonymizeByCustomer_Call) RunAndReturn(run func(context1 context.Context, n int64) ([]string, error)) *MockOrders_AnonymizeByCustomer_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Create provides a mock function for the type MockOrders
+func (_mock *MockOrders) Create(ctx context.Context, order *storage.Order) error {
+	ret := _mock.Called(ctx, order)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Create")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *storage.Order) error); ok {
+		r0 = returnFunc(ctx, order)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockOrders_Create_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Create'
+type MockOrders_Create_Call struct {
+	*mock.Call
+}
+
+// Create is a helper method to define mock.On call
+//   - ctx context.Context
+//   - order *storage.Order
+func (_e *MockOrders_Expecter) Create(ctx interface{}, order interface{}) *MockOrders_Create_Call {
+	return &MockOrders_Create_Call{Call: _e.mock.On("Create", ctx, order)}
+}
+
+func (_c *MockOrders_Create_Call) Run(run func(ctx context.Context, order *storage.Order)) *MockOrders_Create_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 *storage.Order
+		if args[1] != nil {
+			arg1 = args[1].(*storage.Order)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockOrders_Create_Call) Return(err error) *MockOrders_Create_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockOrders_Create_Call) RunAndReturn(run func(ctx context.Context, order *storage.Order) error) *MockOrders_Create_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CreateBatch provides a mock function for the type MockOrders
+func (_mock *MockOrders) CreateBatch(ctx context.Context, orders []*storage.Order) error {
+	ret := _mock.Called(ctx, orders)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateBatch")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []*storage.Order) error); ok {
+		r0 = returnFunc(ctx, orders)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockOrders_CreateBatch_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateBatch'
+type MockOrders_CreateBatch_Call struct {
+	*mock.Call
+}
+
+// CreateBatch is a helper method to define mock.On call
+//   - ctx context.Context
+//   - orders []*storage.Order
+func (_e *MockOrders_Expecter) CreateBatch(ctx interface{}, orders interface{}) *MockOrders_CreateBatch_Call {
+	return &MockOrders_CreateBatch_Call{Call: _e.mock.On("CreateBatch", ctx, orders)}
+}
+
+func (_c *MockOrders_CreateBatch_Call) Run(run func(ctx context.Context, orders []*storage.Order)) *MockOrders_CreateBatch_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 []*storage.Order
+		if args[1] != nil {
+			arg1 = args[1].([]*storage.Order)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockOrders_CreateBatch_Call) Return(err error) *MockOrders_CreateBatch_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockOrders_CreateBatch_Call) RunAndReturn(run func(ctx context.Context, orders []*storage.Order) error) *MockOrders_CreateBatch_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// FindUIDsByDeliveryEmail provides a mock function for the type MockOrders
+func (_mock *MockOrders) FindUIDsByDeliveryEmail(context1 context.Context, s string) ([]string, error) {
+	ret := _mock.Called(context1, s)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindUIDsByDeliveryEmail")
+	}
+
+	var r0 []string
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) ([]string, error)); ok {
+		return returnFunc(context1, s)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) []string); ok {
+		r0 = returnFunc(context1, s)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(context1, s)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockOrders_FindUIDsByDeliveryEmail_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'FindUIDsByDeliveryEmail'
+type MockOrders_FindUIDsByDeliveryEmail_Call struct {
+	*mock.Call
+}
+
+// FindUIDsByDeliveryEmail is a helper method to define mock.On call
+//   - context1 context.Context
+//   - s string
+func (_e *MockOrders_Expecter) FindUIDsByDeliveryEmail(context1 interface{}, s interface{}) *MockOrders_FindUIDsByDeliveryEmail_Call {
+	return &MockOrders_FindUIDsByDeliveryEmail_Call{Call: _e.mock.On("FindUIDsByDeliveryEmail", context1, s)}
+}
+
+func (_c *MockOrders_FindUIDsByDeliveryEmail_Call) Run(run func(context1 context.Context, s string)) *MockOrders_FindUIDsByDeliveryEmail_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockOrders_FindUIDsByDeliveryEmail_Call) Return(strings []string, err error) *MockOrders_FindUIDsByDeliveryEmail_Call {
+	_c.Call.Return(strings, err)
+	return _c
+}
+
+func (_c *MockOrders_FindUIDsByDeliveryEmail_Call) RunAndReturn(run func(context1 context.Context, s string) ([]string, error)) *MockOrders_FindUIDsByDeliveryEmail_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetByID provides a mock function for the type MockOrders
+func (_mock *MockOrders) GetByID(context1 context.Context, s string, n int64) (*storage.Order, error) {
+	ret := _mock.Called(context1, s, n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByID")
+	}
+
+	var r0 *storage.Order
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, int64) (*storage.Order, error)); ok {
+		return returnFunc(context1, s, n)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, int64) *storage.Order); ok {
+		r0 = returnFunc(context1, s, n)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storage.Order)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, int64) error); ok {
+		r1 = returnFunc(context1, s, n)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockOrders_GetByID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetByID'
+type MockOrders_GetByID_Call struct {
+	*mock.Call
+}
+
+// GetByID is a helper method to define mock.On call
+//   - context1 context.Context
+//   - s string
+//   - n int64
+func (_e *MockOrders_Expecter) GetByID(context1 interface{}, s interface{}, n interface{}) *MockOrders_GetByID_Call {
+	return &MockOrders_GetByID_Call{Call: _e.mock.On("GetByID", context1, s, n)}
+}
+
+func (_c *MockOrders_GetByID_Call) Run(run func(context1 context.Context, s string, n int64)) *MockOrders_GetByID_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 int64
+		if args[2] != nil {
+			arg2 = args[2].(int64)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockOrders_GetByID_Call) Return(order *storage.Order, err error) *MockOrders_GetByID_Call {
+	_c.Call.Return(order, err)
+	return _c
+}
+
+func (_c *MockOrders_GetByID_Call) RunAndReturn(run func(context1 context.Context, s string, n int64) (*storage.Order, error)) *MockOrders_GetByID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RotateDeliveryKeys provides a mock function for the type MockOrders
+func (_mock *MockOrders) RotateDeliveryKeys(context1 context.Context, n int) (int, error) {
+	ret := _mock.Called(context1, n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RotateDeliveryKeys")
+	}
+
+	var r0 int
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int) (int, error)); ok {
+		return returnFunc(context1, n)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int) int); ok {
+		r0 = returnFunc(context1, n)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = returnFunc(context1, n)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockOrders_RotateDeliveryKeys_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RotateDeliveryKeys'
+type MockOrders_RotateDeliveryKeys_Call struct {
+	*mock.Call
+}
+
+// RotateDeliveryKeys is a helper method to define mock.On call
+//   - context1 context.Context
+//   - n int
+func (_e *MockOrders_Expecter) RotateDeliveryKeys(context1 interface{}, n interface{}) *MockOrders_RotateDeliveryKeys_Call {
+	return &MockOrders_RotateDeliveryKeys_Call{Call: _e.mock.On("RotateDeliveryKeys", context1, n)}
+}
+
+func (_c *MockOrders_RotateDeliveryKeys_Call) Run(run func(context1 context.Context, n int)) *MockOrders_RotateDeliveryKeys_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 int
+		if args[1] != nil {
+			arg1 = args[1].(int)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockOrders_RotateDeliveryKeys_Call) Return(n1 int, err error) *MockOrders_RotateDeliveryKeys_Call {
+	_c.Call.Return(n1, err)
+	return _c
+}
+
+func (_c *MockOrders_RotateDeliveryKeys_Call) RunAndReturn(run func(context1 context.Context, n int) (int, error)) *MockOrders_RotateDeliveryKeys_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockTokens creates a new instance of MockTokens. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockTokens(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockTokens {
+	mock := &MockTokens{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// MockTokens is an autogenerated mock type for the Tokens type
+type MockTokens struct {
+	mock.Mock
+}
+
+type MockTokens_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockTokens) EXPECT() *MockTokens_Expecter {
+	return &MockTokens_Expecter{mock: &_m.Mock}
+}
+
+// Create provides a mock function for the type MockTokens
+func (_mock *MockTokens) Create(context1 context.Context, refreshToken *storage.RefreshToken) error {
+	ret := _mock.Called(context1, refreshToken)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Create")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *storage.RefreshToken) error); ok {
+		r0 = returnFunc(context1, refreshToken)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockTokens_Create_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Create'
+type MockTokens_Create_Call struct {
+	*mock.Call
+}
+
+// Create is a helper method to define mock.On call
+//   - context1 context.Context
+//   - refreshToken *storage.RefreshToken
+func (_e *MockTokens_Expecter) Create(context1 interface{}, refreshToken interface{}) *MockTokens_Create_Call {
+	return &MockTokens_Create_Call{Call: _e.mock.On("Create", context1, refreshToken)}
+}
+
+func (_c *MockTokens_Create_Call) Run(run func(context1 context.Context, refreshToken *storage.RefreshToken)) *MockTokens_Create_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 *storage.RefreshToken
+		if args[1] != nil {
+			arg1 = args[1].(*storage.RefreshToken)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockTokens_Create_Call) Return(err error) *MockTokens_Create_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockTokens_Create_Call) RunAndReturn(run func(context1 context.Context, refreshToken *storage.RefreshToken) error) *MockTokens_Create_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Delete provides a mock function for the type MockTokens
+func (_mock *MockTokens) Delete(context1 context.Context, s string) error {
+	ret := _mock.Called(context1, s)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Delete")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = returnFunc(context1, s)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockTokens_Delete_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Delete'
+type MockTokens_Delete_Call struct {
+	*mock.Call
+}
+
+// Delete is a helper method to define mock.On call
+//   - context1 context.Context
+//   - s string
+func (_e *MockTokens_Expecter) Delete(context1 interface{}, s interface{}) *MockTokens_Delete_Call {
+	return &MockTokens_Delete_Call{Call: _e.mock.On("Delete", context1, s)}
+}
+
+func (_c *MockTokens_Delete_Call) Run(run func(context1 context.Context, s string)) *MockTokens_Delete_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockTokens_Delete_Call) Return(err error) *MockTokens_Delete_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockTokens_Delete_Call) RunAndReturn(run func(context1 context.Context, s string) error) *MockTokens_Delete_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeleteByUserID provides a mock function for the type MockTokens
+func (_mock *MockTokens) DeleteByUserID(context1 context.Context, n int64) error {
+	ret := _mock.Called(context1, n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteByUserID")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = returnFunc(context1, n)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockTokens_DeleteByUserID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteByUserID'
+type MockTokens_DeleteByUserID_Call struct {
+	*mock.Call
+}
+
+// DeleteByUserID is a helper method to define mock.On call
+//   - context1 context.Context
+//   - n int64
+func (_e *MockTokens_Expecter) DeleteByUserID(context1 interface{}, n interface{}) *MockTokens_DeleteByUserID_Call {
+	return &MockTokens_DeleteByUserID_Call{Call: _e.mock.On("DeleteByUserID", context1, n)}
+}
+
+func (_c *MockTokens_DeleteByUserID_Call) Run(run func(context1 context.Context, n int64)) *MockTokens_DeleteByUserID_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 int64
+		if args[1] != nil {
+			arg1 = args[1].(int64)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockTokens_DeleteByUserID_Call) Return(err error) *MockTokens_DeleteByUserID_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockTokens_DeleteByUserID_Call) RunAndReturn(run func(context1 context.Context, n int64) error) *MockTokens_DeleteByUserID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DeleteByUserIDExcept provides a mock function for the type MockTokens
+func (_mock *MockTokens) DeleteByUserIDExcept(context1 context.Context, n int64, s string) error {
+	ret := _mock.Called(context1, n, s)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteByUserIDExcept")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64, string) error); ok {
+		r0 = returnFunc(context1, n, s)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockTokens_DeleteByUserIDExcept_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteByUserIDExcept'
+type MockTokens_DeleteByUserIDExcept_Call struct {
+	*mock.Call
+}
+
+// DeleteByUserIDExcept is a helper method to define mock.On call
+//   - context1 context.Context
+//   - n int64
+//   - s string
+func (_e *MockTokens_Expecter) DeleteByUserIDExcept(context1 interface{}, n interface{}, s interface{}) *MockTokens_DeleteByUserIDExcept_Call {
+	return &MockTokens_DeleteByUserIDExcept_Call{Call: _e.mock.On("DeleteByUserIDExcept", context1, n, s)}
+}
+
+func (_c *MockTokens_DeleteByUserIDExcept_Call) Run(run func(context1 context.Context, n int64, s string)) *MockTokens_DeleteByUserIDExcept_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 int64
+		if args[1] != nil {
+			arg1 = args[1].(int64)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockTokens_DeleteByUserIDExcept_Call) Return(err error) *MockTokens_DeleteByUserIDExcept_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockTokens_DeleteByUserIDExcept_Call) RunAndReturn(run func(context1 context.Context, n int64, s string) error) *MockTokens_DeleteByUserIDExcept_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetByToken provides a mock function for the type MockTokens
+func (_mock *MockTokens) GetByToken(context1 context.Context, s string) (*storage.RefreshToken, error) {
+	ret := _mock.Called(context1, s)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByToken")
+	}
+
+	var r0 *storage.RefreshToken
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (*storage.RefreshToken, error)); ok {
+		return returnFunc(context1, s)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) *storage.RefreshToken); ok {
+		r0 = returnFunc(context1, s)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storage.RefreshToken)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(context1, s)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockTokens_GetByToken_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetByToken'
+type MockTokens_GetByToken_Call struct {
+	*mock.Call
+}
+
+// GetByToken is a helper method to define mock.On call
+//   - context1 context.Context
+//   - s string
+func (_e *MockTokens_Expecter) GetByToken(context1 interface{}, s interface{}) *MockTokens_GetByToken_Call {
+	return &MockTokens_GetByToken_Call{Call: _e.mock.On("GetByToken", context1, s)}
+}
+
+func (_c *MockTokens_GetByToken_Call) Run(run func(context1 context.Context, s string)) *MockTokens_GetByToken_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockTokens_GetByToken_Call) Return(refreshToken *storage.RefreshToken, err error) *MockTokens_GetByToken_Call {
+	_c.Call.Return(refreshToken, err)
+	return _c
+}
+
+func (_c *MockTokens_GetByToken_Call) RunAndReturn(run func(context1 context.Context, s string) (*storage.RefreshToken, error)) *MockTokens_GetByToken_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockAPIKeys creates a new instance of MockAPIKeys. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockAPIKeys(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockAPIKeys {
+	mock := &MockAPIKeys{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// MockAPIKeys is an autogenerated mock type for the APIKeys type
+type MockAPIKeys struct {
+	mock.Mock
+}
+
+type MockAPIKeys_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockAPIKeys) EXPECT() *MockAPIKeys_Expecter {
+	return &MockAPIKeys_Expecter{mock: &_m.Mock}
+}
+
+// Create provides a mock function for the type MockAPIKeys
+func (_mock *MockAPIKeys) Create(context1 context.Context, aPIKey *storage.APIKey) error {
+	ret := _mock.Called(context1, aPIKey)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Create")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *storage.APIKey) error); ok {
+		r0 = returnFunc(context1, aPIKey)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockAPIKeys_Create_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Create'
+type MockAPIKeys_Create_Call struct {
+	*mock.Call
+}
+
+// Create is a helper method to define mock.On call
+//   - context1 context.Context
+//   - aPIKey *storage.APIKey
+func (_e *MockAPIKeys_Expecter) Create(context1 interface{}, aPIKey interface{}) *MockAPIKeys_Create_Call {
+	return &MockAPIKeys_Create_Call{Call: _e.mock.On("Create", context1, aPIKey)}
+}
+
+func (_c *MockAPIKeys_Create_Call) Run(run func(context1 context.Context, aPIKey *storage.APIKey)) *MockAPIKeys_Create_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 *storage.APIKey
+		if args[1] != nil {
+			arg1 = args[1].(*storage.APIKey)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPIKeys_Create_Call) Return(err error) *MockAPIKeys_Create_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockAPIKeys_Create_Call) RunAndReturn(run func(context1 context.Context, aPIKey *storage.APIKey) error) *MockAPIKeys_Create_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetByHash provides a mock function for the type MockAPIKeys
+func (_mock *MockAPIKeys) GetByHash(context1 context.Context, bytes []byte) (*storage.APIKey, error) {
+	ret := _mock.Called(context1, bytes)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByHash")
+	}
+
+	var r0 *storage.APIKey
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []byte) (*storage.APIKey, error)); ok {
+		return returnFunc(context1, bytes)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []byte) *storage.APIKey); ok {
+		r0 = returnFunc(context1, bytes)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storage.APIKey)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, []byte) error); ok {
+		r1 = returnFunc(context1, bytes)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPIKeys_GetByHash_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetByHash'
+type MockAPIKeys_GetByHash_Call struct {
+	*mock.Call
+}
+
+// GetByHash is a helper method to define mock.On call
+//   - context1 context.Context
+//   - bytes []byte
+func (_e *MockAPIKeys_Expecter) GetByHash(context1 interface{}, bytes interface{}) *MockAPIKeys_GetByHash_Call {
+	return &MockAPIKeys_GetByHash_Call{Call: _e.mock.On("GetByHash", context1, bytes)}
+}
+
+func (_c *MockAPIKeys_GetByHash_Call) Run(run func(context1 context.Context, bytes []byte)) *MockAPIKeys_GetByHash_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 []byte
+		if args[1] != nil {
+			arg1 = args[1].([]byte)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPIKeys_GetByHash_Call) Return(aPIKey *storage.APIKey, err error) *MockAPIKeys_GetByHash_Call {
+	_c.Call.Return(aPIKey, err)
+	return _c
+}
+
+func (_c *MockAPIKeys_GetByHash_Call) RunAndReturn(run func(context1 context.Context, bytes []byte) (*storage.APIKey, error)) *MockAPIKeys_GetByHash_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// List provides a mock function for the type MockAPIKeys
+func (_mock *MockAPIKeys) List(context1 context.Context) ([]storage.APIKey, error) {
+	ret := _mock.Called(context1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 []storage.APIKey
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) ([]storage.APIKey, error)); ok {
+		return returnFunc(context1)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context) []storage.APIKey); ok {
+		r0 = returnFunc(context1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.APIKey)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = returnFunc(context1)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockAPIKeys_List_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'List'
+type MockAPIKeys_List_Call struct {
+	*mock.Call
+}
+
+// List is a helper method to define mock.On call
+//   - context1 context.Context
+func (_e *MockAPIKeys_Expecter) List(context1 interface{}) *MockAPIKeys_List_Call {
+	return &MockAPIKeys_List_Call{Call: _e.mock.On("List", context1)}
+}
+
+func (_c *MockAPIKeys_List_Call) Run(run func(context1 context.Context)) *MockAPIKeys_List_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPIKeys_List_Call) Return(aPIKeys []storage.APIKey, err error) *MockAPIKeys_List_Call {
+	_c.Call.Return(aPIKeys, err)
+	return _c
+}
+
+func (_c *MockAPIKeys_List_Call) RunAndReturn(run func(context1 context.Context) ([]storage.APIKey, error)) *MockAPIKeys_List_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Revoke provides a mock function for the type MockAPIKeys
+func (_mock *MockAPIKeys) Revoke(context1 context.Context, n int64) error {
+	ret := _mock.Called(context1, n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Revoke")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = returnFunc(context1, n)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockAPIKeys_Revoke_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Revoke'
+type MockAPIKeys_Revoke_Call struct {
+	*mock.Call
+}
+
+// Revoke is a helper method to define mock.On call
+//   - context1 context.Context
+//   - n int64
+func (_e *MockAPIKeys_Expecter) Revoke(context1 interface{}, n interface{}) *MockAPIKeys_Revoke_Call {
+	return &MockAPIKeys_Revoke_Call{Call: _e.mock.On("Revoke", context1, n)}
+}
+
+func (_c *MockAPIKeys_Revoke_Call) Run(run func(context1 context.Context, n int64)) *MockAPIKeys_Revoke_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 int64
+		if args[1] != nil {
+			arg1 = args[1].(int64)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPIKeys_Revoke_Call) Return(err error) *MockAPIKeys_Revoke_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockAPIKeys_Revoke_Call) RunAndReturn(run func(context1 context.Context, n int64) error) *MockAPIKeys_Revoke_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Touch provides a mock function for the type MockAPIKeys
+func (_mock *MockAPIKeys) Touch(context1 context.Context, n int64) error {
+	ret := _mock.Called(context1, n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Touch")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = returnFunc(context1, n)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockAPIKeys_Touch_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Touch'
+type MockAPIKeys_Touch_Call struct {
+	*mock.Call
+}
+
+// Touch is a helper method to define mock.On call
+//   - context1 context.Context
+//   - n int64
+func (_e *MockAPIKeys_Expecter) Touch(context1 interface{}, n interface{}) *MockAPIKeys_Touch_Call {
+	return &MockAPIKeys_Touch_Call{Call: _e.mock.On("Touch", context1, n)}
+}
+
+func (_c *MockAPIKeys_Touch_Call) Run(run func(context1 context.Context, n int64)) *MockAPIKeys_Touch_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 int64
+		if args[1] != nil {
+			arg1 = args[1].(int64)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAPIKeys_Touch_Call) Return(err error) *MockAPIKeys_Touch_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockAPIKeys_Touch_Call) RunAndReturn(run func(context1 context.Context, n int64) error) *MockAPIKeys_Touch_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockActionTokens creates a new instance of MockActionTokens. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockActionTokens(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockActionTokens {
+	mock := &MockActionTokens{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// MockActionTokens is an autogenerated mock type for the ActionTokens type
+type MockActionTokens struct {
+	mock.Mock
+}
+
+type MockActionTokens_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockActionTokens) EXPECT() *MockActionTokens_Expecter {
+	return &MockActionTokens_Expecter{mock: &_m.Mock}
+}
+
+// Consume provides a mock function for the type MockActionTokens
+func (_mock *MockActionTokens) Consume(context1 context.Context, bytes []byte, s string) (*storage.ActionToken, error) {
+	ret := _mock.Called(context1, bytes, s)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Consume")
+	}
+
+	var r0 *storage.ActionToken
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []byte, string) (*storage.ActionToken, error)); ok {
+		return returnFunc(context1, bytes, s)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []byte, string) *storage.ActionToken); ok {
+		r0 = returnFunc(context1, bytes, s)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storage.ActionToken)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, []byte, string) error); ok {
+		r1 = returnFunc(context1, bytes, s)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockActionTokens_Consume_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Consume'
+type MockActionTokens_Consume_Call struct {
+	*mock.Call
+}
+
+// Consume is a helper method to define mock.On call
+//   - context1 context.Context
+//   - bytes []byte
+//   - s string
+func (_e *MockActionTokens_Expecter) Consume(context1 interface{}, bytes interface{}, s interface{}) *MockActionTokens_Consume_Call {
+	return &MockActionTokens_Consume_Call{Call: _e.mock.On("Consume", context1, bytes, s)}
+}
+
+func (_c *MockActionTokens_Consume_Call) Run(run func(context1 context.Context, bytes []byte, s string)) *MockActionTokens_Consume_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 []byte
+		if args[1] != nil {
+			arg1 = args[1].([]byte)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockActionTokens_Consume_Call) Return(actionToken *storage.ActionToken, err error) *MockActionTokens_Consume_Call {
+	_c.Call.Return(actionToken, err)
+	return _c
+}
+
+func (_c *MockActionTokens_Consume_Call) RunAndReturn(run func(context1 context.Context, bytes []byte, s string) (*storage.ActionToken, error)) *MockActionTokens_Consume_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Create provides a mock function for the type MockActionTokens
+func (_mock *MockActionTokens) Create(context1 context.Context, actionToken *storage.ActionToken) error {
+	ret := _mock.Called(context1, actionToken)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Create")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *storage.ActionToken) error); ok {
+		r0 = returnFunc(context1, actionToken)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockActionTokens_Create_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Create'
+type MockActionTokens_Create_Call struct {
+	*mock.Call
+}
+
+// Create is a helper method to define mock.On call
+//   - context1 context.Context
+//   - actionToken *storage.ActionToken
+func (_e *MockActionTokens_Expecter) Create(context1 interface{}, actionToken interface{}) *MockActionTokens_Create_Call {
+	return &MockActionTokens_Create_Call{Call: _e.mock.On("Create", context1, actionToken)}
+}
+
+func (_c *MockActionTokens_Create_Call) Run(run func(context1 context.Context, actionToken *storage.ActionToken)) *MockActionTokens_Create_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 *storage.ActionToken
+		if args[1] != nil {
+			arg1 = args[1].(*storage.ActionToken)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockActionTokens_Create_Call) Return(err error) *MockActionTokens_Create_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockActionTokens_Create_Call) RunAndReturn(run func(context1 context.Context, actionToken *storage.ActionToken) error) *MockActionTokens_Create_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RevokeByUser provides a mock function for the type MockActionTokens
+func (_mock *MockActionTokens) RevokeByUser(context1 context.Context, n int64, s string) error {
+	ret := _mock.Called(context1, n, s)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeByUser")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64, string) error); ok {
+		r0 = returnFunc(context1, n, s)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockActionTokens_RevokeByUser_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RevokeByUser'
+type MockActionTokens_RevokeByUser_Call struct {
+	*mock.Call
+}
+
+// RevokeByUser is a helper method to define mock.On call
+//   - context1 context.Context
+//   - n int64
+//   - s string
+func (_e *MockActionTokens_Expecter) RevokeByUser(context1 interface{}, n interface{}, s interface{}) *MockActionTokens_RevokeByUser_Call {
+	return &MockActionTokens_RevokeByUser_Call{Call: _e.mock.On("RevokeByUser", context1, n, s)}
+}
+
+func (_c *MockActionTokens_RevokeByUser_Call) Run(run func(context1 context.Context, n int64, s string)) *MockActionTokens_RevokeByUser_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 int64
+		if args[1] != nil {
+			arg1 = args[1].(int64)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockActionTokens_RevokeByUser_Call) Return(err error) *MockActionTokens_RevokeByUser_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockActionTokens_RevokeByUser_Call) RunAndReturn(run func(context1 context.Context, n int64, s string) error) *MockActionTokens_RevokeByUser_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockTOTP creates a new instance of MockTOTP. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockTOTP(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockTOTP {
+	mock := &MockTOTP{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// MockTOTP is an autogenerated mock type for the TOTP type
+type MockTOTP struct {
+	mock.Mock
+}
+
+type MockTOTP_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockTOTP) EXPECT() *MockTOTP_Expecter {
+	return &MockTOTP_Expecter{mock: &_m.Mock}
+}
+
+// Confirm provides a mock function for the type MockTOTP
+func (_mock *MockTOTP) Confirm(context1 context.Context, n int64, bytes [][]byte) error {
+	ret := _mock.Called(context1, n, bytes)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Confirm")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64, [][]byte) error); ok {
+		r0 = returnFunc(context1, n, bytes)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockTOTP_Confirm_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Confirm'
+type MockTOTP_Confirm_Call struct {
+	*mock.Call
+}
+
+// Confirm is a helper method to define mock.On call
+//   - context1 context.Context
+//   - n int64
+//   - bytes [][]byte
+func (_e *MockTOTP_Expecter) Confirm(context1 interface{}, n interface{}, bytes interface{}) *MockTOTP_Confirm_Call {
+	return &MockTOTP_Confirm_Call{Call: _e.mock.On("Confirm", context1, n, bytes)}
+}
+
+func (_c *MockTOTP_Confirm_Call) Run(run func(context1 context.Context, n int64, bytes [][]byte)) *MockTOTP_Confirm_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 int64
+		if args[1] != nil {
+			arg1 = args[1].(int64)
+		}
+		var arg2 [][]byte
+		if args[2] != nil {
+			arg2 = args[2].([][]byte)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockTOTP_Confirm_Call) Return(err error) *MockTOTP_Confirm_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockTOTP_Confirm_Call) RunAndReturn(run func(context1 context.Context, n int64, bytes [][]byte) error) *MockTOTP_Confirm_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Delete provides a mock function for the type MockTOTP
+func (_mock *MockTOTP) Delete(context1 context.Context, n int64) error {
+	ret := _mock.Called(context1, n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Delete")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = returnFunc(context1, n)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockTOTP_Delete_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Delete'
+type MockTOTP_Delete_Call struct {
+	*mock.Call
+}
+
+// Delete is a helper method to define mock.On call
+//   - context1 context.Context
+//   - n int64
+func (_e *MockTOTP_Expecter) Delete(context1 interface{}, n interface{}) *MockTOTP_Delete_Call {
+	return &MockTOTP_Delete_Call{Call: _e.mock.On("Delete", context1, n)}
+}
+
+func (_c *MockTOTP_Delete_Call) Run(run func(context1 context.Context, n int64)) *MockTOTP_Delete_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 int64
+		if args[1] != nil {
+			arg1 = args[1].(int64)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockTOTP_Delete_Call) Return(err error) *MockTOTP_Delete_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockTOTP_Delete_Call) RunAndReturn(run func(context1 context.Context, n int64) error) *MockTOTP_Delete_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Get provides a mock function for the type MockTOTP
+func (_mock *MockTOTP) Get(context1 context.Context, n int64) (*storage.TOTPCredential, error) {
+	ret := _mock.Called(context1, n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Get")
+	}
+
+	var r0 *storage.TOTPCredential
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64) (*storage.TOTPCredential, error)); ok {
+		return returnFunc(context1, n)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64) *storage.TOTPCredential); ok {
+		r0 = returnFunc(context1, n)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storage.TOTPCredential)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = returnFunc(context1, n)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockTOTP_Get_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Get'
+type MockTOTP_Get_Call struct {
+	*mock.Call
+}
+
+// Get is a helper method to define mock.On call
+//   - context1 context.Context
+//   - n int64
+func (_e *MockTOTP_Expecter) Get(context1 interface{}, n interface{}) *MockTOTP_Get_Call {
+	return &MockTOTP_Get_Call{Call: _e.mock.On("Get", context1, n)}
+}
+
+func (_c *MockTOTP_Get_Call) Run(run func(context1 context.Context, n int64)) *MockTOTP_Get_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 int64
+		if args[1] != nil {
+			arg1 = args[1].(int64)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockTOTP_Get_Call) Return(tOTPCredential *storage.TOTPCredential, err error) *MockTOTP_Get_Call {
+	_c.Call.Return(tOTPCredential, err)
+	return _c
+}
+
+func (_c *MockTOTP_Get_Call) RunAndReturn(run func(context1 context.Context, n int64) (*storage.TOTPCredential, error)) *MockTOTP_Get_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// MarkStepUsed provides a mock function for the type MockTOTP
+func (_mock *MockTOTP) MarkStepUsed(context1 context.Context, n int64, n1 int64) (bool, error) {
+	ret := _mock.Called(context1, n, n1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkStepUsed")
+	}
+
+	var r0 bool
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64, int64) (bool, error)); ok {
+		return returnFunc(context1, n, n1)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64, int64) bool); ok {
+		r0 = returnFunc(context1, n, n1)
+	} else {
+		r0 = ret.Get(0).(bool)
 	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = returnFunc(context1, s)
+	if returnFunc, ok := ret.Get(1).(func(context.Context, int64, int64) error); ok {
+		r1 = returnFunc(context1, n, n1)
 	} else {
 		r1 = ret.Error(1)
 	}
 	return r0, r1
 }
 
-// MockUsers_GetByEmail_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetByEmail'
-type MockUsers_GetByEmail_Call struct {
+// MockTOTP_MarkStepUsed_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MarkStepUsed'
+type MockTOTP_MarkStepUsed_Call struct {
 	*mock.Call
 }
 
-// GetByEmail is a helper method to define mock.On call
+// MarkStepUsed is a helper method to define mock.On call
 //   - context1 context.Context
-//   - s string
-func (_e *MockUsers_Expecter) GetByEmail(context1 interface{}, s interface{}) *MockUsers_GetByEmail_Call {
-	return &MockUsers_GetByEmail_Call{Call: _e.mock.On("GetByEmail", context1, s)}
+//   - n int64
+//   - n1 int64
+func (_e *MockTOTP_Expecter) MarkStepUsed(context1 interface{}, n interface{}, n1 interface{}) *MockTOTP_MarkStepUsed_Call {
+	return &MockTOTP_MarkStepUsed_Call{Call: _e.mock.On("MarkStepUsed", context1, n, n1)}
 }
 
-func (_c *MockUsers_GetByEmail_Call) Run(run func(context1 context.Context, s string)) *MockUsers_GetByEmail_Call {
+func (_c *MockTOTP_MarkStepUsed_Call) Run(run func(context1 context.Context, n int64, n1 int64)) *MockTOTP_MarkStepUsed_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
-		var arg1 string
+		var arg1 int64
 		if args[1] != nil {
-			arg1 = args[1].(string)
+			arg1 = args[1].(int64)
+		}
+		var arg2 int64
+		if args[2] != nil {
+			arg2 = args[2].(int64)
 		}
 		run(
 			arg0,
 			arg1,
+			arg2,
 		)
 	})
 	return _c
 }
 
-func (_c *MockUsers_GetByEmail_Call) Return(user *storage.User, err error) *MockUsers_GetByEmail_Call {
-	_c.Call.Return(user, err)
+func (_c *MockTOTP_MarkStepUsed_Call) Return(b bool, err error) *MockTOTP_MarkStepUsed_Call {
+	_c.Call.Return(b, err)
 	return _c
 }
 
-func (_c *MockUsers_GetByEmail_Call) RunAndReturn(run func(context1 context.Context, s string) (*storage.User, error)) *MockUsers_GetByEmail_Call {
+func (_c *MockTOTP_MarkStepUsed_Call) RunAndReturn(run func(context1 context.Context, n int64, n1 int64) (bool, error)) *MockTOTP_MarkStepUsed_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// NewMockOrders creates a new instance of MockOrders. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
-// The first argument is typically a *testing.T value.
-func NewMockOrders(t interface {
-	mock.TestingT
-	Cleanup(func())
-}) *MockOrders {
-	mock := &MockOrders{}
-	mock.Mock.Test(t)
-
-	t.Cleanup(func() { mock.AssertExpectations(t) })
-
-	return mock
-}
-
-// MockOrders is an autogenerated mock type for the Orders type
-type MockOrders struct {
-	mock.Mock
-}
-
-type MockOrders_Expecter struct {
-	mock *mock.Mock
-}
-
-func (_m *MockOrders) EXPECT() *MockOrders_Expecter {
-	return &MockOrders_Expecter{mock: &_m.Mock}
-}
-
-// Create provides a mock function for the type MockOrders
-func (_mock *MockOrders) Create(ctx context.Context, order *storage.Order) error {
-	ret := _mock.Called(ctx, order)
+// SavePending provides a mock function for the type MockTOTP
+func (_mock *MockTOTP) SavePending(context1 context.Context, n int64, bytes []byte) error {
+	ret := _mock.Called(context1, n, bytes)
 
 	if len(ret) == 0 {
-		panic("no return value specified for Create")
+		panic("no return value specified for SavePending")
 	}
 
 	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, *storage.Order) error); ok {
-		r0 = returnFunc(ctx, order)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64, []byte) error); ok {
+		r0 = returnFunc(context1, n, bytes)
 	} else {
 		r0 = ret.Error(0)
 	}
 	return r0
 }
 
-// MockOrders_Create_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Create'
-type MockOrders_Create_Call struct {
+// MockTOTP_SavePending_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SavePending'
+type MockTOTP_SavePending_Call struct {
 	*mock.Call
 }
 
-// Create is a helper method to define mock.On call
-//   - ctx context.Context
-//   - order *storage.Order
-func (_e *MockOrders_Expecter) Create(ctx interface{}, order interface{}) *MockOrders_Create_Call {
-	return &MockOrders_Create_Call{Call: _e.mock.On("Create", ctx, order)}
+// SavePending is a helper method to define mock.On call
+//   - context1 context.Context
+//   - n int64
+//   - bytes []byte
+func (_e *MockTOTP_Expecter) SavePending(context1 interface{}, n interface{}, bytes interface{}) *MockTOTP_SavePending_Call {
+	return &MockTOTP_SavePending_Call{Call: _e.mock.On("SavePending", context1, n, bytes)}
 }
 
-func (_c *MockOrders_Create_Call) Run(run func(ctx context.Context, order *storage.Order)) *MockOrders_Create_Call {
+func (_c *MockTOTP_SavePending_Call) Run(run func(context1 context.Context, n int64, bytes []byte)) *MockTOTP_SavePending_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
-		var arg1 *storage.Order
+		var arg1 int64
 		if args[1] != nil {
-			arg1 = args[1].(*storage.Order)
+			arg1 = args[1].(int64)
+		}
+		var arg2 []byte
+		if args[2] != nil {
+			arg2 = args[2].([]byte)
 		}
 		run(
 			arg0,
 			arg1,
+			arg2,
 		)
 	})
 	return _c
 }
 
-func (_c *MockOrders_Create_Call) Return(err error) *MockOrders_Create_Call {
+func (_c *MockTOTP_SavePending_Call) Return(err error) *MockTOTP_SavePending_Call {
 	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *MockOrders_Create_Call) RunAndReturn(run func(ctx context.Context, order *storage.Order) error) *MockOrders_Create_Call {
+func (_c *MockTOTP_SavePending_Call) RunAndReturn(run func(context1 context.Context, n int64, bytes []byte) error) *MockTOTP_SavePending_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// GetByID provides a mock function for the type MockOrders
-func (_mock *MockOrders) GetByID(context1 context.Context, s string, n int64) (*storage.Order, error) {
-	ret := _mock.Called(context1, s, n)
+// UseRecoveryCode provides a mock function for the type MockTOTP
+func (_mock *MockTOTP) UseRecoveryCode(context1 context.Context, n int64, bytes []byte) (bool, error) {
+	ret := _mock.Called(context1, n, bytes)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetByID")
+		panic("no return value specified for UseRecoveryCode")
 	}
 
-	var r0 *storage.Order
+	var r0 bool
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string, int64) (*storage.Order, error)); ok {
-		return returnFunc(context1, s, n)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64, []byte) (bool, error)); ok {
+		return returnFunc(context1, n, bytes)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string, int64) *storage.Order); ok {
-		r0 = returnFunc(context1, s, n)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, int64, []byte) bool); ok {
+		r0 = returnFunc(context1, n, bytes)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*storage.Order)
-		}
+		r0 = ret.Get(0).(bool)
 	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, string, int64) error); ok {
-		r1 = returnFunc(context1, s, n)
+	if returnFunc, ok := ret.Get(1).(func(context.Context, int64, []byte) error); ok {
+		r1 = returnFunc(context1, n, bytes)
 	} else {
 		r1 = ret.Error(1)
 	}
 	return r0, r1
 }
 
-// MockOrders_GetByID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetByID'
-type MockOrders_GetByID_Call struct {
+// MockTOTP_UseRecoveryCode_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UseRecoveryCode'
+type MockTOTP_UseRecoveryCode_Call struct {
 	*mock.Call
 }
 
-// GetByID is a helper method to define mock.On call
+// UseRecoveryCode is a helper method to define mock.On call
 //   - context1 context.Context
-//   - s string
 //   - n int64
-func (_e *MockOrders_Expecter) GetByID(context1 interface{}, s interface{}, n interface{}) *MockOrders_GetByID_Call {
-	return &MockOrders_GetByID_Call{Call: _e.mock.On("GetByID", context1, s, n)}
+//   - bytes []byte
+func (_e *MockTOTP_Expecter) UseRecoveryCode(context1 interface{}, n interface{}, bytes interface{}) *MockTOTP_UseRecoveryCode_Call {
+	return &MockTOTP_UseRecoveryCode_Call{Call: _e.mock.On("UseRecoveryCode", context1, n, bytes)}
 }
 
-func (_c *MockOrders_GetByID_Call) Run(run func(context1 context.Context, s string, n int64)) *MockOrders_GetByID_Call {
+func (_c *MockTOTP_UseRecoveryCode_Call) Run(run func(context1 context.Context, n int64, bytes []byte)) *MockTOTP_UseRecoveryCode_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
-		var arg1 string
+		var arg1 int64
 		if args[1] != nil {
-			arg1 = args[1].(string)
+			arg1 = args[1].(int64)
 		}
-		var arg2 int64
+		var arg2 []byte
 		if args[2] != nil {
-			arg2 = args[2].(int64)
+			arg2 = args[2].([]byte)
 		}
 		run(
 			arg0,
@@ -311,23 +2166,23 @@ func (_c *MockOrders_GetByID_Call) Run(run func(context1 context.Context, s stri
 	return _c
 }
 
-func (_c *MockOrders_GetByID_Call) Return(order *storage.Order, err error) *MockOrders_GetByID_Call {
-	_c.Call.Return(order, err)
+func (_c *MockTOTP_UseRecoveryCode_Call) Return(b bool, err error) *MockTOTP_UseRecoveryCode_Call {
+	_c.Call.Return(b, err)
 	return _c
 }
 
-func (_c *MockOrders_GetByID_Call) RunAndReturn(run func(context1 context.Context, s string, n int64) (*storage.Order, error)) *MockOrders_GetByID_Call {
+func (_c *MockTOTP_UseRecoveryCode_Call) RunAndReturn(run func(context1 context.Context, n int64, bytes []byte) (bool, error)) *MockTOTP_UseRecoveryCode_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// NewMockTokens creates a new instance of MockTokens. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// NewMockIdentities creates a new instance of MockIdentities. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
-func NewMockTokens(t interface {
+func NewMockIdentities(t interface {
 	mock.TestingT
 	Cleanup(func())
-}) *MockTokens {
-	mock := &MockTokens{}
+}) *MockIdentities {
+	mock := &MockIdentities{}
 	mock.Mock.Test(t)
 
 	t.Cleanup(func() { mock.AssertExpectations(t) })
@@ -335,114 +2190,226 @@ func NewMockTokens(t interface {
 	return mock
 }
 
-// MockTokens is an autogenerated mock type for the Tokens type
-type MockTokens struct {
+// MockIdentities is an autogenerated mock type for the Identities type
+type MockIdentities struct {
 	mock.Mock
 }
 
-type MockTokens_Expecter struct {
+type MockIdentities_Expecter struct {
 	mock *mock.Mock
 }
 
-func (_m *MockTokens) EXPECT() *MockTokens_Expecter {
-	return &MockTokens_Expecter{mock: &_m.Mock}
+func (_m *MockIdentities) EXPECT() *MockIdentities_Expecter {
+	return &MockIdentities_Expecter{mock: &_m.Mock}
 }
 
-// Create provides a mock function for the type MockTokens
-func (_mock *MockTokens) Create(context1 context.Context, refreshToken *storage.RefreshToken) error {
-	ret := _mock.Called(context1, refreshToken)
+// Deprovision provides a mock function for the type MockIdentities
+func (_mock *MockIdentities) Deprovision(context1 context.Context, s string, s1 string) (*storage.User, error) {
+	ret := _mock.Called(context1, s, s1)
 
 	if len(ret) == 0 {
-		panic("no return value specified for Create")
+		panic("no return value specified for Deprovision")
 	}
 
-	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, *storage.RefreshToken) error); ok {
-		r0 = returnFunc(context1, refreshToken)
+	var r0 *storage.User
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) (*storage.User, error)); ok {
+		return returnFunc(context1, s, s1)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) *storage.User); ok {
+		r0 = returnFunc(context1, s, s1)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storage.User)
+		}
 	}
-	return r0
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = returnFunc(context1, s, s1)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
 }
 
-// MockTokens_Create_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Create'
-type MockTokens_Create_Call struct {
+// MockIdentities_Deprovision_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Deprovision'
+type MockIdentities_Deprovision_Call struct {
 	*mock.Call
 }
 
-// Create is a helper method to define mock.On call
+// Deprovision is a helper method to define mock.On call
 //   - context1 context.Context
-//   - refreshToken *storage.RefreshToken
-func (_e *MockTokens_Expecter) Create(context1 interface{}, refreshToken interface{}) *MockTokens_Create_Call {
-	return &MockTokens_Create_Call{Call: _e.mock.On("Create", context1, refreshToken)}
+//   - s string
+//   - s1 string
+func (_e *MockIdentities_Expecter) Deprovision(context1 interface{}, s interface{}, s1 interface{}) *MockIdentities_Deprovision_Call {
+	return &MockIdentities_Deprovision_Call{Call: _e.mock.On("Deprovision", context1, s, s1)}
 }
 
-func (_c *MockTokens_Create_Call) Run(run func(context1 context.Context, refreshToken *storage.RefreshToken)) *MockTokens_Create_Call {
+func (_c *MockIdentities_Deprovision_Call) Run(run func(context1 context.Context, s string, s1 string)) *MockIdentities_Deprovision_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
-		var arg1 *storage.RefreshToken
+		var arg1 string
 		if args[1] != nil {
-			arg1 = args[1].(*storage.RefreshToken)
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
 		}
 		run(
 			arg0,
 			arg1,
+			arg2,
 		)
 	})
 	return _c
 }
 
-func (_c *MockTokens_Create_Call) Return(err error) *MockTokens_Create_Call {
-	_c.Call.Return(err)
+func (_c *MockIdentities_Deprovision_Call) Return(user *storage.User, err error) *MockIdentities_Deprovision_Call {
+	_c.Call.Return(user, err)
 	return _c
 }
 
-func (_c *MockTokens_Create_Call) RunAndReturn(run func(context1 context.Context, refreshToken *storage.RefreshToken) error) *MockTokens_Create_Call {
+func (_c *MockIdentities_Deprovision_Call) RunAndReturn(run func(context1 context.Context, s string, s1 string) (*storage.User, error)) *MockIdentities_Deprovision_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// Delete provides a mock function for the type MockTokens
-func (_mock *MockTokens) Delete(context1 context.Context, s string) error {
-	ret := _mock.Called(context1, s)
+// FindOrCreateUser provides a mock function for the type MockIdentities
+func (_mock *MockIdentities) FindOrCreateUser(context1 context.Context, externalIdentity *storage.ExternalIdentity) (*storage.User, error) {
+	ret := _mock.Called(context1, externalIdentity)
 
 	if len(ret) == 0 {
-		panic("no return value specified for Delete")
+		panic("no return value specified for FindOrCreateUser")
+	}
+
+	var r0 *storage.User
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *storage.ExternalIdentity) (*storage.User, error)); ok {
+		return returnFunc(context1, externalIdentity)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *storage.ExternalIdentity) *storage.User); ok {
+		r0 = returnFunc(context1, externalIdentity)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storage.User)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, *storage.ExternalIdentity) error); ok {
+		r1 = returnFunc(context1, externalIdentity)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockIdentities_FindOrCreateUser_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'FindOrCreateUser'
+type MockIdentities_FindOrCreateUser_Call struct {
+	*mock.Call
+}
+
+// FindOrCreateUser is a helper method to define mock.On call
+//   - context1 context.Context
+//   - externalIdentity *storage.ExternalIdentity
+func (_e *MockIdentities_Expecter) FindOrCreateUser(context1 interface{}, externalIdentity interface{}) *MockIdentities_FindOrCreateUser_Call {
+	return &MockIdentities_FindOrCreateUser_Call{Call: _e.mock.On("FindOrCreateUser", context1, externalIdentity)}
+}
+
+func (_c *MockIdentities_FindOrCreateUser_Call) Run(run func(context1 context.Context, externalIdentity *storage.ExternalIdentity)) *MockIdentities_FindOrCreateUser_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 *storage.ExternalIdentity
+		if args[1] != nil {
+			arg1 = args[1].(*storage.ExternalIdentity)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockIdentities_FindOrCreateUser_Call) Return(user *storage.User, err error) *MockIdentities_FindOrCreateUser_Call {
+	_c.Call.Return(user, err)
+	return _c
+}
+
+func (_c *MockIdentities_FindOrCreateUser_Call) RunAndReturn(run func(context1 context.Context, externalIdentity *storage.ExternalIdentity) (*storage.User, error)) *MockIdentities_FindOrCreateUser_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockAuditEvents creates a new instance of MockAuditEvents. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockAuditEvents(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockAuditEvents {
+	mock := &MockAuditEvents{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// MockAuditEvents is an autogenerated mock type for the AuditEvents type
+type MockAuditEvents struct {
+	mock.Mock
+}
+
+type MockAuditEvents_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockAuditEvents) EXPECT() *MockAuditEvents_Expecter {
+	return &MockAuditEvents_Expecter{mock: &_m.Mock}
+}
+
+// Create provides a mock function for the type MockAuditEvents
+func (_mock *MockAuditEvents) Create(context1 context.Context, auditEvent *storage.AuditEvent) error {
+	ret := _mock.Called(context1, auditEvent)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Create")
 	}
 
 	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string) error); ok {
-		r0 = returnFunc(context1, s)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *storage.AuditEvent) error); ok {
+		r0 = returnFunc(context1, auditEvent)
 	} else {
 		r0 = ret.Error(0)
 	}
 	return r0
 }
 
-// MockTokens_Delete_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Delete'
-type MockTokens_Delete_Call struct {
+// MockAuditEvents_Create_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Create'
+type MockAuditEvents_Create_Call struct {
 	*mock.Call
 }
 
-// Delete is a helper method to define mock.On call
+// Create is a helper method to define mock.On call
 //   - context1 context.Context
-//   - s string
-func (_e *MockTokens_Expecter) Delete(context1 interface{}, s interface{}) *MockTokens_Delete_Call {
-	return &MockTokens_Delete_Call{Call: _e.mock.On("Delete", context1, s)}
+//   - auditEvent *storage.AuditEvent
+func (_e *MockAuditEvents_Expecter) Create(context1 interface{}, auditEvent interface{}) *MockAuditEvents_Create_Call {
+	return &MockAuditEvents_Create_Call{Call: _e.mock.On("Create", context1, auditEvent)}
 }
 
-func (_c *MockTokens_Delete_Call) Run(run func(context1 context.Context, s string)) *MockTokens_Delete_Call {
+func (_c *MockAuditEvents_Create_Call) Run(run func(context1 context.Context, auditEvent *storage.AuditEvent)) *MockAuditEvents_Create_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
-		var arg1 string
+		var arg1 *storage.AuditEvent
 		if args[1] != nil {
-			arg1 = args[1].(string)
+			arg1 = args[1].(*storage.AuditEvent)
 		}
 		run(
 			arg0,
@@ -452,65 +2419,65 @@ func (_c *MockTokens_Delete_Call) Run(run func(context1 context.Context, s strin
 	return _c
 }
 
-func (_c *MockTokens_Delete_Call) Return(err error) *MockTokens_Delete_Call {
+func (_c *MockAuditEvents_Create_Call) Return(err error) *MockAuditEvents_Create_Call {
 	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *MockTokens_Delete_Call) RunAndReturn(run func(context1 context.Context, s string) error) *MockTokens_Delete_Call {
+func (_c *MockAuditEvents_Create_Call) RunAndReturn(run func(context1 context.Context, auditEvent *storage.AuditEvent) error) *MockAuditEvents_Create_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// GetByToken provides a mock function for the type MockTokens
-func (_mock *MockTokens) GetByToken(context1 context.Context, s string) (*storage.RefreshToken, error) {
-	ret := _mock.Called(context1, s)
+// List provides a mock function for the type MockAuditEvents
+func (_mock *MockAuditEvents) List(context1 context.Context, auditFilter storage.AuditFilter) ([]storage.AuditEvent, error) {
+	ret := _mock.Called(context1, auditFilter)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetByToken")
+		panic("no return value specified for List")
 	}
 
-	var r0 *storage.RefreshToken
+	var r0 []storage.AuditEvent
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (*storage.RefreshToken, error)); ok {
-		return returnFunc(context1, s)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, storage.AuditFilter) ([]storage.AuditEvent, error)); ok {
+		return returnFunc(context1, auditFilter)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, string) *storage.RefreshToken); ok {
-		r0 = returnFunc(context1, s)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, storage.AuditFilter) []storage.AuditEvent); ok {
+		r0 = returnFunc(context1, auditFilter)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*storage.RefreshToken)
+			r0 = ret.Get(0).([]storage.AuditEvent)
 		}
 	}
-	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = returnFunc(context1, s)
+	if returnFunc, ok := ret.Get(1).(func(context.Context, storage.AuditFilter) error); ok {
+		r1 = returnFunc(context1, auditFilter)
 	} else {
 		r1 = ret.Error(1)
 	}
 	return r0, r1
 }
 
-// MockTokens_GetByToken_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetByToken'
-type MockTokens_GetByToken_Call struct {
+// MockAuditEvents_List_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'List'
+type MockAuditEvents_List_Call struct {
 	*mock.Call
 }
 
-// GetByToken is a helper method to define mock.On call
+// List is a helper method to define mock.On call
 //   - context1 context.Context
-//   - s string
-func (_e *MockTokens_Expecter) GetByToken(context1 interface{}, s interface{}) *MockTokens_GetByToken_Call {
-	return &MockTokens_GetByToken_Call{Call: _e.mock.On("GetByToken", context1, s)}
+//   - auditFilter storage.AuditFilter
+func (_e *MockAuditEvents_Expecter) List(context1 interface{}, auditFilter interface{}) *MockAuditEvents_List_Call {
+	return &MockAuditEvents_List_Call{Call: _e.mock.On("List", context1, auditFilter)}
 }
 
-func (_c *MockTokens_GetByToken_Call) Run(run func(context1 context.Context, s string)) *MockTokens_GetByToken_Call {
+func (_c *MockAuditEvents_List_Call) Run(run func(context1 context.Context, auditFilter storage.AuditFilter)) *MockAuditEvents_List_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 context.Context
 		if args[0] != nil {
 			arg0 = args[0].(context.Context)
 		}
-		var arg1 string
+		var arg1 storage.AuditFilter
 		if args[1] != nil {
-			arg1 = args[1].(string)
+			arg1 = args[1].(storage.AuditFilter)
 		}
 		run(
 			arg0,
@@ -520,12 +2487,12 @@ func (_c *MockTokens_GetByToken_Call) Run(run func(context1 context.Context, s s
 	return _c
 }
 
-func (_c *MockTokens_GetByToken_Call) Return(refreshToken *storage.RefreshToken, err error) *MockTokens_GetByToken_Call {
-	_c.Call.Return(refreshToken, err)
+func (_c *MockAuditEvents_List_Call) Return(auditEvents []storage.AuditEvent, err error) *MockAuditEvents_List_Call {
+	_c.Call.Return(auditEvents, err)
 	return _c
 }
 
-func (_c *MockTokens_GetByToken_Call) RunAndReturn(run func(context1 context.Context, s string) (*storage.RefreshToken, error)) *MockTokens_GetByToken_Call {
+func (_c *MockAuditEvents_List_Call) RunAndReturn(run func(context1 context.Context, auditFilter storage.AuditFilter) ([]storage.AuditEvent, error)) *MockAuditEvents_List_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -2,9 +2,11 @@ package storage
 
 import (
 	"context"
-	"database/sql"
 	"errors"
-	"log"
+	"fmt"
+	"github.com/AlexShmak/order-service/internal/encryption"
+	"github.com/jackc/pgx/v5"
+	"strconv"
 	"time"
 )
 
@@ -25,6 +27,13 @@ type Order struct {
 	OofShard          string
 }
 
+// IsOwnedBy tells whether the order belongs to the customer. Orders read by ID
+// are filtered by the customer in the query; anything that bypasses it, like
+// the shared cache, has to check this instead.
+func (o *Order) IsOwnedBy(customerID int64) bool {
+	return o.CustomerID == strconv.FormatInt(customerID, 10)
+}
+
 type Delivery struct {
 	Name    string
 	Phone   string
@@ -62,139 +71,370 @@ type Item struct {
 	Status      int
 }
 
+// copyItemsThreshold is the number of items from which they are inserted with
+// COPY. Below it, queueing one INSERT per item in the same batch as the order
+// is cheaper, as COPY needs round trips of its own. BenchmarkOrdersCreate
+// compares both.
+var copyItemsThreshold = 50
+
+// orderUIDConstraint is the unique key of orders.order_uid. Only its violation
+// means the order is stored already, the track number is unique as well.
+const orderUIDConstraint = "orders_order_uid_key"
+
+var orderColumns = []string{"order_uid", "track_number", "entry", "delivery_data_id", "payment_data_id", "locale", "internal_signature", "customer_id", "delivery_service", "shardkey", "sm_id", "oof_shard"}
+
+var itemColumns = []string{"order_uid", "chrt_id", "track_number", "price", "rid", "name", "sale", "size", "total_price", "nm_id", "brand", "status"}
+
+// OrdersRepository encrypts the delivery PII on write and decrypts it on
+// read, callers only ever see plaintext.
 type OrdersRepository struct {
-	db *sql.DB
+	db      *TxManager
+	keyring *encryption.Keyring
+	hasher  *encryption.Hasher
 }
 
 func (r *OrdersRepository) GetByID(ctx context.Context, uid string, userID int64) (*Order, error) {
-	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	var found *Order
+	err := r.db.WithinTxOptions(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly}, func(ctx context.Context) error {
+		var order Order
+		var delivery Delivery
+		var stored storedDelivery
+		var payment Payment
+
+		orderQuery := `
+            SELECT o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature,
+                   o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
+                   d.name, d.phone, d.zip, d.city, d.address, d.region, d.email, d.data_key,
+                   d.name_encrypted, d.phone_encrypted, d.address_encrypted, d.email_encrypted,
+                   p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt,
+                   p.bank, p.delivery_cost, p.goods_total, p.custom_fee
+            FROM orders_service.orders o
+            JOIN orders_service.deliveries d ON o.delivery_data_id = d.id
+            JOIN orders_service.payments p ON o.payment_data_id = p.id
+            WHERE o.order_uid = $1 AND o.customer_id = $2`
+
+		err := r.db.QueryRow(ctx, orderQuery, uid, strconv.FormatInt(userID, 10)).Scan(
+			&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature,
+			&order.CustomerID, &order.DeliveryService, &order.ShardKey, &order.SmID, &order.DateCreated, &order.OofShard,
+			&stored.name, &stored.phone, &delivery.Zip, &delivery.City, &stored.address, &delivery.Region, &stored.email, &stored.dataKey,
+			&stored.nameEncrypted, &stored.phoneEncrypted, &stored.addressEncrypted, &stored.emailEncrypted,
+			&payment.Transaction, &payment.RequestID, &payment.Currency, &payment.Provider, &payment.Amount, &payment.PaymentDt,
+			&payment.Bank, &payment.DeliveryCost, &payment.GoodsTotal, &payment.CustomFee,
+		)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+		if err := r.decryptDelivery(order.OrderUID, &stored, &delivery); err != nil {
+			return err
+		}
+		order.Delivery = delivery
+		order.Payment = payment
+
+		itemsQuery := `SELECT chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status FROM orders_service.items WHERE order_uid = $1`
+		rows, err := r.db.Query(ctx, itemsQuery, uid)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var items []Item
+		for rows.Next() {
+			var item Item
+			if err := rows.Scan(&item.ChrtID, &item.TrackNumber, &item.Price, &item.RID, &item.Name, &item.Sale, &item.Size, &item.TotalPrice, &item.NMID, &item.Brand, &item.Status); err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		order.Items = items
+
+		found = &order
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return found, nil
+}
 
-	defer func() {
-		if p := recover(); p != nil {
-			_ = tx.Rollback()
-			panic(p)
-		} else if err != nil {
-			_ = tx.Rollback()
-		}
-	}()
-
-	var order Order
-	var delivery Delivery
-	var payment Payment
-
-	orderQuery := `
-        SELECT o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature,
-               o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
-               d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
-               p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt,
-               p.bank, p.delivery_cost, p.goods_total, p.custom_fee
-        FROM orders_service.orders o
-        JOIN orders_service.deliveries d ON o.delivery_data_id = d.id
-        JOIN orders_service.payments p ON o.payment_data_id = p.id
-        WHERE o.order_uid = $1 AND o.customer_id = $2`
-
-	err = tx.QueryRowContext(ctx, orderQuery, uid, userID).Scan(
-		&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature,
-		&order.CustomerID, &order.DeliveryService, &order.ShardKey, &order.SmID, &order.DateCreated, &order.OofShard,
-		&delivery.Name, &delivery.Phone, &delivery.Zip, &delivery.City, &delivery.Address, &delivery.Region, &delivery.Email,
-		&payment.Transaction, &payment.RequestID, &payment.Currency, &payment.Provider, &payment.Amount, &payment.PaymentDt,
-		&payment.Bank, &payment.DeliveryCost, &payment.GoodsTotal, &payment.CustomFee,
-	)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil
+func (r *OrdersRepository) Create(ctx context.Context, order *Order) error {
+	return r.db.WithinTx(ctx, func(ctx context.Context) error {
+		encrypted, err := r.encryptDelivery(order.OrderUID, &order.Delivery)
+		if err != nil {
+			return err
 		}
-		return nil, err
+
+		// the delivery and the payment do not depend on each other and share a round trip
+		var deliveryID, paymentID int64
+		batch := &pgx.Batch{}
+		deliveryQuery := `
+			INSERT INTO orders_service.deliveries (zip, city, region, key_id, data_key, name_encrypted, phone_encrypted, address_encrypted, email_encrypted, email_hash)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING id
+		`
+		batch.Queue(deliveryQuery, order.Delivery.Zip, order.Delivery.City, order.Delivery.Region, encrypted.keyID, encrypted.dataKey, encrypted.name, encrypted.phone, encrypted.address, encrypted.email, encrypted.emailHash).
+			QueryRow(func(row pgx.Row) error { return row.Scan(&deliveryID) })
+		paymentQuery := `INSERT INTO orders_service.payments ("transaction", request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
+		batch.Queue(paymentQuery, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency, order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDt, order.Payment.Bank, order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee).
+			QueryRow(func(row pgx.Row) error { return row.Scan(&paymentID) })
+		if err := r.db.SendBatch(ctx, batch).Close(); err != nil {
+			return err
+		}
+
+		orderQuery := `
+			INSERT INTO orders_service.orders (order_uid, track_number, entry, delivery_data_id, payment_data_id, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, oof_shard)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		`
+		batch = &pgx.Batch{}
+		batch.Queue(
+			orderQuery,
+			order.OrderUID,
+			order.TrackNumber,
+			order.Entry,
+			deliveryID,
+			paymentID,
+			order.Locale,
+			order.InternalSignature,
+			order.CustomerID,
+			order.DeliveryService,
+			order.ShardKey,
+			order.SmID,
+			order.OofShard,
+		)
+		copyItems := len(order.Items) >= copyItemsThreshold
+		if !copyItems {
+			itemQuery := `INSERT INTO orders_service.items (order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+			for _, item := range order.Items {
+				batch.Queue(itemQuery, order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name, item.Sale, item.Size, item.TotalPrice, item.NMID, item.Brand, item.Status)
+			}
+		}
+		if err := r.db.SendBatch(ctx, batch).Close(); err != nil {
+			if IsUniqueViolationOf(err, orderUIDConstraint) {
+				return ErrOrderExists
+			}
+			return err
+		}
+
+		if copyItems {
+			if _, err := r.db.CopyFrom(ctx, pgx.Identifier{"orders_service", "items"}, itemColumns, pgx.CopyFromSlice(len(order.Items), func(i int) ([]any, error) {
+				item := order.Items[i]
+				return []any{order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name, item.Sale, item.Size, item.TotalPrice, item.NMID, item.Brand, item.Status}, nil
+			})); err != nil {
+				return fmt.Errorf("could not copy order items: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// CreateBatch stores orders in one transaction, for the worker to keep up
+// under load. The deliveries and payments are inserted in a single batch for
+// their IDs, the orders and all their items with COPY. If one order is bad,
+// none is stored; callers insert them one by one then to find it.
+func (r *OrdersRepository) CreateBatch(ctx context.Context, orders []*Order) error {
+	if len(orders) == 0 {
+		return nil
 	}
-	order.Delivery = delivery
-	order.Payment = payment
 
-	itemsQuery := `SELECT chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status FROM orders_service.items WHERE order_uid = $1`
-	rows, err := tx.QueryContext(ctx, itemsQuery, uid)
+	return r.db.WithinTx(ctx, func(ctx context.Context) error {
+		deliveryIDs := make([]int64, len(orders))
+		paymentIDs := make([]int64, len(orders))
+		batch := &pgx.Batch{}
+		deliveryQuery := `
+			INSERT INTO orders_service.deliveries (zip, city, region, key_id, data_key, name_encrypted, phone_encrypted, address_encrypted, email_encrypted, email_hash)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING id
+		`
+		paymentQuery := `INSERT INTO orders_service.payments ("transaction", request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
+		for i, order := range orders {
+			encrypted, err := r.encryptDelivery(order.OrderUID, &order.Delivery)
+			if err != nil {
+				return err
+			}
+			batch.Queue(deliveryQuery, order.Delivery.Zip, order.Delivery.City, order.Delivery.Region, encrypted.keyID, encrypted.dataKey, encrypted.name, encrypted.phone, encrypted.address, encrypted.email, encrypted.emailHash).
+				QueryRow(func(row pgx.Row) error { return row.Scan(&deliveryIDs[i]) })
+			batch.Queue(paymentQuery, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency, order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDt, order.Payment.Bank, order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee).
+				QueryRow(func(row pgx.Row) error { return row.Scan(&paymentIDs[i]) })
+		}
+		if err := r.db.SendBatch(ctx, batch).Close(); err != nil {
+			return err
+		}
+
+		if _, err := r.db.CopyFrom(ctx, pgx.Identifier{"orders_service", "orders"}, orderColumns, pgx.CopyFromSlice(len(orders), func(i int) ([]any, error) {
+			order := orders[i]
+			return []any{order.OrderUID, order.TrackNumber, order.Entry, deliveryIDs[i], paymentIDs[i], order.Locale, order.InternalSignature, order.CustomerID, order.DeliveryService, order.ShardKey, order.SmID, order.OofShard}, nil
+		})); err != nil {
+			if IsUniqueViolationOf(err, orderUIDConstraint) {
+				return ErrOrderExists
+			}
+			return fmt.Errorf("could not copy orders: %w", err)
+		}
+
+		var items [][]any
+		for _, order := range orders {
+			for _, item := range order.Items {
+				items = append(items, []any{order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name, item.Sale, item.Size, item.TotalPrice, item.NMID, item.Brand, item.Status})
+			}
+		}
+		if len(items) > 0 {
+			if _, err := r.db.CopyFrom(ctx, pgx.Identifier{"orders_service", "items"}, itemColumns, pgx.CopyFromRows(items)); err != nil {
+				return fmt.Errorf("could not copy order items: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+const anonymizedValue = "[deleted]"
+
+// AnonymizeByCustomer wipes the delivery PII of every order of the customer
+// and returns the UIDs of the affected orders. Payments and items are kept,
+// because they are financial records.
+func (r *OrdersRepository) AnonymizeByCustomer(ctx context.Context, customerID int64) ([]string, error) {
+	query := `
+		UPDATE orders_service.deliveries d
+		SET name = $1, phone = $1, zip = $1, city = $1, address = $1, region = $1, email = $1,
+		    key_id = NULL, data_key = NULL, name_encrypted = NULL, phone_encrypted = NULL,
+		    address_encrypted = NULL, email_encrypted = NULL, email_hash = NULL
+		FROM orders_service.orders o
+		WHERE o.delivery_data_id = d.id AND o.customer_id = $2
+		RETURNING o.order_uid
+	`
+	rows, err := r.db.Query(ctx, query, anonymizedValue, strconv.FormatInt(customerID, 10))
 	if err != nil {
 		return nil, err
 	}
-	defer func(rows *sql.Rows) {
-		err := rows.Close()
-		if err != nil {
-			log.Printf("failed to close rows: %v", err)
-		}
-	}(rows)
+	defer rows.Close()
 
-	var items []Item
+	var uids []string
 	for rows.Next() {
-		var item Item
-		if err := rows.Scan(&item.ChrtID, &item.TrackNumber, &item.Price, &item.RID, &item.Name, &item.Sale, &item.Size, &item.TotalPrice, &item.NMID, &item.Brand, &item.Status); err != nil {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
 			return nil, err
 		}
-		items = append(items, item)
+		uids = append(uids, uid)
 	}
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
-	order.Items = items
-
-	return &order, tx.Commit()
+	return uids, rows.Err()
 }
 
-func (r *OrdersRepository) Create(ctx context.Context, order *Order) (err error) {
-	tx, err := r.db.BeginTx(ctx, nil)
+// FindUIDsByDeliveryEmail looks orders up by the email hash, as the email
+// itself is encrypted. Rows that have not been encrypted yet are matched by
+// their plaintext.
+func (r *OrdersRepository) FindUIDsByDeliveryEmail(ctx context.Context, email string) ([]string, error) {
+	query := `
+		SELECT o.order_uid
+		FROM orders_service.orders o
+		JOIN orders_service.deliveries d ON o.delivery_data_id = d.id
+		WHERE d.email_hash = $1 OR (d.data_key IS NULL AND LOWER(d.email) = $2)
+		ORDER BY o.date_created DESC
+	`
+	rows, err := r.db.Query(ctx, query, r.emailHash(email), NormalizeEmail(email))
 	if err != nil {
-		return err
-	}
-	defer func() {
-		if p := recover(); p != nil {
-			_ = tx.Rollback()
-			panic(p)
-		} else if err != nil {
-			_ = tx.Rollback()
-		}
-	}()
-
-	deliveryQuery := `INSERT INTO orders_service.deliveries (name, phone, zip, city, address, region, email) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
-	var deliveryID int64
-	if err = tx.QueryRowContext(ctx, deliveryQuery, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip, order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email).Scan(&deliveryID); err != nil {
-		return err
+		return nil, fmt.Errorf("could not find orders by email: %w", err)
 	}
+	defer rows.Close()
 
-	paymentQuery := `INSERT INTO orders_service.payments ("transaction", request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
-	var paymentID int64
-	if err = tx.QueryRowContext(ctx, paymentQuery, order.Payment.Transaction, order.Payment.RequestID, order.Payment.Currency, order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDt, order.Payment.Bank, order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee).Scan(&paymentID); err != nil {
-		return err
+	var uids []string
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			return nil, err
+		}
+		uids = append(uids, uid)
 	}
+	return uids, rows.Err()
+}
 
-	orderQuery := `
-		INSERT INTO orders_service.orders (order_uid, track_number, entry, delivery_data_id, payment_data_id, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, oof_shard)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-	`
-	if _, err = tx.ExecContext(
-		ctx,
-		orderQuery,
-		order.OrderUID,
-		order.TrackNumber,
-		order.Entry,
-		deliveryID,
-		paymentID,
-		order.Locale,
-		order.InternalSignature,
-		order.CustomerID,
-		order.DeliveryService,
-		order.ShardKey,
-		order.SmID,
-		order.OofShard,
-	); err != nil {
-		return err
-	}
+// RotateDeliveryKeys moves up to limit deliveries to the active key and
+// returns how many it changed, callers repeat it until that is 0. The data
+// keys wrapped by an older key are rewrapped, the encrypted values stay as
+// they are. Rows that are still in plaintext are encrypted.
+func (r *OrdersRepository) RotateDeliveryKeys(ctx context.Context, limit int) (int, error) {
+	var rotated int
+	err := r.db.WithinTx(ctx, func(ctx context.Context) error {
+		type pendingDelivery struct {
+			id       int64
+			orderUID string
+			stored   storedDelivery
+		}
 
-	itemQuery := `INSERT INTO orders_service.items (order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
-	for _, item := range order.Items {
-		if _, err = tx.ExecContext(ctx, itemQuery, order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name, item.Sale, item.Size, item.TotalPrice, item.NMID, item.Brand, item.Status); err != nil {
+		// the keys are bound to the order of the delivery
+		selectQuery := `
+			SELECT d.id, o.order_uid, d.data_key, d.name, d.phone, d.address, d.email
+			FROM orders_service.deliveries d
+			JOIN orders_service.orders o ON o.delivery_data_id = d.id
+			WHERE d.key_id <> $1 OR (d.data_key IS NULL AND d.name IS DISTINCT FROM $2)
+			ORDER BY d.id
+			LIMIT $3
+			FOR UPDATE OF d SKIP LOCKED
+		`
+		rows, err := r.db.Query(ctx, selectQuery, r.keyring.ActiveKeyID(), anonymizedValue, limit)
+		if err != nil {
+			return fmt.Errorf("could not select deliveries to rotate: %w", err)
+		}
+		var pending []pendingDelivery
+		for rows.Next() {
+			var p pendingDelivery
+			if err = rows.Scan(&p.id, &p.orderUID, &p.stored.dataKey, &p.stored.name, &p.stored.phone, &p.stored.address, &p.stored.email); err != nil {
+				rows.Close()
+				return err
+			}
+			pending = append(pending, p)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
 			return err
 		}
-	}
+		if len(pending) == 0 {
+			return nil
+		}
 
-	return tx.Commit()
+		rewrapQuery := `UPDATE orders_service.deliveries SET key_id = $1, data_key = $2 WHERE id = $3`
+		encryptQuery := `
+			UPDATE orders_service.deliveries
+			SET key_id = $1, data_key = $2, name_encrypted = $3, phone_encrypted = $4, address_encrypted = $5,
+			    email_encrypted = $6, email_hash = $7, name = NULL, phone = NULL, address = NULL, email = NULL
+			WHERE id = $8
+		`
+		batch := &pgx.Batch{}
+		for _, p := range pending {
+			if p.stored.dataKey != nil {
+				var wrapped []byte
+				if wrapped, err = r.keyring.RewrapDataKey(p.stored.dataKey, deliveryKeyAD(p.orderUID)); err != nil {
+					return fmt.Errorf("could not rewrap data key of delivery %d: %w", p.id, err)
+				}
+				batch.Queue(rewrapQuery, r.keyring.ActiveKeyID(), wrapped, p.id)
+				continue
+			}
+
+			var encrypted *encryptedDelivery
+			encrypted, err = r.encryptDelivery(p.orderUID, &Delivery{
+				Name:    p.stored.name.String,
+				Phone:   p.stored.phone.String,
+				Address: p.stored.address.String,
+				Email:   p.stored.email.String,
+			})
+			if err != nil {
+				return err
+			}
+			batch.Queue(encryptQuery, encrypted.keyID, encrypted.dataKey, encrypted.name, encrypted.phone, encrypted.address, encrypted.email, encrypted.emailHash, p.id)
+		}
+		if err = r.db.SendBatch(ctx, batch).Close(); err != nil {
+			return fmt.Errorf("could not update rotated deliveries: %w", err)
+		}
+
+		rotated = len(pending)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return rotated, nil
 }
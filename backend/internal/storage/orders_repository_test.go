@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/AlexShmak/order-service/internal/storage/pgtest"
+	"github.com/google/uuid"
+)
+
+// The benchmarks need a Postgres they may write to, see package pgtest, e.g.
+//
+//	go test -run '^$' -bench OrdersCreate ./internal/storage/
+func newBenchmarkRepository(b *testing.B) *OrdersRepository {
+	b.Helper()
+	pool, keyring, hasher := pgtest.Open(b)
+	return &OrdersRepository{db: NewTxManager(pool), keyring: keyring, hasher: hasher}
+}
+
+func benchmarkOrder(items int) *Order {
+	uid := uuid.NewString()
+	order := &Order{
+		OrderUID:    uid,
+		TrackNumber: uid,
+		Entry:       "WBIL",
+		Delivery: Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: Payment{
+			Transaction: uid,
+			Currency:    "USD",
+			Provider:    "wbpay",
+			Amount:      1817,
+			PaymentDt:   1637907727,
+			Bank:        "alpha",
+		},
+		Locale:          "en",
+		CustomerID:      "1",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SmID:            99,
+		OofShard:        "1",
+	}
+	for i := range items {
+		order.Items = append(order.Items, Item{
+			ChrtID:      9934930 + i,
+			TrackNumber: uid,
+			Price:       453,
+			RID:         uuid.NewString(),
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NMID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		})
+	}
+	return order
+}
+
+// BenchmarkOrdersCreate compares inserting the items in the batch with the
+// order against COPY, which is what copyItemsThreshold is tuned from.
+func BenchmarkOrdersCreate(b *testing.B) {
+	repo := newBenchmarkRepository(b)
+	strategies := []struct {
+		name      string
+		threshold int
+	}{
+		{"batch", math.MaxInt},
+		{"copy", 0},
+	}
+
+	defaultThreshold := copyItemsThreshold
+	b.Cleanup(func() { copyItemsThreshold = defaultThreshold })
+
+	for _, items := range []int{1, 10, 100} {
+		for _, strategy := range strategies {
+			b.Run(fmt.Sprintf("items=%d/%s", items, strategy.name), func(b *testing.B) {
+				copyItemsThreshold = strategy.threshold
+				orders := make([]*Order, b.N)
+				for i := range orders {
+					orders[i] = benchmarkOrder(items)
+				}
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if err := repo.Create(context.Background(), orders[i]); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.StopTimer()
+
+				b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "orders/s")
+				b.ReportMetric(float64(b.N*items)/b.Elapsed().Seconds(), "items/s")
+			})
+		}
+	}
+}
+
+// BenchmarkOrdersCreateBatch compares storing orders one per transaction, as
+// the worker does by default, against CreateBatch.
+func BenchmarkOrdersCreateBatch(b *testing.B) {
+	repo := newBenchmarkRepository(b)
+
+	for _, size := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("batch=%d", size), func(b *testing.B) {
+			orders := make([]*Order, b.N)
+			for i := range orders {
+				orders[i] = benchmarkOrder(3)
+			}
+
+			b.ResetTimer()
+			for start := 0; start < b.N; start += size {
+				batch := orders[start:min(start+size, b.N)]
+				var err error
+				if size == 1 {
+					err = repo.Create(context.Background(), batch[0])
+				} else {
+					err = repo.CreateBatch(context.Background(), batch)
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "orders/s")
+		})
+	}
+}
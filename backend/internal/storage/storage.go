@@ -2,33 +2,92 @@ package storage
 
 import (
 	"context"
-	"database/sql"
+	"github.com/AlexShmak/order-service/internal/encryption"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Users interface {
 	Create(context.Context, *User) error
 	GetByEmail(context.Context, string) (*User, error)
+	GetByID(context.Context, int64) (*User, error)
+	MarkEmailVerified(context.Context, int64) error
+	UpdatePassword(context.Context, *User) error
+	UpdateProfile(context.Context, *User) error
+	Delete(context.Context, int64) error
 }
 type Orders interface {
 	GetByID(context.Context, string, int64) (*Order, error)
 	Create(ctx context.Context, order *Order) error
+	CreateBatch(ctx context.Context, orders []*Order) error
+	AnonymizeByCustomer(context.Context, int64) ([]string, error)
+	FindUIDsByDeliveryEmail(context.Context, string) ([]string, error)
+	RotateDeliveryKeys(context.Context, int) (int, error)
 }
 type Tokens interface {
 	Create(context.Context, *RefreshToken) error
 	Delete(context.Context, string) error
 	GetByToken(context.Context, string) (*RefreshToken, error)
+	DeleteByUserID(context.Context, int64) error
+	DeleteByUserIDExcept(context.Context, int64, string) error
+}
+type APIKeys interface {
+	Create(context.Context, *APIKey) error
+	GetByHash(context.Context, []byte) (*APIKey, error)
+	List(context.Context) ([]APIKey, error)
+	Revoke(context.Context, int64) error
+	Touch(context.Context, int64) error
+}
+type ActionTokens interface {
+	Create(context.Context, *ActionToken) error
+	Consume(context.Context, []byte, string) (*ActionToken, error)
+	RevokeByUser(context.Context, int64, string) error
+}
+
+type TOTP interface {
+	SavePending(context.Context, int64, []byte) error
+	Get(context.Context, int64) (*TOTPCredential, error)
+	Confirm(context.Context, int64, [][]byte) error
+	MarkStepUsed(context.Context, int64, int64) (bool, error)
+	UseRecoveryCode(context.Context, int64, []byte) (bool, error)
+	Delete(context.Context, int64) error
+}
+
+type Identities interface {
+	FindOrCreateUser(context.Context, *ExternalIdentity) (*User, error)
+	Deprovision(context.Context, string, string) (*User, error)
+}
+
+type AuditEvents interface {
+	Create(context.Context, *AuditEvent) error
+	List(context.Context, AuditFilter) ([]AuditEvent, error)
 }
 
 type PostgresStorage struct {
-	Users  Users
-	Orders Orders
-	Tokens Tokens
+	Users        Users
+	Orders       Orders
+	Tokens       Tokens
+	APIKeys      APIKeys
+	ActionTokens ActionTokens
+	TOTP         TOTP
+	Identities   Identities
+	AuditEvents  AuditEvents
+	// Tx composes calls to several repositories into one transaction.
+	Tx Transactor
 }
 
-func NewPostgresStorage(db *sql.DB) *PostgresStorage {
+// NewPostgresStorage needs the PII keyring and the hasher for the email lookup
+// hash, because the orders repository encrypts delivery data.
+func NewPostgresStorage(pool *pgxpool.Pool, piiKeyring *encryption.Keyring, emailHasher *encryption.Hasher) *PostgresStorage {
+	db := NewTxManager(pool)
 	return &PostgresStorage{
-		Users:  &UsersRepository{db: db},
-		Orders: &OrdersRepository{db: db},
-		Tokens: &TokensRepository{db: db},
+		Users:        &UsersRepository{db: db},
+		Orders:       &OrdersRepository{db: db, keyring: piiKeyring, hasher: emailHasher},
+		Tokens:       &TokensRepository{db: db},
+		APIKeys:      &APIKeysRepository{db: db},
+		ActionTokens: &ActionTokensRepository{db: db},
+		TOTP:         &TOTPRepository{db: db},
+		Identities:   &IdentitiesRepository{db: db},
+		AuditEvents:  &AuditEventsRepository{db: db},
+		Tx:           db,
 	}
 }
@@ -0,0 +1,16 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/AlexShmak/order-service/internal/storage/pgtest"
+	"github.com/AlexShmak/order-service/internal/storage/storagetest"
+)
+
+// TestContract needs a Postgres it may write to, see package pgtest.
+func TestContract(t *testing.T) {
+	pool, keyring, hasher := pgtest.Open(t)
+	s := storage.NewPostgresStorage(pool, keyring, hasher)
+	storagetest.Run(t, func(*testing.T) *storage.PostgresStorage { return s })
+}
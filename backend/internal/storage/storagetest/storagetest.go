@@ -0,0 +1,435 @@
+// Package storagetest is the contract every implementation of the storage
+// interfaces has to fulfil. The tests only create rows with random keys, so
+// they can run against a database that is shared with other tests.
+package storagetest
+
+import (
+	"context"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlexShmak/order-service/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// OrderCache is implemented by cache.RedisOrders and cache.MemoryOrders.
+type OrderCache interface {
+	Get(context.Context, string) (*storage.Order, error)
+	Set(context.Context, *storage.Order) error
+	Delete(context.Context, ...string) error
+}
+
+// Run runs the contract of the users, orders and tokens repositories.
+func Run(t *testing.T, newStorage func(t *testing.T) *storage.PostgresStorage) {
+	t.Run("Users", func(t *testing.T) { testUsers(t, newStorage(t)) })
+	t.Run("Orders", func(t *testing.T) { testOrders(t, newStorage(t)) })
+	t.Run("Tokens", func(t *testing.T) { testTokens(t, newStorage(t)) })
+}
+
+func randomEmail() string {
+	return "user-" + uuid.NewString() + "@example.com"
+}
+
+func createUser(t *testing.T, s *storage.PostgresStorage) *storage.User {
+	t.Helper()
+	user := &storage.User{Name: "Test Testov", Email: randomEmail(), Password: "password"}
+	require.NoError(t, s.Users.Create(context.Background(), user))
+	return user
+}
+
+func testUsers(t *testing.T, s *storage.PostgresStorage) {
+	ctx := context.Background()
+
+	t.Run("Create", func(t *testing.T) {
+		user := createUser(t, s)
+		assert.NotZero(t, user.ID)
+		assert.Equal(t, storage.RoleCustomer, user.Role)
+		assert.WithinDuration(t, time.Now(), user.CreatedAt, time.Minute)
+
+		got, err := s.Users.GetByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, user.Email, got.Email)
+		assert.Empty(t, got.Password)
+		assert.True(t, got.CheckPasswordHash("password"))
+		assert.False(t, got.IsEmailVerified())
+	})
+
+	t.Run("EmailIsUniqueIgnoringCase", func(t *testing.T) {
+		user := createUser(t, s)
+		duplicate := &storage.User{Name: "Other", Email: strings.ToUpper(user.Email), Password: "password"}
+		assert.ErrorIs(t, s.Users.Create(ctx, duplicate), storage.ErrEmailTaken)
+	})
+
+	t.Run("GetByEmail", func(t *testing.T) {
+		user := createUser(t, s)
+		got, err := s.Users.GetByEmail(ctx, strings.ToUpper(user.Email))
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, got.ID)
+
+		_, err = s.Users.GetByEmail(ctx, randomEmail())
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("MarkEmailVerified", func(t *testing.T) {
+		user := createUser(t, s)
+		require.NoError(t, s.Users.MarkEmailVerified(ctx, user.ID))
+		first, err := s.Users.GetByID(ctx, user.ID)
+		require.NoError(t, err)
+		require.True(t, first.IsEmailVerified())
+
+		// verifying again keeps the original time
+		require.NoError(t, s.Users.MarkEmailVerified(ctx, user.ID))
+		second, err := s.Users.GetByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.True(t, first.EmailVerifiedAt.Equal(*second.EmailVerifiedAt))
+	})
+
+	t.Run("UpdatePassword", func(t *testing.T) {
+		user := createUser(t, s)
+		user.Password = "new password"
+		require.NoError(t, s.Users.UpdatePassword(ctx, user))
+
+		got, err := s.Users.GetByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.True(t, got.CheckPasswordHash("new password"))
+		assert.False(t, got.CheckPasswordHash("password"))
+		assert.Equal(t, user.Email, got.Email)
+	})
+
+	t.Run("UpdateProfile", func(t *testing.T) {
+		user := createUser(t, s)
+		require.NoError(t, s.Users.MarkEmailVerified(ctx, user.ID))
+
+		user.Name = "Renamed"
+		user.Email = strings.ToUpper(user.Email)
+		require.NoError(t, s.Users.UpdateProfile(ctx, user))
+		assert.True(t, user.IsEmailVerified(), "the same email in another case stays verified")
+
+		user.Email = randomEmail()
+		require.NoError(t, s.Users.UpdateProfile(ctx, user))
+		assert.False(t, user.IsEmailVerified(), "a new email has to be verified again")
+
+		got, err := s.Users.GetByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Renamed", got.Name)
+		assert.Equal(t, user.Email, got.Email)
+		assert.False(t, got.IsEmailVerified())
+
+		user.Email = createUser(t, s).Email
+		assert.ErrorIs(t, s.Users.UpdateProfile(ctx, user), storage.ErrEmailTaken)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		user := createUser(t, s)
+		token := &storage.RefreshToken{UserID: user.ID, Token: uuid.NewString(), ExpiresAt: time.Now().Add(time.Hour)}
+		require.NoError(t, s.Tokens.Create(ctx, token))
+
+		require.NoError(t, s.Users.Delete(ctx, user.ID))
+		_, err := s.Users.GetByID(ctx, user.ID)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+		_, err = s.Tokens.GetByToken(ctx, token.Token)
+		assert.ErrorIs(t, err, storage.ErrNotFound, "sessions are deleted with the user")
+	})
+}
+
+func newOrder(customerID int64, email string) *storage.Order {
+	uid := uuid.NewString()
+	// track numbers are unique as well
+	trackNumber := "WBIL" + strings.ToUpper(strings.ReplaceAll(uid, "-", ""))
+	return &storage.Order{
+		OrderUID:    uid,
+		TrackNumber: trackNumber,
+		Entry:       "WBIL",
+		Delivery: storage.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   email,
+		},
+		Payment: storage.Payment{
+			Transaction:  uid,
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []storage.Item{
+			{ChrtID: 9934930, TrackNumber: trackNumber, Price: 453, RID: uuid.NewString(), Name: "Mascaras", Sale: 30, Size: "0", TotalPrice: 317, NMID: 2389212, Brand: "Vivienne Sabo", Status: 202},
+			{ChrtID: 9934931, TrackNumber: trackNumber, Price: 100, RID: uuid.NewString(), Name: "Lipstick", Size: "0", TotalPrice: 100, NMID: 2389213, Brand: "Vivienne Sabo", Status: 202},
+		},
+		Locale:          "en",
+		CustomerID:      strconv.FormatInt(customerID, 10),
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SmID:            99,
+		OofShard:        "1",
+	}
+}
+
+// randomCustomerID keeps orders of concurrent runs apart, orders are not
+// linked to users by a foreign key.
+func randomCustomerID() int64 {
+	return rand.Int64N(1<<40) + 1<<40
+}
+
+func testOrders(t *testing.T, s *storage.PostgresStorage) {
+	ctx := context.Background()
+
+	t.Run("GetByIDFiltersByOwner", func(t *testing.T) {
+		customerID := randomCustomerID()
+		order := newOrder(customerID, randomEmail())
+		require.NoError(t, s.Orders.Create(ctx, order))
+
+		got, err := s.Orders.GetByID(ctx, order.OrderUID, customerID)
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.WithinDuration(t, time.Now(), got.DateCreated, time.Minute)
+		assert.ElementsMatch(t, order.Items, got.Items)
+		got.DateCreated, got.Items = order.DateCreated, order.Items
+		assert.Equal(t, order, got)
+
+		got, err = s.Orders.GetByID(ctx, order.OrderUID, customerID+1)
+		require.NoError(t, err)
+		assert.Nil(t, got, "another customer must not see the order")
+
+		got, err = s.Orders.GetByID(ctx, uuid.NewString(), customerID)
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("CreateRejectsDuplicates", func(t *testing.T) {
+		order := newOrder(randomCustomerID(), randomEmail())
+		require.NoError(t, s.Orders.Create(ctx, order))
+
+		duplicate := newOrder(randomCustomerID(), randomEmail())
+		duplicate.OrderUID = order.OrderUID
+		assert.ErrorIs(t, s.Orders.Create(ctx, duplicate), storage.ErrOrderExists)
+	})
+
+	t.Run("CreateRejectsTakenTrackNumber", func(t *testing.T) {
+		order := newOrder(randomCustomerID(), randomEmail())
+		require.NoError(t, s.Orders.Create(ctx, order))
+
+		// a different order, it must not pass for a redelivery of the first
+		other := newOrder(randomCustomerID(), randomEmail())
+		other.TrackNumber = order.TrackNumber
+		err := s.Orders.Create(ctx, other)
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, storage.ErrOrderExists)
+	})
+
+	t.Run("CreateBatch", func(t *testing.T) {
+		customerID := randomCustomerID()
+		orders := []*storage.Order{newOrder(customerID, randomEmail()), newOrder(customerID, randomEmail()), newOrder(customerID, randomEmail())}
+		orders[1].Items = nil
+		require.NoError(t, s.Orders.CreateBatch(ctx, orders))
+
+		for _, order := range orders {
+			got, err := s.Orders.GetByID(ctx, order.OrderUID, customerID)
+			require.NoError(t, err)
+			require.NotNil(t, got)
+			assert.Equal(t, order.Delivery, got.Delivery)
+			assert.ElementsMatch(t, order.Items, got.Items)
+		}
+	})
+
+	t.Run("CreateBatchIsAllOrNothing", func(t *testing.T) {
+		existing := newOrder(randomCustomerID(), randomEmail())
+		require.NoError(t, s.Orders.Create(ctx, existing))
+
+		customerID := randomCustomerID()
+		fresh := newOrder(customerID, randomEmail())
+		duplicate := newOrder(customerID, randomEmail())
+		duplicate.OrderUID = existing.OrderUID
+		assert.ErrorIs(t, s.Orders.CreateBatch(ctx, []*storage.Order{fresh, duplicate}), storage.ErrOrderExists)
+
+		got, err := s.Orders.GetByID(ctx, fresh.OrderUID, customerID)
+		require.NoError(t, err)
+		assert.Nil(t, got, "the batch is rolled back as a whole")
+	})
+
+	t.Run("CreateBatchRejectsTakenTrackNumber", func(t *testing.T) {
+		existing := newOrder(randomCustomerID(), randomEmail())
+		require.NoError(t, s.Orders.Create(ctx, existing))
+
+		customerID := randomCustomerID()
+		fresh := newOrder(customerID, randomEmail())
+		other := newOrder(customerID, randomEmail())
+		other.TrackNumber = existing.TrackNumber
+		err := s.Orders.CreateBatch(ctx, []*storage.Order{fresh, other})
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, storage.ErrOrderExists, "the worker would skip the batch as redelivered")
+
+		got, err := s.Orders.GetByID(ctx, fresh.OrderUID, customerID)
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("FindUIDsByDeliveryEmail", func(t *testing.T) {
+		email := randomEmail()
+		older := newOrder(randomCustomerID(), email)
+		require.NoError(t, s.Orders.Create(ctx, older))
+		time.Sleep(10 * time.Millisecond)
+		newer := newOrder(randomCustomerID(), strings.ToUpper(email))
+		require.NoError(t, s.Orders.Create(ctx, newer))
+		require.NoError(t, s.Orders.Create(ctx, newOrder(randomCustomerID(), randomEmail())))
+
+		uids, err := s.Orders.FindUIDsByDeliveryEmail(ctx, " "+strings.ToUpper(email))
+		require.NoError(t, err)
+		assert.Equal(t, []string{newer.OrderUID, older.OrderUID}, uids)
+	})
+
+	t.Run("AnonymizeByCustomer", func(t *testing.T) {
+		customerID := randomCustomerID()
+		first := newOrder(customerID, randomEmail())
+		second := newOrder(customerID, randomEmail())
+		other := newOrder(randomCustomerID(), randomEmail())
+		for _, order := range []*storage.Order{first, second, other} {
+			require.NoError(t, s.Orders.Create(ctx, order))
+		}
+
+		uids, err := s.Orders.AnonymizeByCustomer(ctx, customerID)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{first.OrderUID, second.OrderUID}, uids)
+
+		got, err := s.Orders.GetByID(ctx, first.OrderUID, customerID)
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.NotEqual(t, first.Delivery.Name, got.Delivery.Name)
+		assert.NotEqual(t, first.Delivery.Email, got.Delivery.Email)
+		assert.NotEqual(t, first.Delivery.Phone, got.Delivery.Phone)
+		assert.NotEqual(t, first.Delivery.Address, got.Delivery.Address)
+		assert.Equal(t, first.Payment, got.Payment, "payments are financial records and stay")
+		assert.Len(t, got.Items, len(first.Items))
+
+		got, err = s.Orders.GetByID(ctx, other.OrderUID, customerIDOf(other))
+		require.NoError(t, err)
+		assert.Equal(t, other.Delivery, got.Delivery)
+
+		uids, err = s.Orders.FindUIDsByDeliveryEmail(ctx, first.Delivery.Email)
+		require.NoError(t, err)
+		assert.Empty(t, uids, "the email is no longer searchable")
+	})
+}
+
+func customerIDOf(order *storage.Order) int64 {
+	id, _ := strconv.ParseInt(order.CustomerID, 10, 64)
+	return id
+}
+
+func testTokens(t *testing.T, s *storage.PostgresStorage) {
+	ctx := context.Background()
+
+	newToken := func(userID int64, expiresIn time.Duration) *storage.RefreshToken {
+		token := &storage.RefreshToken{UserID: userID, Token: uuid.NewString(), ExpiresAt: time.Now().Add(expiresIn)}
+		require.NoError(t, s.Tokens.Create(ctx, token))
+		return token
+	}
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		user := createUser(t, s)
+		token := newToken(user.ID, time.Hour)
+		assert.NotZero(t, token.ID)
+		assert.WithinDuration(t, time.Now(), token.CreatedAt, time.Minute)
+
+		got, err := s.Tokens.GetByToken(ctx, token.Token)
+		require.NoError(t, err)
+		assert.Equal(t, token.ID, got.ID)
+		assert.Equal(t, user.ID, got.UserID)
+		assert.WithinDuration(t, token.ExpiresAt, got.ExpiresAt, time.Millisecond)
+
+		_, err = s.Tokens.GetByToken(ctx, uuid.NewString())
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("ExpiredTokensAreNotFound", func(t *testing.T) {
+		token := newToken(createUser(t, s).ID, -time.Second)
+		_, err := s.Tokens.GetByToken(ctx, token.Token)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		token := newToken(createUser(t, s).ID, time.Hour)
+		require.NoError(t, s.Tokens.Delete(ctx, token.Token))
+		_, err := s.Tokens.GetByToken(ctx, token.Token)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("DeleteByUserID", func(t *testing.T) {
+		user := createUser(t, s)
+		first, second := newToken(user.ID, time.Hour), newToken(user.ID, time.Hour)
+		other := newToken(createUser(t, s).ID, time.Hour)
+
+		require.NoError(t, s.Tokens.DeleteByUserID(ctx, user.ID))
+		for _, token := range []*storage.RefreshToken{first, second} {
+			_, err := s.Tokens.GetByToken(ctx, token.Token)
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+		}
+		_, err := s.Tokens.GetByToken(ctx, other.Token)
+		assert.NoError(t, err, "sessions of other users stay")
+	})
+
+	t.Run("DeleteByUserIDExcept", func(t *testing.T) {
+		user := createUser(t, s)
+		current, stale := newToken(user.ID, time.Hour), newToken(user.ID, time.Hour)
+
+		require.NoError(t, s.Tokens.DeleteByUserIDExcept(ctx, user.ID, current.Token))
+		_, err := s.Tokens.GetByToken(ctx, current.Token)
+		assert.NoError(t, err)
+		_, err = s.Tokens.GetByToken(ctx, stale.Token)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+}
+
+// RunOrderCache runs the contract of the order cache.
+func RunOrderCache(t *testing.T, newCache func(t *testing.T) OrderCache) {
+	ctx := context.Background()
+	cache := newCache(t)
+
+	t.Run("Miss", func(t *testing.T) {
+		got, err := cache.Get(ctx, uuid.NewString())
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("SetAndGet", func(t *testing.T) {
+		order := newOrder(randomCustomerID(), randomEmail())
+		order.DateCreated = time.Now().UTC().Truncate(time.Microsecond)
+		require.NoError(t, cache.Set(ctx, order))
+
+		got, err := cache.Get(ctx, order.OrderUID)
+		require.NoError(t, err)
+		assert.Equal(t, order, got)
+
+		got.Delivery.Name = "changed"
+		again, err := cache.Get(ctx, order.OrderUID)
+		require.NoError(t, err)
+		assert.Equal(t, order.Delivery.Name, again.Delivery.Name, "the cached value is not shared")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		first := newOrder(randomCustomerID(), randomEmail())
+		second := newOrder(randomCustomerID(), randomEmail())
+		require.NoError(t, cache.Set(ctx, first))
+		require.NoError(t, cache.Set(ctx, second))
+
+		require.NoError(t, cache.Delete(ctx, first.OrderUID, second.OrderUID))
+		require.NoError(t, cache.Delete(ctx))
+		for _, uid := range []string{first.OrderUID, second.OrderUID} {
+			got, err := cache.Get(ctx, uid)
+			require.NoError(t, err)
+			assert.Nil(t, got)
+		}
+	})
+}
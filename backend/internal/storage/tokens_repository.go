@@ -2,9 +2,9 @@ package storage
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/jackc/pgx/v5"
 	"time"
 )
 
@@ -16,7 +16,7 @@ type RefreshToken struct {
 	CreatedAt time.Time
 }
 type TokensRepository struct {
-	db *sql.DB
+	db *TxManager
 }
 
 func (s *TokensRepository) Create(ctx context.Context, token *RefreshToken) error {
@@ -24,7 +24,7 @@ func (s *TokensRepository) Create(ctx context.Context, token *RefreshToken) erro
 		INSERT INTO orders_service.refresh_tokens (user_id, token, expires_at) 
 		VALUES ($1, $2, $3) RETURNING id, created_at
 	`
-	if err := s.db.QueryRowContext(ctx, query, token.UserID, token.Token, token.ExpiresAt).Scan(&token.ID, &token.CreatedAt); err != nil {
+	if err := s.db.QueryRow(ctx, query, token.UserID, token.Token, token.ExpiresAt).Scan(&token.ID, &token.CreatedAt); err != nil {
 		return fmt.Errorf("could not create refresh token: %w", err)
 	}
 	return nil
@@ -36,9 +36,9 @@ func (s *TokensRepository) GetByToken(ctx context.Context, tokenString string) (
 	query := `
 		SELECT * FROM orders_service.refresh_tokens WHERE token = $1 AND expires_at > NOW()
 	`
-	if err := s.db.QueryRowContext(ctx, query, tokenString).Scan(&token.ID, &token.UserID, &token.Token, &token.ExpiresAt, &token.CreatedAt); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("refresh token not found or expired")
+	if err := s.db.QueryRow(ctx, query, tokenString).Scan(&token.ID, &token.UserID, &token.Token, &token.ExpiresAt, &token.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("refresh token not found or expired: %w", ErrNotFound)
 		}
 		return nil, fmt.Errorf("could not get refresh token by token string: %w", err)
 	}
@@ -49,8 +49,29 @@ func (s *TokensRepository) Delete(ctx context.Context, tokenString string) error
 	query := `
 		DELETE FROM orders_service.refresh_tokens WHERE token = $1
 	`
-	if _, err := s.db.ExecContext(ctx, query, tokenString); err != nil {
+	if _, err := s.db.Exec(ctx, query, tokenString); err != nil {
 		return fmt.Errorf("could not delete refresh token: %w", err)
 	}
 	return nil
 }
+
+func (s *TokensRepository) DeleteByUserID(ctx context.Context, userID int64) error {
+	query := `
+		DELETE FROM orders_service.refresh_tokens WHERE user_id = $1
+	`
+	if _, err := s.db.Exec(ctx, query, userID); err != nil {
+		return fmt.Errorf("could not delete refresh tokens of user: %w", err)
+	}
+	return nil
+}
+
+// DeleteByUserIDExcept revokes every session of the user but the one with the given token.
+func (s *TokensRepository) DeleteByUserIDExcept(ctx context.Context, userID int64, tokenString string) error {
+	query := `
+		DELETE FROM orders_service.refresh_tokens WHERE user_id = $1 AND token <> $2
+	`
+	if _, err := s.db.Exec(ctx, query, userID, tokenString); err != nil {
+		return fmt.Errorf("could not delete other refresh tokens of user: %w", err)
+	}
+	return nil
+}
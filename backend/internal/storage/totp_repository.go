@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+var ErrTOTPAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+
+type TOTPCredential struct {
+	UserID          int64
+	SecretEncrypted []byte
+	LastUsedStep    int64
+	ConfirmedAt     *time.Time
+	CreatedAt       time.Time
+}
+
+func (t *TOTPCredential) IsEnabled() bool {
+	return t != nil && t.ConfirmedAt != nil
+}
+
+type TOTPRepository struct {
+	db *TxManager
+}
+
+// SavePending stores a new, not yet confirmed secret, replacing an earlier
+// unconfirmed one. It returns ErrTOTPAlreadyEnabled for confirmed credentials.
+func (r *TOTPRepository) SavePending(ctx context.Context, userID int64, secretEncrypted []byte) error {
+	query := `
+		INSERT INTO orders_service.totp_credentials (user_id, secret_encrypted)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+			SET secret_encrypted = EXCLUDED.secret_encrypted, last_used_step = 0, created_at = NOW()
+			WHERE totp_credentials.confirmed_at IS NULL
+		RETURNING user_id
+	`
+	var id int64
+	if err := r.db.QueryRow(ctx, query, userID, secretEncrypted).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrTOTPAlreadyEnabled
+		}
+		return fmt.Errorf("could not save TOTP secret: %w", err)
+	}
+	return nil
+}
+
+// Get returns nil without an error when the user has never enrolled.
+func (r *TOTPRepository) Get(ctx context.Context, userID int64) (*TOTPCredential, error) {
+	query := `
+		SELECT user_id, secret_encrypted, last_used_step, confirmed_at, created_at
+		FROM orders_service.totp_credentials WHERE user_id = $1
+	`
+	var cred TOTPCredential
+	if err := r.db.QueryRow(ctx, query, userID).Scan(
+		&cred.UserID, &cred.SecretEncrypted, &cred.LastUsedStep, &cred.ConfirmedAt, &cred.CreatedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not get TOTP credential: %w", err)
+	}
+	return &cred, nil
+}
+
+// Confirm enables two-factor authentication and replaces the recovery codes.
+func (r *TOTPRepository) Confirm(ctx context.Context, userID int64, recoveryCodeHashes [][]byte) error {
+	return r.db.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := r.db.Exec(ctx, `UPDATE orders_service.totp_credentials SET confirmed_at = NOW() WHERE user_id = $1`, userID); err != nil {
+			return fmt.Errorf("could not confirm TOTP: %w", err)
+		}
+		if _, err := r.db.Exec(ctx, `DELETE FROM orders_service.totp_recovery_codes WHERE user_id = $1`, userID); err != nil {
+			return fmt.Errorf("could not delete old recovery codes: %w", err)
+		}
+		for _, hash := range recoveryCodeHashes {
+			if _, err := r.db.Exec(ctx, `INSERT INTO orders_service.totp_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+				return fmt.Errorf("could not save recovery code: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// MarkStepUsed records the time step of an accepted code. It reports false
+// when the step is not newer than the last used one, i.e. the code is replayed.
+func (r *TOTPRepository) MarkStepUsed(ctx context.Context, userID int64, step int64) (bool, error) {
+	query := `
+		UPDATE orders_service.totp_credentials SET last_used_step = $2
+		WHERE user_id = $1 AND last_used_step < $2
+	`
+	tag, err := r.db.Exec(ctx, query, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("could not mark TOTP step as used: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// UseRecoveryCode reports whether an unused code with the hash existed; the code is used up.
+func (r *TOTPRepository) UseRecoveryCode(ctx context.Context, userID int64, codeHash []byte) (bool, error) {
+	query := `
+		UPDATE orders_service.totp_recovery_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+	tag, err := r.db.Exec(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("could not use recovery code: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *TOTPRepository) Delete(ctx context.Context, userID int64) error {
+	// recovery codes have no meaning without the credential
+	query := `
+		WITH deleted AS (DELETE FROM orders_service.totp_recovery_codes WHERE user_id = $1)
+		DELETE FROM orders_service.totp_credentials WHERE user_id = $1
+	`
+	if _, err := r.db.Exec(ctx, query, userID); err != nil {
+		return fmt.Errorf("could not delete TOTP credential: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Transactor runs fn in a single transaction. Repository calls made with the
+// context passed to fn take part in that transaction.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// querier is what pgxpool.Pool and pgx.Tx have in common.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+type txKey struct{}
+
+const (
+	defaultTxAttempts = 3
+	txRetryBackoff    = 20 * time.Millisecond
+)
+
+// TxManager starts transactions and carries them in the context. The
+// repositories query through it, so they run inside the transaction of the
+// context if there is one and on the pool otherwise.
+type TxManager struct {
+	pool        *pgxpool.Pool
+	maxAttempts int
+}
+
+func NewTxManager(pool *pgxpool.Pool) *TxManager {
+	return &TxManager{pool: pool, maxAttempts: defaultTxAttempts}
+}
+
+// WithinTx runs fn in a read-write transaction, see WithinTxOptions.
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return m.WithinTxOptions(ctx, pgx.TxOptions{}, fn)
+}
+
+// WithinTxOptions commits the transaction if fn returns nil and rolls it back
+// otherwise. Serialization failures and deadlocks run fn again in a new
+// transaction, so fn must not have side effects outside the database.
+//
+// If ctx already carries a transaction, fn joins it and opts are ignored;
+// retrying is then up to the outermost call.
+func (m *TxManager) WithinTxOptions(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := m.runTx(ctx, opts, fn)
+		if err == nil || !IsRetryable(err) || attempt >= m.maxAttempts {
+			return err
+		}
+
+		// jitter keeps the conflicting transactions from colliding again
+		backoff := txRetryBackoff*time.Duration(attempt) + rand.N(txRetryBackoff)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
+}
+
+func (m *TxManager) runTx(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context) error) (err error) {
+	tx, err := m.pool.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback(ctx)
+			panic(p)
+		} else if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	if err = fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (m *TxManager) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return m.pool
+}
+
+func (m *TxManager) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return m.conn(ctx).Exec(ctx, sql, args...)
+}
+
+func (m *TxManager) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return m.conn(ctx).Query(ctx, sql, args...)
+}
+
+func (m *TxManager) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return m.conn(ctx).QueryRow(ctx, sql, args...)
+}
+
+func (m *TxManager) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return m.conn(ctx).SendBatch(ctx, b)
+}
+
+func (m *TxManager) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	return m.conn(ctx).CopyFrom(ctx, tableName, columnNames, rowSrc)
+}
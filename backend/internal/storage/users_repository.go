@@ -2,19 +2,28 @@ package storage
 
 import (
 	"context"
-	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	RoleCustomer = "customer"
+	RoleSupport  = "support"
+	RoleAdmin    = "admin"
+)
+
 type User struct {
-	ID           int64     `json:"id"`
-	Name         string    `json:"name"`
-	Password     string    `json:""`
-	Email        string    `json:"email"`
-	CreatedAt    time.Time `json:"created_at"`
-	passwordHash []byte
+	ID              int64      `json:"id"`
+	Name            string     `json:"name"`
+	Password        string     `json:""`
+	Email           string     `json:"email"`
+	Role            string     `json:"role"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	passwordHash    []byte
 }
 
 func (u *User) HashPassword() error {
@@ -28,13 +37,27 @@ func (u *User) HashPassword() error {
 	return nil
 }
 
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// HasPassword is false for staff that only sign in through single sign-on.
+func (u *User) HasPassword() bool {
+	return len(u.passwordHash) > 0
+}
+
 func (u *User) CheckPasswordHash(password string) bool {
 	err := bcrypt.CompareHashAndPassword(u.passwordHash, []byte(password))
 	return err == nil
 }
 
 type UsersRepository struct {
-	db *sql.DB
+	db *TxManager
+}
+
+// NormalizeEmail is applied to every email before it is stored or looked up.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
 
 func (s *UsersRepository) Create(ctx context.Context, user *User) error {
@@ -45,11 +68,14 @@ func (s *UsersRepository) Create(ctx context.Context, user *User) error {
 
 	query := `
 		INSERT INTO orders_service.users (name, password, email)
-		VALUES ($1, $2, $3) RETURNING id, created_at
+		VALUES ($1, $2, $3) RETURNING id, role, created_at
 	`
 
-	err := s.db.QueryRowContext(ctx, query, user.Name, user.passwordHash, user.Email).Scan(&user.ID, &user.CreatedAt)
+	err := s.db.QueryRow(ctx, query, user.Name, user.passwordHash, user.Email).Scan(&user.ID, &user.Role, &user.CreatedAt)
 	if err != nil {
+		if IsUniqueViolation(err) {
+			return ErrEmailTaken
+		}
 		return err
 	}
 	return nil
@@ -57,14 +83,83 @@ func (s *UsersRepository) Create(ctx context.Context, user *User) error {
 
 func (s *UsersRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
-		SELECT * FROM orders_service.users
-		WHERE email = $1
+		SELECT id, name, password, email, role, email_verified_at, created_at FROM orders_service.users
+		WHERE lower(email) = lower($1)
+	`
+	return s.getOne(ctx, query, email)
+}
+
+func (s *UsersRepository) GetByID(ctx context.Context, id int64) (*User, error) {
+	query := `
+		SELECT id, name, password, email, role, email_verified_at, created_at FROM orders_service.users
+		WHERE id = $1
 	`
+	return s.getOne(ctx, query, id)
+}
+
+func (s *UsersRepository) getOne(ctx context.Context, query string, arg any) (*User, error) {
+	return scanUser(s.db.QueryRow(ctx, query, arg))
+}
+
+// scanUser expects the columns id, name, password, email, role, email_verified_at, created_at.
+func scanUser(row rowScanner) (*User, error) {
 	user := &User{}
-	var passwordHash []byte
-	if err := s.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Name, &passwordHash, &user.Email, &user.CreatedAt); err != nil {
+	if err := row.Scan(&user.ID, &user.Name, &user.passwordHash, &user.Email, &user.Role, &user.EmailVerifiedAt, &user.CreatedAt); err != nil {
 		return nil, err
 	}
-	user.passwordHash = passwordHash
 	return user, nil
 }
+
+func (s *UsersRepository) MarkEmailVerified(ctx context.Context, id int64) error {
+	query := `
+		UPDATE orders_service.users SET email_verified_at = NOW()
+		WHERE id = $1 AND email_verified_at IS NULL
+	`
+	if _, err := s.db.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("could not mark email as verified: %w", err)
+	}
+	return nil
+}
+
+// UpdatePassword hashes user.Password and stores the new hash.
+func (s *UsersRepository) UpdatePassword(ctx context.Context, user *User) error {
+	if err := user.HashPassword(); err != nil {
+		return err
+	}
+
+	query := `UPDATE orders_service.users SET password = $1 WHERE id = $2`
+	if _, err := s.db.Exec(ctx, query, user.passwordHash, user.ID); err != nil {
+		return fmt.Errorf("could not update password: %w", err)
+	}
+	return nil
+}
+
+// UpdateProfile stores the name and email of the user. Changing the email
+// resets its verification.
+func (s *UsersRepository) UpdateProfile(ctx context.Context, user *User) error {
+	query := `
+		UPDATE orders_service.users
+		SET name              = $1,
+		    email             = $2,
+		    email_verified_at = CASE WHEN lower(email) = lower($2) THEN email_verified_at END
+		WHERE id = $3
+		RETURNING email_verified_at
+	`
+	if err := s.db.QueryRow(ctx, query, user.Name, user.Email, user.ID).Scan(&user.EmailVerifiedAt); err != nil {
+		if IsUniqueViolation(err) {
+			return ErrEmailTaken
+		}
+		return fmt.Errorf("could not update profile: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the user together with its sessions, API keys and action tokens.
+// Orders are not linked by a foreign key and have to be anonymized separately.
+func (s *UsersRepository) Delete(ctx context.Context, id int64) error {
+	query := `DELETE FROM orders_service.users WHERE id = $1`
+	if _, err := s.db.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("could not delete user: %w", err)
+	}
+	return nil
+}
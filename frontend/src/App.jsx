@@ -2,6 +2,9 @@ import {BrowserRouter as Router, Route, Routes} from "react-router-dom";
 import {Register} from "./pages/register";
 import {Login} from "./pages/login";
 import {Order} from "./pages/order";
+import {VerifyEmail} from "./pages/verify-email";
+import {ForgotPassword} from "./pages/forgot-password";
+import {ResetPassword} from "./pages/reset-password";
 
 function App() {
     return (
@@ -10,6 +13,9 @@ function App() {
                 <Route path="/" element={<Register/>}/>
                 <Route path="/login" element={<Login/>}/>
                 <Route path="/order" element={<Order/>}/>
+                <Route path="/verify-email" element={<VerifyEmail/>}/>
+                <Route path="/forgot-password" element={<ForgotPassword/>}/>
+                <Route path="/reset-password" element={<ResetPassword/>}/>
             </Routes>
         </Router>
     );
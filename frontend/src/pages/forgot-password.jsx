@@ -0,0 +1,63 @@
+import {useState} from "react";
+import {Link} from "react-router-dom";
+
+export function ForgotPassword() {
+    const [email, setEmail] = useState("");
+    const [message, setMessage] = useState(null);
+    const [error, setError] = useState(null);
+    const [loading, setLoading] = useState(false);
+
+    const handleSubmit = async (e) => {
+        e.preventDefault();
+        setLoading(true);
+        setError(null);
+        setMessage(null);
+
+        try {
+            const response = await fetch("http://localhost:8080/auth/forgot-password", {
+                method: "POST",
+                headers: {
+                    "Content-Type": "application/json",
+                },
+                body: JSON.stringify({email}),
+                credentials: "include",
+            });
+
+            const data = await response.json();
+
+            if (!response.ok) {
+                throw new Error(data.error || "Failed to request password reset");
+            }
+
+            setMessage("Если аккаунт существует, мы отправили ссылку для сброса пароля.");
+        } catch (err) {
+            setError(err.message);
+        } finally {
+            setLoading(false);
+        }
+    };
+
+    return (
+        <div className="authorization-page">
+            <h1>Восстановление пароля</h1>
+            <form id="forgot-password-form" onSubmit={handleSubmit}>
+                <input
+                    type="email"
+                    id="email"
+                    placeholder="Email"
+                    value={email}
+                    onChange={(e) => setEmail(e.target.value)}
+                    required
+                />
+                <button type="submit" disabled={loading}>
+                    {loading ? "Отправка..." : "Отправить ссылку"}
+                </button>
+                <Link to="/login" style={{marginLeft: "10px"}}>
+                    Вернуться ко входу
+                </Link>
+                {message && <p>{message}</p>}
+                {error && <p style={{color: "red"}}>{error}</p>}
+            </form>
+        </div>
+    );
+}
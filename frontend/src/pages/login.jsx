@@ -1,13 +1,26 @@
-import {useState} from "react";
-import {Link, useNavigate} from "react-router-dom";
+import {useEffect, useState} from "react";
+import {Link, useLocation, useNavigate} from "react-router-dom";
 
 export function Login() {
     const [email, setEmail] = useState("");
     const [password, setPassword] = useState("");
+    const location = useLocation();
+    // single sign-on of an account with two-factor authentication redirects
+    // here with the challenge in the fragment
+    const [challengeToken, setChallengeToken] = useState(
+        () => new URLSearchParams(location.hash.slice(1)).get("challenge_token")
+    );
+    const [code, setCode] = useState("");
     const [error, setError] = useState(null);
     const [loading, setLoading] = useState(false);
     const navigate = useNavigate();
 
+    useEffect(() => {
+        if (location.hash) {
+            navigate(location.pathname, {replace: true});
+        }
+    }, [location, navigate]);
+
     const handleLogin = async (e) => {
         e.preventDefault();
         setLoading(true);
@@ -29,6 +42,11 @@ export function Login() {
                 throw new Error(data.error || "Failed to login");
             }
 
+            if (data.mfa_required) {
+                setChallengeToken(data.challenge_token);
+                return;
+            }
+
             navigate("/order");
         } catch (err) {
             setError(err.message);
@@ -37,6 +55,67 @@ export function Login() {
         }
     };
 
+    const handleCode = async (e) => {
+        e.preventDefault();
+        setLoading(true);
+        setError(null);
+
+        // recovery codes look like "abcd-efgh", authenticator codes are 6 digits
+        const isRecoveryCode = code.includes("-");
+        try {
+            const response = await fetch("http://localhost:8080/auth/login/2fa", {
+                method: "POST",
+                headers: {
+                    "Content-Type": "application/json",
+                },
+                body: JSON.stringify(
+                    isRecoveryCode
+                        ? {challenge_token: challengeToken, recovery_code: code}
+                        : {challenge_token: challengeToken, code}
+                ),
+                credentials: "include",
+            });
+
+            const data = await response.json();
+
+            if (!response.ok) {
+                // a challenge is good for one attempt only, the login starts over
+                setChallengeToken(null);
+                setCode("");
+                throw new Error(data.error || "Failed to login");
+            }
+
+            navigate("/order");
+        } catch (err) {
+            setError(err.message);
+        } finally {
+            setLoading(false);
+        }
+    };
+
+    if (challengeToken) {
+        return (
+            <div className="authorization-page">
+                <h1>Двухфакторная аутентификация</h1>
+                <form id="totp-form" onSubmit={handleCode}>
+                    <input
+                        type="text"
+                        id="code"
+                        placeholder="Код из приложения или код восстановления"
+                        autoComplete="one-time-code"
+                        value={code}
+                        onChange={(e) => setCode(e.target.value)}
+                        required
+                    />
+                    <button type="submit" disabled={loading}>
+                        {loading ? "Проверка..." : "Подтвердить"}
+                    </button>
+                    {error && <p style={{color: "red"}}>{error}</p>}
+                </form>
+            </div>
+        );
+    }
+
     return (
         <div className="authorization-page">
             <h1>Вход в систему</h1>
@@ -63,6 +142,12 @@ export function Login() {
                 <Link to="/" style={{marginLeft: "10px"}}>
                     Нет аккаунта? Зарегистрироваться
                 </Link>
+                <Link to="/forgot-password" style={{marginLeft: "10px"}}>
+                    Забыли пароль?
+                </Link>
+                <a href="http://localhost:8080/auth/sso/login" style={{marginLeft: "10px"}}>
+                    Вход для сотрудников
+                </a>
                 {error && <p style={{color: "red"}}>{error}</p>}
             </form>
         </div>
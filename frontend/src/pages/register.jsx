@@ -22,7 +22,7 @@ export function Register() {
                     headers: {
                         "Content-Type": "application/json",
                     },
-                    body: JSON.stringify({username, email, password}),
+                    body: JSON.stringify({name: username, email, password}),
                     credentials: "include",
                 }
             );
@@ -0,0 +1,59 @@
+import {useState} from "react";
+import {useNavigate, useSearchParams} from "react-router-dom";
+
+export function ResetPassword() {
+    const [searchParams] = useSearchParams();
+    const [password, setPassword] = useState("");
+    const [error, setError] = useState(null);
+    const [loading, setLoading] = useState(false);
+    const navigate = useNavigate();
+
+    const handleSubmit = async (e) => {
+        e.preventDefault();
+        setLoading(true);
+        setError(null);
+
+        try {
+            const response = await fetch("http://localhost:8080/auth/reset-password", {
+                method: "POST",
+                headers: {
+                    "Content-Type": "application/json",
+                },
+                body: JSON.stringify({token: searchParams.get("token"), password}),
+                credentials: "include",
+            });
+
+            const data = await response.json();
+
+            if (!response.ok) {
+                throw new Error(data.error || "Failed to reset password");
+            }
+
+            navigate("/login");
+        } catch (err) {
+            setError(err.message);
+        } finally {
+            setLoading(false);
+        }
+    };
+
+    return (
+        <div className="authorization-page">
+            <h1>Новый пароль</h1>
+            <form id="reset-password-form" onSubmit={handleSubmit}>
+                <input
+                    type="password"
+                    id="password"
+                    placeholder="Новый пароль"
+                    value={password}
+                    onChange={(e) => setPassword(e.target.value)}
+                    required
+                />
+                <button type="submit" disabled={loading}>
+                    {loading ? "Сохранение..." : "Сохранить"}
+                </button>
+                {error && <p style={{color: "red"}}>{error}</p>}
+            </form>
+        </div>
+    );
+}
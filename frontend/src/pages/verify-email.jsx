@@ -0,0 +1,45 @@
+import {useEffect, useState} from "react";
+import {Link, useSearchParams} from "react-router-dom";
+
+export function VerifyEmail() {
+    const [searchParams] = useSearchParams();
+    const [status, setStatus] = useState("loading");
+    const [error, setError] = useState(null);
+
+    useEffect(() => {
+        const verify = async () => {
+            try {
+                const response = await fetch("http://localhost:8080/auth/verify-email", {
+                    method: "POST",
+                    headers: {
+                        "Content-Type": "application/json",
+                    },
+                    body: JSON.stringify({token: searchParams.get("token")}),
+                    credentials: "include",
+                });
+
+                const data = await response.json();
+
+                if (!response.ok) {
+                    throw new Error(data.error || "Failed to verify email");
+                }
+
+                setStatus("done");
+            } catch (err) {
+                setError(err.message);
+                setStatus("error");
+            }
+        };
+
+        verify();
+    }, [searchParams]);
+
+    return (
+        <div className="authorization-page">
+            <h1>Подтверждение почты</h1>
+            {status === "loading" && <p>Проверка...</p>}
+            {status === "done" && <p>Почта подтверждена. <Link to="/login">Войти</Link></p>}
+            {status === "error" && <p style={{color: "red"}}>{error}</p>}
+        </div>
+    );
+}